@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// newBatchResult - Returns an empty result for a batch, treating an unset mode
+// as partial
+func newBatchResult(mode model.BatchMode) model.BatchResult {
+	if mode == "" {
+		mode = model.BatchPartial
+	}
+	return model.BatchResult{Mode: mode}
+}
+
+// writeBatch - Writes each row of a batch inside its own savepoint of tx, so a
+// failed row leaves nothing behind and doesn't abort the transaction for the
+// rows after it. Partial batches are committed with whatever succeeded; an
+// atomic batch with a failed row is left for the caller to roll back. When the
+// transaction itself fails, no row is reported as written.
+func writeBatch(tx *sqlx.Tx, mode model.BatchMode, ids []string, write func(i int) error) (model.BatchResult, error) {
+	result := newBatchResult(mode)
+	for i, id := range ids {
+		if _, err := tx.Exec(`SAVEPOINT batch_row`); err != nil {
+			return abortBatch(result, ids, fmt.Errorf("failed to create savepoint: %w", err))
+		}
+
+		if err := write(i); err != nil {
+			log.Printf("Failed to write %s in batch: %v", id, err)
+			result.Fail(i, id, err)
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT batch_row`); err != nil {
+				return abortBatch(result, ids, fmt.Errorf("failed to roll back %s: %w", id, err))
+			}
+		} else {
+			result.Succeeded = append(result.Succeeded, id)
+		}
+
+		if _, err := tx.Exec(`RELEASE SAVEPOINT batch_row`); err != nil {
+			return abortBatch(result, ids, fmt.Errorf("failed to release savepoint: %w", err))
+		}
+	}
+
+	if result.Mode == model.BatchAtomic && !result.OK() {
+		result.RollBack(ids)
+		return result, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return abortBatch(result, ids, fmt.Errorf("failed to commit batch transaction: %w", err))
+	}
+	return result, nil
+}
+
+// abortBatch - Marks every row of a batch whose transaction failed as not
+// written, keeping the failures already recorded
+func abortBatch(result model.BatchResult, ids []string, err error) (model.BatchResult, error) {
+	result.RollBack(ids)
+	for i := range result.Failed {
+		if result.Failed[i].Reason == model.FailureRolledBack {
+			result.Failed[i].Error = "rolled back because the batch transaction failed: " + err.Error()
+		}
+	}
+	return result, err
+}
+
+// logBatch - Logs the outcome of a batch write
+func logBatch(resource string, total int, result model.BatchResult) {
+	log.Printf("Successfully added/updated %d out of %d %s in %s batch", len(result.Succeeded), total, resource, result.Mode)
+	if !result.OK() {
+		failed := make([]string, len(result.Failed))
+		for i, failure := range result.Failed {
+			failed[i] = failure.ID
+		}
+		log.Printf("Failed to add %s: %v", resource, failed)
+	}
+}
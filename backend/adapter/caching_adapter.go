@@ -0,0 +1,568 @@
+package adapter
+
+import (
+	"container/list"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// CacheOptions configures a CachingAdapter. Zero fields get the defaults
+// listed below.
+type CacheOptions struct {
+	TTL        time.Duration // how long a read is served from memory (default 30s)
+	MaxRecords int           // items and orders held across all entries before the least recently used are evicted (default 100000)
+}
+
+// withDefaults fills in the zero fields of the options
+func (o CacheOptions) withDefaults() CacheOptions {
+	if o.TTL <= 0 {
+		o.TTL = 30 * time.Second
+	}
+	if o.MaxRecords <= 0 {
+		o.MaxRecords = 100000
+	}
+	return o
+}
+
+// CacheStats reports how well a CachingAdapter is doing. A read that waited
+// for the same read already in flight counts as a hit.
+type CacheStats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Evictions     int64   `json:"evictions"`
+	Invalidations int64   `json:"invalidations"`
+	Entries       int     `json:"entries"`
+	Records       int     `json:"records"`
+	MaxRecords    int     `json:"max_records"`
+	TTLSeconds    float64 `json:"ttl_seconds"`
+}
+
+// CachingAdapter is a read-through cache in front of another adapter. It
+// caches the inventory, single items and orders, order ranges and, when the
+// wrapped adapter aggregates, sales aggregates. Reads and writes go through
+// Adapter, which drops exactly the entries a write through it can change:
+//   - item writes drop the inventory, the cached items and the aggregates
+//     (they carry item names and prices)
+//   - order writes also move stock, so they drop the same, plus the cached
+//     order and every range or aggregate that held it or would hold it now
+//
+// An order's previous completion time is only known if a cached entry holds
+// it, so replacing an order that was never read through the cache can leave
+// its old range stale until the TTL expires, as can writes made to the
+// database by anything else. Search, the stock ledger and price history are
+// not cached; model.As finds them on the wrapped adapter.
+type CachingAdapter struct {
+	inner   model.POSReader
+	adapter model.POSReader
+	options CacheOptions
+	now     func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List // most recently used first
+	records  int
+	inflight map[string]*cacheCall
+	// generation changes on every write, so a read that started before the
+	// write doesn't store its result
+	generation uint64
+	stats      CacheStats
+}
+
+type cacheEntry struct {
+	key     string
+	value   interface{}
+	records int
+	expires time.Time
+	// items marks entries holding items, whose stock any write can change
+	items bool
+	// orders maps the orders the entry holds to their completion time;
+	// covers reports whether an order completed at a given time belongs in it
+	orders map[string]time.Time
+	covers func(completedAt time.Time) bool
+}
+
+type cacheCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// cachedReader is the adapter of a CachingAdapter whose wrapped adapter
+// neither aggregates nor writes
+type cachedReader struct {
+	*CachingAdapter
+}
+
+// Unwrap - Returns the cached adapter, for the capabilities the cache doesn't
+// implement itself
+func (r cachedReader) Unwrap() model.POSReader {
+	return r.inner
+}
+
+// cachedAggregator also caches the wrapped adapter's aggregation
+type cachedAggregator struct {
+	cachedReader
+}
+
+// cachedWriter also writes to the wrapped adapter and invalidates
+type cachedWriter struct {
+	cachedReader
+	writer model.POSAdapter
+}
+
+// cachedWriterAggregator caches aggregation and writes
+type cachedWriterAggregator struct {
+	cachedWriter
+}
+
+// NewCachingAdapter - Wraps inner in a read-through cache. Adapter returns
+// the cached adapter; the CachingAdapter itself only reports on the cache.
+func NewCachingAdapter(inner model.POSReader, options CacheOptions) *CachingAdapter {
+	c := &CachingAdapter{
+		inner:    inner,
+		options:  options.withDefaults(),
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]*cacheCall),
+	}
+
+	// Aggregation and writes are only offered when inner has them, so the
+	// cache doesn't change the adapter's capabilities
+	reader := cachedReader{CachingAdapter: c}
+	_, aggregates := model.As[model.SalesAggregator](inner)
+	writer, writes := inner.(model.POSAdapter)
+	switch {
+	case writes && aggregates:
+		c.adapter = cachedWriterAggregator{cachedWriter{cachedReader: reader, writer: writer}}
+	case writes:
+		c.adapter = cachedWriter{cachedReader: reader, writer: writer}
+	case aggregates:
+		c.adapter = cachedAggregator{cachedReader: reader}
+	default:
+		c.adapter = reader
+	}
+	return c
+}
+
+// Adapter - Returns the cached adapter, with the capabilities of the wrapped
+// one
+func (c *CachingAdapter) Adapter() model.POSReader {
+	return c.adapter
+}
+
+// Stats - Returns the hit, miss and eviction counters and the current size
+func (c *CachingAdapter) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	stats.Entries = c.lru.Len()
+	stats.Records = c.records
+	stats.MaxRecords = c.options.MaxRecords
+	stats.TTLSeconds = c.options.TTL.Seconds()
+	return stats
+}
+
+// Clear - Drops every entry
+func (c *CachingAdapter) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropWhere(func(*cacheEntry) bool { return true })
+}
+
+func (c *CachingAdapter) GetInventory() ([]model.Item, error) {
+	value, err := c.get("inventory", func() (*cacheEntry, error) {
+		items, err := c.inner.GetInventory()
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{value: items, records: len(items), items: true}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]model.Item{}, value.([]model.Item)...), nil
+}
+
+func (c *CachingAdapter) GetItemByID(itemID string) (*model.Item, error) {
+	value, err := c.get("item:"+itemID, func() (*cacheEntry, error) {
+		item, err := c.inner.GetItemByID(itemID)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{value: *item, records: 1, items: true}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	item := value.(model.Item)
+	return &item, nil
+}
+
+// CheckItemExists - Answers from the cached inventory when there is one
+func (c *CachingAdapter) CheckItemExists(itemID string) (bool, error) {
+	if items, ok := c.peek("inventory"); ok {
+		for _, item := range items.([]model.Item) {
+			if item.ID == itemID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return c.inner.CheckItemExists(itemID)
+}
+
+func (c *CachingAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error) {
+	key := "completed:" + timeKey(since)
+	value, err := c.get(key, func() (*cacheEntry, error) {
+		orders, err := c.inner.GetCompletedOrders(since)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{
+			value:   orders,
+			records: len(orders),
+			orders:  orderTimes(orders),
+			covers:  func(completedAt time.Time) bool { return !completedAt.Before(since) },
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return copyOrders(value.([]model.Order)), nil
+}
+
+// QueryOrders - Caches each page. A new order in the query's time range
+// drops every page of it, since it shifts the pages after it.
+func (c *CachingAdapter) QueryOrders(query model.OrderQuery) (model.OrderPage, error) {
+	value, err := c.get(orderQueryKey(query), func() (*cacheEntry, error) {
+		page, err := c.inner.QueryOrders(query)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{
+			value:   page,
+			records: len(page.Orders),
+			orders:  orderTimes(page.Orders),
+			covers:  inRange(query.Start, query.End),
+		}, nil
+	})
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	page := value.(model.OrderPage)
+	page.Orders = copyOrders(page.Orders)
+	return page, nil
+}
+
+func (c *CachingAdapter) GetOrderByID(orderID string) (*model.Order, error) {
+	value, err := c.get("order:"+orderID, func() (*cacheEntry, error) {
+		order, err := c.inner.GetOrderByID(orderID)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{value: *order, records: 1, orders: orderTimes([]model.Order{*order})}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	order := copyOrders([]model.Order{value.(model.Order)})[0]
+	return &order, nil
+}
+
+// CheckOrderExists - Answers from the cached order when there is one
+func (c *CachingAdapter) CheckOrderExists(orderID string) (bool, error) {
+	if _, ok := c.peek("order:" + orderID); ok {
+		return true, nil
+	}
+	return c.inner.CheckOrderExists(orderID)
+}
+
+// AggregateSales - Caches the wrapped adapter's aggregation
+func (a cachedAggregator) AggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	return a.aggregateSales(query)
+}
+
+func (w cachedWriterAggregator) AggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	return w.aggregateSales(query)
+}
+
+func (c *CachingAdapter) aggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	key := fmt.Sprintf("aggregate:%s|%s|%s|%s", timeKey(query.Start), timeKey(query.End), query.GroupBy, query.Zone())
+	value, err := c.get(key, func() (*cacheEntry, error) {
+		aggregator, _ := model.As[model.SalesAggregator](c.inner)
+		rows, err := aggregator.AggregateSales(query)
+		if err != nil {
+			return nil, err
+		}
+		return &cacheEntry{value: rows, records: len(rows), items: true, covers: inRange(query.Start, query.End)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]model.AggregateRow(nil), value.([]model.AggregateRow)...), nil
+}
+
+// Writes go to the wrapped adapter first and invalidate afterwards, even when
+// they fail: a batch may have been partly written.
+
+func (w cachedWriter) AddItem(item model.Item) error {
+	defer w.invalidateItems()
+	return w.writer.AddItem(item)
+}
+
+func (w cachedWriter) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
+	defer w.invalidateItems()
+	return w.writer.AddItems(items, mode)
+}
+
+func (w cachedWriter) UpdateItem(item model.Item) error {
+	defer w.invalidateItems()
+	return w.writer.UpdateItem(item)
+}
+
+func (w cachedWriter) DeleteItem(itemID string) error {
+	defer w.invalidateItems()
+	return w.writer.DeleteItem(itemID)
+}
+
+func (w cachedWriter) AddOrder(order model.Order) error {
+	defer w.invalidateOrders([]model.Order{order})
+	return w.writer.AddOrder(order)
+}
+
+func (w cachedWriter) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
+	defer w.invalidateOrders(orders)
+	return w.writer.AddOrders(orders, mode)
+}
+
+func (w cachedWriter) DeleteOrder(orderID string) error {
+	// Only the ranges holding the order can change
+	defer w.invalidateOrders([]model.Order{{ID: orderID}})
+	return w.writer.DeleteOrder(orderID)
+}
+
+// get - Returns the live entry for key, or loads and stores it. Concurrent
+// misses for the same key share one load.
+func (c *CachingAdapter) get(key string, load func() (*cacheEntry, error)) (interface{}, error) {
+	c.mu.Lock()
+	if element, exists := c.entries[key]; exists {
+		entry := element.Value.(*cacheEntry)
+		if c.now().Before(entry.expires) {
+			c.lru.MoveToFront(element)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return entry.value, nil
+		}
+		c.remove(element)
+	}
+	if call, exists := c.inflight[key]; exists {
+		c.stats.Hits++
+		c.mu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+
+	c.stats.Misses++
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	generation := c.generation
+	c.mu.Unlock()
+
+	entry, err := load()
+
+	c.mu.Lock()
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+	}
+	if err == nil {
+		call.value = entry.value
+		if generation == c.generation {
+			entry.key = key
+			c.store(entry)
+		}
+	}
+	call.err = err
+	c.mu.Unlock()
+
+	close(call.done)
+	return call.value, call.err
+}
+
+// peek - Returns the live entry for key without loading it
+func (c *CachingAdapter) peek(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.stats.Hits++
+	return entry.value, true
+}
+
+// store - Adds an entry and evicts the least recently used ones over the size
+// limit. An entry larger than the limit is not kept.
+func (c *CachingAdapter) store(entry *cacheEntry) {
+	records := entry.records
+	if records < 1 {
+		records = 1
+	}
+	if records > c.options.MaxRecords {
+		return
+	}
+	if element, exists := c.entries[entry.key]; exists {
+		c.remove(element)
+	}
+
+	entry.records = records
+	entry.expires = c.now().Add(c.options.TTL)
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.records += records
+
+	for c.records > c.options.MaxRecords {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *CachingAdapter) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.records -= entry.records
+}
+
+// dropWhere - Removes the matching entries and stops reads in flight from
+// storing what they loaded before the write
+func (c *CachingAdapter) dropWhere(match func(entry *cacheEntry) bool) {
+	c.generation++
+	c.inflight = make(map[string]*cacheCall)
+
+	for element := c.lru.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*cacheEntry)) {
+			c.remove(element)
+			c.stats.Invalidations++
+		}
+		element = next
+	}
+}
+
+func (c *CachingAdapter) invalidateItems() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.dropWhere(func(entry *cacheEntry) bool { return entry.items })
+}
+
+// invalidateOrders - Drops the items (orders move stock) and every entry that
+// holds one of the orders or whose range covers its new or previous
+// completion time
+func (c *CachingAdapter) invalidateOrders(orders []model.Order) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var times []time.Time
+	ids := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		ids[order.ID] = true
+		if !order.CompletedAt.IsZero() {
+			times = append(times, order.CompletedAt)
+		}
+	}
+	for element := c.lru.Front(); element != nil; element = element.Next() {
+		for id, completedAt := range element.Value.(*cacheEntry).orders {
+			if ids[id] {
+				times = append(times, completedAt)
+			}
+		}
+	}
+
+	c.dropWhere(func(entry *cacheEntry) bool {
+		if entry.items {
+			return true
+		}
+		for id := range entry.orders {
+			if ids[id] {
+				return true
+			}
+		}
+		if entry.covers != nil {
+			for _, completedAt := range times {
+				if entry.covers(completedAt) {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func orderTimes(orders []model.Order) map[string]time.Time {
+	times := make(map[string]time.Time, len(orders))
+	for _, order := range orders {
+		times[order.ID] = order.CompletedAt
+	}
+	return times
+}
+
+// inRange - Returns whether a time falls in [start, end), zero bounds being
+// open
+func inRange(start, end time.Time) func(time.Time) bool {
+	return func(at time.Time) bool {
+		return (start.IsZero() || !at.Before(start)) && (end.IsZero() || at.Before(end))
+	}
+}
+
+func timeKey(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// orderQueryKey - Identifies a query, with amounts in minor units
+func orderQueryKey(query model.OrderQuery) string {
+	money := func(m *model.Money) string {
+		if m == nil {
+			return ""
+		}
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+	return "query:" + strings.Join([]string{
+		timeKey(query.Start), timeKey(query.End), money(query.MinTotal), money(query.MaxTotal),
+		strconv.Quote(query.ItemID), string(query.SortBy), strconv.FormatBool(query.Descending), strconv.Itoa(query.Limit), query.Cursor,
+	}, "|")
+}
+
+// copyOrders - Copies orders and their lines so callers can't change the
+// cached ones
+func copyOrders(orders []model.Order) []model.Order {
+	if orders == nil {
+		return nil
+	}
+	copied := make([]model.Order, len(orders))
+	for i, order := range orders {
+		order.Items = append([]model.OrderItem(nil), order.Items...)
+		copied[i] = order
+	}
+	return copied
+}
@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+func TestCachingAdapterKeepsCapabilities(t *testing.T) {
+	memory := NewMemoryPosAdapter("IDR")
+
+	tests := []struct {
+		name  string
+		inner model.POSReader
+	}{
+		{name: "every capability", inner: memory},
+		{name: "read-only", inner: ReadOnly(memory)},
+		{name: "readers only", inner: struct{ model.POSReader }{memory}},
+		{name: "readers and writers", inner: struct{ model.POSAdapter }{memory}},
+	}
+
+	for _, tt := range tests {
+		want := model.CapabilitiesOf(tt.inner)
+		if got := model.CapabilitiesOf(NewCachingAdapter(tt.inner, CacheOptions{}).Adapter()); got != want {
+			t.Errorf("%s: CapabilitiesOf(cached) = %+v, want %+v", tt.name, got, want)
+		}
+	}
+}
+
+func TestCachingAdapterInvalidatesOnWrite(t *testing.T) {
+	memory := NewMemoryPosAdapter("IDR")
+	cache := NewCachingAdapter(memory, CacheOptions{})
+	cached := cache.Adapter()
+
+	if _, err := cached.GetInventory(); err != nil {
+		t.Fatalf("GetInventory: %v", err)
+	}
+
+	// A write beside the cache isn't seen until the entry expires
+	if err := memory.AddItem(testItem("ITEM-A", 10, 1000, "IDR")); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if items, _ := cached.GetInventory(); len(items) != 0 {
+		t.Errorf("inventory = %d items, want the cached empty one", len(items))
+	}
+
+	// A write through the cache drops it
+	writer, ok := model.As[model.InventoryWriter](cached)
+	if !ok {
+		t.Fatal("cached memory adapter can't write items")
+	}
+	if err := writer.AddItem(testItem("ITEM-B", 5, 2000, "IDR")); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if items, _ := cached.GetInventory(); len(items) != 2 {
+		t.Errorf("inventory = %d items, want ITEM-A and ITEM-B", len(items))
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Invalidations == 0 {
+		t.Errorf("stats = %+v, want 1 hit, 2 misses and an invalidation", stats)
+	}
+}
@@ -0,0 +1,33 @@
+package adapter
+
+import (
+	"fmt"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// LoadExchangeRates - Reads the exchange_rates table into a rate table
+func LoadExchangeRates(db *sqlx.DB) (*model.RateTable, error) {
+	query := `
+        SELECT from_currency, to_currency, CAST(rate AS TEXT) AS rate
+        FROM exchange_rates`
+
+	var rows []struct {
+		From string `db:"from_currency"`
+		To   string `db:"to_currency"`
+		Rate string `db:"rate"`
+	}
+	if err := db.Select(&rows, query); err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+
+	table := model.NewRateTable()
+	for _, row := range rows {
+		if err := table.Set(row.From, row.To, row.Rate); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
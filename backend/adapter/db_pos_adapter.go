@@ -1,8 +1,11 @@
 package adapter
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/YudaClairee/garudahacks/model"
@@ -10,15 +13,29 @@ import (
 )
 
 type DBPosAdapter struct {
-	db *sqlx.DB
+	db             *sqlx.DB
+	currency       string
+	oversellPolicy model.OversellPolicy
 }
 
-func NewDBPosAdapter(db *sqlx.DB) *DBPosAdapter {
+// NewDBPosAdapter - currency is the currency of amounts written without one
+func NewDBPosAdapter(db *sqlx.DB, currency string) *DBPosAdapter {
 	return &DBPosAdapter{
-		db: db,
+		db:             db,
+		currency:       normalizeCurrency(currency),
+		oversellPolicy: model.OversellReject,
 	}
 }
 
+// SetOversellPolicy - Sets the deployment-wide oversell policy used for items
+// that don't define their own
+func (d *DBPosAdapter) SetOversellPolicy(policy model.OversellPolicy) {
+	if policy == "" {
+		policy = model.OversellReject
+	}
+	d.oversellPolicy = policy
+}
+
 func (d *DBPosAdapter) AddOrder(order model.Order) error {
 	// Start a transaction
 	tx, err := d.db.Beginx()
@@ -27,37 +44,8 @@ func (d *DBPosAdapter) AddOrder(order model.Order) error {
 	}
 	defer tx.Rollback()
 
-	// Insert order
-	orderQuery := `
-        INSERT INTO orders (id, total, completed_at)
-        VALUES ($1, $2, $3)
-        ON CONFLICT (id) DO UPDATE SET
-            total = EXCLUDED.total,
-            completed_at = EXCLUDED.completed_at`
-
-	_, err = tx.Exec(orderQuery, order.ID, order.Total, order.CompletedAt)
-	if err != nil {
-		log.Printf("Failed to insert order %s: %v", order.ID, err)
-		return fmt.Errorf("failed to insert order %s: %w", order.ID, err)
-	}
-
-	// Delete existing order items (in case of update)
-	deleteItemsQuery := `DELETE FROM order_items WHERE order_id = $1`
-	_, err = tx.Exec(deleteItemsQuery, order.ID)
-	if err != nil {
-		log.Printf("Failed to delete existing order items for order %s: %v", order.ID, err)
-		return fmt.Errorf("failed to delete existing order items for order %s: %w", order.ID, err)
-	}
-
-	// Insert order items
-	itemQuery := `INSERT INTO order_items (order_id, item_id, quantity) VALUES ($1, $2, $3)`
-
-	for _, item := range order.Items {
-		_, err = tx.Exec(itemQuery, order.ID, item.ItemID, item.Quantity)
-		if err != nil {
-			log.Printf("Failed to insert order item %s for order %s: %v", item.ItemID, order.ID, err)
-			return fmt.Errorf("failed to insert order item %s for order %s: %w", item.ItemID, order.ID, err)
-		}
+	if err := d.writeOrder(tx, order); err != nil {
+		return err
 	}
 
 	// Commit transaction
@@ -65,121 +53,107 @@ func (d *DBPosAdapter) AddOrder(order model.Order) error {
 		return fmt.Errorf("failed to commit order transaction: %w", err)
 	}
 
-	log.Printf("Successfully added order: %s with %d items (Total: $%.2f)", order.ID, len(order.Items), order.Total)
+	log.Printf("Successfully added order: %s with %d items (Total: %s)", order.ID, len(order.Items), order.Total.Format())
 	return nil
 }
 
-func (d *DBPosAdapter) AddOrders(orders []model.Order) error {
+func (d *DBPosAdapter) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
 	if len(orders) == 0 {
-		return nil
+		return newBatchResult(mode), nil
+	}
+
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
 	}
 
 	// Start a transaction for batch insert
 	tx, err := d.db.Beginx()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return newBatchResult(mode), fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Prepare statements
-	orderQuery := `
-        INSERT INTO orders (id, total, completed_at)
-        VALUES ($1, $2, $3)
-        ON CONFLICT (id) DO UPDATE SET
-            total = EXCLUDED.total,
-            completed_at = EXCLUDED.completed_at`
+	result, err := writeBatch(tx, mode, ids, func(i int) error {
+		return d.writeOrder(tx, orders[i])
+	})
+	if err != nil {
+		return result, err
+	}
 
-	deleteItemsQuery := `DELETE FROM order_items WHERE order_id = $1`
-	itemQuery := `INSERT INTO order_items (order_id, item_id, quantity) VALUES ($1, $2, $3)`
+	logBatch("orders", len(orders), result)
+	return result, nil
+}
 
-	orderStmt, err := tx.Prepare(orderQuery)
+// writeOrder - Inserts or replaces an order inside tx, snapshotting prices and
+// recording stock movements
+func (d *DBPosAdapter) writeOrder(tx *sqlx.Tx, order model.Order) error {
+	// Take the new lines out of stock (and put any previous version back)
+	if err := lockOrder(tx, order.ID); err != nil {
+		return err
+	}
+	if err := snapshotPrices(tx, &order); err != nil {
+		return err
+	}
+	currency, err := orderCurrency(order, d.currency)
 	if err != nil {
-		return fmt.Errorf("failed to prepare order statement: %w", err)
+		return err
+	}
+	if err := d.applyOrderStock(tx, order); err != nil {
+		log.Printf("Failed to record stock movements for order %s: %v", order.ID, err)
+		return err
 	}
-	defer orderStmt.Close()
 
-	deleteStmt, err := tx.Prepare(deleteItemsQuery)
+	// Insert order
+	orderQuery := `
+        INSERT INTO orders (id, total, currency, completed_at)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (id) DO UPDATE SET
+            total = EXCLUDED.total,
+            currency = EXCLUDED.currency,
+            completed_at = EXCLUDED.completed_at`
+
+	_, err = tx.Exec(orderQuery, order.ID, order.Total, currency, order.CompletedAt)
 	if err != nil {
-		return fmt.Errorf("failed to prepare delete statement: %w", err)
+		log.Printf("Failed to insert order %s: %v", order.ID, err)
+		return fmt.Errorf("failed to insert order %s: %w", order.ID, err)
 	}
-	defer deleteStmt.Close()
 
-	itemStmt, err := tx.Prepare(itemQuery)
+	// Delete existing order items (in case of update)
+	deleteItemsQuery := `DELETE FROM order_items WHERE order_id = $1`
+	_, err = tx.Exec(deleteItemsQuery, order.ID)
 	if err != nil {
-		return fmt.Errorf("failed to prepare item statement: %w", err)
+		log.Printf("Failed to delete existing order items for order %s: %v", order.ID, err)
+		return fmt.Errorf("failed to delete existing order items for order %s: %w", order.ID, err)
 	}
-	defer itemStmt.Close()
-
-	successCount := 0
-	var failedOrders []string
 
-	for _, order := range orders {
-		// Insert order
-		_, err = orderStmt.Exec(order.ID, order.Total, order.CompletedAt)
-		if err != nil {
-			log.Printf("Failed to insert order %s in batch: %v", order.ID, err)
-			failedOrders = append(failedOrders, order.ID)
-			continue
-		}
+	// Insert order items
+	itemQuery := `
+        INSERT INTO order_items (order_id, item_id, quantity, unit_price, unit_cost, discount)
+        VALUES ($1, $2, $3, $4, $5, $6)`
 
-		// Delete existing order items
-		_, err = deleteStmt.Exec(order.ID)
+	for _, item := range order.Items {
+		_, err = tx.Exec(itemQuery, order.ID, item.ItemID, item.Quantity, item.UnitPrice, item.UnitCost, item.Discount)
 		if err != nil {
-			log.Printf("Failed to delete existing order items for order %s in batch: %v", order.ID, err)
-			failedOrders = append(failedOrders, order.ID)
-			continue
-		}
-
-		// Insert order items
-		orderSuccess := true
-		for _, item := range order.Items {
-			_, err = itemStmt.Exec(order.ID, item.ItemID, item.Quantity)
-			if err != nil {
-				log.Printf("Failed to insert order item %s for order %s in batch: %v", item.ItemID, order.ID, err)
-				orderSuccess = false
-				break
-			}
-		}
-
-		if orderSuccess {
-			successCount++
-		} else {
-			failedOrders = append(failedOrders, order.ID)
+			log.Printf("Failed to insert order item %s for order %s: %v", item.ItemID, order.ID, err)
+			return fmt.Errorf("failed to insert order item %s for order %s: %w", item.ItemID, order.ID, err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit batch order transaction: %w", err)
-	}
-
-	log.Printf("Successfully added %d out of %d orders in batch", successCount, len(orders))
-
-	if len(failedOrders) > 0 {
-		log.Printf("Failed to add orders: %v", failedOrders)
-	}
-
 	return nil
 }
 
 // GetOrderByID - Helper method to get a single order by ID
 func (d *DBPosAdapter) GetOrderByID(orderID string) (*model.Order, error) {
 	query := `
-        SELECT o.id as order_id, o.total, o.completed_at,
-               oi.item_id, oi.quantity
+        SELECT o.id as order_id, o.total, o.currency, o.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
         FROM orders o
         LEFT JOIN order_items oi ON o.id = oi.order_id
         WHERE o.id = $1
         ORDER BY oi.item_id`
 
-	type OrderWithItem struct {
-		OrderID     string    `db:"order_id"`
-		Total       float64   `db:"total"`
-		CompletedAt time.Time `db:"completed_at"`
-		ItemID      *string   `db:"item_id"`
-		Quantity    *int      `db:"quantity"`
-	}
-
-	var rows []OrderWithItem
+	var rows []orderItemRow
 	err := d.db.Select(&rows, query, orderID)
 	if err != nil {
 		log.Printf("Failed to query order %s: %v", orderID, err)
@@ -187,28 +161,13 @@ func (d *DBPosAdapter) GetOrderByID(orderID string) (*model.Order, error) {
 	}
 
 	if len(rows) == 0 {
-		return nil, fmt.Errorf("order with ID %s not found", orderID)
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
 	}
 
 	// Build order from rows
-	order := &model.Order{
-		ID:          rows[0].OrderID,
-		Total:       rows[0].Total,
-		CompletedAt: rows[0].CompletedAt,
-		Items:       []model.OrderItem{},
-	}
-
-	for _, row := range rows {
-		if row.ItemID != nil && row.Quantity != nil {
-			orderItem := model.OrderItem{
-				ItemID:   *row.ItemID,
-				Quantity: *row.Quantity,
-			}
-			order.Items = append(order.Items, orderItem)
-		}
-	}
+	orders := groupOrderRows(rows)
 
-	return order, nil
+	return &orders[0], nil
 }
 
 // CheckOrderExists - Helper method to check if an order exists
@@ -233,6 +192,14 @@ func (d *DBPosAdapter) DeleteOrder(orderID string) error {
 	}
 	defer tx.Rollback()
 
+	// Put the order's lines back into stock
+	if err := lockOrder(tx, orderID); err != nil {
+		return err
+	}
+	if err := d.reverseOrderStock(tx, orderID); err != nil {
+		return err
+	}
+
 	// Delete order items first
 	deleteItemsQuery := `DELETE FROM order_items WHERE order_id = $1`
 	_, err = tx.Exec(deleteItemsQuery, orderID)
@@ -253,7 +220,7 @@ func (d *DBPosAdapter) DeleteOrder(orderID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("order with ID %s not found", orderID)
+		return &model.NotFoundError{Resource: "order", ID: orderID}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -266,45 +233,222 @@ func (d *DBPosAdapter) DeleteOrder(orderID string) error {
 
 func (d *DBPosAdapter) GetInventory() ([]model.Item, error) {
 	query := `
-        SELECT id, name, stock, price, production_price AS productionprice
+        SELECT id, name, stock, price, production_price AS productionprice,
+               COALESCE(oversell_policy, '') AS oversellpolicy, currency
         FROM items 
         ORDER BY name`
 
-	var items []model.Item
-	err := d.db.Select(&items, query)
+	var rows []itemRow
+	err := d.db.Select(&rows, query)
 	if err != nil {
 		log.Printf("Failed to query inventory: %v", err)
 		return nil, fmt.Errorf("failed to query inventory: %w", err)
 	}
 
+	items := make([]model.Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.toItem())
+	}
+
+	return items, nil
+}
+
+// SearchItems - Finds items whose name or ID contains term, ignoring case
+func (d *DBPosAdapter) SearchItems(term string, limit int) ([]model.Item, error) {
+	query := `
+        SELECT id, name, stock, price, production_price AS productionprice,
+               COALESCE(oversell_policy, '') AS oversellpolicy, currency
+        FROM items
+        WHERE name ILIKE $1 ESCAPE '\' OR id ILIKE $1 ESCAPE '\'
+        ORDER BY name`
+	args := []interface{}{likePattern(term)}
+	if limit > 0 {
+		query += " LIMIT $2"
+		args = append(args, limit)
+	}
+
+	var rows []itemRow
+	if err := d.db.Select(&rows, query, args...); err != nil {
+		log.Printf("Failed to search items: %v", err)
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+
+	items := make([]model.Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.toItem())
+	}
+
 	return items, nil
 }
 
+// likePattern - Builds a LIKE pattern matching term anywhere, with LIKE's
+// wildcards in term escaped by a backslash
+func likePattern(term string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.TrimSpace(term))
+	return "%" + escaped + "%"
+}
+
 func (d *DBPosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error) {
 	query := `
-        SELECT o.id as order_id, o.total, o.completed_at,
-               oi.item_id, oi.quantity
+        SELECT o.id as order_id, o.total, o.currency, o.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
         FROM orders o
         LEFT JOIN order_items oi ON o.id = oi.order_id
         WHERE o.completed_at >= $1
         ORDER BY o.completed_at DESC, o.id, oi.item_id`
 
-	type OrderWithItem struct {
-		OrderID     string    `db:"order_id"`
-		Total       float64   `db:"total"`
-		CompletedAt time.Time `db:"completed_at"`
-		ItemID      *string   `db:"item_id"`
-		Quantity    *int      `db:"quantity"`
-	}
-
-	var rows []OrderWithItem
+	var rows []orderItemRow
 	err := d.db.Select(&rows, query, since)
 	if err != nil {
 		log.Printf("Failed to query completed orders: %v", err)
 		return nil, fmt.Errorf("failed to query completed orders: %w", err)
 	}
 
-	// Convert to Order structs
+	return groupOrderRows(rows), nil
+}
+
+// QueryOrders - Returns one keyset-paginated page of orders between two
+// timestamps in a single query. The page of order IDs is selected first so
+// that LIMIT applies to orders rather than to order lines.
+func (d *DBPosAdapter) QueryOrders(query model.OrderQuery) (model.OrderPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	cursor, err := model.DecodeOrderCursor(query)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if !query.Start.IsZero() {
+		addCondition("o.completed_at >= ?", query.Start)
+	}
+	if !query.End.IsZero() {
+		addCondition("o.completed_at < ?", query.End)
+	}
+	if query.MinTotal != nil {
+		addCondition("o.total >= ?", *query.MinTotal)
+		addCondition("o.currency = ?", currencyOr(*query.MinTotal, d.currency))
+	}
+	if query.MaxTotal != nil {
+		addCondition("o.total <= ?", *query.MaxTotal)
+		addCondition("o.currency = ?", currencyOr(*query.MaxTotal, d.currency))
+	}
+	if query.ItemID != "" {
+		addCondition("EXISTS (SELECT 1 FROM order_items f WHERE f.order_id = o.id AND f.item_id = ?)", query.ItemID)
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Sort keys in the order of model.OrderQuery; IDs and currency codes
+	// compare byte by byte like in the other adapters, whatever the
+	// database's collation
+	sortKeys := func(alias string) []string {
+		id := alias + `.id COLLATE "C"`
+		switch query.SortBy {
+		case model.SortByID:
+			return []string{id}
+		case model.SortByTotal:
+			return []string{alias + `.currency COLLATE "C"`, alias + ".total", id}
+		default:
+			return []string{alias + ".completed_at", id}
+		}
+	}
+
+	if cursor != nil {
+		var placeholders []string
+		addArg := func(arg interface{}, cast string) {
+			args = append(args, arg)
+			placeholders = append(placeholders, fmt.Sprintf("$%d%s", len(args), cast))
+		}
+		switch query.SortBy {
+		case model.SortByID:
+		case model.SortByTotal:
+			addArg(cursor.Total.Currency, "")
+			addArg(cursor.Total, "::numeric")
+		default:
+			addArg(cursor.CompletedAt, "")
+		}
+		addArg(cursor.ID, "")
+		conditions = append(conditions, fmt.Sprintf("(%s) %s (%s)",
+			strings.Join(sortKeys("o"), ", "), comparison, strings.Join(placeholders, ", ")))
+	}
+
+	orderBy := func(alias string) string {
+		keys := sortKeys(alias)
+		for i := range keys {
+			keys[i] += " " + direction
+		}
+		return strings.Join(keys, ", ")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := ""
+	if query.Limit > 0 {
+		// Fetch one extra order to know whether there is a next page
+		args = append(args, query.Limit+1)
+		limit = fmt.Sprintf("LIMIT $%d", len(args))
+	}
+
+	sqlQuery := `
+        WITH page AS (
+            SELECT o.id, o.total, o.currency, o.completed_at
+            FROM orders o
+            ` + where + `
+            ORDER BY ` + orderBy("o") + `
+            ` + limit + `
+        )
+        SELECT p.id AS order_id, p.total, p.currency, p.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
+        FROM page p
+        LEFT JOIN order_items oi ON oi.order_id = p.id
+        ORDER BY ` + orderBy("p") + `, oi.item_id`
+
+	var rows []orderItemRow
+	if err := d.db.Select(&rows, sqlQuery, args...); err != nil {
+		log.Printf("Failed to query orders: %v", err)
+		return model.OrderPage{}, fmt.Errorf("failed to query orders: %w", err)
+	}
+
+	page := model.OrderPage{Orders: groupOrderRows(rows)}
+	if query.Limit > 0 && len(page.Orders) > query.Limit {
+		page.Orders = page.Orders[:query.Limit]
+		page.NextCursor = model.NewOrderCursor(query, page.Orders[query.Limit-1]).Encode()
+	}
+
+	return page, nil
+}
+
+// orderItemRow is one row of an orders LEFT JOIN order_items query
+type orderItemRow struct {
+	OrderID     string       `db:"order_id"`
+	Total       model.Money  `db:"total"`
+	Currency    string       `db:"currency"`
+	CompletedAt time.Time    `db:"completed_at"`
+	ItemID      *string      `db:"item_id"`
+	Quantity    *int         `db:"quantity"`
+	UnitPrice   *model.Money `db:"unit_price"`
+	UnitCost    *model.Money `db:"unit_cost"`
+	Discount    *model.Money `db:"discount"`
+}
+
+// groupOrderRows folds joined rows back into orders, keeping the row order
+func groupOrderRows(rows []orderItemRow) []model.Order {
 	orderMap := make(map[string]*model.Order)
 	var orderIDs []string
 
@@ -313,7 +457,7 @@ func (d *DBPosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error
 		if _, exists := orderMap[row.OrderID]; !exists {
 			orderMap[row.OrderID] = &model.Order{
 				ID:          row.OrderID,
-				Total:       row.Total,
+				Total:       row.Total.WithCurrency(row.Currency),
 				CompletedAt: row.CompletedAt,
 				Items:       []model.OrderItem{},
 			}
@@ -326,104 +470,174 @@ func (d *DBPosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error
 				ItemID:   *row.ItemID,
 				Quantity: *row.Quantity,
 			}
+			if row.UnitPrice != nil {
+				orderItem.UnitPrice = row.UnitPrice.WithCurrency(row.Currency)
+			}
+			if row.UnitCost != nil {
+				orderItem.UnitCost = row.UnitCost.WithCurrency(row.Currency)
+			}
+			if row.Discount != nil {
+				orderItem.Discount = row.Discount.WithCurrency(row.Currency)
+			}
 			orderMap[row.OrderID].Items = append(orderMap[row.OrderID].Items, orderItem)
 		}
 	}
 
 	// Convert map to slice maintaining order
-	var orders []model.Order
+	orders := make([]model.Order, 0, len(orderIDs))
 	for _, orderID := range orderIDs {
 		orders = append(orders, *orderMap[orderID])
 	}
 
-	return orders, nil
+	return orders
 }
 
-func (d *DBPosAdapter) AddItem(item model.Item) error {
-	query := `
-        INSERT INTO items (id, name, stock, price, production_price)
-        VALUES ($1, $2, $3, $4, $5)
-        ON CONFLICT (id) DO UPDATE SET
-            name = EXCLUDED.name,
-            stock = EXCLUDED.stock,
-            price = EXCLUDED.price,
-            production_price = EXCLUDED.production_price`
+// itemRow is one row of the items table. Prices are stored as plain NUMERIC
+// columns next to the item's currency.
+type itemRow struct {
+	model.Item
+	Currency string `db:"currency"`
+}
 
-	_, err := d.db.Exec(query, item.ID, item.Name, item.Stock, item.Price, item.ProductionPrice)
+// toItem returns the item with its prices tagged with the stored currency
+func (r itemRow) toItem() model.Item {
+	item := r.Item
+	item.Price = item.Price.WithCurrency(r.Currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(r.Currency)
+	return item
+}
+
+// currencyOr - Returns the currency of an amount, or fallback when it has none
+func currencyOr(amount model.Money, fallback string) string {
+	if amount.Currency == "" {
+		return fallback
+	}
+	return amount.Currency
+}
+
+// itemCurrency - Returns the currency an item's prices are recorded in,
+// fallback when neither has one
+func itemCurrency(item model.Item, fallback string) (string, error) {
+	currency := currencyOr(item.Price, currencyOr(item.ProductionPrice, fallback))
+	if item.ProductionPrice.Currency != "" && item.ProductionPrice.Currency != currency {
+		return "", &model.ValidationError{Message: fmt.Sprintf("item %s has a price in %s but a production price in %s", item.ID, currency, item.ProductionPrice.Currency)}
+	}
+	return currency, nil
+}
+
+// orderCurrency - Returns the currency of an order, fallback when its total has
+// none, checking every line is in the same currency as the total
+func orderCurrency(order model.Order, fallback string) (string, error) {
+	currency := currencyOr(order.Total, fallback)
+	for _, line := range order.Items {
+		for _, amount := range []model.Money{line.UnitPrice, line.UnitCost, line.Discount} {
+			if !amount.IsZero() && amount.Currency != "" && amount.Currency != currency {
+				return "", &model.ValidationError{Message: fmt.Sprintf("order %s is in %s but item %s is priced in %s", order.ID, currency, line.ItemID, amount.Currency)}
+			}
+		}
+	}
+	return currency, nil
+}
+
+func (d *DBPosAdapter) AddItem(item model.Item) error {
+	tx, err := d.db.Beginx()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := d.upsertItem(tx, item); err != nil {
 		log.Printf("Failed to add item %s: %v", item.ID, err)
-		return fmt.Errorf("failed to add item %s: %w", item.ID, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Printf("Successfully added/updated item: %s - %s", item.ID, item.Name)
 	return nil
 }
 
-func (d *DBPosAdapter) AddItems(items []model.Item) error {
+func (d *DBPosAdapter) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
 	if len(items) == 0 {
-		return nil
+		return newBatchResult(mode), nil
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
 	}
 
 	// Start a transaction for batch insert
 	tx, err := d.db.Beginx()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return newBatchResult(mode), fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	query := `
-        INSERT INTO items (id, name, stock, price, production_price)
-        VALUES ($1, $2, $3, $4, $5)
-        ON CONFLICT (id) DO UPDATE SET
-            name = EXCLUDED.name,
-            stock = EXCLUDED.stock,
-            price = EXCLUDED.price,
-            production_price = EXCLUDED.production_price`
-
-	stmt, err := tx.Prepare(query)
+	result, err := writeBatch(tx, mode, ids, func(i int) error {
+		return d.upsertItem(tx, items[i])
+	})
 	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
+		return result, err
 	}
-	defer stmt.Close()
 
-	successCount := 0
-	for _, item := range items {
-		_, err := stmt.Exec(item.ID, item.Name, item.Stock, item.Price, item.ProductionPrice)
-		if err != nil {
-			log.Printf("Failed to add item %s in batch: %v", item.ID, err)
-			// Continue with other items instead of failing entirely
-			continue
-		}
-		successCount++
+	logBatch("items", len(items), result)
+	return result, nil
+}
+
+func (d *DBPosAdapter) UpdateItem(item model.Item) error {
+	tx, err := d.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	currency, err := itemCurrency(item, d.currency)
+	if err != nil {
+		return err
 	}
+	item.Price = item.Price.WithCurrency(currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(currency)
 
-	log.Printf("Successfully added/updated %d out of %d items in batch", successCount, len(items))
-	return nil
-}
+	var previousRow itemRow
+	query := `SELECT id, stock, price, production_price AS productionprice, currency FROM items WHERE id = $1 FOR UPDATE`
+	err = tx.Get(&previousRow, query, item.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.NotFoundError{Resource: "item", ID: item.ID}
+		}
+		return fmt.Errorf("failed to read item %s: %w", item.ID, err)
+	}
+	previous := previousRow.toItem()
 
-func (d *DBPosAdapter) UpdateItem(item model.Item) error {
-	query := `
+	query = `
         UPDATE items 
-        SET name = $2, stock = $3, price = $4, production_price = $5
+        SET name = $2, stock = $3, price = $4, production_price = $5, oversell_policy = NULLIF($6, ''), currency = $7
         WHERE id = $1`
 
-	result, err := d.db.Exec(query, item.ID, item.Name, item.Stock, item.Price, item.ProductionPrice)
+	_, err = tx.Exec(query, item.ID, item.Name, item.Stock, item.Price, item.ProductionPrice, item.OversellPolicy, currency)
 	if err != nil {
 		log.Printf("Failed to update item %s: %v", item.ID, err)
 		return fmt.Errorf("failed to update item %s: %w", item.ID, err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	// Record manual stock changes in the ledger
+	movement := model.StockMovement{ItemID: item.ID, Delta: item.Stock - previous.Stock, Reason: model.StockReasonAdjustment}
+	if err := insertStockMovement(tx, movement); err != nil {
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("item with ID %s not found", item.ID)
+	// Record price changes in the price history
+	if previous.Price != item.Price.WithCurrency(currency) || previous.ProductionPrice != item.ProductionPrice.WithCurrency(currency) {
+		if err := recordPriceChange(tx, item); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	log.Printf("Successfully updated item: %s - %s", item.ID, item.Name)
@@ -444,7 +658,7 @@ func (d *DBPosAdapter) DeleteItem(itemID string) error {
 	}
 
 	if orderCount > 0 {
-		return fmt.Errorf("cannot delete item %s: it is referenced in %d order(s)", itemID, orderCount)
+		return &model.ItemInUseError{ItemID: itemID, OrderCount: orderCount}
 	}
 
 	// Delete the item
@@ -462,7 +676,7 @@ func (d *DBPosAdapter) DeleteItem(itemID string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("item with ID %s not found", itemID)
+		return &model.NotFoundError{Resource: "item", ID: itemID}
 	}
 
 	log.Printf("Successfully deleted item: %s", itemID)
@@ -472,20 +686,22 @@ func (d *DBPosAdapter) DeleteItem(itemID string) error {
 // GetItemByID - Helper method to get a single item by ID
 func (d *DBPosAdapter) GetItemByID(itemID string) (*model.Item, error) {
 	query := `
-        SELECT id, name, stock, price, production_price AS productionprice
+        SELECT id, name, stock, price, production_price AS productionprice,
+               COALESCE(oversell_policy, '') AS oversellpolicy, currency
         FROM items 
         WHERE id = $1`
 
-	var item model.Item
-	err := d.db.Get(&item, query, itemID)
+	var row itemRow
+	err := d.db.Get(&row, query, itemID)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			return nil, fmt.Errorf("item with ID %s not found", itemID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.NotFoundError{Resource: "item", ID: itemID}
 		}
 		log.Printf("Failed to query item %s: %v", itemID, err)
 		return nil, fmt.Errorf("failed to query item %s: %w", itemID, err)
 	}
 
+	item := row.toItem()
 	return &item, nil
 }
 
@@ -501,3 +717,99 @@ func (d *DBPosAdapter) CheckItemExists(itemID string) (bool, error) {
 
 	return count > 0, nil
 }
+
+// AggregateSales - Computes revenue, order counts, units and cost with GROUP BY
+// so analytics endpoints don't have to load every order into memory
+func (d *DBPosAdapter) AggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	args := []interface{}{query.Start}
+	dateFilter := `o.completed_at >= $1`
+	if !query.End.IsZero() {
+		args = append(args, query.End)
+		dateFilter += ` AND o.completed_at < $2`
+	}
+
+	var sqlQuery string
+	if query.GroupBy == model.GroupByItem {
+		sqlQuery = `
+        SELECT oi.item_id AS key,
+               o.currency AS currency,
+               COALESCE(i.name, oi.item_id) AS item_name,
+               COALESCE(i.price, 0) AS price,
+               COALESCE(i.currency, o.currency) AS price_currency,
+               COUNT(DISTINCT o.id) AS orders,
+               COALESCE(SUM(oi.quantity), 0) AS units,
+               COALESCE(SUM(oi.quantity * oi.unit_price - oi.discount), 0) AS revenue,
+               COALESCE(SUM(oi.quantity * oi.unit_cost), 0) AS cost
+        FROM order_items oi
+        JOIN orders o ON o.id = oi.order_id
+        LEFT JOIN items i ON i.id = oi.item_id
+        WHERE ` + dateFilter + `
+        GROUP BY oi.item_id, o.currency, i.name, i.price, i.currency
+        ORDER BY oi.item_id, o.currency`
+	} else {
+		// Bucket on the wall clock of the business timezone
+		args = append(args, query.Zone().String())
+		completedAt := fmt.Sprintf("(o.completed_at::timestamptz AT TIME ZONE $%d)", len(args))
+		keyExpr, err := periodKeyExpr(query.GroupBy, completedAt)
+		if err != nil {
+			return nil, err
+		}
+		sqlQuery = `
+        SELECT ` + keyExpr + ` AS key,
+               o.currency AS currency,
+               COUNT(*) AS orders,
+               COALESCE(SUM(li.units), 0) AS units,
+               COALESCE(SUM(o.total), 0) AS revenue,
+               COALESCE(SUM(li.cost), 0) AS cost
+        FROM orders o
+        LEFT JOIN (
+            SELECT order_id,
+                   SUM(quantity) AS units,
+                   SUM(quantity * unit_cost) AS cost
+            FROM order_items
+            GROUP BY order_id
+        ) li ON li.order_id = o.id
+        WHERE ` + dateFilter + `
+        GROUP BY 1, 2
+        ORDER BY 1, 2`
+	}
+
+	var scanned []aggregateRow
+	if err := d.db.Select(&scanned, sqlQuery, args...); err != nil {
+		log.Printf("Failed to aggregate sales by %s: %v", query.GroupBy, err)
+		return nil, fmt.Errorf("failed to aggregate sales by %s: %w", query.GroupBy, err)
+	}
+
+	rows := make([]model.AggregateRow, 0, len(scanned))
+	for _, row := range scanned {
+		row.ApplyCurrency()
+		if row.PriceCurrency != "" {
+			row.Price = row.Price.WithCurrency(row.PriceCurrency)
+		}
+		rows = append(rows, row.AggregateRow)
+	}
+
+	return rows, nil
+}
+
+// aggregateRow is an AggregateRow plus the currency of the item's current
+// price, which can differ from the currency of the orders
+type aggregateRow struct {
+	model.AggregateRow
+	PriceCurrency string `db:"price_currency"`
+}
+
+// periodKeyExpr returns the SQL expression producing the same bucket keys as
+// model.PeriodKey for a local timestamp expression
+func periodKeyExpr(granularity model.Granularity, column string) (string, error) {
+	switch granularity {
+	case model.GroupByDay:
+		return `to_char(date_trunc('day', ` + column + `), 'YYYY-MM-DD')`, nil
+	case model.GroupByWeek:
+		return `to_char(date_trunc('week', ` + column + `), 'IYYY-"W"IW')`, nil
+	case model.GroupByMonth:
+		return `to_char(date_trunc('month', ` + column + `), 'YYYY-MM')`, nil
+	default:
+		return "", fmt.Errorf("unsupported aggregation granularity: %s", granularity)
+	}
+}
@@ -0,0 +1,160 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/migrations"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// testDatabaseDSN names the variable holding a Postgres DSN for the
+// DBPosAdapter tests, which are skipped without it. Every test migrates a
+// schema of its own and drops it afterwards.
+const testDatabaseDSN = "TEST_DATABASE_DSN"
+
+func newTestDBAdapter(t *testing.T) *DBPosAdapter {
+	t.Helper()
+	dsn := os.Getenv(testDatabaseDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDatabaseDSN)
+	}
+
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connecting to Postgres: %v", err)
+	}
+	schema := fmt.Sprintf("adapter_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatalf("creating schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	db, err := sqlx.Connect("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatalf("connecting to schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating schema %s: %v", schema, err)
+	}
+
+	return NewDBPosAdapter(db, "IDR")
+}
+
+// withSearchPath - Adds a search_path run-time parameter to a URL or
+// key=value DSN, so every pooled connection uses the schema
+func withSearchPath(dsn, schema string) string {
+	if parsed, err := url.Parse(dsn); err == nil && strings.Contains(dsn, "://") {
+		query := parsed.Query()
+		query.Set("search_path", schema)
+		parsed.RawQuery = query.Encode()
+		return parsed.String()
+	}
+	return dsn + " search_path=" + schema
+}
+
+func newDBTestAdapter(t *testing.T) testAdapter {
+	return newTestDBAdapter(t)
+}
+
+func TestDBAddOrdersBatch(t *testing.T) {
+	testAddOrdersBatch(t, newDBTestAdapter)
+}
+
+func TestDBAddItemsBatch(t *testing.T) {
+	testAddItemsBatch(t, newDBTestAdapter)
+}
+
+func TestDBOversellPolicy(t *testing.T) {
+	testOversellPolicy(t, newDBTestAdapter)
+}
+
+func TestDBQueryOrdersPages(t *testing.T) {
+	testQueryOrdersPages(t, newDBTestAdapter)
+}
+
+// TestDBConcurrentWritesKeepLedger - Upserts the same items and records
+// orders for them from several goroutines at once. The stock must still equal
+// the sum of the ledger, a new item must get a single opening balance, and
+// orders locking the same items in different line orders must not deadlock.
+func TestDBConcurrentWritesKeepLedger(t *testing.T) {
+	adapter := newTestDBAdapter(t)
+	seedItems(t, adapter, testItem("ITEM-A", 1000, 10000, "IDR"), testItem("ITEM-B", 1000, 5000, "IDR"))
+
+	const writers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// A new item upserted concurrently, and existing items restocked
+			if err := adapter.AddItem(testItem("ITEM-NEW", 10+i, 2000, "IDR")); err != nil {
+				errs <- fmt.Errorf("AddItem(ITEM-NEW): %w", err)
+			}
+			if err := adapter.AddItem(testItem("ITEM-A", 1000+i, 10000, "IDR")); err != nil {
+				errs <- fmt.Errorf("AddItem(ITEM-A): %w", err)
+			}
+			// Lines in both orders, so the stock locks are taken in a
+			// consistent order whatever the line order
+			order := model.Order{
+				ID:          fmt.Sprintf("ORD-%d", i),
+				CompletedAt: testTime.Add(time.Duration(i) * time.Minute),
+				Items:       []model.OrderItem{{ItemID: "ITEM-A", Quantity: 1}, {ItemID: "ITEM-B", Quantity: 2}},
+			}
+			if i%2 == 1 {
+				order.Items[0], order.Items[1] = order.Items[1], order.Items[0]
+			}
+			if err := adapter.AddOrder(order); err != nil {
+				errs <- fmt.Errorf("AddOrder(%s): %w", order.ID, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, itemID := range []string{"ITEM-A", "ITEM-B", "ITEM-NEW"} {
+		item, err := adapter.GetItemByID(itemID)
+		if err != nil {
+			t.Fatalf("GetItemByID(%s): %v", itemID, err)
+		}
+		checkStock(t, adapter, itemID, item.Stock)
+	}
+	checkStock(t, adapter, "ITEM-B", 1000-2*writers)
+
+	movements, err := adapter.GetStockMovements("ITEM-NEW")
+	if err != nil {
+		t.Fatalf("GetStockMovements: %v", err)
+	}
+	openings := 0
+	for _, movement := range movements {
+		if movement.Reason == model.StockReasonOpeningBalance {
+			openings++
+		}
+	}
+	if openings != 1 {
+		t.Errorf("ITEM-NEW has %d opening balances, want 1", openings)
+	}
+}
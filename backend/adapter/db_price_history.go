@@ -0,0 +1,85 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// recordPriceChange - Appends the item's current price and production price
+// to its price history, effective now
+func recordPriceChange(tx *sqlx.Tx, item model.Item) error {
+	query := `
+        INSERT INTO item_price_history (item_id, price, production_price, currency, effective_from)
+        VALUES ($1, $2, $3, $4, NOW())`
+
+	if _, err := tx.Exec(query, item.ID, item.Price, item.ProductionPrice, item.Price.CurrencyCode()); err != nil {
+		log.Printf("Failed to record price change for item %s: %v", item.ID, err)
+		return fmt.Errorf("failed to record price change for item %s: %w", item.ID, err)
+	}
+
+	return nil
+}
+
+// GetItemPriceHistory - Returns the price timeline of an item, oldest first
+func (d *DBPosAdapter) GetItemPriceHistory(itemID string) ([]model.ItemPrice, error) {
+	query := `
+        SELECT id, item_id, price, production_price, currency, effective_from, recorded_at
+        FROM item_price_history
+        WHERE item_id = $1
+        ORDER BY effective_from, id`
+
+	var rows []itemPriceRow
+	if err := d.db.Select(&rows, query, itemID); err != nil {
+		log.Printf("Failed to query price history for item %s: %v", itemID, err)
+		return nil, fmt.Errorf("failed to query price history for item %s: %w", itemID, err)
+	}
+
+	prices := make([]model.ItemPrice, 0, len(rows))
+	for _, row := range rows {
+		prices = append(prices, row.toItemPrice())
+	}
+
+	return prices, nil
+}
+
+// GetItemPriceAsOf - Returns the price of an item that was valid at the given time
+func (d *DBPosAdapter) GetItemPriceAsOf(itemID string, at time.Time) (*model.ItemPrice, error) {
+	query := `
+        SELECT id, item_id, price, production_price, currency, effective_from, recorded_at
+        FROM item_price_history
+        WHERE item_id = $1 AND effective_from <= $2
+        ORDER BY effective_from DESC, id DESC
+        LIMIT 1`
+
+	var row itemPriceRow
+	err := d.db.Get(&row, query, itemID, at)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Printf("Failed to query price of item %s as of %s: %v", itemID, at.Format(time.RFC3339), err)
+		return nil, fmt.Errorf("failed to query price of item %s: %w", itemID, err)
+	}
+
+	price := row.toItemPrice()
+	return &price, nil
+}
+
+// itemPriceRow is one row of item_price_history
+type itemPriceRow struct {
+	model.ItemPrice
+	Currency string `db:"currency"`
+}
+
+func (r itemPriceRow) toItemPrice() model.ItemPrice {
+	price := r.ItemPrice
+	price.Price = price.Price.WithCurrency(r.Currency)
+	price.ProductionPrice = price.ProductionPrice.WithCurrency(r.Currency)
+	return price
+}
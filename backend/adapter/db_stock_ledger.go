@@ -0,0 +1,307 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// lockOrder - Serializes concurrent writes of the same order ID, including
+// orders that don't exist yet and therefore have no row to lock
+func lockOrder(tx *sqlx.Tx, orderID string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('order:' || $1))`, orderID); err != nil {
+		return fmt.Errorf("failed to lock order %s: %w", orderID, err)
+	}
+	return nil
+}
+
+// lockItem - Serializes concurrent upserts of the same item ID, including
+// items that don't exist yet and therefore have no row to lock
+func lockItem(tx *sqlx.Tx, itemID string) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('item:' || $1))`, itemID); err != nil {
+		return fmt.Errorf("failed to lock item %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// applyOrderStock - Reverses the lines of any previously stored version of the
+// order and records its new lines, enforcing the oversell policy. Must run
+// inside the order's transaction, after lockOrder and before the old
+// order_items are deleted. Nothing is written if the order is rejected.
+func (d *DBPosAdapter) applyOrderStock(tx *sqlx.Tx, order model.Order) error {
+	var previous []model.OrderItem
+	err := tx.Select(&previous, `SELECT item_id AS itemid, quantity FROM order_items WHERE order_id = $1`, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load previous lines of order %s: %w", order.ID, err)
+	}
+
+	// Net change per item: positive means the new version takes more stock
+	netDemand := netStockDemand(previous, order.Items)
+
+	oversold, err := d.checkStock(tx, order.ID, netDemand)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range previous {
+		movement := model.StockMovement{ItemID: line.ItemID, OrderID: &order.ID, Delta: line.Quantity, Reason: model.StockReasonOrderReversal}
+		if err := recordStockMovement(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range order.Items {
+		movement := model.StockMovement{ItemID: line.ItemID, OrderID: &order.ID, Delta: -line.Quantity, Reason: model.StockReasonSale, Oversold: oversold[line.ItemID]}
+		if err := recordStockMovement(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkStock - Locks the affected item rows (in ID order, so concurrent orders
+// can't deadlock) and applies the oversell policy to them. Returns the items
+// to flag as oversold.
+func (d *DBPosAdapter) checkStock(tx *sqlx.Tx, orderID string, netDemand map[string]int) (map[string]bool, error) {
+	itemIDs := make([]string, 0, len(netDemand))
+	for itemID := range netDemand {
+		itemIDs = append(itemIDs, itemID)
+	}
+	if len(itemIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        SELECT id, stock, COALESCE(oversell_policy, '') AS oversellpolicy
+        FROM items
+        WHERE id = ANY($1)
+        ORDER BY id
+        FOR UPDATE`
+
+	var items []model.Item
+	if err := tx.Select(&items, query, pq.Array(itemIDs)); err != nil {
+		return nil, fmt.Errorf("failed to lock items for order %s: %w", orderID, err)
+	}
+
+	return checkOversell(orderID, items, netDemand, d.oversellPolicy)
+}
+
+// netStockDemand - Returns the net change per item between the stored lines of
+// an order and its new lines: positive means the new version takes more stock
+func netStockDemand(previous, next []model.OrderItem) map[string]int {
+	netDemand := make(map[string]int)
+	for _, line := range previous {
+		netDemand[line.ItemID] -= line.Quantity
+	}
+	for _, line := range next {
+		netDemand[line.ItemID] += line.Quantity
+	}
+	return netDemand
+}
+
+// checkOversell - Applies the oversell policy to every item whose stock would
+// go negative. Items without their own policy use defaultPolicy. Returns the
+// items to flag as oversold.
+func checkOversell(orderID string, items []model.Item, netDemand map[string]int, defaultPolicy model.OversellPolicy) (map[string]bool, error) {
+	oversold := make(map[string]bool)
+	for _, item := range items {
+		demand := netDemand[item.ID]
+		if demand <= 0 || item.Stock-demand >= 0 {
+			continue
+		}
+
+		policy := item.OversellPolicy
+		if policy == "" {
+			policy = defaultPolicy
+		}
+
+		switch policy {
+		case model.OversellAllow:
+		case model.OversellFlag:
+			log.Printf("Order %s oversells item %s: requested %d, available %d", orderID, item.ID, demand, item.Stock)
+			oversold[item.ID] = true
+		default:
+			return nil, &model.InsufficientStockError{
+				OrderID:   orderID,
+				ItemID:    item.ID,
+				Requested: demand,
+				Available: item.Stock,
+			}
+		}
+	}
+
+	return oversold, nil
+}
+
+// reverseOrderStock - Puts back the stock of every stored line of an order
+func (d *DBPosAdapter) reverseOrderStock(tx *sqlx.Tx, orderID string) error {
+	return d.applyOrderStock(tx, model.Order{ID: orderID})
+}
+
+// recordStockMovement - Appends a movement to the ledger and applies it to items.stock.
+// Lines for items that are not in the inventory are ignored.
+func recordStockMovement(tx *sqlx.Tx, movement model.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+
+	if err := insertStockMovement(tx, movement); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE items SET stock = stock + $2 WHERE id = $1`, movement.ItemID, movement.Delta)
+	if err != nil {
+		return fmt.Errorf("failed to update stock of item %s: %w", movement.ItemID, err)
+	}
+
+	return nil
+}
+
+// insertStockMovement - Appends a movement to the ledger without touching items.stock
+func insertStockMovement(tx *sqlx.Tx, movement model.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+
+	query := `
+        INSERT INTO stock_movements (item_id, order_id, delta, reason, oversold)
+        SELECT $1, $2, $3, $4, $5
+        WHERE EXISTS (SELECT 1 FROM items WHERE id = $1)`
+
+	_, err := tx.Exec(query, movement.ItemID, movement.OrderID, movement.Delta, movement.Reason, movement.Oversold)
+	if err != nil {
+		log.Printf("Failed to record stock movement for item %s: %v", movement.ItemID, err)
+		return fmt.Errorf("failed to record stock movement for item %s: %w", movement.ItemID, err)
+	}
+
+	return nil
+}
+
+// snapshotPrices - Fills in the unit price and cost of lines that were
+// submitted without a snapshot, using the item's price at the time of writing
+func snapshotPrices(tx *sqlx.Tx, order *model.Order) error {
+	var missing []string
+	for _, line := range order.Items {
+		if !line.HasSnapshot() {
+			missing = append(missing, line.ItemID)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	query := `
+        SELECT id, price, production_price AS productionprice, currency
+        FROM items
+        WHERE id = ANY($1)`
+
+	var rows []itemRow
+	if err := tx.Select(&rows, query, pq.Array(missing)); err != nil {
+		return fmt.Errorf("failed to read prices for order %s: %w", order.ID, err)
+	}
+
+	itemMap := make(map[string]model.Item)
+	for _, row := range rows {
+		itemMap[row.ID] = row.toItem()
+	}
+
+	// Copy the lines so the caller's order isn't modified
+	order.Items = append([]model.OrderItem(nil), order.Items...)
+	for i, line := range order.Items {
+		if item, exists := itemMap[line.ItemID]; exists && !line.HasSnapshot() {
+			order.Items[i].UnitPrice = item.Price
+			order.Items[i].UnitCost = item.ProductionPrice
+		}
+	}
+
+	return nil
+}
+
+// upsertItem - Inserts or updates an item, recording the stock difference as
+// an adjustment and any price change in the price history. The item is locked
+// until the transaction ends so that no order write changes its stock between
+// the read and the upsert, which would make the recorded delta wrong.
+func (d *DBPosAdapter) upsertItem(tx *sqlx.Tx, item model.Item) error {
+	currency, err := itemCurrency(item, d.currency)
+	if err != nil {
+		return err
+	}
+	item.Price = item.Price.WithCurrency(currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(currency)
+
+	if err := lockItem(tx, item.ID); err != nil {
+		return err
+	}
+	var previousRow itemRow
+	err = tx.Get(&previousRow, `SELECT id, stock, price, production_price AS productionprice, currency FROM items WHERE id = $1 FOR UPDATE`, item.ID)
+	isNew := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !isNew {
+		return fmt.Errorf("failed to read item %s: %w", item.ID, err)
+	}
+	previous := previousRow.toItem()
+
+	query := `
+        INSERT INTO items (id, name, stock, price, production_price, oversell_policy, currency)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+        ON CONFLICT (id) DO UPDATE SET
+            name = EXCLUDED.name,
+            stock = EXCLUDED.stock,
+            price = EXCLUDED.price,
+            production_price = EXCLUDED.production_price,
+            oversell_policy = EXCLUDED.oversell_policy,
+            currency = EXCLUDED.currency`
+
+	_, err = tx.Exec(query, item.ID, item.Name, item.Stock, item.Price, item.ProductionPrice, item.OversellPolicy, currency)
+	if err != nil {
+		return fmt.Errorf("failed to add item %s: %w", item.ID, err)
+	}
+
+	reason := model.StockReasonAdjustment
+	if isNew {
+		reason = model.StockReasonOpeningBalance
+	}
+
+	if err := insertStockMovement(tx, model.StockMovement{ItemID: item.ID, Delta: item.Stock - previous.Stock, Reason: reason}); err != nil {
+		return err
+	}
+
+	if isNew || previous.Price != item.Price.WithCurrency(currency) || previous.ProductionPrice != item.ProductionPrice.WithCurrency(currency) {
+		return recordPriceChange(tx, item)
+	}
+
+	return nil
+}
+
+// GetStockMovements - Returns the stock ledger of an item, oldest first
+func (d *DBPosAdapter) GetStockMovements(itemID string) ([]model.StockMovement, error) {
+	query := `
+        SELECT id, item_id, order_id, delta, reason, oversold, created_at
+        FROM stock_movements
+        WHERE item_id = $1
+        ORDER BY id`
+
+	var movements []model.StockMovement
+	if err := d.db.Select(&movements, query, itemID); err != nil {
+		log.Printf("Failed to query stock movements for item %s: %v", itemID, err)
+		return nil, fmt.Errorf("failed to query stock movements for item %s: %w", itemID, err)
+	}
+
+	return movements, nil
+}
+
+// GetLedgerStock - Derives the current stock of an item from its ledger
+func (d *DBPosAdapter) GetLedgerStock(itemID string) (int, error) {
+	var stock int
+	err := d.db.Get(&stock, `SELECT COALESCE(SUM(delta), 0) FROM stock_movements WHERE item_id = $1`, itemID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum stock movements for item %s: %w", itemID, err)
+	}
+
+	return stock, nil
+}
@@ -0,0 +1,253 @@
+package adapter
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// GeneratorConfig controls the demo data produced by GenerateSalesData. Zero
+// fields get the defaults listed below. The same config (including End and
+// Seed) always produces the same data.
+type GeneratorConfig struct {
+	Items        int            // menu items, at most len(menuCatalog) (default 20)
+	Days         int            // days of sales ending the day before End (default 180)
+	End          time.Time      // first day without sales (default today)
+	OrdersPerDay int            // orders on an average day (default 80)
+	Seed         int64          // random seed (default 1)
+	Location     *time.Location // timezone of the outlet's opening hours (default UTC)
+
+	// Currency of the generated amounts (default IDR). The menu is priced in
+	// IDR and converted with Rates for any other currency.
+	Currency string
+	Rates    model.ExchangeRates
+
+	// Relative traffic per weekday (Sunday first) and per hour of the day.
+	// Defaults: busier Friday to Sunday, with breakfast, lunch and dinner
+	// peaks between 07:00 and 22:00.
+	WeekdayWeights []float64
+	HourlyWeights  []float64
+}
+
+// menuItem is one entry of the demo menu. Prices are in IDR.
+type menuItem struct {
+	name       string
+	price      int64
+	costRatio  float64
+	popularity float64
+	// Seasonal swing: >0 sells more in the dry months (cold drinks), <0 more
+	// in the rainy months (hot food and drinks)
+	seasonality float64
+}
+
+var menuCatalog = []menuItem{
+	{"Es Kopi Susu", 22000, 0.32, 1.6, 0.35},
+	{"Kopi Tubruk", 15000, 0.25, 0.9, -0.25},
+	{"Cappuccino", 28000, 0.30, 1.0, -0.1},
+	{"Americano", 24000, 0.22, 0.8, 0},
+	{"Es Teh Manis", 8000, 0.20, 1.8, 0.4},
+	{"Teh Tarik", 14000, 0.28, 0.7, -0.2},
+	{"Jus Alpukat", 20000, 0.40, 0.6, 0.45},
+	{"Es Jeruk", 12000, 0.35, 0.9, 0.4},
+	{"Air Mineral", 6000, 0.45, 1.1, 0.2},
+	{"Nasi Goreng", 30000, 0.38, 1.5, 0},
+	{"Mie Goreng", 27000, 0.36, 1.2, 0},
+	{"Ayam Geprek", 28000, 0.42, 1.4, 0},
+	{"Soto Ayam", 25000, 0.40, 0.8, -0.45},
+	{"Bakso", 23000, 0.41, 0.9, -0.5},
+	{"Nasi Uduk", 20000, 0.37, 0.7, 0},
+	{"Gado-Gado", 22000, 0.35, 0.5, 0.1},
+	{"Sate Ayam", 32000, 0.45, 0.7, 0},
+	{"Pisang Goreng", 12000, 0.30, 1.0, -0.2},
+	{"Roti Bakar", 18000, 0.33, 0.8, -0.1},
+	{"Kentang Goreng", 17000, 0.35, 0.9, 0},
+	{"Tahu Crispy", 13000, 0.30, 0.6, 0},
+	{"Martabak Manis", 35000, 0.38, 0.5, -0.1},
+	{"Es Campur", 18000, 0.36, 0.6, 0.5},
+	{"Pudding Coklat", 15000, 0.34, 0.4, 0.1},
+}
+
+var (
+	defaultWeekdayWeights = []float64{1.3, 0.85, 0.85, 0.9, 0.95, 1.15, 1.4}
+	defaultHourlyWeights  = []float64{
+		0, 0, 0, 0, 0, 0, 0, // closed until 07:00
+		0.6, 0.9, 0.7, 0.5, 0.9, // breakfast
+		1.6, 1.4, 0.8, 0.6, 0.7, 0.9, // lunch
+		1.3, 1.5, 1.2, 0.8, 0.4, // dinner
+		0, // closed from 23:00
+	}
+)
+
+// withDefaults fills in the zero fields of a config
+func (c GeneratorConfig) withDefaults() GeneratorConfig {
+	if c.Items <= 0 {
+		c.Items = 20
+	}
+	if c.Items > len(menuCatalog) {
+		c.Items = len(menuCatalog)
+	}
+	if c.Days <= 0 {
+		c.Days = 180
+	}
+	if c.OrdersPerDay <= 0 {
+		c.OrdersPerDay = 80
+	}
+	if c.Seed == 0 {
+		c.Seed = 1
+	}
+	if c.Location == nil {
+		c.Location = time.UTC
+	}
+	if c.Currency == "" {
+		c.Currency = "IDR"
+	}
+	if c.End.IsZero() {
+		c.End = time.Now()
+	}
+	if len(c.WeekdayWeights) != 7 {
+		c.WeekdayWeights = defaultWeekdayWeights
+	}
+	if len(c.HourlyWeights) != 24 {
+		c.HourlyWeights = defaultHourlyWeights
+	}
+	return c
+}
+
+// GenerateSalesData - Produces a realistic food and beverage menu and a
+// history of completed orders. Traffic follows the weekday and hourly
+// patterns, a yearly season (cold drinks in the dry months, soups and hot
+// drinks in the rainy months), a December peak and day-to-day noise. Order
+// lines carry price snapshots and some orders get a small discount.
+func GenerateSalesData(config GeneratorConfig) ([]model.Item, []model.Order, error) {
+	config = config.withDefaults()
+	random := rand.New(rand.NewSource(config.Seed))
+
+	menu := menuCatalog[:config.Items]
+	items := make([]model.Item, 0, len(menu))
+	for i, entry := range menu {
+		price, err := model.ConvertMoney(model.MustParseMoney(strconv.FormatInt(entry.price, 10), "IDR"), config.Currency, config.Rates)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to price the demo menu: %w", err)
+		}
+		cost := price.Mul(int64(math.Round(entry.costRatio * 100))).Div(100)
+		items = append(items, model.Item{
+			ID:              fmt.Sprintf("ITEM-%03d", i+1),
+			Name:            entry.name,
+			Stock:           40 + random.Intn(160),
+			Price:           price,
+			ProductionPrice: cost,
+		})
+	}
+
+	end := config.End.In(config.Location)
+	lastDay := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, config.Location)
+	firstDay := lastDay.AddDate(0, 0, -config.Days)
+
+	var orders []model.Order
+	for day := firstDay; day.Before(lastDay); day = day.AddDate(0, 0, 1) {
+		season := dryness(day)
+		traffic := float64(config.OrdersPerDay) * config.WeekdayWeights[day.Weekday()] * (1 + 0.1*season)
+		if day.Month() == time.December {
+			traffic *= 1.2
+		}
+		traffic *= 0.85 + 0.3*random.Float64()
+
+		// Item weights for the day: popularity shifted by the season
+		weights := make([]float64, len(menu))
+		for i, entry := range menu {
+			weights[i] = math.Max(0.05, entry.popularity*(1+entry.seasonality*season))
+		}
+
+		orderCount := int(math.Round(traffic))
+		for n := 0; n < orderCount; n++ {
+			hour := pickWeighted(random, config.HourlyWeights)
+			completedAt := day.Add(time.Duration(hour)*time.Hour + time.Duration(random.Intn(3600))*time.Second)
+
+			order := model.Order{CompletedAt: completedAt.UTC()}
+			lineCount := 1 + pickWeighted(random, []float64{0.45, 0.35, 0.15, 0.05})
+			if lineCount > len(menu) {
+				lineCount = len(menu)
+			}
+			chosen := make(map[int]bool)
+			for len(order.Items) < lineCount {
+				index := pickWeighted(random, weights)
+				if chosen[index] {
+					continue
+				}
+				chosen[index] = true
+
+				item := items[index]
+				line := model.OrderItem{
+					ItemID:    item.ID,
+					Quantity:  1 + pickWeighted(random, []float64{0.7, 0.22, 0.08}),
+					UnitPrice: item.Price,
+					UnitCost:  item.ProductionPrice,
+				}
+				// One order in twelve gets 10% off a line
+				if random.Intn(12) == 0 {
+					line.Discount = line.UnitPrice.Mul(int64(line.Quantity)).Div(10)
+				}
+				order.Items = append(order.Items, line)
+			}
+
+			order.Total = model.NewMoney(0, config.Currency)
+			for _, line := range order.Items {
+				revenue, err := line.Revenue()
+				if err == nil {
+					order.Total, err = order.Total.Add(revenue)
+				}
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			orders = append(orders, order)
+		}
+	}
+
+	// Number orders in completion order
+	sort.SliceStable(orders, func(i, j int) bool { return orders[i].CompletedAt.Before(orders[j].CompletedAt) })
+	for i := range orders {
+		orders[i].ID = fmt.Sprintf("ORD-%06d", i+1)
+	}
+
+	return items, orders, nil
+}
+
+// NewDemoPosAdapter - Returns an in-memory adapter seeded with generated data
+func NewDemoPosAdapter(config GeneratorConfig) (*MemoryPosAdapter, error) {
+	items, orders, err := GenerateSalesData(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate demo data: %w", err)
+	}
+	adapter := NewMemoryPosAdapter(config.withDefaults().Currency)
+	adapter.Seed(items, orders)
+	return adapter, nil
+}
+
+// dryness is 1 in the middle of the dry season (August), -1 in the middle of
+// the rainy season (February) and varies smoothly in between
+func dryness(day time.Time) float64 {
+	return math.Cos(2 * math.Pi * float64(day.YearDay()-220) / 365)
+}
+
+// pickWeighted returns an index with probability proportional to its weight
+func pickWeighted(random *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+
+	target := random.Float64() * total
+	for i, weight := range weights {
+		if target < weight {
+			return i
+		}
+		target -= weight
+	}
+	return len(weights) - 1
+}
@@ -0,0 +1,724 @@
+package adapter
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// MemoryPosAdapter keeps the POS data in memory. It behaves like DBPosAdapter
+// (upserts, stock ledger, oversell policy, price snapshots and price history)
+// without needing a database, which makes it suitable for demos and handler
+// tests. It is safe for concurrent use. Data is lost when the process exits.
+type MemoryPosAdapter struct {
+	mu             sync.RWMutex
+	items          map[string]model.Item
+	orders         map[string]model.Order
+	movements      []model.StockMovement
+	prices         map[string][]model.ItemPrice
+	nextMovementID int64
+	nextPriceID    int64
+	currency       string
+	oversellPolicy model.OversellPolicy
+	now            func() time.Time
+}
+
+// NewMemoryPosAdapter - currency is the currency of amounts written without one
+func NewMemoryPosAdapter(currency string) *MemoryPosAdapter {
+	return &MemoryPosAdapter{
+		currency:       normalizeCurrency(currency),
+		items:          make(map[string]model.Item),
+		orders:         make(map[string]model.Order),
+		prices:         make(map[string][]model.ItemPrice),
+		oversellPolicy: model.OversellReject,
+		now:            time.Now,
+	}
+}
+
+// SetOversellPolicy - Sets the deployment-wide oversell policy used for items
+// that don't define their own
+func (m *MemoryPosAdapter) SetOversellPolicy(policy model.OversellPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if policy == "" {
+		policy = model.OversellReject
+	}
+	m.oversellPolicy = policy
+}
+
+// SetClock - Replaces the clock used to timestamp stock movements and price
+// changes, so tests get reproducible timestamps
+func (m *MemoryPosAdapter) SetClock(now func() time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.now = now
+}
+
+// Seed - Loads existing data without validation: items with their current
+// stock and price (assumed to have always applied) and orders whose lines
+// already carry a price snapshot. The ledger gets an opening balance per item
+// plus one sale per order line, so it sums to the current stock.
+func (m *MemoryPosAdapter) Seed(items []model.Item, orders []model.Order) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sorted := make([]model.Order, 0, len(orders))
+	for _, order := range orders {
+		sorted = append(sorted, cloneOrder(order))
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CompletedAt.Before(sorted[j].CompletedAt) })
+
+	sold := make(map[string]int)
+	for _, order := range sorted {
+		for _, line := range order.Items {
+			sold[line.ItemID] += line.Quantity
+		}
+	}
+
+	epoch := time.Unix(0, 0).UTC()
+	for _, item := range items {
+		m.items[item.ID] = item
+		m.addMovement(model.StockMovement{ItemID: item.ID, Delta: item.Stock + sold[item.ID], Reason: model.StockReasonOpeningBalance, CreatedAt: epoch})
+		m.addPrice(item, epoch)
+	}
+
+	for _, order := range sorted {
+		m.orders[order.ID] = order
+		orderID := order.ID
+		for _, line := range order.Items {
+			m.addMovement(model.StockMovement{ItemID: line.ItemID, OrderID: &orderID, Delta: -line.Quantity, Reason: model.StockReasonSale, CreatedAt: order.CompletedAt})
+		}
+	}
+
+	log.Printf("Seeded in-memory POS with %d items and %d orders", len(items), len(orders))
+}
+
+func (m *MemoryPosAdapter) GetInventory() ([]model.Item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := make([]model.Item, 0, len(m.items))
+	for _, item := range m.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// SearchItems - Finds items whose name or ID contains term, ignoring case
+func (m *MemoryPosAdapter) SearchItems(term string, limit int) ([]model.Item, error) {
+	items, err := m.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return model.SearchItems(items, term, limit), nil
+}
+
+// GetItemByID - Helper method to get a single item by ID
+func (m *MemoryPosAdapter) GetItemByID(itemID string) (*model.Item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, exists := m.items[itemID]
+	if !exists {
+		return nil, &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+	return &item, nil
+}
+
+// CheckItemExists - Helper method to check if an item exists
+func (m *MemoryPosAdapter) CheckItemExists(itemID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.items[itemID]
+	return exists, nil
+}
+
+func (m *MemoryPosAdapter) AddItem(item model.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.upsertItem(item); err != nil {
+		log.Printf("Failed to add item %s: %v", item.ID, err)
+		return err
+	}
+
+	log.Printf("Successfully added/updated item: %s - %s", item.ID, item.Name)
+	return nil
+}
+
+func (m *MemoryPosAdapter) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := m.writeBatch(mode, ids, func(i int) error {
+		return m.upsertItem(items[i])
+	})
+
+	logBatch("items", len(items), result)
+	return result, nil
+}
+
+func (m *MemoryPosAdapter) UpdateItem(item model.Item) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.items[item.ID]; !exists {
+		return &model.NotFoundError{Resource: "item", ID: item.ID}
+	}
+
+	if err := m.upsertItem(item); err != nil {
+		log.Printf("Failed to update item %s: %v", item.ID, err)
+		return err
+	}
+
+	log.Printf("Successfully updated item: %s - %s", item.ID, item.Name)
+	return nil
+}
+
+func (m *MemoryPosAdapter) DeleteItem(itemID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// First check if item exists in any orders
+	orderCount := 0
+	for _, order := range m.orders {
+		for _, line := range order.Items {
+			if line.ItemID == itemID {
+				orderCount++
+			}
+		}
+	}
+	if orderCount > 0 {
+		return &model.ItemInUseError{ItemID: itemID, OrderCount: orderCount}
+	}
+
+	if _, exists := m.items[itemID]; !exists {
+		return &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+
+	// The item's ledger and price history go with it
+	delete(m.items, itemID)
+	delete(m.prices, itemID)
+	movements := m.movements[:0]
+	for _, movement := range m.movements {
+		if movement.ItemID != itemID {
+			movements = append(movements, movement)
+		}
+	}
+	m.movements = movements
+
+	log.Printf("Successfully deleted item: %s", itemID)
+	return nil
+}
+
+func (m *MemoryPosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var orders []model.Order
+	for _, order := range m.orders {
+		if !order.CompletedAt.Before(since) {
+			orders = append(orders, cloneOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CompletedAt.Equal(orders[j].CompletedAt) {
+			return orders[i].CompletedAt.After(orders[j].CompletedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	return orders, nil
+}
+
+// QueryOrders - Returns one keyset-paginated page of orders, like
+// DBPosAdapter.QueryOrders
+func (m *MemoryPosAdapter) QueryOrders(query model.OrderQuery) (model.OrderPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]model.Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		orders = append(orders, order)
+	}
+
+	return pageOrders(orders, query, m.currency)
+}
+
+// GetOrderByID - Helper method to get a single order by ID
+func (m *MemoryPosAdapter) GetOrderByID(orderID string) (*model.Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, exists := m.orders[orderID]
+	if !exists {
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+	order = cloneOrder(order)
+	return &order, nil
+}
+
+// CheckOrderExists - Helper method to check if an order exists
+func (m *MemoryPosAdapter) CheckOrderExists(orderID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, exists := m.orders[orderID]
+	return exists, nil
+}
+
+func (m *MemoryPosAdapter) AddOrder(order model.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.writeOrder(order); err != nil {
+		log.Printf("Failed to add order %s: %v", order.ID, err)
+		return err
+	}
+
+	log.Printf("Successfully added order: %s with %d items (Total: %s)", order.ID, len(order.Items), order.Total.Format())
+	return nil
+}
+
+func (m *MemoryPosAdapter) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := m.writeBatch(mode, ids, func(i int) error {
+		return m.writeOrder(orders[i])
+	})
+
+	logBatch("orders", len(orders), result)
+	return result, nil
+}
+
+// DeleteOrder - Helper method to delete an order and put its lines back into stock
+func (m *MemoryPosAdapter) DeleteOrder(orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.orders[orderID]; !exists {
+		return &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+
+	if err := m.applyOrderStock(model.Order{ID: orderID}); err != nil {
+		return err
+	}
+	delete(m.orders, orderID)
+
+	log.Printf("Successfully deleted order: %s", orderID)
+	return nil
+}
+
+// AggregateSales - Aggregates with model.AggregateOrders; everything is in
+// memory already
+func (m *MemoryPosAdapter) AggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	if query.GroupBy != model.GroupByDay && query.GroupBy != model.GroupByWeek && query.GroupBy != model.GroupByMonth && query.GroupBy != model.GroupByItem {
+		return nil, fmt.Errorf("unsupported aggregation granularity: %s", query.GroupBy)
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]model.Order, 0, len(m.orders))
+	for _, order := range m.orders {
+		orders = append(orders, order)
+	}
+	inventory := make([]model.Item, 0, len(m.items))
+	for _, item := range m.items {
+		inventory = append(inventory, item)
+	}
+
+	return model.AggregateOrders(orders, inventory, query)
+}
+
+// GetStockMovements - Returns the stock ledger of an item, oldest first
+func (m *MemoryPosAdapter) GetStockMovements(itemID string) ([]model.StockMovement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var movements []model.StockMovement
+	for _, movement := range m.movements {
+		if movement.ItemID == itemID {
+			movements = append(movements, movement)
+		}
+	}
+	return movements, nil
+}
+
+// GetLedgerStock - Derives the current stock of an item from its ledger
+func (m *MemoryPosAdapter) GetLedgerStock(itemID string) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stock := 0
+	for _, movement := range m.movements {
+		if movement.ItemID == itemID {
+			stock += movement.Delta
+		}
+	}
+	return stock, nil
+}
+
+// GetItemPriceHistory - Returns the price timeline of an item, oldest first
+func (m *MemoryPosAdapter) GetItemPriceHistory(itemID string) ([]model.ItemPrice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]model.ItemPrice{}, m.prices[itemID]...), nil
+}
+
+// GetItemPriceAsOf - Returns the price of an item that was valid at the given time
+func (m *MemoryPosAdapter) GetItemPriceAsOf(itemID string, at time.Time) (*model.ItemPrice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	price := model.PriceAsOf(m.prices[itemID], at)
+	if price == nil {
+		return nil, nil
+	}
+	result := *price
+	return &result, nil
+}
+
+// writeBatch - Writes each row of a batch. A failed row changes nothing, so a
+// partial batch keeps the rest; an atomic batch with a failed row is undone
+// by restoring the state from before it. Caller holds the write lock.
+func (m *MemoryPosAdapter) writeBatch(mode model.BatchMode, ids []string, write func(i int) error) model.BatchResult {
+	result := newBatchResult(mode)
+	if len(ids) == 0 {
+		return result
+	}
+
+	var saved memorySnapshot
+	if result.Mode == model.BatchAtomic {
+		saved = m.snapshot()
+	}
+
+	for i, id := range ids {
+		if err := write(i); err != nil {
+			log.Printf("Failed to write %s in batch: %v", id, err)
+			result.Fail(i, id, err)
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, id)
+	}
+
+	if result.Mode == model.BatchAtomic && !result.OK() {
+		m.restore(saved)
+		result.RollBack(ids)
+	}
+	return result
+}
+
+// memorySnapshot is a copy of the adapter's state taken before an atomic batch
+type memorySnapshot struct {
+	items          map[string]model.Item
+	orders         map[string]model.Order
+	movements      []model.StockMovement
+	prices         map[string][]model.ItemPrice
+	nextMovementID int64
+	nextPriceID    int64
+}
+
+// snapshot - Copies the adapter's state. Caller holds the write lock.
+func (m *MemoryPosAdapter) snapshot() memorySnapshot {
+	saved := memorySnapshot{
+		items:          make(map[string]model.Item, len(m.items)),
+		orders:         make(map[string]model.Order, len(m.orders)),
+		movements:      append([]model.StockMovement(nil), m.movements...),
+		prices:         make(map[string][]model.ItemPrice, len(m.prices)),
+		nextMovementID: m.nextMovementID,
+		nextPriceID:    m.nextPriceID,
+	}
+	for id, item := range m.items {
+		saved.items[id] = item
+	}
+	for id, order := range m.orders {
+		saved.orders[id] = order
+	}
+	for id, prices := range m.prices {
+		saved.prices[id] = append([]model.ItemPrice(nil), prices...)
+	}
+	return saved
+}
+
+// restore - Puts back a snapshot. Caller holds the write lock.
+func (m *MemoryPosAdapter) restore(saved memorySnapshot) {
+	m.items = saved.items
+	m.orders = saved.orders
+	m.movements = saved.movements
+	m.prices = saved.prices
+	m.nextMovementID = saved.nextMovementID
+	m.nextPriceID = saved.nextPriceID
+}
+
+// upsertItem - Inserts or updates an item, recording the stock difference as
+// an adjustment and any price change in the price history. Caller holds the
+// write lock.
+func (m *MemoryPosAdapter) upsertItem(item model.Item) error {
+	currency, err := itemCurrency(item, m.currency)
+	if err != nil {
+		return err
+	}
+	item.Price = item.Price.WithCurrency(currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(currency)
+
+	previous, exists := m.items[item.ID]
+	m.items[item.ID] = item
+
+	reason := model.StockReasonAdjustment
+	if !exists {
+		reason = model.StockReasonOpeningBalance
+	}
+	m.addMovement(model.StockMovement{ItemID: item.ID, Delta: item.Stock - previous.Stock, Reason: reason, CreatedAt: m.now()})
+
+	if !exists || previous.Price != item.Price || previous.ProductionPrice != item.ProductionPrice {
+		m.addPrice(item, m.now())
+	}
+
+	return nil
+}
+
+// writeOrder - Inserts or replaces an order, snapshotting prices and recording
+// stock movements. Nothing changes if the order is rejected. Caller holds the
+// write lock.
+func (m *MemoryPosAdapter) writeOrder(order model.Order) error {
+	order = cloneOrder(order)
+
+	// Snapshot prices of lines submitted without one
+	for i, line := range order.Items {
+		if item, exists := m.items[line.ItemID]; exists && !line.HasSnapshot() {
+			order.Items[i].UnitPrice = item.Price
+			order.Items[i].UnitCost = item.ProductionPrice
+		}
+	}
+
+	currency, err := orderCurrency(order, m.currency)
+	if err != nil {
+		return err
+	}
+	order.Total = order.Total.WithCurrency(currency)
+	for i := range order.Items {
+		order.Items[i].UnitPrice = order.Items[i].UnitPrice.WithCurrency(currency)
+		order.Items[i].UnitCost = order.Items[i].UnitCost.WithCurrency(currency)
+		order.Items[i].Discount = order.Items[i].Discount.WithCurrency(currency)
+	}
+
+	if err := m.applyOrderStock(order); err != nil {
+		return err
+	}
+	m.orders[order.ID] = order
+
+	return nil
+}
+
+// applyOrderStock - Reverses the lines of any previously stored version of the
+// order and records its new lines, enforcing the oversell policy. Nothing is
+// written if the order is rejected.
+func (m *MemoryPosAdapter) applyOrderStock(order model.Order) error {
+	previous := m.orders[order.ID].Items
+	netDemand := netStockDemand(previous, order.Items)
+
+	var affected []model.Item
+	for itemID := range netDemand {
+		if item, exists := m.items[itemID]; exists {
+			affected = append(affected, item)
+		}
+	}
+	sort.Slice(affected, func(i, j int) bool { return affected[i].ID < affected[j].ID })
+
+	oversold, err := checkOversell(order.ID, affected, netDemand, m.oversellPolicy)
+	if err != nil {
+		return err
+	}
+
+	orderID := order.ID
+	for _, line := range previous {
+		m.recordStockMovement(model.StockMovement{ItemID: line.ItemID, OrderID: &orderID, Delta: line.Quantity, Reason: model.StockReasonOrderReversal})
+	}
+	for _, line := range order.Items {
+		m.recordStockMovement(model.StockMovement{ItemID: line.ItemID, OrderID: &orderID, Delta: -line.Quantity, Reason: model.StockReasonSale, Oversold: oversold[line.ItemID]})
+	}
+
+	return nil
+}
+
+// recordStockMovement - Appends a movement to the ledger and applies it to the
+// item's stock. Lines for items that are not in the inventory are ignored.
+func (m *MemoryPosAdapter) recordStockMovement(movement model.StockMovement) {
+	item, exists := m.items[movement.ItemID]
+	if !exists || movement.Delta == 0 {
+		return
+	}
+
+	movement.CreatedAt = m.now()
+	m.addMovement(movement)
+	item.Stock += movement.Delta
+	m.items[movement.ItemID] = item
+}
+
+func (m *MemoryPosAdapter) addMovement(movement model.StockMovement) {
+	if movement.Delta == 0 {
+		return
+	}
+	m.nextMovementID++
+	movement.ID = m.nextMovementID
+	m.movements = append(m.movements, movement)
+}
+
+func (m *MemoryPosAdapter) addPrice(item model.Item, effectiveFrom time.Time) {
+	m.nextPriceID++
+	m.prices[item.ID] = append(m.prices[item.ID], model.ItemPrice{
+		ID:              m.nextPriceID,
+		ItemID:          item.ID,
+		Price:           item.Price,
+		ProductionPrice: item.ProductionPrice,
+		EffectiveFrom:   effectiveFrom,
+		RecordedAt:      m.now(),
+	})
+	sort.SliceStable(m.prices[item.ID], func(i, j int) bool {
+		return m.prices[item.ID][i].EffectiveFrom.Before(m.prices[item.ID][j].EffectiveFrom)
+	})
+}
+
+// pageOrders - Applies an OrderQuery to a set of orders in memory: filters,
+// sorts by the query's column and ID, and cuts a keyset-paginated page. Total
+// bounds without a currency are in currency.
+func pageOrders(all []model.Order, query model.OrderQuery, currency string) (model.OrderPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	cursor, err := model.DecodeOrderCursor(query)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	// compare orders by the sort column, then by ID
+	compare := func(a, b model.Order) int {
+		result := 0
+		switch query.SortBy {
+		case model.SortByTotal:
+			result = compareTotals(a.Total, b.Total)
+		case model.SortByCompletedAt:
+			result = a.CompletedAt.Compare(b.CompletedAt)
+		}
+		if result == 0 {
+			result = strings.Compare(a.ID, b.ID)
+		}
+		if query.Descending {
+			result = -result
+		}
+		return result
+	}
+
+	var after *model.Order
+	if cursor != nil {
+		after = &model.Order{ID: cursor.ID, Total: cursor.Total, CompletedAt: cursor.CompletedAt}
+	}
+
+	var orders []model.Order
+	for _, order := range all {
+		if !query.Start.IsZero() && order.CompletedAt.Before(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && !order.CompletedAt.Before(query.End) {
+			continue
+		}
+		if query.MinTotal != nil {
+			if result, ok := compareTotal(order.Total, *query.MinTotal, currency); !ok || result < 0 {
+				continue
+			}
+		}
+		if query.MaxTotal != nil {
+			if result, ok := compareTotal(order.Total, *query.MaxTotal, currency); !ok || result > 0 {
+				continue
+			}
+		}
+		if query.ItemID != "" && !orderHasItem(order, query.ItemID) {
+			continue
+		}
+		if after != nil && compare(order, *after) <= 0 {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return compare(orders[i], orders[j]) < 0 })
+
+	page := model.OrderPage{Orders: make([]model.Order, 0, len(orders))}
+	for _, order := range orders {
+		page.Orders = append(page.Orders, cloneOrder(order))
+	}
+	if query.Limit > 0 && len(page.Orders) > query.Limit {
+		page.Orders = page.Orders[:query.Limit]
+		page.NextCursor = model.NewOrderCursor(query, page.Orders[query.Limit-1]).Encode()
+	}
+
+	return page, nil
+}
+
+// cloneOrder - Copies an order so callers can't modify stored lines, with the
+// lines sorted by item ID like the SQL adapters return them
+func cloneOrder(order model.Order) model.Order {
+	order.Items = append([]model.OrderItem{}, order.Items...)
+	sort.SliceStable(order.Items, func(i, j int) bool { return order.Items[i].ItemID < order.Items[j].ItemID })
+	return order
+}
+
+func orderHasItem(order model.Order, itemID string) bool {
+	for _, line := range order.Items {
+		if line.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// compareTotals - Orders amounts by currency code and then by amount, the
+// way the SQL adapters sort the total column
+func compareTotals(a, b model.Money) int {
+	if result := strings.Compare(a.CurrencyCode(), b.CurrencyCode()); result != 0 {
+		return result
+	}
+	return cmp.Compare(a.Amount, b.Amount)
+}
+
+// compareTotal - Compares an order total with a bound of a query, which is in
+// currency when it has none. ok is false when they are in different
+// currencies, which never match.
+func compareTotal(total, bound model.Money, currency string) (int, bool) {
+	bound = bound.WithCurrency(currencyOr(bound, currency))
+	if total.CurrencyCode() != bound.Currency {
+		return 0, false
+	}
+	result, err := total.Cmp(bound)
+	return result, err == nil
+}
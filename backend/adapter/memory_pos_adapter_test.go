@@ -0,0 +1,140 @@
+package adapter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+func newMemoryTestAdapter(t *testing.T) testAdapter {
+	return NewMemoryPosAdapter("IDR")
+}
+
+func TestMemoryAddOrdersBatch(t *testing.T) {
+	testAddOrdersBatch(t, newMemoryTestAdapter)
+}
+
+func TestMemoryAddItemsBatch(t *testing.T) {
+	testAddItemsBatch(t, newMemoryTestAdapter)
+}
+
+func TestMemoryOversellPolicy(t *testing.T) {
+	testOversellPolicy(t, newMemoryTestAdapter)
+}
+
+func TestMemoryQueryOrdersPages(t *testing.T) {
+	testQueryOrdersPages(t, newMemoryTestAdapter)
+}
+
+func TestGenerateSalesDataIsDeterministic(t *testing.T) {
+	config := GeneratorConfig{Items: 5, Days: 14, OrdersPerDay: 10, End: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+
+	items, orders, err := GenerateSalesData(config)
+	if err != nil {
+		t.Fatalf("GenerateSalesData: %v", err)
+	}
+	againItems, againOrders, err := GenerateSalesData(config)
+	if err != nil {
+		t.Fatalf("GenerateSalesData: %v", err)
+	}
+	if !reflect.DeepEqual(items, againItems) || !reflect.DeepEqual(orders, againOrders) {
+		t.Error("the same config generated different data")
+	}
+
+	config.Seed = 2
+	_, otherOrders, err := GenerateSalesData(config)
+	if err != nil {
+		t.Fatalf("GenerateSalesData: %v", err)
+	}
+	if reflect.DeepEqual(orders, otherOrders) {
+		t.Error("another seed generated the same orders")
+	}
+
+	if len(items) != 5 || len(orders) == 0 {
+		t.Fatalf("generated %d items and %d orders", len(items), len(orders))
+	}
+	first, last := config.End.AddDate(0, 0, -14), config.End
+	for _, order := range orders {
+		if order.CompletedAt.Before(first) || !order.CompletedAt.Before(last) {
+			t.Errorf("order %s completed at %s, outside [%s, %s)", order.ID, order.CompletedAt, first, last)
+		}
+		var total model.Money
+		for _, line := range order.Items {
+			revenue, err := line.Revenue()
+			if err != nil {
+				t.Fatalf("order %s: %v", order.ID, err)
+			}
+			if total, err = total.Add(revenue); err != nil {
+				t.Fatalf("order %s: %v", order.ID, err)
+			}
+		}
+		if total != order.Total {
+			t.Errorf("order %s totals %v, its lines %v", order.ID, order.Total, total)
+		}
+	}
+}
+
+func TestGenerateSalesDataCurrency(t *testing.T) {
+	rates := model.NewRateTable()
+	if err := rates.Set("IDR", "USD", "0.0001"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		currency  string
+		rates     model.ExchangeRates
+		wantPrice model.Money // of the first menu item, Rp 22.000
+		wantErr   bool
+	}{
+		{name: "default", wantPrice: model.NewMoney(2200000, "IDR")},
+		{name: "converted", currency: "USD", rates: rates, wantPrice: model.NewMoney(220, "USD")},
+		{name: "without a rate", currency: "EUR", rates: rates, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		config := GeneratorConfig{Items: 2, Days: 2, OrdersPerDay: 5, Currency: tt.currency, Rates: tt.rates}
+		items, orders, err := GenerateSalesData(config)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: GenerateSalesData succeeded, want an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: GenerateSalesData: %v", tt.name, err)
+			continue
+		}
+		if items[0].Price != tt.wantPrice {
+			t.Errorf("%s: price = %#v, want %#v", tt.name, items[0].Price, tt.wantPrice)
+		}
+		for _, order := range orders {
+			if order.Total.Currency != tt.wantPrice.Currency {
+				t.Errorf("%s: order %s is in %s", tt.name, order.ID, order.Total.Currency)
+				break
+			}
+		}
+	}
+}
+
+func TestNewDemoPosAdapter(t *testing.T) {
+	config := GeneratorConfig{Items: 3, Days: 7, OrdersPerDay: 5, End: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}
+	adapter, err := NewDemoPosAdapter(config)
+	if err != nil {
+		t.Fatalf("NewDemoPosAdapter: %v", err)
+	}
+
+	_, orders, err := GenerateSalesData(config)
+	if err != nil {
+		t.Fatalf("GenerateSalesData: %v", err)
+	}
+	stored, err := adapter.GetCompletedOrders(time.Time{})
+	if err != nil {
+		t.Fatalf("GetCompletedOrders: %v", err)
+	}
+	if len(stored) != len(orders) {
+		t.Errorf("adapter has %d orders, want %d", len(stored), len(orders))
+	}
+}
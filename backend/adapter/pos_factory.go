@@ -2,15 +2,61 @@ package adapter
 
 import (
 	"errors"
+	"strings"
+
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/jmoiron/sqlx"
 )
 
-func GetPOSAdapter(provider string, apiKey string, db *sqlx.DB) (model.POSAdapter, error) {
+// GetPOSAdapter - Returns the adapter for a provider: "db" (Postgres),
+// "sqlite" (an embedded database opened with OpenSQLite), "memory" (empty,
+// in memory), "demo" (in memory, seeded with generated sales) or "rest" (a
+// vendor API described by rest, authenticated with apiKey). Only the database
+// providers use db. Amounts without a currency are in the business currency;
+// the demo data, generated as set by demo, is in the business currency and
+// timezone. Use model.CapabilitiesOf to find out what the adapter supports
+// beyond reads.
+func GetPOSAdapter(provider string, apiKey string, db *sqlx.DB, rest RESTConfig, demo GeneratorConfig, business model.Business) (model.POSReader, error) {
 	switch provider {
 	case "db":
-		return NewDBPosAdapter(db), nil
+		return NewDBPosAdapter(db, business.Currency), nil
+	case "sqlite":
+		return NewSQLitePosAdapter(db, business.Currency)
+	case "memory":
+		return NewMemoryPosAdapter(business.Currency), nil
+	case "demo":
+		demo.Currency = business.Currency
+		demo.Rates = business.Rates
+		demo.Location = business.Zone()
+		demoAdapter, err := NewDemoPosAdapter(demo)
+		if err != nil {
+			return nil, err
+		}
+		return demoAdapter, nil
+	case "rest":
+		rest.APIKey = apiKey
+		if rest.Currency == "" {
+			rest.Currency = business.Currency
+		}
+		restAdapter, err := NewRESTPosAdapter(rest)
+		if err != nil {
+			return nil, err
+		}
+		if rest.ReadOnly {
+			return ReadOnly(restAdapter), nil
+		}
+		return restAdapter, nil
 	default:
 		return nil, errors.New("unsupported POS provider")
 	}
-}
\ No newline at end of file
+}
+
+// normalizeCurrency - Returns an adapter's currency code in upper case,
+// model.DefaultCurrency when none is given
+func normalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return model.DefaultCurrency
+	}
+	return currency
+}
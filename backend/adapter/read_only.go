@@ -0,0 +1,79 @@
+package adapter
+
+import "github.com/YudaClairee/garudahacks/model"
+
+// readOnlyAdapter exposes only the read capabilities of the adapter it wraps
+type readOnlyAdapter struct {
+	model.InventoryReader
+	model.OrderReader
+}
+
+// The optional read capabilities are each added by a layer that unwraps to
+// the next one, so model.As finds every one the wrapped adapter has but
+// never reaches its writers
+
+// readOnlyAggregator is a readOnlyAdapter that keeps SQL aggregation
+type readOnlyAggregator struct {
+	readOnlyAdapter
+	model.SalesAggregator
+	next model.POSReader
+}
+
+func (r readOnlyAggregator) Unwrap() model.POSReader {
+	return r.next
+}
+
+// readOnlySearcher is a readOnlyAdapter that keeps item search
+type readOnlySearcher struct {
+	readOnlyAdapter
+	model.ItemSearcher
+	next model.POSReader
+}
+
+func (r readOnlySearcher) Unwrap() model.POSReader {
+	return r.next
+}
+
+// readOnlyLedger is a readOnlyAdapter that keeps the stock ledger
+type readOnlyLedger struct {
+	readOnlyAdapter
+	model.StockLedger
+	next model.POSReader
+}
+
+func (r readOnlyLedger) Unwrap() model.POSReader {
+	return r.next
+}
+
+// readOnlyPriceHistory is a readOnlyAdapter that keeps price history
+type readOnlyPriceHistory struct {
+	readOnlyAdapter
+	model.PriceHistory
+	next model.POSReader
+}
+
+func (r readOnlyPriceHistory) Unwrap() model.POSReader {
+	return r.next
+}
+
+// ReadOnly - Hides the write capabilities of an adapter and keeps every read
+// one (aggregation, search, the stock ledger and price history), so write
+// routes answer 501 instead of changing a POS the server must only read
+func ReadOnly(reader model.POSReader) model.POSReader {
+	readers := readOnlyAdapter{InventoryReader: reader, OrderReader: reader}
+
+	var wrapped model.POSReader = readers
+	if history, ok := model.As[model.PriceHistory](reader); ok {
+		wrapped = readOnlyPriceHistory{readOnlyAdapter: readers, PriceHistory: history, next: wrapped}
+	}
+	if ledger, ok := model.As[model.StockLedger](reader); ok {
+		wrapped = readOnlyLedger{readOnlyAdapter: readers, StockLedger: ledger, next: wrapped}
+	}
+	if searcher, ok := model.As[model.ItemSearcher](reader); ok {
+		wrapped = readOnlySearcher{readOnlyAdapter: readers, ItemSearcher: searcher, next: wrapped}
+	}
+	if aggregator, ok := model.As[model.SalesAggregator](reader); ok {
+		wrapped = readOnlyAggregator{readOnlyAdapter: readers, SalesAggregator: aggregator, next: wrapped}
+	}
+	return wrapped
+}
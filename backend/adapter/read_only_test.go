@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"testing"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+func TestReadOnlyKeepsReadCapabilities(t *testing.T) {
+	memory := NewMemoryPosAdapter("IDR")
+	if err := memory.AddItem(testItem("ITEM-A", 10, 1000, "IDR")); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		reader model.POSReader
+	}{
+		{name: "every capability", reader: memory},
+		{name: "readers only", reader: struct{ model.POSReader }{memory}},
+		{name: "behind a cache", reader: NewCachingAdapter(memory, CacheOptions{}).Adapter()},
+	}
+
+	for _, tt := range tests {
+		want := model.CapabilitiesOf(tt.reader)
+		want.InventoryWriter, want.OrderWriter, want.OversellPolicy = false, false, false
+
+		readOnly := ReadOnly(tt.reader)
+		if got := model.CapabilitiesOf(readOnly); got != want {
+			t.Errorf("%s: CapabilitiesOf(ReadOnly) = %+v, want %+v", tt.name, got, want)
+		}
+
+		// The kept capabilities still reach the wrapped adapter
+		if searcher, ok := model.As[model.ItemSearcher](readOnly); ok {
+			if items, err := searcher.SearchItems("item-a", 0); err != nil || len(items) != 1 {
+				t.Errorf("%s: SearchItems = %d items, %v, want ITEM-A", tt.name, len(items), err)
+			}
+		}
+		if ledger, ok := model.As[model.StockLedger](readOnly); ok {
+			if stock, err := ledger.GetLedgerStock("ITEM-A"); err != nil || stock != 10 {
+				t.Errorf("%s: GetLedgerStock = %d, %v, want 10", tt.name, stock, err)
+			}
+		}
+	}
+}
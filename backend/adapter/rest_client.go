@@ -0,0 +1,207 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxResponseSize caps how much of a response body is read
+const maxResponseSize = 32 << 20
+
+// restStatusError is a non-2xx response from the vendor API
+type restStatusError struct {
+	Method string
+	URL    string
+	Status int
+	Body   string
+}
+
+func (e *restStatusError) Error() string {
+	return fmt.Sprintf("POS API %s %s returned %d: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// isNotFound reports whether err is a 404 from the vendor API
+func isNotFound(err error) bool {
+	var statusErr *restStatusError
+	return errors.As(err, &statusErr) && statusErr.Status == http.StatusNotFound
+}
+
+// request - Sends a request to the vendor API and decodes the JSON response
+// (numbers as json.Number). Rate-limited requests (429) are retried after the
+// server's Retry-After delay; server errors and network failures are retried
+// with exponential backoff for idempotent methods only, since a failed POST
+// may still have been applied.
+func (r *RESTPosAdapter) request(method, endpoint string, query url.Values, payload interface{}) (interface{}, error) {
+	target, err := r.resolve(endpoint, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var body []byte
+	if payload != nil {
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, fmt.Errorf("failed to encode request to %s: %w", target, err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, header, data, err := r.send(method, target, body)
+		if err == nil && status < 300 {
+			return decodeResponse(data)
+		}
+
+		if err == nil {
+			err = &restStatusError{Method: method, URL: target, Status: status, Body: truncate(strings.TrimSpace(string(data)), 200)}
+		}
+
+		delay, retry := r.retryDelay(method, status, header, attempt)
+		if !retry || attempt >= r.config.MaxRetries {
+			return nil, err
+		}
+
+		log.Printf("POS API request failed (%v), retrying in %s", err, delay)
+		time.Sleep(delay)
+	}
+}
+
+// send - Performs one attempt and reads the whole response. A status of 0
+// means the request failed before a response arrived.
+func (r *RESTPosAdapter) send(method, target string, body []byte) (int, http.Header, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.config.APIKey != "" {
+		value := r.config.APIKey
+		if r.config.AuthScheme != "" {
+			value = r.config.AuthScheme + " " + value
+		}
+		req.Header.Set(r.config.AuthHeader, value)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to call POS API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("failed to read POS API response: %w", err)
+	}
+
+	return resp.StatusCode, resp.Header, data, nil
+}
+
+// retryDelay - Decides whether a failed attempt is retried and after how long
+func (r *RESTPosAdapter) retryDelay(method string, status int, header http.Header, attempt int) (time.Duration, bool) {
+	idempotent := method != http.MethodPost
+	switch {
+	case status == http.StatusTooManyRequests:
+	case (status == 0 || status >= 500) && idempotent:
+	default:
+		return 0, false
+	}
+
+	if delay, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		return min(delay, r.config.MaxRetryDelay), true
+	}
+
+	// Exponential backoff with jitter so concurrent callers spread out
+	delay := r.config.RetryDelay << attempt
+	if delay <= 0 || delay > r.config.MaxRetryDelay {
+		delay = r.config.MaxRetryDelay
+	}
+	delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	return delay, true
+}
+
+// parseRetryAfter - Reads a Retry-After header in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// resolve - Builds the URL of an endpoint. Endpoints are paths appended to the
+// base URL; absolute URLs (next-page links) must point at the same host so
+// the API key is never sent elsewhere.
+func (r *RESTPosAdapter) resolve(endpoint string, query url.Values) (string, error) {
+	var target *url.URL
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		parsed, err := url.Parse(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid POS API URL %q: %w", endpoint, err)
+		}
+		if parsed.Host != r.baseURL.Host {
+			return "", fmt.Errorf("refusing to follow POS API link to another host: %s", parsed.Host)
+		}
+		target = parsed
+	} else {
+		parsed, err := url.Parse(strings.TrimSuffix(r.baseURL.String(), "/") + "/" + strings.TrimPrefix(endpoint, "/"))
+		if err != nil {
+			return "", fmt.Errorf("invalid POS API path %q: %w", endpoint, err)
+		}
+		target = parsed
+	}
+
+	if len(query) > 0 {
+		values := target.Query()
+		for key, list := range query {
+			values[key] = list
+		}
+		target.RawQuery = values.Encode()
+	}
+
+	return target.String(), nil
+}
+
+// decodeResponse - Decodes a JSON body; an empty body decodes to nil
+func decodeResponse(data []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON from POS API: %w", err)
+	}
+	return value, nil
+}
+
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	return text[:limit] + "..."
+}
@@ -0,0 +1,608 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// RESTItemMapping - Where the vendor API lists items and where each model.Item
+// field is in an item record. Field paths are JSONPath-style expressions
+// relative to the record, e.g. "$.name", "$.pricing.amount",
+// "$.variants[0].sku" or "$['unit price']". A path left empty uses the
+// field's JSON name in this API ("$.production_price"), so a vendor that
+// already speaks our format needs no mapping.
+type RESTItemMapping struct {
+	ListPath string `yaml:"list_path" toml:"list_path"` // endpoint listing items, e.g. /v2/items
+	GetPath  string `yaml:"get_path" toml:"get_path"`   // endpoint of one item, e.g. /v2/items/{id}
+	Records  string `yaml:"records" toml:"records"`     // items in a list response (default $, the body is an array)
+	Record   string `yaml:"record" toml:"record"`       // the item in a single-item response (default $)
+
+	ID              string `yaml:"id" toml:"id"`
+	Name            string `yaml:"name" toml:"name"`
+	Stock           string `yaml:"stock" toml:"stock"`
+	Price           string `yaml:"price" toml:"price"`
+	ProductionPrice string `yaml:"production_price" toml:"production_price"`
+	Currency        string `yaml:"currency" toml:"currency"` // optional, the configured currency otherwise
+}
+
+// RESTOrderMapping - Where the vendor API lists orders and where each
+// model.Order field is in an order record. Line paths are relative to one
+// element of Lines. A missing total is computed from the lines.
+type RESTOrderMapping struct {
+	ListPath   string `yaml:"list_path" toml:"list_path"`
+	GetPath    string `yaml:"get_path" toml:"get_path"`
+	Records    string `yaml:"records" toml:"records"`
+	Record     string `yaml:"record" toml:"record"`
+	SinceParam string `yaml:"since_param" toml:"since_param"` // optional query parameter taking the earliest completion time
+
+	ID          string `yaml:"id" toml:"id"`
+	Total       string `yaml:"total" toml:"total"`
+	CompletedAt string `yaml:"completed_at" toml:"completed_at"`
+	Currency    string `yaml:"currency" toml:"currency"`
+	Lines       string `yaml:"lines" toml:"lines"`
+
+	LineItemID    string `yaml:"line_item_id" toml:"line_item_id"`
+	LineQuantity  string `yaml:"line_quantity" toml:"line_quantity"`
+	LineUnitPrice string `yaml:"line_unit_price" toml:"line_unit_price"`
+	LineUnitCost  string `yaml:"line_unit_cost" toml:"line_unit_cost"`
+	LineDiscount  string `yaml:"line_discount" toml:"line_discount"`
+}
+
+// jsonPath is a parsed JSONPath-style expression. Supported: the root $, child
+// keys (.key or ['key']), array indexes ([0]) and wildcards (.* or [*]).
+type jsonPath []pathSegment
+
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// parseJSONPath - Parses an expression; the leading $ may be omitted ("name")
+func parseJSONPath(expression string) (jsonPath, error) {
+	rest := strings.TrimSpace(expression)
+	if rest == "" {
+		return nil, errors.New("empty path")
+	}
+	rest = strings.TrimPrefix(rest, "$")
+	if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		rest = "." + rest
+	}
+
+	var path jsonPath
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("invalid path %q: empty key", expression)
+			case "*":
+				path = append(path, pathSegment{wildcard: true})
+			default:
+				path = append(path, pathSegment{key: key})
+			}
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expression)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			// A quoted key may itself contain ]
+			if len(inner) > 0 && (inner[0] == '\'' || inner[0] == '"') {
+				closing := strings.IndexByte(rest[2:], rest[1])
+				if closing < 0 || !strings.HasPrefix(rest[2+closing+1:], "]") {
+					return nil, fmt.Errorf("invalid path %q: unterminated key", expression)
+				}
+				path = append(path, pathSegment{key: rest[2 : 2+closing]})
+				rest = rest[2+closing+2:]
+				continue
+			}
+			rest = rest[end+1:]
+			if inner == "*" {
+				path = append(path, pathSegment{wildcard: true})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", expression, inner)
+			}
+			path = append(path, pathSegment{index: index, isIndex: true})
+		default:
+			return nil, fmt.Errorf("invalid path %q at %q", expression, rest)
+		}
+	}
+
+	return path, nil
+}
+
+// find - Returns every value the path matches
+func (p jsonPath) find(value interface{}) []interface{} {
+	current := []interface{}{value}
+	for _, segment := range p {
+		var next []interface{}
+		for _, node := range current {
+			switch typed := node.(type) {
+			case map[string]interface{}:
+				if segment.wildcard {
+					for _, child := range typed {
+						next = append(next, child)
+					}
+				} else if child, exists := typed[segment.key]; exists && !segment.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				if segment.wildcard {
+					next = append(next, typed...)
+				} else if segment.isIndex && segment.index < len(typed) {
+					next = append(next, typed[segment.index])
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// first - Returns the first non-null match
+func (p jsonPath) first(value interface{}) (interface{}, bool) {
+	for _, match := range p.find(value) {
+		if match != nil {
+			return match, true
+		}
+	}
+	return nil, false
+}
+
+// records - Returns the records a list path points to. A path that matches a
+// single array yields its elements.
+func (p jsonPath) records(value interface{}) []interface{} {
+	matches := p.find(value)
+	if len(matches) == 1 {
+		if array, ok := matches[0].([]interface{}); ok {
+			return array
+		}
+		if matches[0] == nil {
+			return nil
+		}
+	}
+	return matches
+}
+
+// set - Writes value at the path, creating objects on the way. Only key
+// segments can be written.
+func (p jsonPath) set(object map[string]interface{}, value interface{}) error {
+	if len(p) == 0 {
+		return errors.New("cannot write to the root of a record")
+	}
+	for i, segment := range p {
+		if segment.isIndex || segment.wildcard {
+			return errors.New("cannot write through an index or wildcard")
+		}
+		if i == len(p)-1 {
+			object[segment.key] = value
+			return nil
+		}
+		child, ok := object[segment.key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			object[segment.key] = child
+		}
+		object = child
+	}
+	return nil
+}
+
+// restFormat - How scalar values are written by the vendor
+type restFormat struct {
+	currency   string
+	moneyUnits string // "major" or "minor"
+	timeFormat string // "rfc3339", "unix", "unix_ms" or a Go layout
+}
+
+func (f restFormat) toString(value interface{}) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case json.Number:
+		return typed.String(), nil
+	case bool:
+		return strconv.FormatBool(typed), nil
+	default:
+		return "", fmt.Errorf("expected a string, got %T", value)
+	}
+}
+
+func (f restFormat) toInt(value interface{}) (int, error) {
+	text, err := f.toString(value)
+	if err != nil {
+		return 0, err
+	}
+	text = strings.TrimSpace(text)
+	if parsed, err := strconv.Atoi(text); err == nil {
+		return parsed, nil
+	}
+	// Some APIs send counts as 3.0
+	parsed, err := strconv.ParseFloat(text, 64)
+	if err != nil || parsed != math.Trunc(parsed) {
+		return 0, fmt.Errorf("invalid whole number %q", text)
+	}
+	return int(parsed), nil
+}
+
+func (f restFormat) toMoney(value interface{}, currency string) (model.Money, error) {
+	text, err := f.toString(value)
+	if err != nil {
+		return model.Money{}, err
+	}
+	text = strings.TrimSpace(text)
+	if f.moneyUnits == "minor" {
+		minorUnits, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return model.Money{}, fmt.Errorf("invalid amount in minor units %q", text)
+		}
+		return model.NewMoney(minorUnits, currency), nil
+	}
+	// json.Number keeps the decimal form, but large or tiny floats may use an exponent
+	if strings.ContainsAny(text, "eE") {
+		parsed, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return model.Money{}, fmt.Errorf("invalid amount %q", text)
+		}
+		return model.MoneyFromFloat(parsed, currency), nil
+	}
+	return model.ParseMoney(text, currency)
+}
+
+func (f restFormat) fromMoney(amount model.Money) interface{} {
+	if f.moneyUnits == "minor" {
+		return amount.Amount
+	}
+	return json.Number(amount.String())
+}
+
+func (f restFormat) toTime(value interface{}) (time.Time, error) {
+	text, err := f.toString(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	text = strings.TrimSpace(text)
+
+	switch f.timeFormat {
+	case "unix", "unix_ms":
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", text)
+		}
+		if f.timeFormat == "unix_ms" {
+			return time.UnixMilli(int64(number)).UTC(), nil
+		}
+		seconds, fraction := math.Modf(number)
+		return time.Unix(int64(seconds), int64(fraction*1e9)).UTC(), nil
+	case "rfc3339":
+		return time.Parse(time.RFC3339Nano, text)
+	default:
+		return time.Parse(f.timeFormat, text)
+	}
+}
+
+func (f restFormat) fromTime(t time.Time) interface{} {
+	switch f.timeFormat {
+	case "unix":
+		return t.Unix()
+	case "unix_ms":
+		return t.UnixMilli()
+	case "rfc3339":
+		return t.UTC().Format(time.RFC3339Nano)
+	default:
+		return t.UTC().Format(f.timeFormat)
+	}
+}
+
+// itemFields - The compiled paths of a RESTItemMapping
+type itemFields struct {
+	records, record                                   jsonPath
+	id, name, stock, price, productionPrice, currency jsonPath
+}
+
+// orderFields - The compiled paths of a RESTOrderMapping
+type orderFields struct {
+	records, record                                                     jsonPath
+	id, total, completedAt, currency, lines                             jsonPath
+	lineItemID, lineQuantity, lineUnitPrice, lineUnitCost, lineDiscount jsonPath
+}
+
+// pathSpec - A mapping expression, its default and where to store the result
+type pathSpec struct {
+	expression string
+	fallback   string
+	target     *jsonPath
+}
+
+// compilePaths - Parses each expression into its target, using the default
+// when the expression is empty. An empty default leaves the field unmapped.
+func compilePaths(section string, paths map[string]pathSpec) error {
+	var problems []error
+	for name, path := range paths {
+		expression := path.expression
+		if expression == "" {
+			expression = path.fallback
+		}
+		if expression == "" {
+			continue
+		}
+		parsed, err := parseJSONPath(expression)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s.%s: %w", section, name, err))
+			continue
+		}
+		*path.target = parsed
+	}
+	return errors.Join(problems...)
+}
+
+func compileItemMapping(mapping RESTItemMapping) (itemFields, error) {
+	var fields itemFields
+	err := compilePaths("items", map[string]pathSpec{
+		"records":          {mapping.Records, "$", &fields.records},
+		"record":           {mapping.Record, "$", &fields.record},
+		"id":               {mapping.ID, "$.id", &fields.id},
+		"name":             {mapping.Name, "$.name", &fields.name},
+		"stock":            {mapping.Stock, "$.stock", &fields.stock},
+		"price":            {mapping.Price, "$.price", &fields.price},
+		"production_price": {mapping.ProductionPrice, "$.production_price", &fields.productionPrice},
+		"currency":         {mapping.Currency, "", &fields.currency},
+	})
+	return fields, err
+}
+
+func compileOrderMapping(mapping RESTOrderMapping) (orderFields, error) {
+	var fields orderFields
+	err := compilePaths("orders", map[string]pathSpec{
+		"records":         {mapping.Records, "$", &fields.records},
+		"record":          {mapping.Record, "$", &fields.record},
+		"id":              {mapping.ID, "$.id", &fields.id},
+		"total":           {mapping.Total, "$.total", &fields.total},
+		"completed_at":    {mapping.CompletedAt, "$.completed_at", &fields.completedAt},
+		"currency":        {mapping.Currency, "", &fields.currency},
+		"lines":           {mapping.Lines, "$.items", &fields.lines},
+		"line_item_id":    {mapping.LineItemID, "$.item_id", &fields.lineItemID},
+		"line_quantity":   {mapping.LineQuantity, "$.quantity", &fields.lineQuantity},
+		"line_unit_price": {mapping.LineUnitPrice, "$.unit_price", &fields.lineUnitPrice},
+		"line_unit_cost":  {mapping.LineUnitCost, "$.unit_cost", &fields.lineUnitCost},
+		"line_discount":   {mapping.LineDiscount, "$.discount", &fields.lineDiscount},
+	})
+	return fields, err
+}
+
+// recordCurrency - The currency at path in the record, or the default
+func (f restFormat) recordCurrency(record interface{}, path jsonPath) string {
+	if path != nil {
+		if value, found := path.first(record); found {
+			if currency, err := f.toString(value); err == nil && currency != "" {
+				return strings.ToUpper(currency)
+			}
+		}
+	}
+	return f.currency
+}
+
+// decodeItem - Maps an item record to a model.Item
+func (f restFormat) decodeItem(fields itemFields, record interface{}) (model.Item, error) {
+	currency := f.recordCurrency(record, fields.currency)
+	item := model.Item{Price: model.NewMoney(0, currency), ProductionPrice: model.NewMoney(0, currency)}
+
+	value, found := fields.id.first(record)
+	if !found {
+		return model.Item{}, errors.New("item record has no ID")
+	}
+	id, err := f.toString(value)
+	if err != nil || id == "" {
+		return model.Item{}, fmt.Errorf("invalid item ID: %v", value)
+	}
+	item.ID = id
+
+	if value, found := fields.name.first(record); found {
+		if item.Name, err = f.toString(value); err != nil {
+			return model.Item{}, fmt.Errorf("item %s: name: %w", id, err)
+		}
+	}
+	if value, found := fields.stock.first(record); found {
+		if item.Stock, err = f.toInt(value); err != nil {
+			return model.Item{}, fmt.Errorf("item %s: stock: %w", id, err)
+		}
+	}
+	if value, found := fields.price.first(record); found {
+		if item.Price, err = f.toMoney(value, currency); err != nil {
+			return model.Item{}, fmt.Errorf("item %s: price: %w", id, err)
+		}
+	}
+	if value, found := fields.productionPrice.first(record); found {
+		if item.ProductionPrice, err = f.toMoney(value, currency); err != nil {
+			return model.Item{}, fmt.Errorf("item %s: production price: %w", id, err)
+		}
+	}
+
+	return item, nil
+}
+
+// decodeOrder - Maps an order record to a model.Order
+func (f restFormat) decodeOrder(fields orderFields, record interface{}) (model.Order, error) {
+	currency := f.recordCurrency(record, fields.currency)
+
+	value, found := fields.id.first(record)
+	if !found {
+		return model.Order{}, errors.New("order record has no ID")
+	}
+	id, err := f.toString(value)
+	if err != nil || id == "" {
+		return model.Order{}, fmt.Errorf("invalid order ID: %v", value)
+	}
+	order := model.Order{ID: id, Items: []model.OrderItem{}}
+
+	value, found = fields.completedAt.first(record)
+	if !found {
+		return model.Order{}, fmt.Errorf("order %s has no completion time", id)
+	}
+	if order.CompletedAt, err = f.toTime(value); err != nil {
+		return model.Order{}, fmt.Errorf("order %s: completion time: %w", id, err)
+	}
+
+	for i, line := range fields.lines.records(record) {
+		decoded, err := f.decodeLine(fields, line, currency)
+		if err != nil {
+			return model.Order{}, fmt.Errorf("order %s: line %d: %w", id, i+1, err)
+		}
+		order.Items = append(order.Items, decoded)
+	}
+
+	if value, found := fields.total.first(record); found {
+		if order.Total, err = f.toMoney(value, currency); err != nil {
+			return model.Order{}, fmt.Errorf("order %s: total: %w", id, err)
+		}
+	} else {
+		order.Total = model.NewMoney(0, currency)
+		for i, line := range order.Items {
+			revenue, err := line.Revenue()
+			if err == nil {
+				order.Total, err = order.Total.Add(revenue)
+			}
+			if err != nil {
+				return model.Order{}, fmt.Errorf("order %s: line %d: %w", id, i+1, err)
+			}
+		}
+	}
+
+	return order, nil
+}
+
+func (f restFormat) decodeLine(fields orderFields, record interface{}, currency string) (model.OrderItem, error) {
+	line := model.OrderItem{
+		UnitPrice: model.NewMoney(0, currency),
+		UnitCost:  model.NewMoney(0, currency),
+		Discount:  model.NewMoney(0, currency),
+	}
+
+	value, found := fields.lineItemID.first(record)
+	if !found {
+		return line, errors.New("no item ID")
+	}
+	itemID, err := f.toString(value)
+	if err != nil {
+		return line, fmt.Errorf("item ID: %w", err)
+	}
+	line.ItemID = itemID
+
+	line.Quantity = 1
+	if value, found := fields.lineQuantity.first(record); found {
+		if line.Quantity, err = f.toInt(value); err != nil {
+			return line, fmt.Errorf("quantity: %w", err)
+		}
+	}
+
+	amounts := []struct {
+		path   jsonPath
+		target *model.Money
+		name   string
+	}{
+		{fields.lineUnitPrice, &line.UnitPrice, "unit price"},
+		{fields.lineUnitCost, &line.UnitCost, "unit cost"},
+		{fields.lineDiscount, &line.Discount, "discount"},
+	}
+	for _, amount := range amounts {
+		if value, found := amount.path.first(record); found {
+			if *amount.target, err = f.toMoney(value, currency); err != nil {
+				return line, fmt.Errorf("%s: %w", amount.name, err)
+			}
+		}
+	}
+
+	return line, nil
+}
+
+// encodeItem - Builds the request body for an item from the same mapping
+func (f restFormat) encodeItem(fields itemFields, item model.Item) (map[string]interface{}, error) {
+	body := make(map[string]interface{})
+	values := []struct {
+		path  jsonPath
+		value interface{}
+	}{
+		{fields.id, item.ID},
+		{fields.name, item.Name},
+		{fields.stock, item.Stock},
+		{fields.price, f.fromMoney(item.Price)},
+		{fields.productionPrice, f.fromMoney(item.ProductionPrice)},
+		{fields.currency, item.Price.CurrencyCode()},
+	}
+	for _, field := range values {
+		if field.path == nil {
+			continue
+		}
+		if err := field.path.set(body, field.value); err != nil {
+			return nil, fmt.Errorf("failed to encode item %s: %w", item.ID, err)
+		}
+	}
+	return body, nil
+}
+
+// encodeOrder - Builds the request body for an order from the same mapping
+func (f restFormat) encodeOrder(fields orderFields, order model.Order) (map[string]interface{}, error) {
+	lines := make([]interface{}, 0, len(order.Items))
+	for _, line := range order.Items {
+		encoded := make(map[string]interface{})
+		values := []struct {
+			path  jsonPath
+			value interface{}
+		}{
+			{fields.lineItemID, line.ItemID},
+			{fields.lineQuantity, line.Quantity},
+			{fields.lineUnitPrice, f.fromMoney(line.UnitPrice)},
+			{fields.lineUnitCost, f.fromMoney(line.UnitCost)},
+			{fields.lineDiscount, f.fromMoney(line.Discount)},
+		}
+		for _, field := range values {
+			if field.path == nil {
+				continue
+			}
+			if err := field.path.set(encoded, field.value); err != nil {
+				return nil, fmt.Errorf("failed to encode order %s: %w", order.ID, err)
+			}
+		}
+		lines = append(lines, encoded)
+	}
+
+	body := make(map[string]interface{})
+	values := []struct {
+		path  jsonPath
+		value interface{}
+	}{
+		{fields.id, order.ID},
+		{fields.total, f.fromMoney(order.Total)},
+		{fields.completedAt, f.fromTime(order.CompletedAt)},
+		{fields.currency, order.Total.CurrencyCode()},
+		{fields.lines, lines},
+	}
+	for _, field := range values {
+		if field.path == nil {
+			continue
+		}
+		if err := field.path.set(body, field.value); err != nil {
+			return nil, fmt.Errorf("failed to encode order %s: %w", order.ID, err)
+		}
+	}
+	return body, nil
+}
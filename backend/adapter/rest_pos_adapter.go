@@ -0,0 +1,562 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// maxPages stops a list that never reports its last page
+const maxPages = 10000
+
+// RESTConfig describes a vendor's POS API. Zero fields get the defaults listed
+// below.
+type RESTConfig struct {
+	BaseURL    string // e.g. https://api.vendor.example/v2
+	APIKey     string
+	AuthHeader string // header carrying the key (default Authorization)
+	AuthScheme string // written before the key (default Bearer when the header is Authorization)
+
+	Timeout       time.Duration // per attempt (default 15s)
+	MaxRetries    int           // retries after the first attempt (default 3, -1 for none)
+	RetryDelay    time.Duration // first backoff, doubled on every retry (default 500ms)
+	MaxRetryDelay time.Duration // longest wait, including Retry-After (default 30s)
+
+	Currency   string // currency of records without one (default the business currency)
+	MoneyUnits string // "major" amounts such as 12.50 (default) or "minor" units such as 1250
+	TimeFormat string // "rfc3339" (default), "unix", "unix_ms" or a Go time layout
+	ReadOnly   bool   // expose only the read capabilities, for APIs without write access
+
+	Pagination RESTPagination
+	Items      RESTItemMapping
+	Orders     RESTOrderMapping
+}
+
+// RESTPagination - How list endpoints are paged. Styles:
+//   - "none": one request returns everything (default)
+//   - "page": PageParam is the page number from 1, a short page is the last
+//   - "offset": PageParam is the number of records to skip, a short page is the last
+//   - "cursor": CursorParam is the value at NextPath in the previous page, until it is empty
+//   - "next_url": NextPath holds the URL of the next page, until it is empty
+type RESTPagination struct {
+	Style       string `yaml:"style" toml:"style"`
+	PageParam   string `yaml:"page_param" toml:"page_param"`     // default page or offset
+	SizeParam   string `yaml:"size_param" toml:"size_param"`     // default limit
+	PageSize    int    `yaml:"page_size" toml:"page_size"`       // default 100
+	CursorParam string `yaml:"cursor_param" toml:"cursor_param"` // default cursor
+	NextPath    string `yaml:"next_path" toml:"next_path"`       // required for cursor and next_url
+}
+
+// withDefaults fills in the zero fields of a config
+func (c RESTConfig) withDefaults() RESTConfig {
+	if c.AuthHeader == "" {
+		c.AuthHeader = "Authorization"
+	}
+	if c.AuthScheme == "" && strings.EqualFold(c.AuthHeader, "Authorization") {
+		c.AuthScheme = "Bearer"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 15 * time.Second
+	}
+	switch {
+	case c.MaxRetries == 0:
+		c.MaxRetries = 3
+	case c.MaxRetries < 0:
+		c.MaxRetries = 0
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 500 * time.Millisecond
+	}
+	if c.MaxRetryDelay <= 0 {
+		c.MaxRetryDelay = 30 * time.Second
+	}
+	c.Currency = normalizeCurrency(c.Currency)
+	if c.MoneyUnits == "" {
+		c.MoneyUnits = "major"
+	}
+	if c.TimeFormat == "" {
+		c.TimeFormat = "rfc3339"
+	}
+
+	paging := &c.Pagination
+	if paging.Style == "" {
+		paging.Style = "none"
+	}
+	if paging.PageParam == "" {
+		paging.PageParam = "page"
+		if paging.Style == "offset" {
+			paging.PageParam = "offset"
+		}
+	}
+	if paging.SizeParam == "" {
+		paging.SizeParam = "limit"
+	}
+	if paging.PageSize <= 0 {
+		paging.PageSize = 100
+	}
+	if paging.CursorParam == "" {
+		paging.CursorParam = "cursor"
+	}
+	return c
+}
+
+// Validate - Checks a config before any request is made and reports every
+// problem at once
+func (c RESTConfig) Validate() error {
+	_, err := NewRESTPosAdapter(c)
+	return err
+}
+
+// RESTPosAdapter reads and writes POS data through a vendor's HTTP API,
+// described entirely by a RESTConfig so a new vendor needs configuration
+// rather than code. Filtering, sorting and pagination of order queries happen
+// here after the orders are fetched. Batch writes only support partial mode:
+// each record is sent on its own, a failed one is reported in the result and
+// the rest are still sent. It is safe for concurrent use.
+type RESTPosAdapter struct {
+	config  RESTConfig
+	baseURL *url.URL
+	client  *http.Client
+	format  restFormat
+	items   itemFields
+	orders  orderFields
+	next    jsonPath
+}
+
+func NewRESTPosAdapter(config RESTConfig) (*RESTPosAdapter, error) {
+	config = config.withDefaults()
+	var problems []error
+
+	baseURL, err := url.Parse(config.BaseURL)
+	if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		problems = append(problems, fmt.Errorf("base URL %q must be an absolute http(s) URL", config.BaseURL))
+	}
+	if config.Items.ListPath == "" {
+		problems = append(problems, errors.New("items.list_path is required"))
+	}
+	if config.Orders.ListPath == "" {
+		problems = append(problems, errors.New("orders.list_path is required"))
+	}
+	if config.MoneyUnits != "major" && config.MoneyUnits != "minor" {
+		problems = append(problems, fmt.Errorf("money units %q must be major or minor", config.MoneyUnits))
+	}
+
+	items, err := compileItemMapping(config.Items)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	orders, err := compileOrderMapping(config.Orders)
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	var next jsonPath
+	switch config.Pagination.Style {
+	case "none", "page", "offset":
+	case "cursor", "next_url":
+		if next, err = parseJSONPath(config.Pagination.NextPath); err != nil {
+			problems = append(problems, fmt.Errorf("pagination.next_path: %w", err))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("pagination style %q must be none, page, offset, cursor or next_url", config.Pagination.Style))
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid REST POS config: %w", errors.Join(problems...))
+	}
+
+	return &RESTPosAdapter{
+		config:  config,
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: config.Timeout},
+		format:  restFormat{currency: config.Currency, moneyUnits: config.MoneyUnits, timeFormat: config.TimeFormat},
+		items:   items,
+		orders:  orders,
+		next:    next,
+	}, nil
+}
+
+// list - Fetches every page of a list endpoint and returns the records
+func (r *RESTPosAdapter) list(endpoint string, records jsonPath, query url.Values) ([]interface{}, error) {
+	paging := r.config.Pagination
+	var all []interface{}
+	page, offset, next := 1, 0, ""
+
+	for pages := 0; pages < maxPages; pages++ {
+		target := endpoint
+		values := url.Values{}
+		for key, list := range query {
+			values[key] = list
+		}
+
+		switch paging.Style {
+		case "page":
+			values.Set(paging.PageParam, strconv.Itoa(page))
+			values.Set(paging.SizeParam, strconv.Itoa(paging.PageSize))
+		case "offset":
+			values.Set(paging.PageParam, strconv.Itoa(offset))
+			values.Set(paging.SizeParam, strconv.Itoa(paging.PageSize))
+		case "cursor":
+			values.Set(paging.SizeParam, strconv.Itoa(paging.PageSize))
+			if next != "" {
+				values.Set(paging.CursorParam, next)
+			}
+		case "next_url":
+			if next != "" {
+				// The link already carries every parameter; a relative link
+				// is relative to the API host, not to the base path
+				link, err := url.Parse(next)
+				if err != nil {
+					return nil, fmt.Errorf("invalid next page link %q: %w", next, err)
+				}
+				target, values = r.baseURL.ResolveReference(link).String(), nil
+			} else {
+				values.Set(paging.SizeParam, strconv.Itoa(paging.PageSize))
+			}
+		}
+
+		body, err := r.request(http.MethodGet, target, values, nil)
+		if err != nil {
+			return nil, err
+		}
+		batch := records.records(body)
+		all = append(all, batch...)
+
+		switch paging.Style {
+		case "page":
+			if len(batch) < paging.PageSize {
+				return all, nil
+			}
+			page++
+		case "offset":
+			if len(batch) < paging.PageSize {
+				return all, nil
+			}
+			offset += len(batch)
+		case "cursor", "next_url":
+			next = ""
+			if value, found := r.next.first(body); found {
+				next, _ = r.format.toString(value)
+			}
+			if next == "" || len(batch) == 0 {
+				return all, nil
+			}
+		default:
+			return all, nil
+		}
+	}
+
+	return nil, fmt.Errorf("listing %s returned more than %d pages", endpoint, maxPages)
+}
+
+// resourcePath - Fills the {id} placeholder of an endpoint
+func resourcePath(endpoint, id string) string {
+	return strings.ReplaceAll(endpoint, "{id}", url.PathEscape(id))
+}
+
+func (r *RESTPosAdapter) GetInventory() ([]model.Item, error) {
+	records, err := r.list(r.config.Items.ListPath, r.items.records, nil)
+	if err != nil {
+		log.Printf("Failed to fetch inventory from POS API: %v", err)
+		return nil, fmt.Errorf("failed to fetch inventory: %w", err)
+	}
+
+	items := make([]model.Item, 0, len(records))
+	for _, record := range records {
+		item, err := r.format.decodeItem(r.items, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map item: %w", err)
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Name != items[j].Name {
+			return items[i].Name < items[j].Name
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// GetItemByID - Fetches one item, or searches the inventory when the API has
+// no single-item endpoint
+func (r *RESTPosAdapter) GetItemByID(itemID string) (*model.Item, error) {
+	if r.config.Items.GetPath == "" {
+		items, err := r.GetInventory()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if item.ID == itemID {
+				return &item, nil
+			}
+		}
+		return nil, &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+
+	body, err := r.request(http.MethodGet, resourcePath(r.config.Items.GetPath, itemID), nil, nil)
+	if isNotFound(err) {
+		return nil, &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch item %s: %w", itemID, err)
+	}
+
+	record, found := r.items.record.first(body)
+	if !found {
+		return nil, &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+	item, err := r.format.decodeItem(r.items, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map item %s: %w", itemID, err)
+	}
+
+	return &item, nil
+}
+
+// CheckItemExists - Helper method to check if an item exists
+func (r *RESTPosAdapter) CheckItemExists(itemID string) (bool, error) {
+	_, err := r.GetItemByID(itemID)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddItem - Creates the item with a POST to the item list endpoint
+func (r *RESTPosAdapter) AddItem(item model.Item) error {
+	body, err := r.format.encodeItem(r.items, item)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.request(http.MethodPost, r.config.Items.ListPath, nil, body); err != nil {
+		log.Printf("Failed to add item %s through POS API: %v", item.ID, err)
+		return fmt.Errorf("failed to add item %s: %w", item.ID, err)
+	}
+
+	log.Printf("Successfully added item: %s", item.ID)
+	return nil
+}
+
+// AddItems - Posts the items one by one. The API has no transactions, so
+// only partial batches are supported.
+func (r *RESTPosAdapter) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
+	result := newBatchResult(mode)
+	if result.Mode != model.BatchPartial {
+		return result, fmt.Errorf("%w: %s batches need a transactional store", model.ErrBatchModeUnsupported, result.Mode)
+	}
+
+	for i, item := range items {
+		if err := r.AddItem(item); err != nil {
+			result.Fail(i, item.ID, err)
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, item.ID)
+	}
+	return result, nil
+}
+
+// UpdateItem - Replaces the item with a PUT to its endpoint
+func (r *RESTPosAdapter) UpdateItem(item model.Item) error {
+	if r.config.Items.GetPath == "" {
+		return errors.New("the POS API has no item endpoint configured (items.get_path)")
+	}
+
+	body, err := r.format.encodeItem(r.items, item)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.request(http.MethodPut, resourcePath(r.config.Items.GetPath, item.ID), nil, body)
+	if isNotFound(err) {
+		return &model.NotFoundError{Resource: "item", ID: item.ID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update item %s: %w", item.ID, err)
+	}
+
+	log.Printf("Successfully updated item: %s", item.ID)
+	return nil
+}
+
+// DeleteItem - Deletes the item through its endpoint. The vendor decides
+// whether items referenced by orders can be deleted.
+func (r *RESTPosAdapter) DeleteItem(itemID string) error {
+	if r.config.Items.GetPath == "" {
+		return errors.New("the POS API has no item endpoint configured (items.get_path)")
+	}
+
+	_, err := r.request(http.MethodDelete, resourcePath(r.config.Items.GetPath, itemID), nil, nil)
+	if isNotFound(err) {
+		return &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete item %s: %w", itemID, err)
+	}
+
+	log.Printf("Successfully deleted item: %s", itemID)
+	return nil
+}
+
+// GetCompletedOrders - Fetches orders completed since the given time, newest
+// first. The time is passed as SinceParam when configured and always checked
+// locally.
+func (r *RESTPosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error) {
+	query := url.Values{}
+	if r.config.Orders.SinceParam != "" && !since.IsZero() {
+		query.Set(r.config.Orders.SinceParam, fmt.Sprint(r.format.fromTime(since)))
+	}
+
+	records, err := r.list(r.config.Orders.ListPath, r.orders.records, query)
+	if err != nil {
+		log.Printf("Failed to fetch orders from POS API: %v", err)
+		return nil, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+
+	var orders []model.Order
+	for _, record := range records {
+		order, err := r.format.decodeOrder(r.orders, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map order: %w", err)
+		}
+		if !order.CompletedAt.Before(since) {
+			orders = append(orders, cloneOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CompletedAt.Equal(orders[j].CompletedAt) {
+			return orders[i].CompletedAt.After(orders[j].CompletedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+
+	return orders, nil
+}
+
+// QueryOrders - Fetches the orders from the start of the query and pages
+// through them locally, like MemoryPosAdapter.QueryOrders
+func (r *RESTPosAdapter) QueryOrders(query model.OrderQuery) (model.OrderPage, error) {
+	normalized, err := query.Normalize()
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+	if _, err := model.DecodeOrderCursor(normalized); err != nil {
+		return model.OrderPage{}, err
+	}
+
+	orders, err := r.GetCompletedOrders(query.Start)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	return pageOrders(orders, query, r.config.Currency)
+}
+
+// GetOrderByID - Fetches one order, or searches the order list when the API
+// has no single-order endpoint
+func (r *RESTPosAdapter) GetOrderByID(orderID string) (*model.Order, error) {
+	if r.config.Orders.GetPath == "" {
+		orders, err := r.GetCompletedOrders(time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range orders {
+			if order.ID == orderID {
+				return &order, nil
+			}
+		}
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+
+	body, err := r.request(http.MethodGet, resourcePath(r.config.Orders.GetPath, orderID), nil, nil)
+	if isNotFound(err) {
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order %s: %w", orderID, err)
+	}
+
+	record, found := r.orders.record.first(body)
+	if !found {
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+	order, err := r.format.decodeOrder(r.orders, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to map order %s: %w", orderID, err)
+	}
+	order = cloneOrder(order)
+
+	return &order, nil
+}
+
+// CheckOrderExists - Helper method to check if an order exists
+func (r *RESTPosAdapter) CheckOrderExists(orderID string) (bool, error) {
+	_, err := r.GetOrderByID(orderID)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddOrder - Creates the order with a POST to the order list endpoint. Stock
+// and price snapshots are the vendor's responsibility.
+func (r *RESTPosAdapter) AddOrder(order model.Order) error {
+	body, err := r.format.encodeOrder(r.orders, order)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.request(http.MethodPost, r.config.Orders.ListPath, nil, body); err != nil {
+		log.Printf("Failed to add order %s through POS API: %v", order.ID, err)
+		return fmt.Errorf("failed to add order %s: %w", order.ID, err)
+	}
+
+	log.Printf("Successfully added order: %s", order.ID)
+	return nil
+}
+
+// AddOrders - Posts the orders one by one. Like AddItems, only partial
+// batches are supported.
+func (r *RESTPosAdapter) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
+	result := newBatchResult(mode)
+	if result.Mode != model.BatchPartial {
+		return result, fmt.Errorf("%w: %s batches need a transactional store", model.ErrBatchModeUnsupported, result.Mode)
+	}
+
+	for i, order := range orders {
+		if err := r.AddOrder(order); err != nil {
+			result.Fail(i, order.ID, err)
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, order.ID)
+	}
+	return result, nil
+}
+
+// DeleteOrder - Deletes the order through its endpoint
+func (r *RESTPosAdapter) DeleteOrder(orderID string) error {
+	if r.config.Orders.GetPath == "" {
+		return errors.New("the POS API has no order endpoint configured (orders.get_path)")
+	}
+
+	_, err := r.request(http.MethodDelete, resourcePath(r.config.Orders.GetPath, orderID), nil, nil)
+	if isNotFound(err) {
+		return &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete order %s: %w", orderID, err)
+	}
+
+	log.Printf("Successfully deleted order: %s", orderID)
+	return nil
+}
@@ -0,0 +1,429 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// newRESTTestServer - Starts a vendor API served by handler and returns its URL
+func newRESTTestServer(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newTestRESTAdapter(t *testing.T, config RESTConfig) *RESTPosAdapter {
+	t.Helper()
+	adapter, err := NewRESTPosAdapter(config)
+	if err != nil {
+		t.Fatalf("NewRESTPosAdapter: %v", err)
+	}
+	return adapter
+}
+
+// writeJSON - Writes a raw JSON body
+func writeJSON(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, body)
+}
+
+func TestRESTMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     RESTConfig
+		items      string
+		orders     string
+		wantItems  []model.Item
+		wantOrders []model.Order
+	}{
+		{
+			name:   "our own format",
+			config: RESTConfig{Currency: "IDR"},
+			items: `[
+				{"id": "B", "name": "Teh", "stock": 4, "price": 8000, "production_price": "3000.50"},
+				{"id": "A", "name": "Kopi", "stock": 3, "price": 15000, "production_price": 7500}
+			]`,
+			orders: `[
+				{"id": "O1", "total": 30000, "completed_at": "2024-03-01T09:00:00Z",
+				 "items": [{"item_id": "A", "quantity": 2, "unit_price": 15000, "unit_cost": 7500, "discount": 0}]}
+			]`,
+			wantItems: []model.Item{
+				{ID: "A", Name: "Kopi", Stock: 3, Price: model.NewMoney(1500000, "IDR"), ProductionPrice: model.NewMoney(750000, "IDR")},
+				{ID: "B", Name: "Teh", Stock: 4, Price: model.NewMoney(800000, "IDR"), ProductionPrice: model.NewMoney(300050, "IDR")},
+			},
+			wantOrders: []model.Order{{
+				ID:          "O1",
+				Total:       model.NewMoney(3000000, "IDR"),
+				CompletedAt: testTime,
+				Items: []model.OrderItem{{
+					ItemID: "A", Quantity: 2,
+					UnitPrice: model.NewMoney(1500000, "IDR"), UnitCost: model.NewMoney(750000, "IDR"), Discount: model.NewMoney(0, "IDR"),
+				}},
+			}},
+		},
+		{
+			name: "nested records in minor units with unix times",
+			config: RESTConfig{
+				Currency:   "USD",
+				MoneyUnits: "minor",
+				TimeFormat: "unix",
+				Items: RESTItemMapping{
+					Records:         "$.data.products",
+					ID:              "$.sku",
+					Name:            "$['display name']",
+					Stock:           "$.inventory[0].qty",
+					Price:           "$.pricing.amount",
+					ProductionPrice: "$.pricing.cost",
+					Currency:        "$.pricing.currency",
+				},
+				Orders: RESTOrderMapping{
+					Records:       "$.data.orders",
+					ID:            "$.number",
+					CompletedAt:   "$.closed_at",
+					Lines:         "$.line_items",
+					LineItemID:    "$.sku",
+					LineQuantity:  "$.qty",
+					LineUnitPrice: "$.price",
+				},
+			},
+			items: `{"data": {"products": [
+				{"sku": "B", "display name": "Teh", "inventory": [{"qty": 4}], "pricing": {"amount": 250, "cost": 100, "currency": "eur"}},
+				{"sku": "C", "display name": "Roti", "inventory": [], "pricing": {"amount": 100}}
+			]}}`,
+			// No total: it is computed from the lines, and a line without a
+			// quantity counts once
+			orders: `{"data": {"orders": [
+				{"number": 1001, "closed_at": 1709283600,
+				 "line_items": [{"sku": "C", "price": 100}, {"sku": "B", "qty": 3, "price": 250, "discount": 50}]}
+			]}}`,
+			wantItems: []model.Item{
+				{ID: "C", Name: "Roti", Price: model.NewMoney(100, "USD"), ProductionPrice: model.NewMoney(0, "USD")},
+				{ID: "B", Name: "Teh", Stock: 4, Price: model.NewMoney(250, "EUR"), ProductionPrice: model.NewMoney(100, "EUR")},
+			},
+			wantOrders: []model.Order{{
+				ID:          "1001",
+				Total:       model.NewMoney(800, "USD"),
+				CompletedAt: testTime,
+				Items: []model.OrderItem{
+					{ItemID: "B", Quantity: 3, UnitPrice: model.NewMoney(250, "USD"), UnitCost: model.NewMoney(0, "USD"), Discount: model.NewMoney(50, "USD")},
+					{ItemID: "C", Quantity: 1, UnitPrice: model.NewMoney(100, "USD"), UnitCost: model.NewMoney(0, "USD"), Discount: model.NewMoney(0, "USD")},
+				},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		baseURL := newRESTTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/items":
+				writeJSON(w, tt.items)
+			case "/orders":
+				writeJSON(w, tt.orders)
+			default:
+				http.NotFound(w, r)
+			}
+		})
+		config := tt.config
+		config.BaseURL = baseURL
+		config.Items.ListPath = "/items"
+		config.Orders.ListPath = "/orders"
+		adapter := newTestRESTAdapter(t, config)
+
+		items, err := adapter.GetInventory()
+		if err != nil {
+			t.Errorf("%s: GetInventory returned error: %v", tt.name, err)
+		} else if !reflect.DeepEqual(items, tt.wantItems) {
+			t.Errorf("%s: GetInventory = %+v, want %+v", tt.name, items, tt.wantItems)
+		}
+
+		orders, err := adapter.GetCompletedOrders(testTime.Add(-time.Hour))
+		if err != nil {
+			t.Errorf("%s: GetCompletedOrders returned error: %v", tt.name, err)
+		} else if !reflect.DeepEqual(orders, tt.wantOrders) {
+			t.Errorf("%s: GetCompletedOrders = %+v, want %+v", tt.name, orders, tt.wantOrders)
+		}
+
+		// Orders before since are dropped even when the API ignores it
+		if orders, err := adapter.GetCompletedOrders(testTime.Add(time.Minute)); err != nil || len(orders) != 0 {
+			t.Errorf("%s: GetCompletedOrders after the last order = %v, %v, want none", tt.name, orders, err)
+		}
+	}
+}
+
+func TestRESTPagination(t *testing.T) {
+	const total, pageSize = 5, 2
+
+	// page - The records from start and where the next page starts, or -1
+	page := func(start int) (string, int) {
+		var records []interface{}
+		for i := start; i < min(start+pageSize, total); i++ {
+			records = append(records, map[string]interface{}{"id": fmt.Sprintf("ITEM-%d", i)})
+		}
+		data, _ := json.Marshal(records)
+		if start+pageSize >= total {
+			return string(data), -1
+		}
+		return string(data), start + pageSize
+	}
+
+	tests := []struct {
+		style   string
+		records string
+		next    string
+		respond func(w http.ResponseWriter, r *http.Request) error
+	}{
+		{
+			style: "page",
+			respond: func(w http.ResponseWriter, r *http.Request) error {
+				number, err := strconv.Atoi(r.URL.Query().Get("page"))
+				if err != nil || r.URL.Query().Get("limit") != strconv.Itoa(pageSize) {
+					return fmt.Errorf("unexpected query %s", r.URL.RawQuery)
+				}
+				records, _ := page((number - 1) * pageSize)
+				writeJSON(w, records)
+				return nil
+			},
+		},
+		{
+			style: "offset",
+			respond: func(w http.ResponseWriter, r *http.Request) error {
+				offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+				if err != nil || r.URL.Query().Get("limit") != strconv.Itoa(pageSize) {
+					return fmt.Errorf("unexpected query %s", r.URL.RawQuery)
+				}
+				records, _ := page(offset)
+				writeJSON(w, records)
+				return nil
+			},
+		},
+		{
+			style:   "cursor",
+			records: "$.data",
+			next:    "$.meta.next_cursor",
+			respond: func(w http.ResponseWriter, r *http.Request) error {
+				start := 0
+				if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+					var err error
+					if start, err = strconv.Atoi(cursor); err != nil {
+						return fmt.Errorf("unexpected cursor %q", cursor)
+					}
+				}
+				records, next := page(start)
+				cursor := ""
+				if next >= 0 {
+					cursor = strconv.Itoa(next)
+				}
+				writeJSON(w, fmt.Sprintf(`{"data": %s, "meta": {"next_cursor": %q}}`, records, cursor))
+				return nil
+			},
+		},
+		{
+			// Relative links are relative to the host, not the base path
+			style:   "next_url",
+			records: "$.data",
+			next:    "$.links.next",
+			respond: func(w http.ResponseWriter, r *http.Request) error {
+				start := 0
+				if after := r.URL.Query().Get("after"); after != "" {
+					var err error
+					if start, err = strconv.Atoi(after); err != nil {
+						return fmt.Errorf("unexpected link %s", r.URL)
+					}
+				} else if r.URL.Query().Get("limit") != strconv.Itoa(pageSize) {
+					return fmt.Errorf("unexpected query %s", r.URL.RawQuery)
+				}
+				records, next := page(start)
+				link := "null"
+				if next >= 0 {
+					link = fmt.Sprintf(`"/api/items?after=%d"`, next)
+				}
+				writeJSON(w, fmt.Sprintf(`{"data": %s, "links": {"next": %s}}`, records, link))
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var requests atomic.Int32
+		baseURL := newRESTTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			if r.URL.Path != "/api/items" {
+				http.NotFound(w, r)
+				return
+			}
+			if err := tt.respond(w, r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		})
+
+		adapter := newTestRESTAdapter(t, RESTConfig{
+			BaseURL:    baseURL + "/api",
+			MaxRetries: -1,
+			Pagination: RESTPagination{Style: tt.style, PageSize: pageSize, NextPath: tt.next},
+			Items:      RESTItemMapping{ListPath: "/items", Records: tt.records},
+			Orders:     RESTOrderMapping{ListPath: "/orders"},
+		})
+
+		items, err := adapter.GetInventory()
+		if err != nil {
+			t.Errorf("%s: GetInventory returned error: %v", tt.style, err)
+			continue
+		}
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		want := []string{"ITEM-0", "ITEM-1", "ITEM-2", "ITEM-3", "ITEM-4"}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s: GetInventory returned %v, want %v", tt.style, ids, want)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("%s: made %d requests, want 3", tt.style, got)
+		}
+	}
+}
+
+func TestRESTRequests(t *testing.T) {
+	var getAttempts, postAttempts atomic.Int32
+	var posted map[string]interface{}
+	baseURL := newRESTTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "missing key", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/items/A":
+			// The first attempt fails and is retried
+			if getAttempts.Add(1) == 1 {
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			}
+			writeJSON(w, `{"item": {"id": "A", "name": "Kopi", "stock": 3, "pricing": {"amount": 1500}}}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/items":
+			postAttempts.Add(1)
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "not JSON", http.StatusUnsupportedMediaType)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if posted["id"] == "FAIL" {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	adapter := newTestRESTAdapter(t, RESTConfig{
+		BaseURL:       baseURL,
+		APIKey:        "secret",
+		AuthHeader:    "X-Api-Key",
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 5 * time.Millisecond,
+		Currency:      "USD",
+		MoneyUnits:    "minor",
+		Items: RESTItemMapping{
+			ListPath: "/items",
+			GetPath:  "/items/{id}",
+			Record:   "$.item",
+			Price:    "$.pricing.amount",
+			Currency: "$.pricing.currency",
+		},
+		Orders: RESTOrderMapping{ListPath: "/orders"},
+	})
+
+	item, err := adapter.GetItemByID("A")
+	if err != nil {
+		t.Fatalf("GetItemByID returned error: %v", err)
+	}
+	want := model.Item{ID: "A", Name: "Kopi", Stock: 3, Price: model.NewMoney(1500, "USD"), ProductionPrice: model.NewMoney(0, "USD")}
+	if !reflect.DeepEqual(*item, want) {
+		t.Errorf("GetItemByID = %+v, want %+v", *item, want)
+	}
+	if got := getAttempts.Load(); got != 2 {
+		t.Errorf("GetItemByID made %d attempts, want 2", got)
+	}
+
+	var notFound *model.NotFoundError
+	if _, err := adapter.GetItemByID("missing"); !errors.As(err, &notFound) || !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("GetItemByID(missing) returned %v, want a NotFoundError", err)
+	}
+	if exists, err := adapter.CheckItemExists("missing"); exists || err != nil {
+		t.Errorf("CheckItemExists(missing) = %v, %v, want false, nil", exists, err)
+	}
+
+	// The body uses the same mapping as responses
+	item = &model.Item{ID: "B", Name: "Teh", Stock: 4, Price: model.NewMoney(250, "EUR"), ProductionPrice: model.NewMoney(100, "EUR")}
+	if err := adapter.AddItem(*item); err != nil {
+		t.Fatalf("AddItem returned error: %v", err)
+	}
+	wantBody := map[string]interface{}{
+		"id": "B", "name": "Teh", "stock": float64(4), "production_price": float64(100),
+		"pricing": map[string]interface{}{"amount": float64(250), "currency": "EUR"},
+	}
+	if !reflect.DeepEqual(posted, wantBody) {
+		t.Errorf("AddItem posted %v, want %v", posted, wantBody)
+	}
+
+	// A failed POST may have been applied, so it is not retried
+	postAttempts.Store(0)
+	if err := adapter.AddItem(model.Item{ID: "FAIL"}); err == nil {
+		t.Error("AddItem(FAIL) succeeded, want an error")
+	}
+	if got := postAttempts.Load(); got != 1 {
+		t.Errorf("AddItem(FAIL) made %d attempts, want 1", got)
+	}
+
+	// The API has no transactions, so only partial batches are accepted
+	if _, err := adapter.AddItems([]model.Item{*item}, model.BatchAtomic); !errors.Is(err, model.ErrBatchModeUnsupported) {
+		t.Errorf("AddItems(atomic) returned %v, want ErrBatchModeUnsupported", err)
+	}
+}
+
+func TestRESTConfigValidate(t *testing.T) {
+	valid := RESTConfig{
+		BaseURL: "https://api.vendor.example/v2",
+		Items:   RESTItemMapping{ListPath: "/items"},
+		Orders:  RESTOrderMapping{ListPath: "/orders"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(config *RESTConfig)
+	}{
+		{name: "relative base URL", modify: func(c *RESTConfig) { c.BaseURL = "/v2" }},
+		{name: "no item list", modify: func(c *RESTConfig) { c.Items.ListPath = "" }},
+		{name: "no order list", modify: func(c *RESTConfig) { c.Orders.ListPath = "" }},
+		{name: "unknown money units", modify: func(c *RESTConfig) { c.MoneyUnits = "cents" }},
+		{name: "bad path", modify: func(c *RESTConfig) { c.Items.Price = "$.variants[first]" }},
+		{name: "unknown pagination", modify: func(c *RESTConfig) { c.Pagination.Style = "scroll" }},
+		{name: "cursor without next path", modify: func(c *RESTConfig) { c.Pagination.Style = "cursor" }},
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate returned error for a valid config: %v", err)
+	}
+	for _, tt := range tests {
+		config := valid
+		tt.modify(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("%s: Validate succeeded, want an error", tt.name)
+		}
+	}
+}
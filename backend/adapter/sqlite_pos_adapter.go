@@ -0,0 +1,820 @@
+package adapter
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// sqliteTimeFormat is how timestamps are stored: always UTC and fixed width,
+// so comparing the strings compares the times
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// SQLitePosAdapter stores the POS data in an embedded SQLite database file. It
+// needs no database server, so the whole API can run on a laptop or at an
+// outlet without network access. Behaviour matches DBPosAdapter: the same
+// stock ledger, oversell policy, price snapshots and price history.
+type SQLitePosAdapter struct {
+	db             *sqlx.DB
+	currency       string
+	oversellPolicy model.OversellPolicy
+}
+
+// OpenSQLite - Opens the SQLite database at path, creating the file if needed,
+// and applies the POS schema. ":memory:" opens a private in-memory database.
+func OpenSQLite(path string) (*sqlx.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+	db, err := sqlx.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+
+	// SQLite has a single writer. One connection serializes every transaction,
+	// which stands in for the row and advisory locks DBPosAdapter takes, and
+	// keeps an in-memory database from being opened once per connection.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database %s: %w", path, err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply sqlite schema: %w", err)
+	}
+
+	return db, nil
+}
+
+// NewSQLitePosAdapter - Creates the adapter on db, which must come from
+// OpenSQLite. currency is the currency of amounts written without one.
+func NewSQLitePosAdapter(db *sqlx.DB, currency string) (*SQLitePosAdapter, error) {
+	if db == nil {
+		return nil, errors.New("sqlite adapter needs a database")
+	}
+
+	return &SQLitePosAdapter{
+		db:             db,
+		currency:       normalizeCurrency(currency),
+		oversellPolicy: model.OversellReject,
+	}, nil
+}
+
+// SetOversellPolicy - Sets the deployment-wide oversell policy used for items
+// that don't define their own
+func (s *SQLitePosAdapter) SetOversellPolicy(policy model.OversellPolicy) {
+	if policy == "" {
+		policy = model.OversellReject
+	}
+	s.oversellPolicy = policy
+}
+
+func (s *SQLitePosAdapter) AddOrder(order model.Order) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.writeOrder(tx, order); err != nil {
+		log.Printf("Failed to add order %s: %v", order.ID, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order transaction: %w", err)
+	}
+
+	log.Printf("Successfully added order: %s with %d items (Total: %s)", order.ID, len(order.Items), order.Total.Format())
+	return nil
+}
+
+func (s *SQLitePosAdapter) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
+	if len(orders) == 0 {
+		return newBatchResult(mode), nil
+	}
+
+	ids := make([]string, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return newBatchResult(mode), fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := writeBatch(tx, mode, ids, func(i int) error {
+		return s.writeOrder(tx, orders[i])
+	})
+	if err != nil {
+		return result, err
+	}
+
+	logBatch("orders", len(orders), result)
+	return result, nil
+}
+
+// writeOrder - Inserts or replaces an order and its lines, snapshotting prices
+// and recording stock movements
+func (s *SQLitePosAdapter) writeOrder(tx *sqlx.Tx, order model.Order) error {
+	if err := s.snapshotPrices(tx, &order); err != nil {
+		return err
+	}
+	currency, err := orderCurrency(order, s.currency)
+	if err != nil {
+		return err
+	}
+	if err := s.applyOrderStock(tx, order); err != nil {
+		return err
+	}
+
+	orderQuery := `
+        INSERT INTO orders (id, total, currency, completed_at)
+        VALUES (?, ?, ?, ?)
+        ON CONFLICT (id) DO UPDATE SET
+            total = excluded.total,
+            currency = excluded.currency,
+            completed_at = excluded.completed_at`
+
+	_, err = tx.Exec(orderQuery, order.ID, order.Total.Amount, currency, sqliteTime(order.CompletedAt))
+	if err != nil {
+		return fmt.Errorf("failed to insert order %s: %w", order.ID, err)
+	}
+
+	// Delete existing order items (in case of update)
+	_, err = tx.Exec(`DELETE FROM order_items WHERE order_id = ?`, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing order items for order %s: %w", order.ID, err)
+	}
+
+	itemQuery := `
+        INSERT INTO order_items (order_id, item_id, quantity, unit_price, unit_cost, discount)
+        VALUES (?, ?, ?, ?, ?, ?)`
+
+	for _, item := range order.Items {
+		_, err = tx.Exec(itemQuery, order.ID, item.ItemID, item.Quantity, item.UnitPrice.Amount, item.UnitCost.Amount, item.Discount.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to insert order item %s for order %s: %w", item.ItemID, order.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetOrderByID - Helper method to get a single order by ID
+func (s *SQLitePosAdapter) GetOrderByID(orderID string) (*model.Order, error) {
+	query := `
+        SELECT o.id AS order_id, o.total, o.currency, o.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
+        FROM orders o
+        LEFT JOIN order_items oi ON o.id = oi.order_id
+        WHERE o.id = ?
+        ORDER BY oi.item_id`
+
+	var rows []sqliteOrderRow
+	if err := s.db.Select(&rows, query, orderID); err != nil {
+		log.Printf("Failed to query order %s: %v", orderID, err)
+		return nil, fmt.Errorf("failed to query order %s: %w", orderID, err)
+	}
+
+	if len(rows) == 0 {
+		return nil, &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+
+	orders, err := groupSQLiteOrderRows(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orders[0], nil
+}
+
+// CheckOrderExists - Helper method to check if an order exists
+func (s *SQLitePosAdapter) CheckOrderExists(orderID string) (bool, error) {
+	var count int
+	if err := s.db.Get(&count, `SELECT COUNT(*) FROM orders WHERE id = ?`, orderID); err != nil {
+		return false, fmt.Errorf("failed to check if order exists: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// DeleteOrder - Helper method to delete an order and its items
+func (s *SQLitePosAdapter) DeleteOrder(orderID string) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Put the order's lines back into stock
+	if err := s.applyOrderStock(tx, model.Order{ID: orderID}); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM order_items WHERE order_id = ?`, orderID); err != nil {
+		return fmt.Errorf("failed to delete order items for order %s: %w", orderID, err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM orders WHERE id = ?`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to delete order %s: %w", orderID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return &model.NotFoundError{Resource: "order", ID: orderID}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit delete transaction: %w", err)
+	}
+
+	log.Printf("Successfully deleted order: %s", orderID)
+	return nil
+}
+
+func (s *SQLitePosAdapter) GetInventory() ([]model.Item, error) {
+	query := `
+        SELECT id, name, stock, price, production_price, currency,
+               COALESCE(oversell_policy, '') AS oversell_policy
+        FROM items
+        ORDER BY name`
+
+	var rows []sqliteItemRow
+	if err := s.db.Select(&rows, query); err != nil {
+		log.Printf("Failed to query inventory: %v", err)
+		return nil, fmt.Errorf("failed to query inventory: %w", err)
+	}
+
+	items := make([]model.Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.toItem())
+	}
+
+	return items, nil
+}
+
+// SearchItems - Finds items whose name or ID contains term, ignoring case
+// (SQLite only folds ASCII letters)
+func (s *SQLitePosAdapter) SearchItems(term string, limit int) ([]model.Item, error) {
+	query := `
+        SELECT id, name, stock, price, production_price, currency,
+               COALESCE(oversell_policy, '') AS oversell_policy
+        FROM items
+        WHERE name LIKE ? ESCAPE '\' OR id LIKE ? ESCAPE '\'
+        ORDER BY name`
+	pattern := likePattern(term)
+	args := []interface{}{pattern, pattern}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	var rows []sqliteItemRow
+	if err := s.db.Select(&rows, query, args...); err != nil {
+		log.Printf("Failed to search items: %v", err)
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+
+	items := make([]model.Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.toItem())
+	}
+
+	return items, nil
+}
+
+func (s *SQLitePosAdapter) GetCompletedOrders(since time.Time) ([]model.Order, error) {
+	query := `
+        SELECT o.id AS order_id, o.total, o.currency, o.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
+        FROM orders o
+        LEFT JOIN order_items oi ON o.id = oi.order_id
+        WHERE o.completed_at >= ?
+        ORDER BY o.completed_at DESC, o.id, oi.item_id`
+
+	var rows []sqliteOrderRow
+	if err := s.db.Select(&rows, query, sqliteTime(since)); err != nil {
+		log.Printf("Failed to query completed orders: %v", err)
+		return nil, fmt.Errorf("failed to query completed orders: %w", err)
+	}
+
+	return groupSQLiteOrderRows(rows)
+}
+
+// QueryOrders - Returns one keyset-paginated page of orders, like
+// DBPosAdapter.QueryOrders
+func (s *SQLitePosAdapter) QueryOrders(query model.OrderQuery) (model.OrderPage, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	cursor, err := model.DecodeOrderCursor(query)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, conditionArgs ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+
+	if !query.Start.IsZero() {
+		addCondition("o.completed_at >= ?", sqliteTime(query.Start))
+	}
+	if !query.End.IsZero() {
+		addCondition("o.completed_at < ?", sqliteTime(query.End))
+	}
+	if query.MinTotal != nil {
+		addCondition("o.total >= ? AND o.currency = ?", query.MinTotal.Amount, currencyOr(*query.MinTotal, s.currency))
+	}
+	if query.MaxTotal != nil {
+		addCondition("o.total <= ? AND o.currency = ?", query.MaxTotal.Amount, currencyOr(*query.MaxTotal, s.currency))
+	}
+	if query.ItemID != "" {
+		addCondition("EXISTS (SELECT 1 FROM order_items f WHERE f.order_id = o.id AND f.item_id = ?)", query.ItemID)
+	}
+
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	// Sort keys in the order of model.OrderQuery; text columns use SQLite's
+	// default BINARY collation, which compares byte by byte
+	sortKeys := func(alias string) []string {
+		switch query.SortBy {
+		case model.SortByID:
+			return []string{alias + ".id"}
+		case model.SortByTotal:
+			return []string{alias + ".currency", alias + ".total", alias + ".id"}
+		default:
+			return []string{alias + ".completed_at", alias + ".id"}
+		}
+	}
+
+	if cursor != nil {
+		var values []interface{}
+		switch query.SortBy {
+		case model.SortByID:
+		case model.SortByTotal:
+			values = append(values, cursor.Total.Currency, cursor.Total.Amount)
+		default:
+			values = append(values, sqliteTime(cursor.CompletedAt))
+		}
+		values = append(values, cursor.ID)
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		addCondition("("+strings.Join(sortKeys("o"), ", ")+") "+comparison+" ("+placeholders+")", values...)
+	}
+
+	orderBy := func(alias string) string {
+		keys := sortKeys(alias)
+		for i := range keys {
+			keys[i] += " " + direction
+		}
+		return strings.Join(keys, ", ")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := ""
+	if query.Limit > 0 {
+		// Fetch one extra order to know whether there is a next page
+		args = append(args, query.Limit+1)
+		limit = "LIMIT ?"
+	}
+
+	sqlQuery := `
+        WITH page AS (
+            SELECT o.id, o.total, o.currency, o.completed_at
+            FROM orders o
+            ` + where + `
+            ORDER BY ` + orderBy("o") + `
+            ` + limit + `
+        )
+        SELECT p.id AS order_id, p.total, p.currency, p.completed_at,
+               oi.item_id, oi.quantity, oi.unit_price, oi.unit_cost, oi.discount
+        FROM page p
+        LEFT JOIN order_items oi ON oi.order_id = p.id
+        ORDER BY ` + orderBy("p") + `, oi.item_id`
+
+	var rows []sqliteOrderRow
+	if err := s.db.Select(&rows, sqlQuery, args...); err != nil {
+		log.Printf("Failed to query orders: %v", err)
+		return model.OrderPage{}, fmt.Errorf("failed to query orders: %w", err)
+	}
+
+	orders, err := groupSQLiteOrderRows(rows)
+	if err != nil {
+		return model.OrderPage{}, err
+	}
+
+	page := model.OrderPage{Orders: orders}
+	if query.Limit > 0 && len(page.Orders) > query.Limit {
+		page.Orders = page.Orders[:query.Limit]
+		page.NextCursor = model.NewOrderCursor(query, page.Orders[query.Limit-1]).Encode()
+	}
+
+	return page, nil
+}
+
+func (s *SQLitePosAdapter) AddItem(item model.Item) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.upsertItem(tx, item); err != nil {
+		log.Printf("Failed to add item %s: %v", item.ID, err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Successfully added/updated item: %s - %s", item.ID, item.Name)
+	return nil
+}
+
+func (s *SQLitePosAdapter) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
+	if len(items) == 0 {
+		return newBatchResult(mode), nil
+	}
+
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return newBatchResult(mode), fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := writeBatch(tx, mode, ids, func(i int) error {
+		return s.upsertItem(tx, items[i])
+	})
+	if err != nil {
+		return result, err
+	}
+
+	logBatch("items", len(items), result)
+	return result, nil
+}
+
+func (s *SQLitePosAdapter) UpdateItem(item model.Item) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	currency, err := itemCurrency(item, s.currency)
+	if err != nil {
+		return err
+	}
+	item.Price = item.Price.WithCurrency(currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(currency)
+
+	var previousRow sqliteItemRow
+	query := `SELECT id, name, stock, price, production_price, currency FROM items WHERE id = ?`
+	err = tx.Get(&previousRow, query, item.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &model.NotFoundError{Resource: "item", ID: item.ID}
+		}
+		return fmt.Errorf("failed to read item %s: %w", item.ID, err)
+	}
+	previous := previousRow.toItem()
+
+	query = `
+        UPDATE items
+        SET name = ?, stock = ?, price = ?, production_price = ?, oversell_policy = NULLIF(?, ''), currency = ?
+        WHERE id = ?`
+
+	_, err = tx.Exec(query, item.Name, item.Stock, item.Price.Amount, item.ProductionPrice.Amount, string(item.OversellPolicy), currency, item.ID)
+	if err != nil {
+		log.Printf("Failed to update item %s: %v", item.ID, err)
+		return fmt.Errorf("failed to update item %s: %w", item.ID, err)
+	}
+
+	// Record manual stock changes in the ledger
+	movement := model.StockMovement{ItemID: item.ID, Delta: item.Stock - previous.Stock, Reason: model.StockReasonAdjustment}
+	if err := s.insertStockMovement(tx, movement); err != nil {
+		return err
+	}
+
+	// Record price changes in the price history
+	if previous.Price != item.Price.WithCurrency(currency) || previous.ProductionPrice != item.ProductionPrice.WithCurrency(currency) {
+		if err := s.recordPriceChange(tx, item); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("Successfully updated item: %s - %s", item.ID, item.Name)
+	return nil
+}
+
+func (s *SQLitePosAdapter) DeleteItem(itemID string) error {
+	// First check if item exists in any orders
+	var orderCount int
+	if err := s.db.Get(&orderCount, `SELECT COUNT(*) FROM order_items WHERE item_id = ?`, itemID); err != nil {
+		return fmt.Errorf("failed to check item usage in orders: %w", err)
+	}
+
+	if orderCount > 0 {
+		return &model.ItemInUseError{ItemID: itemID, OrderCount: orderCount}
+	}
+
+	result, err := s.db.Exec(`DELETE FROM items WHERE id = ?`, itemID)
+	if err != nil {
+		log.Printf("Failed to delete item %s: %v", itemID, err)
+		return fmt.Errorf("failed to delete item %s: %w", itemID, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return &model.NotFoundError{Resource: "item", ID: itemID}
+	}
+
+	log.Printf("Successfully deleted item: %s", itemID)
+	return nil
+}
+
+// GetItemByID - Helper method to get a single item by ID
+func (s *SQLitePosAdapter) GetItemByID(itemID string) (*model.Item, error) {
+	query := `
+        SELECT id, name, stock, price, production_price, currency,
+               COALESCE(oversell_policy, '') AS oversell_policy
+        FROM items
+        WHERE id = ?`
+
+	var row sqliteItemRow
+	if err := s.db.Get(&row, query, itemID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, &model.NotFoundError{Resource: "item", ID: itemID}
+		}
+		log.Printf("Failed to query item %s: %v", itemID, err)
+		return nil, fmt.Errorf("failed to query item %s: %w", itemID, err)
+	}
+
+	item := row.toItem()
+	return &item, nil
+}
+
+// CheckItemExists - Helper method to check if an item exists
+func (s *SQLitePosAdapter) CheckItemExists(itemID string) (bool, error) {
+	var count int
+	if err := s.db.Get(&count, `SELECT COUNT(*) FROM items WHERE id = ?`, itemID); err != nil {
+		return false, fmt.Errorf("failed to check if item exists: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// AggregateSales - Computes the same rows as DBPosAdapter.AggregateSales.
+// Items are grouped in SQL; SQLite has no timezone support, so orders are
+// summed per order in SQL and bucketed into periods here.
+func (s *SQLitePosAdapter) AggregateSales(query model.AggregateQuery) ([]model.AggregateRow, error) {
+	args := []interface{}{sqliteTime(query.Start)}
+	dateFilter := `o.completed_at >= ?`
+	if !query.End.IsZero() {
+		args = append(args, sqliteTime(query.End))
+		dateFilter += ` AND o.completed_at < ?`
+	}
+
+	if query.GroupBy == model.GroupByItem {
+		sqlQuery := `
+        SELECT oi.item_id AS key,
+               o.currency AS currency,
+               COALESCE(i.name, oi.item_id) AS item_name,
+               COALESCE(i.price, 0) AS price,
+               COALESCE(i.currency, o.currency) AS price_currency,
+               COUNT(DISTINCT o.id) AS orders,
+               COALESCE(SUM(oi.quantity), 0) AS units,
+               COALESCE(SUM(oi.quantity * oi.unit_price - oi.discount), 0) AS revenue,
+               COALESCE(SUM(oi.quantity * oi.unit_cost), 0) AS cost
+        FROM order_items oi
+        JOIN orders o ON o.id = oi.order_id
+        LEFT JOIN items i ON i.id = oi.item_id
+        WHERE ` + dateFilter + `
+        GROUP BY oi.item_id, o.currency, i.name, i.price, i.currency
+        ORDER BY oi.item_id, o.currency`
+
+		var scanned []sqliteAggregateRow
+		if err := s.db.Select(&scanned, sqlQuery, args...); err != nil {
+			log.Printf("Failed to aggregate sales by %s: %v", query.GroupBy, err)
+			return nil, fmt.Errorf("failed to aggregate sales by %s: %w", query.GroupBy, err)
+		}
+
+		rows := make([]model.AggregateRow, 0, len(scanned))
+		for _, row := range scanned {
+			rows = append(rows, model.AggregateRow{
+				Key:      row.Key,
+				Currency: row.Currency,
+				ItemName: row.ItemName,
+				Price:    model.NewMoney(row.Price, row.PriceCurrency),
+				Orders:   row.Orders,
+				Units:    row.Units,
+				Revenue:  model.NewMoney(row.Revenue, row.Currency),
+				Cost:     model.NewMoney(row.Cost, row.Currency),
+			})
+		}
+		return rows, nil
+	}
+
+	if query.GroupBy != model.GroupByDay && query.GroupBy != model.GroupByWeek && query.GroupBy != model.GroupByMonth {
+		return nil, fmt.Errorf("unsupported aggregation granularity: %s", query.GroupBy)
+	}
+
+	sqlQuery := `
+        SELECT o.id, o.total, o.currency, o.completed_at,
+               COALESCE(SUM(oi.quantity), 0) AS units,
+               COALESCE(SUM(oi.quantity * oi.unit_cost), 0) AS cost
+        FROM orders o
+        LEFT JOIN order_items oi ON oi.order_id = o.id
+        WHERE ` + dateFilter + `
+        GROUP BY o.id, o.total, o.currency, o.completed_at`
+
+	var orderTotals []struct {
+		ID          string `db:"id"`
+		Total       int64  `db:"total"`
+		Currency    string `db:"currency"`
+		CompletedAt string `db:"completed_at"`
+		Units       int    `db:"units"`
+		Cost        int64  `db:"cost"`
+	}
+	if err := s.db.Select(&orderTotals, sqlQuery, args...); err != nil {
+		log.Printf("Failed to aggregate sales by %s: %v", query.GroupBy, err)
+		return nil, fmt.Errorf("failed to aggregate sales by %s: %w", query.GroupBy, err)
+	}
+
+	rowMap := make(map[[2]string]*model.AggregateRow)
+	for _, order := range orderTotals {
+		completedAt, err := parseSQLiteTime(order.CompletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+
+		// Bucket on the wall clock of the business timezone
+		key := [2]string{model.PeriodKey(completedAt.In(query.Zone()), query.GroupBy), order.Currency}
+		row, exists := rowMap[key]
+		if !exists {
+			row = &model.AggregateRow{Key: key[0], Currency: key[1], Revenue: model.NewMoney(0, key[1]), Cost: model.NewMoney(0, key[1])}
+			rowMap[key] = row
+		}
+		row.Orders++
+		row.Units += order.Units
+		if row.Revenue, err = row.Revenue.Add(model.NewMoney(order.Total, order.Currency)); err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+		if row.Cost, err = row.Cost.Add(model.NewMoney(order.Cost, order.Currency)); err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+	}
+
+	rows := make([]model.AggregateRow, 0, len(rowMap))
+	for _, row := range rowMap {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Key != rows[j].Key {
+			return rows[i].Key < rows[j].Key
+		}
+		return rows[i].Currency < rows[j].Currency
+	})
+
+	return rows, nil
+}
+
+// sqliteItemRow is one row of the items table, with prices in minor units
+type sqliteItemRow struct {
+	ID              string `db:"id"`
+	Name            string `db:"name"`
+	Stock           int    `db:"stock"`
+	Price           int64  `db:"price"`
+	ProductionPrice int64  `db:"production_price"`
+	Currency        string `db:"currency"`
+	OversellPolicy  string `db:"oversell_policy"`
+}
+
+func (r sqliteItemRow) toItem() model.Item {
+	return model.Item{
+		ID:              r.ID,
+		Name:            r.Name,
+		Stock:           r.Stock,
+		Price:           model.NewMoney(r.Price, r.Currency),
+		ProductionPrice: model.NewMoney(r.ProductionPrice, r.Currency),
+		OversellPolicy:  model.OversellPolicy(r.OversellPolicy),
+	}
+}
+
+// sqliteOrderRow is one row of an orders LEFT JOIN order_items query
+type sqliteOrderRow struct {
+	OrderID     string  `db:"order_id"`
+	Total       int64   `db:"total"`
+	Currency    string  `db:"currency"`
+	CompletedAt string  `db:"completed_at"`
+	ItemID      *string `db:"item_id"`
+	Quantity    *int    `db:"quantity"`
+	UnitPrice   *int64  `db:"unit_price"`
+	UnitCost    *int64  `db:"unit_cost"`
+	Discount    *int64  `db:"discount"`
+}
+
+// groupSQLiteOrderRows converts the rows to orderItemRow and folds them into
+// orders with groupOrderRows
+func groupSQLiteOrderRows(rows []sqliteOrderRow) ([]model.Order, error) {
+	converted := make([]orderItemRow, 0, len(rows))
+	for _, row := range rows {
+		completedAt, err := parseSQLiteTime(row.CompletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("order %s: %w", row.OrderID, err)
+		}
+
+		converted = append(converted, orderItemRow{
+			OrderID:     row.OrderID,
+			Total:       model.NewMoney(row.Total, row.Currency),
+			Currency:    row.Currency,
+			CompletedAt: completedAt,
+			ItemID:      row.ItemID,
+			Quantity:    row.Quantity,
+			UnitPrice:   sqliteMoney(row.UnitPrice, row.Currency),
+			UnitCost:    sqliteMoney(row.UnitCost, row.Currency),
+			Discount:    sqliteMoney(row.Discount, row.Currency),
+		})
+	}
+
+	return groupOrderRows(converted), nil
+}
+
+// sqliteAggregateRow is one row of the by-item aggregation
+type sqliteAggregateRow struct {
+	Key           string `db:"key"`
+	Currency      string `db:"currency"`
+	ItemName      string `db:"item_name"`
+	Price         int64  `db:"price"`
+	PriceCurrency string `db:"price_currency"`
+	Orders        int    `db:"orders"`
+	Units         int    `db:"units"`
+	Revenue       int64  `db:"revenue"`
+	Cost          int64  `db:"cost"`
+}
+
+func sqliteMoney(minorUnits *int64, currency string) *model.Money {
+	if minorUnits == nil {
+		return nil
+	}
+	amount := model.NewMoney(*minorUnits, currency)
+	return &amount
+}
+
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseSQLiteTime(value string) (time.Time, error) {
+	t, err := time.Parse(sqliteTimeFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stored timestamp %q: %w", value, err)
+	}
+	return t, nil
+}
@@ -0,0 +1,397 @@
+package adapter
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// testAdapter is what the adapter tests use: a writable adapter with a stock
+// ledger and an oversell policy
+type testAdapter interface {
+	model.POSAdapter
+	model.StockLedger
+	model.OversellPolicySetter
+}
+
+// testTime is when the test orders are completed, one minute apart
+var testTime = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestSQLiteAdapter(t *testing.T) *SQLitePosAdapter {
+	t.Helper()
+	db, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	adapter, err := NewSQLitePosAdapter(db, "IDR")
+	if err != nil {
+		t.Fatalf("NewSQLitePosAdapter: %v", err)
+	}
+	return adapter
+}
+
+// testItem - An item priced in whole units of currency, costing half its price
+func testItem(id string, stock int, price int64, currency string) model.Item {
+	unit := model.MustParseMoney(strconv.FormatInt(price, 10), currency)
+	return model.Item{ID: id, Name: "Item " + id, Stock: stock, Price: unit, ProductionPrice: unit.Div(2)}
+}
+
+// testOrder - An order of quantity units of each item, priced from the
+// inventory
+func testOrder(id string, minute int, quantities map[string]int) model.Order {
+	order := model.Order{ID: id, CompletedAt: testTime.Add(time.Duration(minute) * time.Minute)}
+	for itemID, quantity := range quantities {
+		order.Items = append(order.Items, model.OrderItem{ItemID: itemID, Quantity: quantity})
+	}
+	return order
+}
+
+func seedItems(t *testing.T, adapter model.InventoryWriter, items ...model.Item) {
+	t.Helper()
+	for _, item := range items {
+		if err := adapter.AddItem(item); err != nil {
+			t.Fatalf("AddItem(%s): %v", item.ID, err)
+		}
+	}
+}
+
+// checkStock - Fails the test unless the item's stock, and the sum of its
+// ledger, is want
+func checkStock(t *testing.T, adapter testAdapter, itemID string, want int) {
+	t.Helper()
+	item, err := adapter.GetItemByID(itemID)
+	if err != nil {
+		t.Fatalf("GetItemByID(%s): %v", itemID, err)
+	}
+	if item.Stock != want {
+		t.Errorf("stock of %s = %d, want %d", itemID, item.Stock, want)
+	}
+	ledger, err := adapter.GetLedgerStock(itemID)
+	if err != nil {
+		t.Fatalf("GetLedgerStock(%s): %v", itemID, err)
+	}
+	if ledger != want {
+		t.Errorf("ledger stock of %s = %d, want %d", itemID, ledger, want)
+	}
+}
+
+// batchOutcome is a BatchResult without the error messages
+type batchOutcome struct {
+	Succeeded []string
+	Failed    []model.BatchFailureReason // by row, "" for rows that were written
+}
+
+func outcomeOf(result model.BatchResult, rows int) batchOutcome {
+	outcome := batchOutcome{Succeeded: result.Succeeded, Failed: make([]model.BatchFailureReason, rows)}
+	for _, failure := range result.Failed {
+		outcome.Failed[failure.Index] = failure.Reason
+	}
+	return outcome
+}
+
+// testAddOrdersBatch - Writes a batch with a good order, one that oversells,
+// one with mixed currencies and another good one
+func testAddOrdersBatch(t *testing.T, newAdapter func(t *testing.T) testAdapter) {
+	mixed := testOrder("ORD-3", 3, map[string]int{"ITEM-1": 1})
+	mixed.Total = model.NewMoney(500, "USD")
+
+	orders := []model.Order{
+		testOrder("ORD-1", 1, map[string]int{"ITEM-1": 2}),
+		testOrder("ORD-2", 2, map[string]int{"ITEM-2": 10}),
+		mixed,
+		testOrder("ORD-4", 4, map[string]int{"ITEM-1": 1}),
+	}
+
+	tests := []struct {
+		mode       model.BatchMode
+		want       batchOutcome
+		wantStored []string
+		wantStock1 int
+	}{
+		{
+			mode: model.BatchPartial,
+			want: batchOutcome{
+				Succeeded: []string{"ORD-1", "ORD-4"},
+				Failed:    []model.BatchFailureReason{"", model.FailureInsufficientStock, model.FailureInvalid, ""},
+			},
+			wantStored: []string{"ORD-1", "ORD-4"},
+			wantStock1: 7,
+		},
+		{
+			mode: model.BatchAtomic,
+			want: batchOutcome{
+				Failed: []model.BatchFailureReason{model.FailureRolledBack, model.FailureInsufficientStock, model.FailureInvalid, model.FailureRolledBack},
+			},
+			wantStock1: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			adapter := newAdapter(t)
+			seedItems(t, adapter, testItem("ITEM-1", 10, 15000, "IDR"), testItem("ITEM-2", 3, 20000, "IDR"))
+
+			result, err := adapter.AddOrders(orders, tt.mode)
+			if err != nil {
+				t.Fatalf("AddOrders: %v", err)
+			}
+			if result.Mode != tt.mode {
+				t.Errorf("mode = %q, want %q", result.Mode, tt.mode)
+			}
+			if got := outcomeOf(result, len(orders)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+
+			for _, order := range orders {
+				exists, err := adapter.CheckOrderExists(order.ID)
+				if err != nil {
+					t.Fatalf("CheckOrderExists(%s): %v", order.ID, err)
+				}
+				if want := contains(tt.wantStored, order.ID); exists != want {
+					t.Errorf("order %s stored = %v, want %v", order.ID, exists, want)
+				}
+			}
+
+			checkStock(t, adapter, "ITEM-1", tt.wantStock1)
+			checkStock(t, adapter, "ITEM-2", 3)
+		})
+	}
+}
+
+func testAddItemsBatch(t *testing.T, newAdapter func(t *testing.T) testAdapter) {
+	mixed := testItem("ITEM-2", 5, 10, "USD")
+	mixed.ProductionPrice = model.NewMoney(100, "IDR")
+	items := []model.Item{testItem("ITEM-1", 5, 15000, "IDR"), mixed, testItem("ITEM-3", 5, 2, "USD")}
+
+	tests := []struct {
+		mode model.BatchMode
+		want batchOutcome
+	}{
+		{
+			mode: model.BatchPartial,
+			want: batchOutcome{
+				Succeeded: []string{"ITEM-1", "ITEM-3"},
+				Failed:    []model.BatchFailureReason{"", model.FailureInvalid, ""},
+			},
+		},
+		{
+			mode: model.BatchAtomic,
+			want: batchOutcome{
+				Failed: []model.BatchFailureReason{model.FailureRolledBack, model.FailureInvalid, model.FailureRolledBack},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			adapter := newAdapter(t)
+
+			result, err := adapter.AddItems(items, tt.mode)
+			if err != nil {
+				t.Fatalf("AddItems: %v", err)
+			}
+			if got := outcomeOf(result, len(items)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outcome = %+v, want %+v", got, tt.want)
+			}
+
+			inventory, err := adapter.GetInventory()
+			if err != nil {
+				t.Fatalf("GetInventory: %v", err)
+			}
+			if len(inventory) != len(tt.want.Succeeded) {
+				t.Errorf("inventory has %d items, want %d", len(inventory), len(tt.want.Succeeded))
+			}
+		})
+	}
+}
+
+// testOversellPolicy - Orders ITEM-1 (5 in stock) under every combination of
+// deployment and item policy
+func testOversellPolicy(t *testing.T, newAdapter func(t *testing.T) testAdapter) {
+	tests := []struct {
+		name         string
+		deployment   model.OversellPolicy
+		item         model.OversellPolicy
+		previous     int // quantity of an earlier version of the order, 0 for none
+		quantity     int
+		wantErr      bool
+		wantStock    int
+		wantOversold bool
+	}{
+		{name: "within stock", deployment: model.OversellReject, quantity: 5, wantStock: 0},
+		{name: "reject", deployment: model.OversellReject, quantity: 7, wantErr: true, wantStock: 5},
+		{name: "allow", deployment: model.OversellAllow, quantity: 7, wantStock: -2},
+		{name: "flag", deployment: model.OversellFlag, quantity: 7, wantStock: -2, wantOversold: true},
+		{name: "item allows", deployment: model.OversellReject, item: model.OversellAllow, quantity: 7, wantStock: -2},
+		{name: "item rejects", deployment: model.OversellAllow, item: model.OversellReject, quantity: 7, wantErr: true, wantStock: 5},
+		{name: "replacing needs the difference", deployment: model.OversellReject, previous: 4, quantity: 5, wantStock: 0},
+		{name: "replacing beyond stock", deployment: model.OversellReject, previous: 4, quantity: 7, wantErr: true, wantStock: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newAdapter(t)
+			adapter.SetOversellPolicy(tt.deployment)
+			item := testItem("ITEM-1", 5, 15000, "IDR")
+			item.OversellPolicy = tt.item
+			seedItems(t, adapter, item)
+
+			if tt.previous > 0 {
+				if err := adapter.AddOrder(testOrder("ORD-1", 1, map[string]int{"ITEM-1": tt.previous})); err != nil {
+					t.Fatalf("AddOrder (previous): %v", err)
+				}
+			}
+
+			err := adapter.AddOrder(testOrder("ORD-1", 2, map[string]int{"ITEM-1": tt.quantity}))
+			var stockErr *model.InsufficientStockError
+			if tt.wantErr {
+				if !errors.As(err, &stockErr) {
+					t.Fatalf("AddOrder returned %v, want an InsufficientStockError", err)
+				}
+				available := 5 - tt.previous
+				if stockErr.ItemID != "ITEM-1" || stockErr.Requested != tt.quantity-tt.previous || stockErr.Available != available {
+					t.Errorf("error = %+v, want ITEM-1 requesting %d of %d", *stockErr, tt.quantity-tt.previous, available)
+				}
+			} else if err != nil {
+				t.Fatalf("AddOrder: %v", err)
+			}
+
+			checkStock(t, adapter, "ITEM-1", tt.wantStock)
+
+			movements, err := adapter.GetStockMovements("ITEM-1")
+			if err != nil {
+				t.Fatalf("GetStockMovements: %v", err)
+			}
+			last := movements[len(movements)-1]
+			if tt.wantErr {
+				if tt.previous == 0 && last.Reason != model.StockReasonOpeningBalance {
+					t.Errorf("rejected order left a %s movement", last.Reason)
+				}
+				return
+			}
+			if last.Reason != model.StockReasonSale || last.Delta != -tt.quantity || last.Oversold != tt.wantOversold {
+				t.Errorf("last movement = %s %d (oversold %v), want sale %d (oversold %v)", last.Reason, last.Delta, last.Oversold, -tt.quantity, tt.wantOversold)
+			}
+		})
+	}
+}
+
+// testQueryOrdersPages - Pages through orders two at a time and checks every
+// order comes back once, in order. Totals are in JPY, which has no minor unit,
+// except for one USD order whose lower-case ID sorts after the others byte by
+// byte; two orders tie on total and two on completion time.
+func testQueryOrdersPages(t *testing.T, newAdapter func(t *testing.T) testAdapter) {
+	adapter := newAdapter(t)
+	seedItems(t, adapter, testItem("ITEM-1", 100, 1500, "JPY"), testItem("ITEM-2", 100, 800, "JPY"), testItem("ITEM-3", 100, 5, "USD"))
+
+	orders := []struct {
+		id         string
+		minute     int
+		quantities map[string]int
+		total      model.Money
+	}{
+		{"ORD-1", 1, map[string]int{"ITEM-1": 1}, model.NewMoney(1500, "JPY")},
+		{"ORD-2", 2, map[string]int{"ITEM-2": 1}, model.NewMoney(800, "JPY")},
+		{"ORD-3", 3, map[string]int{"ITEM-1": 2}, model.NewMoney(3000, "JPY")},
+		{"ORD-4", 3, map[string]int{"ITEM-2": 1}, model.NewMoney(800, "JPY")},
+		{"ord-0", 4, map[string]int{"ITEM-3": 1}, model.NewMoney(500, "USD")},
+		{"ORD-5", 5, map[string]int{"ITEM-1": 1, "ITEM-2": 2}, model.NewMoney(3100, "JPY")},
+	}
+	for _, o := range orders {
+		order := testOrder(o.id, o.minute, o.quantities)
+		order.Total = o.total
+		if err := adapter.AddOrder(order); err != nil {
+			t.Fatalf("AddOrder(%s): %v", o.id, err)
+		}
+	}
+
+	minTotal := model.NewMoney(1000, "JPY")
+	tests := []struct {
+		query model.OrderQuery
+		want  []string
+	}{
+		{query: model.OrderQuery{}, want: []string{"ORD-5", "ord-0", "ORD-4", "ORD-3", "ORD-2", "ORD-1"}},
+		{query: model.OrderQuery{SortBy: model.SortByCompletedAt}, want: []string{"ORD-1", "ORD-2", "ORD-3", "ORD-4", "ord-0", "ORD-5"}},
+		{query: model.OrderQuery{SortBy: model.SortByTotal}, want: []string{"ORD-2", "ORD-4", "ORD-1", "ORD-3", "ORD-5", "ord-0"}},
+		{query: model.OrderQuery{SortBy: model.SortByTotal, Descending: true}, want: []string{"ord-0", "ORD-5", "ORD-3", "ORD-1", "ORD-4", "ORD-2"}},
+		{query: model.OrderQuery{SortBy: model.SortByID}, want: []string{"ORD-1", "ORD-2", "ORD-3", "ORD-4", "ORD-5", "ord-0"}},
+		{query: model.OrderQuery{SortBy: model.SortByID, Descending: true}, want: []string{"ord-0", "ORD-5", "ORD-4", "ORD-3", "ORD-2", "ORD-1"}},
+		{query: model.OrderQuery{SortBy: model.SortByTotal, ItemID: "ITEM-2"}, want: []string{"ORD-2", "ORD-4", "ORD-5"}},
+		{query: model.OrderQuery{SortBy: model.SortByTotal, MinTotal: &minTotal}, want: []string{"ORD-1", "ORD-3", "ORD-5"}},
+		{query: model.OrderQuery{Start: testTime.Add(2 * time.Minute), End: testTime.Add(5 * time.Minute)}, want: []string{"ord-0", "ORD-4", "ORD-3", "ORD-2"}},
+	}
+
+	for _, tt := range tests {
+		query := tt.query
+		query.Limit = 2
+
+		var got []string
+		for page := 0; ; page++ {
+			if page > len(tt.want) {
+				t.Fatalf("%+v: pagination doesn't end", tt.query)
+			}
+			result, err := adapter.QueryOrders(query)
+			if err != nil {
+				t.Fatalf("%+v: QueryOrders: %v", tt.query, err)
+			}
+			for _, order := range result.Orders {
+				got = append(got, order.ID)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			query.Cursor = result.NextCursor
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: pages = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// A cursor only continues the query it was issued for
+	first, err := adapter.QueryOrders(model.OrderQuery{SortBy: model.SortByTotal, Limit: 2})
+	if err != nil {
+		t.Fatalf("QueryOrders: %v", err)
+	}
+	if _, err := adapter.QueryOrders(model.OrderQuery{SortBy: model.SortByTotal, ItemID: "ITEM-1", Limit: 2, Cursor: first.NextCursor}); !errors.Is(err, model.ErrInvalidCursor) {
+		t.Errorf("QueryOrders with a cursor from other filters returned %v, want ErrInvalidCursor", err)
+	}
+	if _, err := adapter.QueryOrders(model.OrderQuery{SortBy: "price"}); !errors.Is(err, model.ErrInvalid) {
+		t.Errorf("QueryOrders sorted by an unknown column returned %v, want ErrInvalid", err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func newSQLiteTestAdapter(t *testing.T) testAdapter {
+	return newTestSQLiteAdapter(t)
+}
+
+func TestSQLiteAddOrdersBatch(t *testing.T) {
+	testAddOrdersBatch(t, newSQLiteTestAdapter)
+}
+
+func TestSQLiteAddItemsBatch(t *testing.T) {
+	testAddItemsBatch(t, newSQLiteTestAdapter)
+}
+
+func TestSQLiteOversellPolicy(t *testing.T) {
+	testOversellPolicy(t, newSQLiteTestAdapter)
+}
+
+func TestSQLiteQueryOrdersPages(t *testing.T) {
+	testQueryOrdersPages(t, newSQLiteTestAdapter)
+}
@@ -0,0 +1,111 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// recordPriceChange - Appends the item's current price and production price
+// to its price history, effective now
+func (s *SQLitePosAdapter) recordPriceChange(tx *sqlx.Tx, item model.Item) error {
+	query := `
+        INSERT INTO item_price_history (item_id, price, production_price, currency, effective_from, recorded_at)
+        VALUES (?1, ?2, ?3, ?4, ?5, ?5)`
+
+	_, err := tx.Exec(query, item.ID, item.Price.Amount, item.ProductionPrice.Amount, item.Price.CurrencyCode(), sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Failed to record price change for item %s: %v", item.ID, err)
+		return fmt.Errorf("failed to record price change for item %s: %w", item.ID, err)
+	}
+
+	return nil
+}
+
+// GetItemPriceHistory - Returns the price timeline of an item, oldest first
+func (s *SQLitePosAdapter) GetItemPriceHistory(itemID string) ([]model.ItemPrice, error) {
+	query := `
+        SELECT id, item_id, price, production_price, currency, effective_from, recorded_at
+        FROM item_price_history
+        WHERE item_id = ?
+        ORDER BY effective_from, id`
+
+	var rows []sqlitePriceRow
+	if err := s.db.Select(&rows, query, itemID); err != nil {
+		log.Printf("Failed to query price history for item %s: %v", itemID, err)
+		return nil, fmt.Errorf("failed to query price history for item %s: %w", itemID, err)
+	}
+
+	prices := make([]model.ItemPrice, 0, len(rows))
+	for _, row := range rows {
+		price, err := row.toItemPrice()
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+
+	return prices, nil
+}
+
+// GetItemPriceAsOf - Returns the price of an item that was valid at the given time
+func (s *SQLitePosAdapter) GetItemPriceAsOf(itemID string, at time.Time) (*model.ItemPrice, error) {
+	query := `
+        SELECT id, item_id, price, production_price, currency, effective_from, recorded_at
+        FROM item_price_history
+        WHERE item_id = ? AND effective_from <= ?
+        ORDER BY effective_from DESC, id DESC
+        LIMIT 1`
+
+	var row sqlitePriceRow
+	err := s.db.Get(&row, query, itemID, sqliteTime(at))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Printf("Failed to query price of item %s as of %s: %v", itemID, at.Format(time.RFC3339), err)
+		return nil, fmt.Errorf("failed to query price of item %s: %w", itemID, err)
+	}
+
+	price, err := row.toItemPrice()
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// sqlitePriceRow is one row of item_price_history
+type sqlitePriceRow struct {
+	ID              int64  `db:"id"`
+	ItemID          string `db:"item_id"`
+	Price           int64  `db:"price"`
+	ProductionPrice int64  `db:"production_price"`
+	Currency        string `db:"currency"`
+	EffectiveFrom   string `db:"effective_from"`
+	RecordedAt      string `db:"recorded_at"`
+}
+
+func (r sqlitePriceRow) toItemPrice() (model.ItemPrice, error) {
+	effectiveFrom, err := parseSQLiteTime(r.EffectiveFrom)
+	if err != nil {
+		return model.ItemPrice{}, fmt.Errorf("price history entry %d: %w", r.ID, err)
+	}
+	recordedAt, err := parseSQLiteTime(r.RecordedAt)
+	if err != nil {
+		return model.ItemPrice{}, fmt.Errorf("price history entry %d: %w", r.ID, err)
+	}
+
+	return model.ItemPrice{
+		ID:              r.ID,
+		ItemID:          r.ItemID,
+		Price:           model.NewMoney(r.Price, r.Currency),
+		ProductionPrice: model.NewMoney(r.ProductionPrice, r.Currency),
+		EffectiveFrom:   effectiveFrom,
+		RecordedAt:      recordedAt,
+	}, nil
+}
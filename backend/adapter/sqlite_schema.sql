@@ -0,0 +1,70 @@
+-- Schema of the embedded SQLite database, applied every time it is opened.
+--
+-- Amounts are INTEGER minor units of the row's currency so sums stay exact,
+-- and timestamps are fixed-width UTC TEXT (see sqliteTimeFormat) so they sort
+-- and compare as strings.
+
+CREATE TABLE IF NOT EXISTS items (
+    id                TEXT PRIMARY KEY,
+    name              TEXT NOT NULL,
+    stock             INTEGER NOT NULL DEFAULT 0,
+    price             INTEGER NOT NULL DEFAULT 0,
+    production_price  INTEGER NOT NULL DEFAULT 0,
+    currency          TEXT NOT NULL DEFAULT 'IDR',
+    oversell_policy   TEXT CHECK (oversell_policy IN ('reject', 'allow', 'flag'))
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id            TEXT PRIMARY KEY,
+    total         INTEGER NOT NULL DEFAULT 0,
+    currency      TEXT NOT NULL DEFAULT 'IDR',
+    completed_at  TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_completed_at ON orders (completed_at, id);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id    TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    item_id     TEXT NOT NULL,
+    quantity    INTEGER NOT NULL,
+    unit_price  INTEGER NOT NULL DEFAULT 0,
+    unit_cost   INTEGER NOT NULL DEFAULT 0,
+    discount    INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_item ON order_items (item_id);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id     TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    order_id    TEXT,
+    delta       INTEGER NOT NULL,
+    reason      TEXT NOT NULL,
+    oversold    INTEGER NOT NULL DEFAULT 0,
+    created_at  TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_item ON stock_movements (item_id, id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order ON stock_movements (order_id);
+
+CREATE TABLE IF NOT EXISTS item_price_history (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    item_id           TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    price             INTEGER NOT NULL,
+    production_price  INTEGER NOT NULL,
+    currency          TEXT NOT NULL DEFAULT 'IDR',
+    effective_from    TEXT NOT NULL,
+    recorded_at       TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_price_history_item ON item_price_history (item_id, effective_from);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    from_currency  TEXT NOT NULL,
+    to_currency    TEXT NOT NULL,
+    rate           TEXT NOT NULL,
+    updated_at     TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (from_currency, to_currency)
+);
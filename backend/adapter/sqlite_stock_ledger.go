@@ -0,0 +1,263 @@
+package adapter
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// applyOrderStock - Reverses the lines of any previously stored version of the
+// order and records its new lines, enforcing the oversell policy. Must run
+// before the old order_items are deleted. Nothing is written if the order is
+// rejected.
+func (s *SQLitePosAdapter) applyOrderStock(tx *sqlx.Tx, order model.Order) error {
+	var previous []model.OrderItem
+	err := tx.Select(&previous, `SELECT item_id AS itemid, quantity FROM order_items WHERE order_id = ?`, order.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load previous lines of order %s: %w", order.ID, err)
+	}
+
+	netDemand := netStockDemand(previous, order.Items)
+
+	oversold, err := s.checkStock(tx, order.ID, netDemand)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range previous {
+		movement := model.StockMovement{ItemID: line.ItemID, OrderID: &order.ID, Delta: line.Quantity, Reason: model.StockReasonOrderReversal}
+		if err := s.recordStockMovement(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	for _, line := range order.Items {
+		movement := model.StockMovement{ItemID: line.ItemID, OrderID: &order.ID, Delta: -line.Quantity, Reason: model.StockReasonSale, Oversold: oversold[line.ItemID]}
+		if err := s.recordStockMovement(tx, movement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkStock - Applies the oversell policy to the affected items. The single
+// connection already serializes writers, so no row locks are needed.
+func (s *SQLitePosAdapter) checkStock(tx *sqlx.Tx, orderID string, netDemand map[string]int) (map[string]bool, error) {
+	itemIDs := make([]string, 0, len(netDemand))
+	for itemID := range netDemand {
+		itemIDs = append(itemIDs, itemID)
+	}
+	if len(itemIDs) == 0 {
+		return nil, nil
+	}
+
+	query, args, err := sqlx.In(`
+        SELECT id, stock, COALESCE(oversell_policy, '') AS oversellpolicy
+        FROM items
+        WHERE id IN (?)
+        ORDER BY id`, itemIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stock query for order %s: %w", orderID, err)
+	}
+
+	var items []model.Item
+	if err := tx.Select(&items, tx.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("failed to read stock for order %s: %w", orderID, err)
+	}
+
+	return checkOversell(orderID, items, netDemand, s.oversellPolicy)
+}
+
+// recordStockMovement - Appends a movement to the ledger and applies it to items.stock.
+// Lines for items that are not in the inventory are ignored.
+func (s *SQLitePosAdapter) recordStockMovement(tx *sqlx.Tx, movement model.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+
+	if err := s.insertStockMovement(tx, movement); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`UPDATE items SET stock = stock + ? WHERE id = ?`, movement.Delta, movement.ItemID)
+	if err != nil {
+		return fmt.Errorf("failed to update stock of item %s: %w", movement.ItemID, err)
+	}
+
+	return nil
+}
+
+// insertStockMovement - Appends a movement to the ledger without touching items.stock
+func (s *SQLitePosAdapter) insertStockMovement(tx *sqlx.Tx, movement model.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+
+	query := `
+        INSERT INTO stock_movements (item_id, order_id, delta, reason, oversold, created_at)
+        SELECT ?1, ?2, ?3, ?4, ?5, ?6
+        WHERE EXISTS (SELECT 1 FROM items WHERE id = ?1)`
+
+	_, err := tx.Exec(query, movement.ItemID, movement.OrderID, movement.Delta, movement.Reason, movement.Oversold, sqliteTime(time.Now()))
+	if err != nil {
+		log.Printf("Failed to record stock movement for item %s: %v", movement.ItemID, err)
+		return fmt.Errorf("failed to record stock movement for item %s: %w", movement.ItemID, err)
+	}
+
+	return nil
+}
+
+// snapshotPrices - Fills in the unit price and cost of lines that were
+// submitted without a snapshot, using the item's price at the time of writing
+func (s *SQLitePosAdapter) snapshotPrices(tx *sqlx.Tx, order *model.Order) error {
+	var missing []string
+	for _, line := range order.Items {
+		if !line.HasSnapshot() {
+			missing = append(missing, line.ItemID)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In(`
+        SELECT id, name, stock, price, production_price, currency
+        FROM items
+        WHERE id IN (?)`, missing)
+	if err != nil {
+		return fmt.Errorf("failed to build price query for order %s: %w", order.ID, err)
+	}
+
+	var rows []sqliteItemRow
+	if err := tx.Select(&rows, tx.Rebind(query), args...); err != nil {
+		return fmt.Errorf("failed to read prices for order %s: %w", order.ID, err)
+	}
+
+	itemMap := make(map[string]model.Item)
+	for _, row := range rows {
+		itemMap[row.ID] = row.toItem()
+	}
+
+	// Copy the lines so the caller's order isn't modified
+	order.Items = append([]model.OrderItem(nil), order.Items...)
+	for i, line := range order.Items {
+		if item, exists := itemMap[line.ItemID]; exists && !line.HasSnapshot() {
+			order.Items[i].UnitPrice = item.Price
+			order.Items[i].UnitCost = item.ProductionPrice
+		}
+	}
+
+	return nil
+}
+
+// upsertItem - Inserts or updates an item, recording the stock difference as
+// an adjustment and any price change in the price history
+func (s *SQLitePosAdapter) upsertItem(tx *sqlx.Tx, item model.Item) error {
+	currency, err := itemCurrency(item, s.currency)
+	if err != nil {
+		return err
+	}
+	item.Price = item.Price.WithCurrency(currency)
+	item.ProductionPrice = item.ProductionPrice.WithCurrency(currency)
+
+	var previousRow sqliteItemRow
+	err = tx.Get(&previousRow, `SELECT id, name, stock, price, production_price, currency FROM items WHERE id = ?`, item.ID)
+	isNew := errors.Is(err, sql.ErrNoRows)
+	if err != nil && !isNew {
+		return fmt.Errorf("failed to read item %s: %w", item.ID, err)
+	}
+	previous := previousRow.toItem()
+
+	query := `
+        INSERT INTO items (id, name, stock, price, production_price, oversell_policy, currency)
+        VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?)
+        ON CONFLICT (id) DO UPDATE SET
+            name = excluded.name,
+            stock = excluded.stock,
+            price = excluded.price,
+            production_price = excluded.production_price,
+            oversell_policy = excluded.oversell_policy,
+            currency = excluded.currency`
+
+	_, err = tx.Exec(query, item.ID, item.Name, item.Stock, item.Price.Amount, item.ProductionPrice.Amount, string(item.OversellPolicy), currency)
+	if err != nil {
+		return fmt.Errorf("failed to add item %s: %w", item.ID, err)
+	}
+
+	reason := model.StockReasonAdjustment
+	if isNew {
+		reason = model.StockReasonOpeningBalance
+	}
+
+	if err := s.insertStockMovement(tx, model.StockMovement{ItemID: item.ID, Delta: item.Stock - previous.Stock, Reason: reason}); err != nil {
+		return err
+	}
+
+	if isNew || previous.Price != item.Price.WithCurrency(currency) || previous.ProductionPrice != item.ProductionPrice.WithCurrency(currency) {
+		return s.recordPriceChange(tx, item)
+	}
+
+	return nil
+}
+
+// GetStockMovements - Returns the stock ledger of an item, oldest first
+func (s *SQLitePosAdapter) GetStockMovements(itemID string) ([]model.StockMovement, error) {
+	query := `
+        SELECT id, item_id, order_id, delta, reason, oversold, created_at
+        FROM stock_movements
+        WHERE item_id = ?
+        ORDER BY id`
+
+	var rows []sqliteMovementRow
+	if err := s.db.Select(&rows, query, itemID); err != nil {
+		log.Printf("Failed to query stock movements for item %s: %v", itemID, err)
+		return nil, fmt.Errorf("failed to query stock movements for item %s: %w", itemID, err)
+	}
+
+	movements := make([]model.StockMovement, 0, len(rows))
+	for _, row := range rows {
+		createdAt, err := parseSQLiteTime(row.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("stock movement %d: %w", row.ID, err)
+		}
+		movements = append(movements, model.StockMovement{
+			ID:        row.ID,
+			ItemID:    row.ItemID,
+			OrderID:   row.OrderID,
+			Delta:     row.Delta,
+			Reason:    row.Reason,
+			Oversold:  row.Oversold,
+			CreatedAt: createdAt,
+		})
+	}
+
+	return movements, nil
+}
+
+// GetLedgerStock - Derives the current stock of an item from its ledger
+func (s *SQLitePosAdapter) GetLedgerStock(itemID string) (int, error) {
+	var stock int
+	err := s.db.Get(&stock, `SELECT COALESCE(SUM(delta), 0) FROM stock_movements WHERE item_id = ?`, itemID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum stock movements for item %s: %w", itemID, err)
+	}
+
+	return stock, nil
+}
+
+// sqliteMovementRow is one row of stock_movements
+type sqliteMovementRow struct {
+	ID        int64   `db:"id"`
+	ItemID    string  `db:"item_id"`
+	OrderID   *string `db:"order_id"`
+	Delta     int     `db:"delta"`
+	Reason    string  `db:"reason"`
+	Oversold  bool    `db:"oversold"`
+	CreatedAt string  `db:"created_at"`
+}
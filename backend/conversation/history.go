@@ -0,0 +1,144 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/YudaClairee/garudahacks/llm"
+)
+
+// HistoryOptions limit the history sent to the model with each chat message.
+// Zero fields get the defaults listed below.
+type HistoryOptions struct {
+	MaxMessages int  // newest messages sent verbatim (default 20)
+	MaxTokens   int  // estimated token budget of those messages (default 4000)
+	Summarize   bool // summarize older messages instead of only dropping them
+}
+
+func (o HistoryOptions) withDefaults() HistoryOptions {
+	if o.MaxMessages <= 0 {
+		o.MaxMessages = 20
+	}
+	if o.MaxTokens <= 0 {
+		o.MaxTokens = 4000
+	}
+	return o
+}
+
+// Summarizer condenses messages that no longer fit the history, folding in
+// the summary of the messages before them
+type Summarizer func(ctx context.Context, previous string, messages []Message) (string, error)
+
+// Window is the part of a conversation sent to the model: a summary of the
+// older messages and the newest messages verbatim
+type Window struct {
+	Summary  string
+	Messages []Message
+}
+
+// History fits conversations into the model's context window
+type History struct {
+	store      Store
+	options    HistoryOptions
+	summarizer Summarizer
+}
+
+// NewHistory - summarizer may be nil, in which case older messages are only
+// dropped, as they also are when options.Summarize is off
+func NewHistory(store Store, options HistoryOptions, summarizer Summarizer) *History {
+	options = options.withDefaults()
+	if !options.Summarize {
+		summarizer = nil
+	}
+	return &History{store: store, options: options, summarizer: summarizer}
+}
+
+// Window - Returns the window of a conversation. Messages trimmed since the
+// last summary are summarized first; if that fails they are left out and the
+// previous summary is kept, to be retried on the next message.
+func (h *History) Window(ctx context.Context, conversation *Conversation) (Window, error) {
+	messages, err := h.store.Messages(conversation.ID)
+	if err != nil {
+		return Window{}, err
+	}
+
+	start := h.trim(messages)
+	window := Window{Summary: conversation.Summary, Messages: messages[start:]}
+	if h.summarizer == nil {
+		return window, nil
+	}
+
+	var unsummarized []Message
+	for _, message := range messages[:start] {
+		if message.ID > conversation.SummarizedThrough {
+			unsummarized = append(unsummarized, message)
+		}
+	}
+	if len(unsummarized) == 0 {
+		return window, nil
+	}
+
+	summary, err := h.summarizer(ctx, conversation.Summary, unsummarized)
+	if err != nil {
+		log.Printf("Failed to summarize conversation %s, leaving %d older messages out: %v", conversation.ID, len(unsummarized), err)
+		return window, nil
+	}
+
+	through := unsummarized[len(unsummarized)-1].ID
+	if err := h.store.SetSummary(conversation.ID, summary, through); err != nil {
+		return Window{}, err
+	}
+	window.Summary = summary
+	return window, nil
+}
+
+// trim - Returns the index of the oldest message kept: the newest messages
+// within MaxMessages and MaxTokens, starting with a user message. The newest
+// message is always kept.
+func (h *History) trim(messages []Message) int {
+	start, tokens := len(messages), 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		cost := estimateTokens(messages[i].Content)
+		if start < len(messages) && (len(messages)-i > h.options.MaxMessages || tokens+cost > h.options.MaxTokens) {
+			break
+		}
+		start, tokens = i, tokens+cost
+	}
+
+	// A reply without its question confuses the model
+	for start < len(messages)-1 && messages[start].Role != llm.RoleUser {
+		start++
+	}
+	return start
+}
+
+// estimateTokens - A rough token count: about four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// LLMSummarizer - Returns a Summarizer that asks the model of profile for the
+// summary
+func LLMSummarizer(profile *llm.Profile) Summarizer {
+	return func(ctx context.Context, previous string, messages []Message) (string, error) {
+		var transcript strings.Builder
+		if previous != "" {
+			fmt.Fprintf(&transcript, "Summary of the conversation so far:\n%s\n\n", previous)
+		}
+		transcript.WriteString("Messages to add to the summary:\n")
+		for _, message := range messages {
+			fmt.Fprintf(&transcript, "%s: %s\n", message.Role, message.Content)
+		}
+
+		resp, err := profile.Complete(ctx,
+			llm.Message{Role: llm.RoleSystem, Content: `Summarize this conversation between a business owner and their POS analytics assistant in at most 10 short lines. Keep the questions asked, the figures and periods discussed, and any decisions or preferences the user stated. Reply with the summary only.`},
+			llm.Message{Role: llm.RoleUser, Content: transcript.String()},
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize conversation: %w", err)
+		}
+		return strings.TrimSpace(resp.Content), nil
+	}
+}
@@ -0,0 +1,116 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/YudaClairee/garudahacks/llm"
+)
+
+// turns - Alternating user and assistant messages with the given contents,
+// starting with the user
+func turns(contents ...string) []Message {
+	messages := make([]Message, len(contents))
+	for i, content := range contents {
+		role := llm.RoleUser
+		if i%2 == 1 {
+			role = llm.RoleAssistant
+		}
+		messages[i] = Message{ID: int64(i + 1), Role: role, Content: content}
+	}
+	return messages
+}
+
+func TestHistoryTrim(t *testing.T) {
+	short := strings.Repeat("x", 8) // 2 tokens
+	long := strings.Repeat("x", 40) // 10 tokens
+
+	tests := []struct {
+		name     string
+		options  HistoryOptions
+		messages []Message
+		want     int
+	}{
+		{name: "no messages", messages: nil, want: 0},
+		{name: "everything fits", messages: turns(short, short, short, short), want: 0},
+		{name: "message limit", options: HistoryOptions{MaxMessages: 2}, messages: turns(short, short, short, short), want: 2},
+		{name: "message limit starts at a user message", options: HistoryOptions{MaxMessages: 3}, messages: turns(short, short, short, short), want: 2},
+		{name: "token budget", options: HistoryOptions{MaxTokens: 10}, messages: turns(short, long, short, short, short), want: 2},
+		{name: "token budget starts at a user message", options: HistoryOptions{MaxTokens: 7}, messages: turns(short, short, short, short, short, short), want: 4},
+		{name: "newest message over the budget", options: HistoryOptions{MaxTokens: 5}, messages: turns(short, long), want: 1},
+		{name: "newest user message over the budget", options: HistoryOptions{MaxTokens: 5}, messages: turns(short, short, long), want: 2},
+	}
+
+	for _, tt := range tests {
+		history := NewHistory(NewMemoryStore(), tt.options, nil)
+		if got := history.trim(tt.messages); got != tt.want {
+			t.Errorf("%s: trim = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryWindowSummarizesTrimmedMessages(t *testing.T) {
+	store := NewMemoryStore()
+	conversation, err := store.Create("Sales")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.AddMessages(conversation.ID, turns("q1", "a1", "q2", "a2", "q3", "a3")...); err != nil {
+		t.Fatalf("AddMessages: %v", err)
+	}
+
+	var summarized [][]string
+	failing := false
+	summarizer := func(ctx context.Context, previous string, messages []Message) (string, error) {
+		if failing {
+			return "", errors.New("model unavailable")
+		}
+		var contents []string
+		for _, message := range messages {
+			contents = append(contents, message.Content)
+		}
+		summarized = append(summarized, contents)
+		return strings.TrimSpace(previous + " " + strings.Join(contents, " ")), nil
+	}
+	history := NewHistory(store, HistoryOptions{MaxMessages: 2, Summarize: true}, summarizer)
+
+	window := func() Window {
+		t.Helper()
+		current, err := store.Get(conversation.ID)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		window, err := history.Window(context.Background(), current)
+		if err != nil {
+			t.Fatalf("Window: %v", err)
+		}
+		return window
+	}
+
+	// The four older messages are summarized once
+	for i := 0; i < 2; i++ {
+		got := window()
+		if got.Summary != "q1 a1 q2 a2" || len(got.Messages) != 2 || got.Messages[0].Content != "q3" {
+			t.Errorf("window %d = %+v, want the summary of q1 to a2, then q3 and a3", i+1, got)
+		}
+	}
+	if fmt.Sprint(summarized) != "[[q1 a1 q2 a2]]" {
+		t.Errorf("summarized %v, want q1 to a2 once", summarized)
+	}
+
+	// Messages trimmed while summarizing fails are summarized on a later call
+	if err := store.AddMessages(conversation.ID, turns("q4", "a4")...); err != nil {
+		t.Fatalf("AddMessages: %v", err)
+	}
+	failing = true
+	if got := window(); got.Summary != "q1 a1 q2 a2" || len(got.Messages) != 2 || got.Messages[0].Content != "q4" {
+		t.Errorf("window while failing = %+v, want the previous summary, then q4 and a4", got)
+	}
+	failing = false
+	if got := window(); got.Summary != "q1 a1 q2 a2 q3 a3" {
+		t.Errorf("summary = %q, want q3 and a3 added", got.Summary)
+	}
+}
@@ -0,0 +1,25 @@
+-- Chatbot conversations in the embedded SQLite database, applied every time
+-- the store is opened. Mirrors migrations/0010_chat_conversations for
+-- Postgres; timestamps are fixed-width UTC TEXT (see sqliteTimeFormat) so
+-- they sort and compare as strings.
+
+CREATE TABLE IF NOT EXISTS chat_conversations (
+    id                  TEXT PRIMARY KEY,
+    title               TEXT NOT NULL,
+    summary             TEXT NOT NULL DEFAULT '',
+    summarized_through  INTEGER NOT NULL DEFAULT 0,
+    created_at          TEXT NOT NULL,
+    updated_at          TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_conversations_updated_at ON chat_conversations (updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id  TEXT NOT NULL REFERENCES chat_conversations (id) ON DELETE CASCADE,
+    role             TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content          TEXT NOT NULL,
+    created_at       TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_messages_conversation ON chat_messages (conversation_id, id);
@@ -0,0 +1,236 @@
+package conversation
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// sqliteTimeFormat is how timestamps are stored, the same as the SQLite POS
+// adapter: always UTC and fixed width, so comparing the strings compares the
+// times
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteStore keeps conversations in the embedded SQLite database of the
+// sqlite POS provider
+type SQLiteStore struct {
+	db  *sqlx.DB
+	now func() time.Time
+}
+
+// NewSQLiteStore - Creates the store and applies its schema to db, which must
+// come from adapter.OpenSQLite
+func NewSQLiteStore(db *sqlx.DB) (*SQLiteStore, error) {
+	if db == nil {
+		return nil, errors.New("sqlite conversation store needs a database")
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("failed to apply conversation schema: %w", err)
+	}
+	return &SQLiteStore{db: db, now: time.Now}, nil
+}
+
+// sqliteConversationRow is a conversation with its timestamps as stored
+type sqliteConversationRow struct {
+	ID                string `db:"id"`
+	Title             string `db:"title"`
+	Summary           string `db:"summary"`
+	SummarizedThrough int64  `db:"summarized_through"`
+	MessageCount      int    `db:"message_count"`
+	CreatedAt         string `db:"created_at"`
+	UpdatedAt         string `db:"updated_at"`
+}
+
+func (r sqliteConversationRow) conversation() (Conversation, error) {
+	createdAt, err := parseSQLiteTime(r.CreatedAt)
+	if err != nil {
+		return Conversation{}, err
+	}
+	updatedAt, err := parseSQLiteTime(r.UpdatedAt)
+	if err != nil {
+		return Conversation{}, err
+	}
+	return Conversation{
+		ID:                r.ID,
+		Title:             r.Title,
+		Summary:           r.Summary,
+		SummarizedThrough: r.SummarizedThrough,
+		MessageCount:      r.MessageCount,
+		CreatedAt:         createdAt,
+		UpdatedAt:         updatedAt,
+	}, nil
+}
+
+// sqliteMessageRow is a message with its timestamp as stored
+type sqliteMessageRow struct {
+	ID             int64  `db:"id"`
+	ConversationID string `db:"conversation_id"`
+	Role           string `db:"role"`
+	Content        string `db:"content"`
+	CreatedAt      string `db:"created_at"`
+}
+
+func (s *SQLiteStore) Create(title string) (*Conversation, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.timestamp()
+	query := `INSERT INTO chat_conversations (id, title, created_at, updated_at) VALUES (?, ?, ?, ?)`
+	if _, err := s.db.Exec(query, id, title, now, now); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return s.Get(id)
+}
+
+func (s *SQLiteStore) Get(id string) (*Conversation, error) {
+	var row sqliteConversationRow
+	err := s.db.Get(&row, selectConversation+` WHERE c.id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", id, err)
+	}
+
+	conversation, err := row.conversation()
+	if err != nil {
+		return nil, fmt.Errorf("conversation %s: %w", id, err)
+	}
+	return &conversation, nil
+}
+
+func (s *SQLiteStore) List(limit int) ([]Conversation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var rows []sqliteConversationRow
+	query := selectConversation + ` ORDER BY c.updated_at DESC, c.id LIMIT ?`
+	if err := s.db.Select(&rows, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+
+	conversations := make([]Conversation, 0, len(rows))
+	for _, row := range rows {
+		conversation, err := row.conversation()
+		if err != nil {
+			return nil, fmt.Errorf("conversation %s: %w", row.ID, err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	return conversations, nil
+}
+
+func (s *SQLiteStore) Rename(id, title string) error {
+	query := `UPDATE chat_conversations SET title = ?, updated_at = ? WHERE id = ?`
+	return s.execOne(query, "rename", id, title, s.timestamp())
+}
+
+func (s *SQLiteStore) Delete(id string) error {
+	// Messages go with the conversation (ON DELETE CASCADE)
+	query := `DELETE FROM chat_conversations WHERE id = ?`
+	return s.execOne(query, "delete", id)
+}
+
+func (s *SQLiteStore) AddMessages(id string, messages ...Message) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Touching the conversation first also checks that it exists
+	now := s.timestamp()
+	result, err := tx.Exec(`UPDATE chat_conversations SET updated_at = ? WHERE id = ?`, now, id)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation %s: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+
+	query := `INSERT INTO chat_messages (conversation_id, role, content, created_at) VALUES (?, ?, ?, ?)`
+	for _, message := range messages {
+		if _, err := tx.Exec(query, id, message.Role, message.Content, now); err != nil {
+			return fmt.Errorf("failed to add message to conversation %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Messages(id string) ([]Message, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, conversation_id, role, content, created_at
+        FROM chat_messages
+        WHERE conversation_id = ?
+        ORDER BY id`
+
+	var rows []sqliteMessageRow
+	if err := s.db.Select(&rows, query, id); err != nil {
+		return nil, fmt.Errorf("failed to load messages of conversation %s: %w", id, err)
+	}
+
+	messages := make([]Message, 0, len(rows))
+	for _, row := range rows {
+		createdAt, err := parseSQLiteTime(row.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", row.ID, err)
+		}
+		messages = append(messages, Message{
+			ID:             row.ID,
+			ConversationID: row.ConversationID,
+			Role:           row.Role,
+			Content:        row.Content,
+			CreatedAt:      createdAt,
+		})
+	}
+	return messages, nil
+}
+
+func (s *SQLiteStore) SetSummary(id, summary string, through int64) error {
+	query := `UPDATE chat_conversations SET summary = ?, summarized_through = ? WHERE id = ?`
+	return s.execOne(query, "summarize", id, summary, through)
+}
+
+// execOne - Runs a statement whose last parameter is the conversation ID,
+// returning ErrNotFound when no row matched
+func (s *SQLiteStore) execOne(query, action, id string, args ...interface{}) error {
+	result, err := s.db.Exec(query, append(args, id)...)
+	if err != nil {
+		return fmt.Errorf("failed to %s conversation %s: %w", action, id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// timestamp - The current time as stored
+func (s *SQLiteStore) timestamp() string {
+	return s.now().UTC().Format(sqliteTimeFormat)
+}
+
+func parseSQLiteTime(value string) (time.Time, error) {
+	t, err := time.Parse(sqliteTimeFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stored timestamp %q: %w", value, err)
+	}
+	return t, nil
+}
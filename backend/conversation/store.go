@@ -0,0 +1,339 @@
+// Package conversation keeps chatbot conversations and their message history,
+// and fits the history of long conversations into the model's context window
+// by trimming it and summarizing what was trimmed.
+package conversation
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNotFound is returned for a conversation that doesn't exist
+var ErrNotFound = errors.New("conversation not found")
+
+// Conversation is a chat session. Summary condenses the messages up to and
+// including SummarizedThrough that no longer fit the history sent to the
+// model.
+type Conversation struct {
+	ID                string    `db:"id" json:"id"`
+	Title             string    `db:"title" json:"title"`
+	Summary           string    `db:"summary" json:"summary,omitempty"`
+	SummarizedThrough int64     `db:"summarized_through" json:"-"`
+	MessageCount      int       `db:"message_count" json:"message_count"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Message is one turn of a conversation, from the user or the assistant
+type Message struct {
+	ID             int64     `db:"id" json:"id"`
+	ConversationID string    `db:"conversation_id" json:"-"`
+	Role           string    `db:"role" json:"role"`
+	Content        string    `db:"content" json:"content"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// Store keeps conversations. Every method taking an ID returns ErrNotFound
+// when the conversation doesn't exist. List returns the most recently updated
+// conversations first and Messages the messages oldest first.
+type Store interface {
+	Create(title string) (*Conversation, error)
+	Get(id string) (*Conversation, error)
+	List(limit int) ([]Conversation, error)
+	Rename(id, title string) error
+	Delete(id string) error
+	AddMessages(id string, messages ...Message) error
+	Messages(id string) ([]Message, error)
+	SetSummary(id, summary string, through int64) error
+}
+
+// newID - Returns a random conversation ID
+func newID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("failed to generate conversation ID: %w", err)
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// DBStore keeps conversations in the Postgres chat_conversations and
+// chat_messages tables
+type DBStore struct {
+	db *sqlx.DB
+}
+
+func NewDBStore(db *sqlx.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+const selectConversation = `
+        SELECT c.id, c.title, c.summary, c.summarized_through, c.created_at, c.updated_at,
+               (SELECT COUNT(*) FROM chat_messages m WHERE m.conversation_id = c.id) AS message_count
+        FROM chat_conversations c`
+
+func (s *DBStore) Create(title string) (*Conversation, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO chat_conversations (id, title) VALUES ($1, $2)`
+	if _, err := s.db.Exec(query, id, title); err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return s.Get(id)
+}
+
+func (s *DBStore) Get(id string) (*Conversation, error) {
+	var conversation Conversation
+	err := s.db.Get(&conversation, selectConversation+` WHERE c.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", id, err)
+	}
+	return &conversation, nil
+}
+
+func (s *DBStore) List(limit int) ([]Conversation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	var conversations []Conversation
+	query := selectConversation + ` ORDER BY c.updated_at DESC, c.id LIMIT $1`
+	if err := s.db.Select(&conversations, query, limit); err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	return conversations, nil
+}
+
+func (s *DBStore) Rename(id, title string) error {
+	query := `UPDATE chat_conversations SET title = $2, updated_at = NOW() WHERE id = $1`
+	return s.execOne(query, "rename", id, title)
+}
+
+func (s *DBStore) Delete(id string) error {
+	// Messages go with the conversation (ON DELETE CASCADE)
+	query := `DELETE FROM chat_conversations WHERE id = $1`
+	return s.execOne(query, "delete", id)
+}
+
+func (s *DBStore) AddMessages(id string, messages ...Message) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Touching the conversation first also checks that it exists
+	result, err := tx.Exec(`UPDATE chat_conversations SET updated_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to update conversation %s: %w", id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+
+	query := `INSERT INTO chat_messages (conversation_id, role, content) VALUES ($1, $2, $3)`
+	for _, message := range messages {
+		if _, err := tx.Exec(query, id, message.Role, message.Content); err != nil {
+			return fmt.Errorf("failed to add message to conversation %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *DBStore) Messages(id string) ([]Message, error) {
+	if _, err := s.Get(id); err != nil {
+		return nil, err
+	}
+
+	query := `
+        SELECT id, conversation_id, role, content, created_at
+        FROM chat_messages
+        WHERE conversation_id = $1
+        ORDER BY id`
+
+	var messages []Message
+	if err := s.db.Select(&messages, query, id); err != nil {
+		return nil, fmt.Errorf("failed to load messages of conversation %s: %w", id, err)
+	}
+	return messages, nil
+}
+
+func (s *DBStore) SetSummary(id, summary string, through int64) error {
+	query := `UPDATE chat_conversations SET summary = $2, summarized_through = $3 WHERE id = $1`
+	return s.execOne(query, "summarize", id, summary, through)
+}
+
+// execOne - Runs a statement on one conversation, returning ErrNotFound when
+// no row matched
+func (s *DBStore) execOne(query, action, id string, args ...interface{}) error {
+	result, err := s.db.Exec(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return fmt.Errorf("failed to %s conversation %s: %w", action, id, err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MemoryStore keeps conversations for the life of the process, for providers
+// without the chat tables
+type MemoryStore struct {
+	mu            sync.Mutex
+	conversations map[string]*Conversation
+	messages      map[string][]Message
+	nextMessageID int64
+	now           func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[string]*Conversation),
+		messages:      make(map[string][]Message),
+		now:           time.Now,
+	}
+}
+
+func (s *MemoryStore) Create(title string) (*Conversation, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	conversation := &Conversation{ID: id, Title: title, CreatedAt: now, UpdatedAt: now}
+	s.conversations[id] = conversation
+
+	result := *conversation
+	return &result, nil
+}
+
+func (s *MemoryStore) Get(id string) (*Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation, exists := s.conversations[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	result := *conversation
+	result.MessageCount = len(s.messages[id])
+	return &result, nil
+}
+
+func (s *MemoryStore) List(limit int) ([]Conversation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversations := make([]Conversation, 0, len(s.conversations))
+	for id, conversation := range s.conversations {
+		result := *conversation
+		result.MessageCount = len(s.messages[id])
+		conversations = append(conversations, result)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		if !conversations[i].UpdatedAt.Equal(conversations[j].UpdatedAt) {
+			return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+		}
+		return conversations[i].ID < conversations[j].ID
+	})
+
+	if len(conversations) > limit {
+		conversations = conversations[:limit]
+	}
+	return conversations, nil
+}
+
+func (s *MemoryStore) Rename(id, title string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation, exists := s.conversations[id]
+	if !exists {
+		return ErrNotFound
+	}
+	conversation.Title = title
+	conversation.UpdatedAt = s.now()
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.conversations[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.conversations, id)
+	delete(s.messages, id)
+	return nil
+}
+
+func (s *MemoryStore) AddMessages(id string, messages ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation, exists := s.conversations[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	now := s.now()
+	for _, message := range messages {
+		s.nextMessageID++
+		message.ID = s.nextMessageID
+		message.ConversationID = id
+		message.CreatedAt = now
+		s.messages[id] = append(s.messages[id], message)
+	}
+	conversation.UpdatedAt = now
+	return nil
+}
+
+func (s *MemoryStore) Messages(id string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.conversations[id]; !exists {
+		return nil, ErrNotFound
+	}
+	return append([]Message(nil), s.messages[id]...), nil
+}
+
+func (s *MemoryStore) SetSummary(id, summary string, through int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation, exists := s.conversations[id]
+	if !exists {
+		return ErrNotFound
+	}
+	conversation.Summary = summary
+	conversation.SummarizedThrough = through
+	return nil
+}
@@ -13,29 +13,48 @@ import (
 )
 
 type AddItemHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter model.POSReader
+	business   model.Business
 }
 
 type AddItemResponse struct {
-	Message      string        `json:"message"`
-	ItemsAdded   int           `json:"items_added"`
-	ItemsSkipped int           `json:"items_skipped"`
-	Errors       []string      `json:"errors,omitempty"`
-	AddedItems   []model.Item  `json:"added_items,omitempty"`
-	SkippedItems []SkippedItem `json:"skipped_items,omitempty"`
+	Message      string          `json:"message"`
+	Mode         model.BatchMode `json:"mode"`
+	ItemsAdded   int             `json:"items_added"`
+	ItemsSkipped int             `json:"items_skipped"`
+	Errors       []string        `json:"errors,omitempty"`
+	AddedItems   []model.Item    `json:"added_items,omitempty"`
+	SkippedItems []SkippedItem   `json:"skipped_items,omitempty"`
 }
 
+// SkippedItem is a CSV row that wasn't written. Code is model.FailureInvalid
+// for rows rejected while parsing, or the adapter's reason otherwise.
 type SkippedItem struct {
-	Row    int    `json:"row"`
-	Reason string `json:"reason"`
-	Data   string `json:"data"`
+	Row    int                      `json:"row"`
+	ID     string                   `json:"id,omitempty"`
+	Code   model.BatchFailureReason `json:"code"`
+	Reason string                   `json:"reason"`
+	Data   string                   `json:"data"`
 }
 
-func NewAddItemHandler(posAdapter model.POSAdapter) *AddItemHandler {
-	return &AddItemHandler{posAdapter: posAdapter}
+// NewAddItemHandler - Prices sent without a currency are in the business
+// currency
+func NewAddItemHandler(posAdapter model.POSReader, business model.Business) *AddItemHandler {
+	return &AddItemHandler{posAdapter: posAdapter, business: business}
 }
 
 func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
+	items, ok := inventoryWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	// partial writes every valid row, atomic writes all rows or none
+	mode, ok := batchMode(c)
+	if !ok {
+		return
+	}
+
 	// Get the uploaded file
 	file, header, err := c.Request.FormFile("csv_file")
 	if err != nil {
@@ -55,6 +74,7 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 	reader.FieldsPerRecord = -1 // Allow variable number of fields
 
 	var validItems []model.Item
+	var validRows []int
 	var skippedItems []SkippedItem
 	var errors []string
 	rowNumber := 0
@@ -109,6 +129,7 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 		if err != nil {
 			skippedItems = append(skippedItems, SkippedItem{
 				Row:    rowNumber,
+				Code:   model.FailureInvalid,
 				Reason: err.Error(),
 				Data:   strings.Join(record, ","),
 			})
@@ -116,9 +137,11 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 		}
 
 		// Validate item
-		if err := h.validateItem(item); err != nil {
+		if err := validateItem(item); err != nil {
 			skippedItems = append(skippedItems, SkippedItem{
 				Row:    rowNumber,
+				ID:     item.ID,
+				Code:   model.FailureInvalid,
 				Reason: "Validation error: " + err.Error(),
 				Data:   strings.Join(record, ","),
 			})
@@ -126,18 +149,43 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 		}
 
 		validItems = append(validItems, *item)
+		validRows = append(validRows, rowNumber)
+	}
+
+	// Save valid items in one batch; an atomic upload with rejected rows
+	// isn't written at all
+	ids := make([]string, len(validItems))
+	for i, item := range validItems {
+		ids[i] = item.ID
+	}
+
+	var result model.BatchResult
+	if mode == model.BatchAtomic && (len(skippedItems) > 0 || len(errors) > 0) {
+		result = abandonedBatch(mode, ids)
+	} else {
+		result, err = items.AddItems(validItems, mode)
+		if err != nil {
+			batchFailed(c, "items", err)
+			return
+		}
+	}
+
+	failed := make(map[int]bool, len(result.Failed))
+	for _, failure := range result.Failed {
+		failed[failure.Index] = true
+		item := validItems[failure.Index]
+		skippedItems = append(skippedItems, SkippedItem{
+			Row:    validRows[failure.Index],
+			ID:     failure.ID,
+			Code:   failure.Reason,
+			Reason: batchFailureReason(failure),
+			Data:   fmt.Sprintf("ID: %s, Name: %s", item.ID, item.Name),
+		})
 	}
 
-	// Save valid items to database
 	var addedItems []model.Item
-	for _, item := range validItems {
-		if err := h.posAdapter.AddItem(item); err != nil {
-			skippedItems = append(skippedItems, SkippedItem{
-				Row:    -1, // Database error, not tied to specific row
-				Reason: "Database error: " + err.Error(),
-				Data:   fmt.Sprintf("ID: %s, Name: %s", item.ID, item.Name),
-			})
-		} else {
+	for i, item := range validItems {
+		if !failed[i] {
 			addedItems = append(addedItems, item)
 		}
 	}
@@ -145,6 +193,7 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 	// Prepare response
 	response := AddItemResponse{
 		Message:      fmt.Sprintf("CSV processing completed. %d items added, %d items skipped", len(addedItems), len(skippedItems)),
+		Mode:         mode,
 		ItemsAdded:   len(addedItems),
 		ItemsSkipped: len(skippedItems),
 		AddedItems:   addedItems,
@@ -169,7 +218,13 @@ func (h *AddItemHandler) AddItemsFromCSV(c *gin.Context) {
 }
 
 func (h *AddItemHandler) AddSingleItem(c *gin.Context) {
-	var item model.Item
+	items, ok := inventoryWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	// Prices are read in the business currency
+	item := model.Item{Price: h.business.Zero(), ProductionPrice: h.business.Zero()}
 
 	if err := c.ShouldBindJSON(&item); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format: " + err.Error()})
@@ -177,14 +232,14 @@ func (h *AddItemHandler) AddSingleItem(c *gin.Context) {
 	}
 
 	// Validate item
-	if err := h.validateItem(&item); err != nil {
+	if err := validateItem(&item); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error: " + err.Error()})
 		return
 	}
 
 	// Save to database
-	if err := h.posAdapter.AddItem(item); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item to database: " + err.Error()})
+	if err := items.AddItem(item); err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": "Failed to add item to database: " + err.Error()})
 		return
 	}
 
@@ -229,12 +284,21 @@ func (h *AddItemHandler) parseItemFromRecord(record []string, headerMap map[stri
 		return nil, fmt.Errorf("invalid stock value: %s", stockStr)
 	}
 
+	// Parse optional Currency (defaults to the business currency)
+	currency := h.business.Currency
+	if _, exists := headerMap["currency"]; exists {
+		currencyStr, err := getField("currency")
+		if err == nil && currencyStr != "" {
+			currency = strings.ToUpper(currencyStr)
+		}
+	}
+
 	// Parse Price
 	priceStr, err := getField("price")
 	if err != nil {
 		return nil, err
 	}
-	price, err := strconv.ParseFloat(priceStr, 64)
+	price, err := model.ParseMoney(priceStr, currency)
 	if err != nil {
 		return nil, fmt.Errorf("invalid price value: %s", priceStr)
 	}
@@ -244,21 +308,35 @@ func (h *AddItemHandler) parseItemFromRecord(record []string, headerMap map[stri
 	if err != nil {
 		return nil, err
 	}
-	productionPrice, err := strconv.ParseFloat(productionPriceStr, 64)
+	productionPrice, err := model.ParseMoney(productionPriceStr, currency)
 	if err != nil {
 		return nil, fmt.Errorf("invalid production_price value: %s", productionPriceStr)
 	}
 
+	// Parse optional Oversell Policy
+	var oversellPolicy model.OversellPolicy
+	if _, exists := headerMap["oversell_policy"]; exists {
+		policyStr, err := getField("oversell_policy")
+		if err == nil {
+			oversellPolicy, err = model.ParseOversellPolicy(policyStr)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return &model.Item{
 		ID:              id,
 		Name:            name,
 		Stock:           stock,
 		Price:           price,
 		ProductionPrice: productionPrice,
+		OversellPolicy:  oversellPolicy,
 	}, nil
 }
 
-func (h *AddItemHandler) validateItem(item *model.Item) error {
+// validateItem - Checks the fields of an item before it is written
+func validateItem(item *model.Item) error {
 	if item.ID == "" {
 		return fmt.Errorf("ID cannot be empty")
 	}
@@ -268,15 +346,25 @@ func (h *AddItemHandler) validateItem(item *model.Item) error {
 	if item.Stock < 0 {
 		return fmt.Errorf("stock cannot be negative")
 	}
-	if item.Price < 0 {
+	if item.Price.IsNegative() {
 		return fmt.Errorf("price cannot be negative")
 	}
-	if item.ProductionPrice < 0 {
+	if item.ProductionPrice.IsNegative() {
 		return fmt.Errorf("production price cannot be negative")
 	}
-	if item.ProductionPrice > item.Price {
-		return fmt.Errorf("production price ($%.2f) cannot be higher than selling price ($%.2f)",
-			item.ProductionPrice, item.Price)
+	if _, err := model.ParseOversellPolicy(string(item.OversellPolicy)); err != nil {
+		return err
+	}
+	if item.ProductionPrice.Currency != "" && item.Price.Currency != "" && item.ProductionPrice.Currency != item.Price.Currency {
+		return fmt.Errorf("price and production price must use the same currency")
+	}
+	cmp, err := item.ProductionPrice.Cmp(item.Price)
+	if err != nil {
+		return err
+	}
+	if cmp > 0 {
+		return fmt.Errorf("production price (%s) cannot be higher than selling price (%s)",
+			item.ProductionPrice.Format(), item.Price.Format())
 	}
 	return nil
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -14,36 +15,64 @@ import (
 )
 
 type AddOrderHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter model.POSReader
+	business   model.Business
 }
 
 type AddOrderResponse struct {
-	Message       string         `json:"message"`
-	OrdersAdded   int            `json:"orders_added"`
-	OrdersSkipped int            `json:"orders_skipped"`
-	Errors        []string       `json:"errors,omitempty"`
-	AddedOrders   []model.Order  `json:"added_orders,omitempty"`
-	SkippedOrders []SkippedOrder `json:"skipped_orders,omitempty"`
+	Message       string          `json:"message"`
+	Mode          model.BatchMode `json:"mode"`
+	OrdersAdded   int             `json:"orders_added"`
+	OrdersSkipped int             `json:"orders_skipped"`
+	Errors        []string        `json:"errors,omitempty"`
+	AddedOrders   []model.Order   `json:"added_orders,omitempty"`
+	SkippedOrders []SkippedOrder  `json:"skipped_orders,omitempty"`
 }
 
+// SkippedOrder is a CSV row, or an order grouped from several rows, that
+// wasn't written. Row is the first CSV row of the order and Rows all of them.
+// Code is model.FailureInvalid for rows rejected while parsing, or the
+// adapter's reason otherwise.
 type SkippedOrder struct {
-	Row    int    `json:"row"`
-	Reason string `json:"reason"`
-	Data   string `json:"data"`
+	Row    int                      `json:"row"`
+	Rows   []int                    `json:"rows,omitempty"`
+	ID     string                   `json:"id,omitempty"`
+	Code   model.BatchFailureReason `json:"code"`
+	Reason string                   `json:"reason"`
+	Data   string                   `json:"data"`
 }
 
 type CSVOrderRow struct {
+	Row         int
 	OrderID     string
 	ItemID      string
 	Quantity    int
 	CompletedAt time.Time
 }
 
-func NewAddOrderHandler(posAdapter model.POSAdapter) *AddOrderHandler {
-	return &AddOrderHandler{posAdapter: posAdapter}
+func NewAddOrderHandler(posAdapter model.POSReader, business model.Business) *AddOrderHandler {
+	return &AddOrderHandler{posAdapter: posAdapter, business: business}
 }
 
 func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
+	orders, ok := orderWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	// partial writes every valid order, atomic writes all orders or none
+	mode, ok := batchMode(c)
+	if !ok {
+		return
+	}
+
+	// Timestamps without an offset are read in the business timezone
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get the uploaded file
 	file, header, err := c.Request.FormFile("csv_file")
 	if err != nil {
@@ -64,7 +93,7 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 
 	var csvRows []CSVOrderRow
 	var skippedOrders []SkippedOrder
-	var errors []string
+	var readErrors []string
 	rowNumber := 0
 
 	// Read header row
@@ -113,7 +142,7 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 			break
 		}
 		if err != nil {
-			errors = append(errors, fmt.Sprintf("Row %d: Error reading CSV: %s", rowNumber+1, err.Error()))
+			readErrors = append(readErrors, fmt.Sprintf("Row %d: Error reading CSV: %s", rowNumber+1, err.Error()))
 			rowNumber++
 			continue
 		}
@@ -125,10 +154,11 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 		}
 
 		// Parse order row from record
-		csvRow, err := h.parseOrderRowFromRecord(record, headerMap, rowNumber)
+		csvRow, err := h.parseOrderRowFromRecord(record, headerMap, rowNumber, business.Zone())
 		if err != nil {
 			skippedOrders = append(skippedOrders, SkippedOrder{
 				Row:    rowNumber,
+				Code:   model.FailureInvalid,
 				Reason: err.Error(),
 				Data:   strings.Join(record, ","),
 			})
@@ -139,6 +169,8 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 		if _, exists := itemMap[csvRow.ItemID]; !exists {
 			skippedOrders = append(skippedOrders, SkippedOrder{
 				Row:    rowNumber,
+				ID:     csvRow.OrderID,
+				Code:   model.FailureNotFound,
 				Reason: fmt.Sprintf("Item ID %s not found in inventory", csvRow.ItemID),
 				Data:   strings.Join(record, ","),
 			})
@@ -148,49 +180,88 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 		csvRows = append(csvRows, *csvRow)
 	}
 
-	// Group CSV rows by order_id and completed_at
+	// Load price timelines so back-dated orders are priced as of their completed_at
+	priceHistories := make(map[string][]model.ItemPrice)
+	if history, ok := model.As[model.PriceHistory](h.posAdapter); ok {
+		for _, csvRow := range csvRows {
+			if _, loaded := priceHistories[csvRow.ItemID]; loaded {
+				continue
+			}
+			prices, err := history.GetItemPriceHistory(csvRow.ItemID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+				return
+			}
+			priceHistories[csvRow.ItemID] = prices
+		}
+	}
+
+	// Group CSV rows by order_id and completed_at, keeping the orders in the
+	// order they first appear in the file
 	orderMap := make(map[string]*model.Order)
+	orderRows := make(map[string][]int)
+	var orderKeys []string
 
 	for _, csvRow := range csvRows {
 		orderKey := fmt.Sprintf("%s_%s", csvRow.OrderID, csvRow.CompletedAt.Format("2006-01-02T15:04:05"))
+		orderRows[orderKey] = append(orderRows[orderKey], csvRow.Row)
+
+		// Snapshot the item's price and cost at sale time
+		item := itemMap[csvRow.ItemID]
+		orderItem := model.OrderItem{
+			ItemID:    csvRow.ItemID,
+			Quantity:  csvRow.Quantity,
+			UnitPrice: item.Price,
+			UnitCost:  item.ProductionPrice,
+		}
+		if price := model.PriceAsOf(priceHistories[csvRow.ItemID], csvRow.CompletedAt); price != nil {
+			orderItem.UnitPrice = price.Price
+			orderItem.UnitCost = price.ProductionPrice
+		}
 
 		if order, exists := orderMap[orderKey]; exists {
 			// Add item to existing order
-			order.Items = append(order.Items, model.OrderItem{
-				ItemID:   csvRow.ItemID,
-				Quantity: csvRow.Quantity,
-			})
+			order.Items = append(order.Items, orderItem)
 		} else {
 			// Create new order
+			orderKeys = append(orderKeys, orderKey)
 			orderMap[orderKey] = &model.Order{
 				ID:          csvRow.OrderID,
 				CompletedAt: csvRow.CompletedAt,
-				Items: []model.OrderItem{
-					{
-						ItemID:   csvRow.ItemID,
-						Quantity: csvRow.Quantity,
-					},
-				},
-				Total: 0, // Will be calculated below
+				Items:       []model.OrderItem{orderItem},
+				Total:       model.Money{}, // Will be calculated below
 			}
 		}
 	}
 
 	// Calculate totals for each order
 	var validOrders []model.Order
-	for _, order := range orderMap {
-		total := 0.0
-		for _, orderItem := range order.Items {
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				total += float64(orderItem.Quantity) * item.Price
-			}
+	var validRows [][]int
+	for _, orderKey := range orderKeys {
+		order := orderMap[orderKey]
+		rows := orderRows[orderKey]
+
+		total, err := sumLineRevenue(order.Items)
+		if err != nil {
+			skippedOrders = append(skippedOrders, SkippedOrder{
+				Row:    rows[0],
+				Rows:   rows,
+				ID:     order.ID,
+				Code:   model.FailureInvalid,
+				Reason: "Order validation error: " + err.Error(),
+				Data:   fmt.Sprintf("Order ID: %s", order.ID),
+			})
+			continue
 		}
 		order.Total = total
 
 		// Validate order
-		if err := h.validateOrder(order); err != nil {
+		if err := validateOrder(order); err != nil {
 			skippedOrders = append(skippedOrders, SkippedOrder{
-				Row:    -1,
+				Row:    rows[0],
+				Rows:   rows,
+				ID:     order.ID,
+				Code:   model.FailureInvalid,
 				Reason: "Order validation error: " + err.Error(),
 				Data:   fmt.Sprintf("Order ID: %s", order.ID),
 			})
@@ -198,18 +269,44 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 		}
 
 		validOrders = append(validOrders, *order)
+		validRows = append(validRows, rows)
+	}
+
+	// Save valid orders in one batch; an atomic upload with rejected rows
+	// isn't written at all
+	ids := make([]string, len(validOrders))
+	for i, order := range validOrders {
+		ids[i] = order.ID
+	}
+
+	var result model.BatchResult
+	if mode == model.BatchAtomic && (len(skippedOrders) > 0 || len(readErrors) > 0) {
+		result = abandonedBatch(mode, ids)
+	} else {
+		result, err = orders.AddOrders(validOrders, mode)
+		if err != nil {
+			batchFailed(c, "orders", err)
+			return
+		}
+	}
+
+	failed := make(map[int]bool, len(result.Failed))
+	for _, failure := range result.Failed {
+		failed[failure.Index] = true
+		rows := validRows[failure.Index]
+		skippedOrders = append(skippedOrders, SkippedOrder{
+			Row:    rows[0],
+			Rows:   rows,
+			ID:     failure.ID,
+			Code:   failure.Reason,
+			Reason: batchFailureReason(failure),
+			Data:   fmt.Sprintf("Order ID: %s", failure.ID),
+		})
 	}
 
-	// Save valid orders to database
 	var addedOrders []model.Order
-	for _, order := range validOrders {
-		if err := h.posAdapter.AddOrder(order); err != nil {
-			skippedOrders = append(skippedOrders, SkippedOrder{
-				Row:    -1, // Database error, not tied to specific row
-				Reason: "Database error: " + err.Error(),
-				Data:   fmt.Sprintf("Order ID: %s", order.ID),
-			})
-		} else {
+	for i, order := range validOrders {
+		if !failed[i] {
 			addedOrders = append(addedOrders, order)
 		}
 	}
@@ -217,13 +314,14 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 	// Prepare response
 	response := AddOrderResponse{
 		Message:       fmt.Sprintf("CSV processing completed. %d orders added, %d orders skipped", len(addedOrders), len(skippedOrders)),
+		Mode:          mode,
 		OrdersAdded:   len(addedOrders),
 		OrdersSkipped: len(skippedOrders),
 		AddedOrders:   addedOrders,
 	}
 
-	if len(errors) > 0 {
-		response.Errors = errors
+	if len(readErrors) > 0 {
+		response.Errors = readErrors
 	}
 
 	if len(skippedOrders) > 0 {
@@ -241,7 +339,13 @@ func (h *AddOrderHandler) AddOrdersFromCSV(c *gin.Context) {
 }
 
 func (h *AddOrderHandler) AddSingleOrder(c *gin.Context) {
-	var order model.Order
+	orders, ok := orderWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	// Amounts are read in the business currency
+	order := model.Order{Total: h.business.Zero()}
 
 	if err := c.ShouldBindJSON(&order); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format: " + err.Error()})
@@ -255,23 +359,10 @@ func (h *AddOrderHandler) AddSingleOrder(c *gin.Context) {
 		return
 	}
 
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
-	}
-
-	// Calculate total if not provided
-	if order.Total == 0 {
-		total := 0.0
-		for _, orderItem := range order.Items {
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				total += float64(orderItem.Quantity) * item.Price
-			} else {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item ID %s not found in inventory", orderItem.ItemID)})
-				return
-			}
-		}
-		order.Total = total
+	// Snapshot prices and calculate the total if not provided
+	if err := fillOrderPrices(&order, inventory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Set completion time if not provided
@@ -280,14 +371,24 @@ func (h *AddOrderHandler) AddSingleOrder(c *gin.Context) {
 	}
 
 	// Validate order
-	if err := h.validateOrder(&order); err != nil {
+	if err := validateOrder(&order); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error: " + err.Error()})
 		return
 	}
 
 	// Save to database
-	if err := h.posAdapter.AddOrder(order); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add order to database: " + err.Error()})
+	if err := orders.AddOrder(order); err != nil {
+		var stockErr *model.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "Insufficient stock: " + stockErr.Error(),
+				"item_id":   stockErr.ItemID,
+				"requested": stockErr.Requested,
+				"available": stockErr.Available,
+			})
+			return
+		}
+		c.JSON(adapterErrorStatus(err), gin.H{"error": "Failed to add order to database: " + err.Error()})
 		return
 	}
 
@@ -297,7 +398,7 @@ func (h *AddOrderHandler) AddSingleOrder(c *gin.Context) {
 	})
 }
 
-func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map[string]int, rowNumber int) (*CSVOrderRow, error) {
+func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map[string]int, rowNumber int, location *time.Location) (*CSVOrderRow, error) {
 	// Helper function to get field value safely
 	getField := func(fieldName string) (string, error) {
 		index, exists := headerMap[fieldName]
@@ -338,11 +439,11 @@ func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map
 		return nil, err
 	}
 
-	// Try multiple date formats
+	// Try multiple date formats; those without an offset are local time
 	var completedAt time.Time
 	dateFormats := []string{
 		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05Z",
+		time.RFC3339,
 		"2006-01-02T15:04:05",
 		"2006-01-02",
 		"01/02/2006",
@@ -350,7 +451,7 @@ func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map
 	}
 
 	for _, format := range dateFormats {
-		if completedAt, err = time.Parse(format, completedAtStr); err == nil {
+		if completedAt, err = time.ParseInLocation(format, completedAtStr, location); err == nil {
 			break
 		}
 	}
@@ -360,6 +461,7 @@ func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map
 	}
 
 	return &CSVOrderRow{
+		Row:         rowNumber,
 		OrderID:     orderID,
 		ItemID:      itemID,
 		Quantity:    quantity,
@@ -367,14 +469,15 @@ func (h *AddOrderHandler) parseOrderRowFromRecord(record []string, headerMap map
 	}, nil
 }
 
-func (h *AddOrderHandler) validateOrder(order *model.Order) error {
+// validateOrder - Checks the fields of an order before it is written
+func validateOrder(order *model.Order) error {
 	if order.ID == "" {
 		return fmt.Errorf("order ID cannot be empty")
 	}
 	if len(order.Items) == 0 {
 		return fmt.Errorf("order must have at least one item")
 	}
-	if order.Total < 0 {
+	if order.Total.IsNegative() {
 		return fmt.Errorf("order total cannot be negative")
 	}
 	if order.CompletedAt.IsZero() {
@@ -388,6 +491,16 @@ func (h *AddOrderHandler) validateOrder(order *model.Order) error {
 		if item.Quantity <= 0 {
 			return fmt.Errorf("item %d: quantity must be positive", i+1)
 		}
+		if item.UnitPrice.IsNegative() || item.UnitCost.IsNegative() {
+			return fmt.Errorf("item %d: unit price and cost cannot be negative", i+1)
+		}
+		cmp, err := item.Discount.Cmp(item.UnitPrice.Mul(int64(item.Quantity)))
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i+1, err)
+		}
+		if item.Discount.IsNegative() || cmp > 0 {
+			return fmt.Errorf("item %d: discount must be between 0 and the line amount", i+1)
+		}
 	}
 
 	return nil
@@ -405,3 +518,63 @@ func (h *AddOrderHandler) GetCSVTemplate(c *gin.Context) {
 	c.Header("Content-Disposition", "attachment; filename=orders_template.csv")
 	c.String(http.StatusOK, template)
 }
+
+// sumLineRevenue - Adds up the revenue of order lines, which must all be
+// priced in the same currency
+func sumLineRevenue(items []model.OrderItem) (model.Money, error) {
+	total := model.Money{}
+	for _, orderItem := range items {
+		for _, amount := range []model.Money{orderItem.UnitPrice, orderItem.UnitCost, orderItem.Discount} {
+			if amount.IsZero() {
+				continue
+			}
+			if total.Currency == "" {
+				total.Currency = amount.CurrencyCode()
+			} else if amount.CurrencyCode() != total.Currency {
+				return model.Money{}, fmt.Errorf("item %s is priced in %s but the order is in %s",
+					orderItem.ItemID, amount.CurrencyCode(), total.Currency)
+			}
+		}
+		revenue, err := orderItem.Revenue()
+		if err != nil {
+			return model.Money{}, fmt.Errorf("item %s: %w", orderItem.ItemID, err)
+		}
+		if total, err = total.Add(revenue); err != nil {
+			return model.Money{}, fmt.Errorf("item %s: %w", orderItem.ItemID, err)
+		}
+	}
+	return total, nil
+}
+
+// fillOrderPrices - Snapshots the price and cost of lines submitted without
+// one and calculates the total when it wasn't provided
+func fillOrderPrices(order *model.Order, inventory []model.Item) error {
+	itemMap := make(map[string]model.Item)
+	for _, item := range inventory {
+		itemMap[item.ID] = item
+	}
+
+	for i, orderItem := range order.Items {
+		item, exists := itemMap[orderItem.ItemID]
+		if !exists {
+			if order.Total.IsZero() {
+				return fmt.Errorf("Item ID %s not found in inventory", orderItem.ItemID)
+			}
+			continue
+		}
+		if !orderItem.HasSnapshot() {
+			order.Items[i].UnitPrice = item.Price
+			order.Items[i].UnitCost = item.ProductionPrice
+		}
+	}
+
+	if order.Total.IsZero() {
+		total, err := sumLineRevenue(order.Items)
+		if err != nil {
+			return fmt.Errorf("Validation error: %w", err)
+		}
+		order.Total = total
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+// requestBusiness returns the business settings for a request, honouring an
+// optional ?tz= override of the business timezone (e.g. tz=Asia/Makassar)
+func requestBusiness(c *gin.Context, business model.Business) (model.Business, error) {
+	tz := c.Query("tz")
+	if tz == "" {
+		return business, nil
+	}
+
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return business, fmt.Errorf("invalid tz parameter: %s", tz)
+	}
+	return business.InLocation(location), nil
+}
+
+// monthsAgo returns the start of a rolling window of the last months, rounded
+// down to the minute so that requests made within the same minute share
+// cached reads
+func monthsAgo(business model.Business, months int) time.Time {
+	return business.Now().AddDate(0, -months, 0).Truncate(time.Minute)
+}
+
+// aggregateSales uses the adapter's own aggregation when it has one and falls
+// back to summing completed orders in memory otherwise. Amounts are converted
+// into the business currency and periods are bucketed in the business timezone.
+func aggregateSales(posAdapter model.POSReader, business model.Business, query model.AggregateQuery) ([]model.AggregateRow, error) {
+	if query.Location == nil {
+		query.Location = business.Zone()
+	}
+
+	rows, err := aggregateSalesRows(posAdapter, query)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := business.ConvertAggregateRows(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert sales to %s: %w", business.Currency, err)
+	}
+	return converted, nil
+}
+
+func aggregateSalesRows(posAdapter model.POSReader, query model.AggregateQuery) ([]model.AggregateRow, error) {
+	if aggregator, ok := model.As[model.SalesAggregator](posAdapter); ok {
+		return aggregator.AggregateSales(query)
+	}
+
+	page, err := posAdapter.QueryOrders(model.OrderQuery{Start: query.Start, End: query.End})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+
+	inventory, err := posAdapter.GetInventory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %w", err)
+	}
+
+	return model.AggregateOrders(page.Orders, inventory, query)
+}
+
+// sumSales - Adds up the revenue and cost of rows already converted to the
+// business currency
+func sumSales(business model.Business, rows []model.AggregateRow) (model.Money, model.Money, error) {
+	revenue, cost := business.Zero(), business.Zero()
+	for _, row := range rows {
+		var err error
+		if revenue, err = revenue.Add(row.Revenue); err != nil {
+			return model.Money{}, model.Money{}, err
+		}
+		if cost, err = cost.Add(row.Cost); err != nil {
+			return model.Money{}, model.Money{}, err
+		}
+	}
+	return revenue, cost, nil
+}
+
+// itemSalesFromRows converts item-grouped aggregate rows into ItemSales
+func itemSalesFromRows(rows []model.AggregateRow) []ItemSales {
+	itemSales := make([]ItemSales, 0, len(rows))
+	for _, row := range rows {
+		itemSales = append(itemSales, ItemSales{
+			ItemID:                row.Key,
+			ItemName:              row.ItemName,
+			Price:                 row.Price,
+			TotalSold:             row.Units,
+			TotalRevenue:          row.Revenue,
+			TotalRevenueFormatted: row.Revenue.Format(),
+		})
+	}
+	return itemSales
+}
+
+// aiWarnings - Logs the problems of an AI reply and returns them for the
+// response, as an empty list when there are none
+func aiWarnings(useCase string, warnings []string) []string {
+	if len(warnings) == 0 {
+		return []string{}
+	}
+	log.Printf("AI %s returned with warnings: %s", useCase, strings.Join(warnings, "; "))
+	return warnings
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+// batchMode - Reads the ?mode= of a CSV upload (partial or atomic, default
+// partial), answering 400 when it is invalid
+func batchMode(c *gin.Context) (model.BatchMode, bool) {
+	mode, err := model.ParseBatchMode(c.Query("mode"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return mode, true
+}
+
+// abandonedBatch - Returns the result of an atomic upload that isn't sent to
+// the adapter because some of its rows were already rejected while parsing
+func abandonedBatch(mode model.BatchMode, ids []string) model.BatchResult {
+	result := model.BatchResult{Mode: mode}
+	result.RollBack(ids)
+	return result
+}
+
+// batchFailed - Answers a batch the adapter couldn't write as a whole: 501
+// when it doesn't support the requested mode, 500 otherwise
+func batchFailed(c *gin.Context, resource string, err error) {
+	if errors.Is(err, model.ErrBatchModeUnsupported) {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add " + resource + ": " + err.Error()})
+}
+
+// batchFailureReason - Describes a row the adapter didn't write
+func batchFailureReason(failure model.BatchFailure) string {
+	switch failure.Reason {
+	case model.FailureInvalid:
+		return "Rejected: " + failure.Error
+	case model.FailureInsufficientStock:
+		return "Rejected by oversell policy: " + failure.Error
+	case model.FailureNotFound:
+		return "Not found: " + failure.Error
+	case model.FailureRolledBack:
+		return "Not written: another row of this atomic upload failed"
+	default:
+		return "Database error: " + failure.Error
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	cache *adapter.CachingAdapter
+}
+
+type CacheStatsResponse struct {
+	Enabled bool                `json:"enabled"`
+	Stats   *adapter.CacheStats `json:"stats,omitempty"`
+}
+
+// NewCacheHandler - Reports on cache; nil means the cache is disabled
+func NewCacheHandler(cache *adapter.CachingAdapter) *CacheHandler {
+	return &CacheHandler{cache: cache}
+}
+
+// GetCacheStats - Returns the hit and miss counters and the size of the read
+// cache
+func (h *CacheHandler) GetCacheStats(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusOK, CacheStatsResponse{Enabled: false})
+		return
+	}
+
+	stats := h.cache.Stats()
+	c.JSON(http.StatusOK, CacheStatsResponse{Enabled: true, Stats: &stats})
+}
+
+// ClearCache - Drops every cached read, e.g. after changing the database by
+// hand
+func (h *CacheHandler) ClearCache(c *gin.Context) {
+	if h.cache == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "The read cache is disabled"})
+		return
+	}
+
+	h.cache.Clear()
+	c.JSON(http.StatusOK, gin.H{"message": "Cache cleared"})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+type CapabilitiesHandler struct {
+	provider     string
+	capabilities model.Capabilities
+}
+
+type CapabilitiesResponse struct {
+	Provider     string             `json:"provider"`
+	Capabilities model.Capabilities `json:"capabilities"`
+	Supported    []string           `json:"supported"`
+}
+
+func NewCapabilitiesHandler(provider string, posAdapter model.POSReader) *CapabilitiesHandler {
+	return &CapabilitiesHandler{provider: provider, capabilities: model.CapabilitiesOf(posAdapter)}
+}
+
+// GetCapabilities - Lists what the configured POS adapter supports. Routes
+// needing a missing capability answer 501.
+func (h *CapabilitiesHandler) GetCapabilities(c *gin.Context) {
+	supported := h.capabilities.Supported()
+	if supported == nil {
+		supported = []string{}
+	}
+
+	c.JSON(http.StatusOK, CapabilitiesResponse{
+		Provider:     h.provider,
+		Capabilities: h.capabilities,
+		Supported:    supported,
+	})
+}
+
+// inventoryWriter - Returns the adapter as an InventoryWriter, or answers 501
+// when it can't write items
+func inventoryWriter(c *gin.Context, posAdapter model.POSReader) (model.InventoryWriter, bool) {
+	writer, ok := model.As[model.InventoryWriter](posAdapter)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Writing items is not supported by this POS adapter"})
+	}
+	return writer, ok
+}
+
+// orderWriter - Returns the adapter as an OrderWriter, or answers 501 when it
+// can't write orders
+func orderWriter(c *gin.Context, posAdapter model.POSReader) (model.OrderWriter, bool) {
+	writer, ok := model.As[model.OrderWriter](posAdapter)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Writing orders is not supported by this POS adapter"})
+	}
+	return writer, ok
+}
@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/llm"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on what one chat tool call returns
+const (
+	chatToolDefaultLimit = 10
+	chatToolMaxLimit     = 50
+	chatToolMaxPeriods   = 100
+	chatToolDefaultDays  = 30
+)
+
+type chatItemArgs struct {
+	ItemID string `json:"item_id" desc:"ID of the item"`
+}
+
+type chatSearchArgs struct {
+	Query string `json:"query" desc:"Part of the item name or ID; empty lists every item"`
+	Limit int    `json:"limit,omitempty" desc:"Most items to return (default 10, at most 50)"`
+}
+
+type chatSalesArgs struct {
+	StartDate string `json:"start_date" desc:"First day of the period, YYYY-MM-DD"`
+	EndDate   string `json:"end_date" desc:"Last day of the period (included), YYYY-MM-DD"`
+	GroupBy   string `json:"group_by,omitempty" enum:"day,week,month" desc:"Also break the totals down by day, week or month"`
+}
+
+type chatTopItemsArgs struct {
+	StartDate string `json:"start_date,omitempty" desc:"First day of the period, YYYY-MM-DD (default: the last 30 days)"`
+	EndDate   string `json:"end_date,omitempty" desc:"Last day of the period (included), YYYY-MM-DD"`
+	SortBy    string `json:"sort_by,omitempty" enum:"units,revenue,profit" desc:"Ranking (default units)"`
+	Limit     int    `json:"limit,omitempty" desc:"Most items to return (default 10, at most 50)"`
+}
+
+type chatOrderArgs struct {
+	OrderID string `json:"order_id" desc:"ID of the order"`
+}
+
+type chatMarginArgs struct {
+	ItemID    string `json:"item_id,omitempty" desc:"Only this item (default: every item sold in the period)"`
+	StartDate string `json:"start_date,omitempty" desc:"First day of the period, YYYY-MM-DD (default: the last 30 days)"`
+	EndDate   string `json:"end_date,omitempty" desc:"Last day of the period (included), YYYY-MM-DD"`
+	Limit     int    `json:"limit,omitempty" desc:"Most items to return, highest profit first (default 10, at most 50)"`
+}
+
+// chatItem is an item as the chat tools describe it, with amounts formatted
+// in the business currency
+type chatItem struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Stock          int     `json:"stock"`
+	Price          string  `json:"price"`
+	ProductionCost string  `json:"production_cost"`
+	UnitMargin     string  `json:"unit_margin"`
+	MarginPercent  float64 `json:"margin_percent"`
+}
+
+// chatSales is the sales of a period or of one item in it
+type chatSales struct {
+	Key           string  `json:"key,omitempty"`
+	Name          string  `json:"name,omitempty"`
+	Orders        int     `json:"orders"`
+	Units         int     `json:"units"`
+	Revenue       string  `json:"revenue"`
+	Cost          string  `json:"cost"`
+	Profit        string  `json:"profit"`
+	MarginPercent float64 `json:"margin_percent"`
+
+	profit  model.Money
+	revenue model.Money
+}
+
+// chatTools builds the read-only tools the chatbot uses to look up POS data
+// for a request. Amounts are in the business currency and dates in its
+// timezone.
+type chatTools struct {
+	posAdapter model.POSReader
+	business   model.Business
+}
+
+// newChatToolbox - Returns the chat tools for one request's business settings
+func newChatToolbox(posAdapter model.POSReader, business model.Business) *llm.Toolbox {
+	tools := &chatTools{posAdapter: posAdapter, business: business}
+	toolbox := llm.NewToolbox()
+
+	llm.AddTool(toolbox, "get_item", "Get one item by ID: stock, price, production cost and unit margin.", tools.getItem)
+	llm.AddTool(toolbox, "search_items", "Find items whose name or ID contains a term, ordered by name.", tools.searchItems)
+	llm.AddTool(toolbox, "sales_for_period", "Total orders, units sold, revenue, cost and profit of completed orders between two dates, optionally broken down by day, week or month.", tools.salesForPeriod)
+	llm.AddTool(toolbox, "top_items", "The best-selling items of a period by units sold, revenue or profit.", tools.topItems)
+	llm.AddTool(toolbox, "get_order", "Get one completed order by ID with its lines.", tools.getOrder)
+	llm.AddTool(toolbox, "item_margins", "Realized revenue, cost, profit and margin per item sold in a period, from the prices the orders were charged at.", tools.itemMargins)
+	return toolbox
+}
+
+func (t *chatTools) getItem(ctx context.Context, args chatItemArgs) (interface{}, error) {
+	item, err := t.posAdapter.GetItemByID(strings.TrimSpace(args.ItemID))
+	if err != nil {
+		return nil, err
+	}
+	return t.describeItem(*item)
+}
+
+func (t *chatTools) searchItems(ctx context.Context, args chatSearchArgs) (interface{}, error) {
+	items, err := searchItems(t.posAdapter, args.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := toolLimit(args.Limit)
+	result := gin.H{"count": len(items)}
+	if len(items) > limit {
+		items = items[:limit]
+		result["truncated"] = true
+	}
+
+	described := make([]chatItem, 0, len(items))
+	for _, item := range items {
+		description, err := t.describeItem(item)
+		if err != nil {
+			return nil, err
+		}
+		described = append(described, description)
+	}
+	result["items"] = described
+	return result, nil
+}
+
+func (t *chatTools) salesForPeriod(ctx context.Context, args chatSalesArgs) (interface{}, error) {
+	if args.StartDate == "" || args.EndDate == "" {
+		return nil, errors.New("start_date and end_date are required")
+	}
+	start, end, err := t.period(args.StartDate, args.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	groupBy := model.GroupByMonth
+	switch args.GroupBy {
+	case "":
+	case string(model.GroupByDay), string(model.GroupByWeek), string(model.GroupByMonth):
+		groupBy = model.Granularity(args.GroupBy)
+	default:
+		return nil, fmt.Errorf("group_by must be day, week or month, not %q", args.GroupBy)
+	}
+
+	rows, err := aggregateSales(t.posAdapter, t.business, model.AggregateQuery{Start: start, End: end, GroupBy: groupBy})
+	if err != nil {
+		return nil, err
+	}
+
+	total := chatSalesRow{revenue: t.business.Zero(), cost: t.business.Zero()}
+	var periods []chatSales
+	for _, row := range rows {
+		if err := total.add(row); err != nil {
+			return nil, err
+		}
+		period, err := t.describeSales(newChatSalesRow(row.Key, "", row))
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period)
+	}
+	described, err := t.describeSales(total)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(periods, func(i, j int) bool { return periods[i].Key < periods[j].Key })
+
+	result := gin.H{
+		"start_date": args.StartDate,
+		"end_date":   args.EndDate,
+		"total":      described,
+	}
+	if args.GroupBy != "" {
+		if len(periods) > chatToolMaxPeriods {
+			periods = periods[:chatToolMaxPeriods]
+			result["truncated"] = true
+		}
+		result["periods"] = periods
+	}
+	return result, nil
+}
+
+func (t *chatTools) topItems(ctx context.Context, args chatTopItemsArgs) (interface{}, error) {
+	switch args.SortBy {
+	case "", "units", "revenue", "profit":
+	default:
+		return nil, fmt.Errorf("sort_by must be units, revenue or profit, not %q", args.SortBy)
+	}
+
+	items, err := t.itemSales(args.StartDate, args.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		switch args.SortBy {
+		case "revenue":
+			return greater(items[i].revenue, items[j].revenue)
+		case "profit":
+			return greater(items[i].profit, items[j].profit)
+		default:
+			return items[i].Units > items[j].Units
+		}
+	})
+	if limit := toolLimit(args.Limit); len(items) > limit {
+		items = items[:limit]
+	}
+	return gin.H{"items": items}, nil
+}
+
+func (t *chatTools) getOrder(ctx context.Context, args chatOrderArgs) (interface{}, error) {
+	order, err := t.posAdapter.GetOrderByID(strings.TrimSpace(args.OrderID))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]gin.H, 0, len(order.Items))
+	for _, line := range order.Items {
+		revenue, err := line.Revenue()
+		if err != nil {
+			return nil, err
+		}
+		name := ""
+		if item, err := t.posAdapter.GetItemByID(line.ItemID); err == nil {
+			name = item.Name
+		} else if !errors.Is(err, model.ErrNotFound) {
+			return nil, err
+		}
+
+		lines = append(lines, gin.H{
+			"item_id":    line.ItemID,
+			"item_name":  name,
+			"quantity":   line.Quantity,
+			"unit_price": t.business.Format(line.UnitPrice),
+			"discount":   t.business.Format(line.Discount),
+			"revenue":    t.business.Format(revenue),
+			"cost":       t.business.Format(line.Cost()),
+		})
+	}
+
+	return gin.H{
+		"id":           order.ID,
+		"completed_at": order.CompletedAt.In(t.business.Zone()).Format(time.RFC3339),
+		"total":        t.business.Format(order.Total),
+		"items":        lines,
+	}, nil
+}
+
+func (t *chatTools) itemMargins(ctx context.Context, args chatMarginArgs) (interface{}, error) {
+	items, err := t.itemSales(args.StartDate, args.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	if itemID := strings.TrimSpace(args.ItemID); itemID != "" {
+		var matched []chatSales
+		for _, item := range items {
+			if item.Key == itemID {
+				matched = append(matched, item)
+			}
+		}
+		if len(matched) == 0 {
+			// Tell an unknown item from one without sales
+			if _, err := t.posAdapter.GetItemByID(itemID); err != nil {
+				return nil, err
+			}
+		}
+		items = matched
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return greater(items[i].profit, items[j].profit) })
+	if limit := toolLimit(args.Limit); len(items) > limit {
+		items = items[:limit]
+	}
+	if items == nil {
+		items = []chatSales{}
+	}
+	return gin.H{"items": items}, nil
+}
+
+// itemSales - The sales of every item sold in a period, the last 30 days by
+// default
+func (t *chatTools) itemSales(startDate, endDate string) ([]chatSales, error) {
+	if startDate == "" && endDate == "" {
+		today := t.business.Now()
+		endDate = today.Format("2006-01-02")
+		startDate = today.AddDate(0, 0, 1-chatToolDefaultDays).Format("2006-01-02")
+	}
+	if startDate == "" || endDate == "" {
+		return nil, errors.New("give both start_date and end_date, or neither for the last 30 days")
+	}
+	start, end, err := t.period(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := aggregateSales(t.posAdapter, t.business, model.AggregateQuery{Start: start, End: end, GroupBy: model.GroupByItem})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]chatSales, 0, len(rows))
+	for _, row := range rows {
+		item, err := t.describeSales(newChatSalesRow(row.Key, row.ItemName, row))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// period - Parses an inclusive range of dates into [start, end)
+func (t *chatTools) period(startDate, endDate string) (time.Time, time.Time, error) {
+	start, err := t.business.ParseDate(startDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_date %q, use YYYY-MM-DD", startDate)
+	}
+	end, err := t.business.ParseDate(endDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date %q, use YYYY-MM-DD", endDate)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, errors.New("end_date is before start_date")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+func (t *chatTools) describeItem(item model.Item) (chatItem, error) {
+	margin, err := item.Price.Sub(item.ProductionPrice)
+	if err != nil {
+		return chatItem{}, fmt.Errorf("item %s: %w", item.ID, err)
+	}
+	return chatItem{
+		ID:             item.ID,
+		Name:           item.Name,
+		Stock:          item.Stock,
+		Price:          t.business.Format(item.Price),
+		ProductionCost: t.business.Format(item.ProductionPrice),
+		UnitMargin:     t.business.Format(margin),
+		MarginPercent:  percent(margin.Ratio(item.Price)),
+	}, nil
+}
+
+func (t *chatTools) describeSales(row chatSalesRow) (chatSales, error) {
+	profit, err := row.revenue.Sub(row.cost)
+	if err != nil {
+		return chatSales{}, err
+	}
+	return chatSales{
+		Key:           row.key,
+		Name:          row.name,
+		Orders:        row.orders,
+		Units:         row.units,
+		Revenue:       row.revenue.Format(),
+		Cost:          row.cost.Format(),
+		Profit:        profit.Format(),
+		MarginPercent: percent(profit.Ratio(row.revenue)),
+		profit:        profit,
+		revenue:       row.revenue,
+	}, nil
+}
+
+// chatSalesRow sums aggregate rows already converted to the business currency
+type chatSalesRow struct {
+	key, name     string
+	orders, units int
+	revenue, cost model.Money
+}
+
+func (r *chatSalesRow) add(row model.AggregateRow) error {
+	var err error
+	if r.revenue, err = r.revenue.Add(row.Revenue); err != nil {
+		return err
+	}
+	if r.cost, err = r.cost.Add(row.Cost); err != nil {
+		return err
+	}
+	r.orders += row.Orders
+	r.units += row.Units
+	return nil
+}
+
+func newChatSalesRow(key, name string, row model.AggregateRow) chatSalesRow {
+	return chatSalesRow{key: key, name: name, orders: row.Orders, units: row.Units, revenue: row.Revenue, cost: row.Cost}
+}
+
+// greater - Orders amounts for ranking. They are all in the business
+// currency; amounts that can't be compared keep their order.
+func greater(a, b model.Money) bool {
+	cmp, err := a.Cmp(b)
+	return err == nil && cmp > 0
+}
+
+// toolLimit - The limit asked for, or the default, capped at the maximum
+func toolLimit(limit int) int {
+	if limit <= 0 {
+		return chatToolDefaultLimit
+	}
+	return min(limit, chatToolMaxLimit)
+}
+
+// percent - A ratio as a percentage rounded to one decimal
+func percent(ratio float64) float64 {
+	return math.Round(ratio*1000) / 10
+}
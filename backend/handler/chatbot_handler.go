@@ -1,215 +1,302 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
-	"os"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/YudaClairee/garudahacks/conversation"
+	"github.com/YudaClairee/garudahacks/llm"
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/gin-gonic/gin"
 )
 
 type ChatbotHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter    model.POSReader
+	business      model.Business
+	llm           *llm.Profile
+	conversations conversation.Store
+	history       *conversation.History
+	maxToolRounds int
 }
 
+// ChatRequest - Without a conversation ID the message starts a new
+// conversation, whose ID comes back with the reply
 type ChatRequest struct {
-	Message string `json:"message" binding:"required"`
+	Message        string `json:"message" binding:"required"`
+	ConversationID string `json:"conversation_id"`
 }
 
+// ChatResponse - ToolRuns lists the POS lookups made for the reply
 type ChatResponse struct {
-	Response string `json:"response"`
-	Message  string `json:"message"`
+	Response       string        `json:"response"`
+	ConversationID string        `json:"conversation_id"`
+	ToolRuns       []llm.ToolRun `json:"tool_runs"`
+	Message        string        `json:"message"`
 }
 
-type GroqChatRequest struct {
-	Messages            []GroqChatMessage `json:"messages"`
-	Model               string            `json:"model"`
-	Temperature         float64           `json:"temperature"`
-	MaxCompletionTokens int               `json:"max_completion_tokens"`
-	TopP                float64           `json:"top_p"`
-	Stream              bool              `json:"stream"`
+// ChatStreamDelta is the data of a "delta" event of the chat stream
+type ChatStreamDelta struct {
+	Content string `json:"content"`
 }
 
-type GroqChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+// ChatStreamDone is the data of the final "done" event of the chat stream
+type ChatStreamDone struct {
+	ConversationID string        `json:"conversation_id"`
+	Model          string        `json:"model"`
+	Usage          llm.Usage     `json:"usage"`
+	ToolRuns       []llm.ToolRun `json:"tool_runs"`
+	DurationMS     int64         `json:"duration_ms"`
 }
 
-type GroqChatResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+// chatTurn is a chat message ready to send: the conversation it continues
+// (nil for a new one), the messages for the model and the tools it may call
+type chatTurn struct {
+	request      ChatRequest
+	conversation *conversation.Conversation
+	messages     []llm.Message
+	toolbox      *llm.Toolbox
 }
 
-func NewChatbotHandler(posAdapter model.POSAdapter) *ChatbotHandler {
-	return &ChatbotHandler{posAdapter: posAdapter}
+// NewChatbotHandler - maxToolRounds bounds the rounds of tool calls the
+// model may make before it has to answer
+func NewChatbotHandler(posAdapter model.POSReader, business model.Business, profile *llm.Profile, conversations conversation.Store, history *conversation.History, maxToolRounds int) *ChatbotHandler {
+	return &ChatbotHandler{
+		posAdapter:    posAdapter,
+		business:      business,
+		llm:           profile,
+		conversations: conversations,
+		history:       history,
+		maxToolRounds: maxToolRounds,
+	}
 }
 
 func (h *ChatbotHandler) Chat(c *gin.Context) {
-	var chatReq ChatRequest
-	if err := c.ShouldBindJSON(&chatReq); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+	turn, ok := h.prepareChat(c)
+	if !ok {
 		return
 	}
 
-	// Gather all business data for system message
-	systemMessage, err := h.generateSystemMessage()
+	// Get response from the chat model, which looks up the data it needs
+	resp, runs, err := h.llm.RunTools(c.Request.Context(), h.toolLoop(turn, nil, nil), turn.messages...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to gather business data: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat response: " + err.Error()})
 		return
 	}
 
-	// Get response from Groq
-	response, err := h.getChatResponse(systemMessage, chatReq.Message)
+	conversationID, err := h.saveTurn(turn, resp.Content)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat response: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save conversation: " + err.Error()})
 		return
 	}
 
 	chatResponse := ChatResponse{
-		Response: response,
-		Message:  "Chat response generated successfully",
+		Response:       resp.Content,
+		ConversationID: conversationID,
+		ToolRuns:       toolRunsOrEmpty(runs),
+		Message:        "Chat response generated successfully",
 	}
 
 	c.JSON(http.StatusOK, chatResponse)
 }
 
-func (h *ChatbotHandler) generateSystemMessage() (string, error) {
-	// Get current year data
-	currentYear := time.Now().Year()
-	startOfYear := time.Date(currentYear, 1, 1, 0, 0, 0, 0, time.UTC)
+// ChatStream - Like Chat, but relays the reply as Server-Sent Events while
+// the model writes it: "delta" events carry pieces of the reply and "tool"
+// events each POS lookup, then a final "done" event carries the conversation,
+// model, token usage and lookups, or an "error" event the reason the reply
+// stopped. A "reset" event means the text streamed so far led to lookups
+// rather than the answer: the client drops it, so what it shows matches the
+// saved reply. The model request is cancelled when the client disconnects,
+// and the unfinished turn isn't saved.
+func (h *ChatbotHandler) ChatStream(c *gin.Context) {
+	turn, ok := h.prepareChat(c)
+	if !ok {
+		return
+	}
 
-	// Get all inventory
-	inventory, err := h.posAdapter.GetInventory()
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	started := time.Now()
+	onDelta := func(delta string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent("delta", ChatStreamDelta{Content: delta})
+		c.Writer.Flush()
+		return nil
+	}
+	onToolRun := func(run llm.ToolRun) {
+		c.SSEvent("tool", run)
+		c.Writer.Flush()
+	}
+	loop := h.toolLoop(turn, onDelta, onToolRun)
+	loop.OnDiscard = func() {
+		c.SSEvent("reset", gin.H{})
+		c.Writer.Flush()
+	}
+	resp, runs, err := h.llm.RunTools(ctx, loop, turn.messages...)
+	if ctx.Err() != nil {
+		// The client is gone; nobody is left to tell
+		log.Printf("Chat stream cancelled: %v", ctx.Err())
+		return
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to get inventory: %w", err)
+		c.SSEvent("error", gin.H{"error": "Failed to get chat response: " + err.Error()})
+		c.Writer.Flush()
+		return
 	}
 
-	// Get all orders for this year
-	orders, err := h.posAdapter.GetCompletedOrders(startOfYear)
+	conversationID, err := h.saveTurn(turn, resp.Content)
 	if err != nil {
-		return "", fmt.Errorf("failed to get orders: %w", err)
+		c.SSEvent("error", gin.H{"error": "Failed to save conversation: " + err.Error()})
+		c.Writer.Flush()
+		return
 	}
 
-	// Calculate business metrics
-	totalRevenue := 0.0
-	totalOrders := len(orders)
-	totalItemsSold := 0
-	totalProductionCost := 0.0
-	monthlySales := make(map[string]int)
-	monthlyRevenue := make(map[string]float64)
-	itemSales := make(map[string]int)
-
-	// Create item lookup map
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
+	c.SSEvent("done", ChatStreamDone{
+		ConversationID: conversationID,
+		Model:          resp.Model,
+		Usage:          resp.Usage,
+		ToolRuns:       toolRunsOrEmpty(runs),
+		DurationMS:     time.Since(started).Milliseconds(),
+	})
+	c.Writer.Flush()
+}
+
+// prepareChat - Reads the chat request and builds the messages for the model:
+// the system message from the business data, the conversation so far and the
+// new message. Answers the request itself on failure.
+func (h *ChatbotHandler) prepareChat(c *gin.Context) (chatTurn, bool) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return chatTurn{}, false
 	}
 
-	// Process orders
-	for _, order := range orders {
-		totalRevenue += order.Total
-		monthKey := order.CompletedAt.Format("2006-01")
-		monthlyRevenue[monthKey] += order.Total
+	var chatReq ChatRequest
+	if err := c.ShouldBindJSON(&chatReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return chatTurn{}, false
+	}
 
-		for _, orderItem := range order.Items {
-			totalItemsSold += orderItem.Quantity
-			monthlySales[monthKey] += orderItem.Quantity
-			itemSales[orderItem.ItemID] += orderItem.Quantity
+	turn := chatTurn{request: chatReq}
+	var window conversation.Window
+	if chatReq.ConversationID != "" {
+		turn.conversation, err = h.conversations.Get(chatReq.ConversationID)
+		if errors.Is(err, conversation.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return chatTurn{}, false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load conversation: " + err.Error()})
+			return chatTurn{}, false
+		}
 
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				totalProductionCost += float64(orderItem.Quantity) * item.ProductionPrice
-			}
+		window, err = h.history.Window(c.Request.Context(), turn.conversation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load conversation history: " + err.Error()})
+			return chatTurn{}, false
 		}
 	}
 
-	// Calculate profit
-	cleanProfit := totalRevenue - totalProductionCost
-	profitMargin := 0.0
-	if totalRevenue > 0 {
-		profitMargin = (cleanProfit / totalRevenue) * 100
+	turn.toolbox = newChatToolbox(h.posAdapter, business)
+	turn.messages = append(turn.messages, llm.Message{Role: llm.RoleSystem, Content: h.generateSystemMessage(business)})
+	if window.Summary != "" {
+		turn.messages = append(turn.messages, llm.Message{
+			Role:    llm.RoleSystem,
+			Content: "Summary of the earlier part of this conversation:\n" + window.Summary,
+		})
 	}
-
-	// Get top selling items (top 10)
-	type ItemSalesData struct {
-		Item      model.Item
-		SoldCount int
+	for _, message := range window.Messages {
+		turn.messages = append(turn.messages, llm.Message{Role: message.Role, Content: message.Content})
 	}
+	turn.messages = append(turn.messages, llm.Message{Role: llm.RoleUser, Content: chatReq.Message})
 
-	var topItems []ItemSalesData
-	for itemID, soldCount := range itemSales {
-		if item, exists := itemMap[itemID]; exists {
-			topItems = append(topItems, ItemSalesData{
-				Item:      item,
-				SoldCount: soldCount,
-			})
-		}
-	}
+	return turn, true
+}
 
-	// Sort top items by sales count (simple bubble sort)
-	for i := 0; i < len(topItems)-1; i++ {
-		for j := 0; j < len(topItems)-i-1; j++ {
-			if topItems[j].SoldCount < topItems[j+1].SoldCount {
-				topItems[j], topItems[j+1] = topItems[j+1], topItems[j]
+// toolLoop - The tool loop of a turn. Every tool call is logged for auditing
+// and passed on to onToolRun when it is set.
+func (h *ChatbotHandler) toolLoop(turn chatTurn, onDelta llm.DeltaFunc, onToolRun func(llm.ToolRun)) llm.ToolLoop {
+	return llm.ToolLoop{
+		Toolbox:   turn.toolbox,
+		MaxRounds: h.maxToolRounds,
+		OnDelta:   onDelta,
+		OnToolRun: func(run llm.ToolRun) {
+			if run.Error != "" {
+				log.Printf("Chat tool %s %s failed after %dms: %s", run.Name, run.Arguments, run.DurationMS, run.Error)
+			} else {
+				log.Printf("Chat tool %s %s ran in %dms", run.Name, run.Arguments, run.DurationMS)
 			}
-		}
+			if onToolRun != nil {
+				onToolRun(run)
+			}
+		},
 	}
+}
 
-	// Limit to top 10
-	if len(topItems) > 10 {
-		topItems = topItems[:10]
+func toolRunsOrEmpty(runs []llm.ToolRun) []llm.ToolRun {
+	if runs == nil {
+		return []llm.ToolRun{}
 	}
+	return runs
+}
 
-	// Build system message
-	systemMessage := fmt.Sprintf(`You are an AI assistant for a Point of Sale (POS) business analytics system. You have access to complete business data and should help users understand their business performance, trends, and provide insights.
-
-CURRENT BUSINESS DATA (%d):
-
-FINANCIAL OVERVIEW:
-- Total Revenue YTD: $%.2f
-- Total Production Cost: $%.2f
-- Clean Profit: $%.2f
-- Profit Margin: %.2f%%
-- Total Orders: %d
-- Total Items Sold: %d
-
-INVENTORY (%d items):`, currentYear, totalRevenue, totalProductionCost, cleanProfit, profitMargin, totalOrders, totalItemsSold, len(inventory))
-
-	// Add inventory details
-	for _, item := range inventory {
-		systemMessage += fmt.Sprintf(`
-- %s (ID: %s): Stock: %d, Price: $%.2f, Production Cost: $%.2f`,
-			item.Name, item.ID, item.Stock, item.Price, item.ProductionPrice)
+// saveTurn - Adds the message and its reply to the conversation, starting a
+// new one titled after the message if needed, and returns its ID
+func (h *ChatbotHandler) saveTurn(turn chatTurn, reply string) (string, error) {
+	current := turn.conversation
+	if current == nil {
+		var err error
+		if current, err = h.conversations.Create(conversationTitle(turn.request.Message)); err != nil {
+			return "", err
+		}
 	}
 
-	// Add top selling items
-	systemMessage += "\n\nTOP SELLING ITEMS:"
-	for i, itemData := range topItems {
-		revenue := float64(itemData.SoldCount) * itemData.Item.Price
-		systemMessage += fmt.Sprintf(`
-%d. %s: %d units sold (Revenue: $%.2f)`,
-			i+1, itemData.Item.Name, itemData.SoldCount, revenue)
+	err := h.conversations.AddMessages(current.ID,
+		conversation.Message{Role: llm.RoleUser, Content: turn.request.Message},
+		conversation.Message{Role: llm.RoleAssistant, Content: reply},
+	)
+	if err != nil {
+		return "", err
 	}
+	return current.ID, nil
+}
 
-	// Add monthly sales breakdown
-	systemMessage += "\n\nMONTHLY SALES BREAKDOWN:"
-	for month := 1; month <= 12; month++ {
-		monthKey := fmt.Sprintf("%d-%02d", currentYear, month)
-		sales := monthlySales[monthKey]
-		revenue := monthlyRevenue[monthKey]
-		monthName := time.Month(month).String()
-		systemMessage += fmt.Sprintf(`
-%s %d: %d items sold, $%.2f revenue`, monthName, currentYear, sales, revenue)
+// conversationTitle - The first line of the opening message, shortened to 60
+// characters
+func conversationTitle(message string) string {
+	title := strings.TrimSpace(message)
+	if line, _, found := strings.Cut(title, "\n"); found {
+		title = strings.TrimSpace(line)
 	}
+	if utf8.RuneCountInString(title) > 60 {
+		title = strings.TrimSpace(string([]rune(title)[:57])) + "..."
+	}
+	return title
+}
 
-	systemMessage += `
+// generateSystemMessage - The chatbot's instructions. The business data
+// isn't included: the model looks up what each question needs with the chat
+// tools.
+func (h *ChatbotHandler) generateSystemMessage(business model.Business) string {
+	today := business.Now()
+
+	return fmt.Sprintf(`You are an AI assistant for a Point of Sale (POS) business analytics system. You help users understand their business performance and trends, and provide insights.
+
+Today is %s (timezone %s). All amounts are in %s.
+
+You don't have the business data up front. Use the tools to look up exactly what each question needs: items and their stock, prices and margins, sales of any period down to a single day, the best-selling items, individual orders and realized margins per item. Dates are YYYY-MM-DD in the business timezone. Don't guess figures you haven't looked up.
 
 INSTRUCTIONS:
 - Answer questions about business performance, trends, and analytics
@@ -220,70 +307,8 @@ INSTRUCTIONS:
 - Suggest business improvements
 - Calculate metrics and projections when asked
 - Be conversational and helpful
-- If asked about data not provided, clearly state that you don't have that specific information
-
-Always base your responses on the actual data provided above. Be specific with numbers and provide actionable insights.`
-
-	return systemMessage, nil
-}
-
-func (h *ChatbotHandler) getChatResponse(systemMessage, userMessage string) (string, error) {
-	groqAPIKey := os.Getenv("GROQ_API_KEY")
-	if groqAPIKey == "" {
-		return "", fmt.Errorf("GROQ_API_KEY environment variable is not set")
-	}
-
-	reqBody := GroqChatRequest{
-		Messages: []GroqChatMessage{
-			{
-				Role:    "system",
-				Content: systemMessage,
-			},
-			{
-				Role:    "user",
-				Content: userMessage,
-			},
-		},
-		Model:               "llama-3.3-70b-versatile", // Good for business conversations
-		Temperature:         0.7,
-		MaxCompletionTokens: 2048,
-		TopP:                0.95,
-		Stream:              false,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+groqAPIKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var groqResp GroqChatResponse
-	if err := json.NewDecoder(resp.Body).Decode(&groqResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if len(groqResp.Choices) == 0 {
-		return "", fmt.Errorf("no response choices received")
-	}
+- If the tools can't provide some data, clearly state that you don't have that specific information
 
-	return groqResp.Choices[0].Message.Content, nil
+Always base your responses on the data the tools return. Be specific with numbers and provide actionable insights.`,
+		today.Format("Monday, 2 January 2006"), business.Zone(), business.Currency)
 }
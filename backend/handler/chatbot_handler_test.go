@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/YudaClairee/garudahacks/conversation"
+	"github.com/YudaClairee/garudahacks/llm"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+// scriptedClient streams the queued responses in turn, word by word, and
+// fails once they run out
+type scriptedClient struct {
+	responses []llm.Response
+}
+
+func (s *scriptedClient) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	if len(s.responses) == 0 {
+		return llm.Response{}, errors.New("model unavailable")
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	resp.Model = req.Model
+	return resp, nil
+}
+
+func (s *scriptedClient) Stream(ctx context.Context, req llm.Request, onDelta llm.DeltaFunc) (llm.Response, error) {
+	resp, err := s.Complete(ctx, req)
+	if err != nil {
+		return llm.Response{}, err
+	}
+	for _, word := range strings.SplitAfter(resp.Content, " ") {
+		if word == "" {
+			continue
+		}
+		if err := onDelta(word); err != nil {
+			return llm.Response{}, err
+		}
+	}
+	return resp, nil
+}
+
+// sseEvent is one Server-Sent Event of a response
+type sseEvent struct {
+	name string
+	data string
+}
+
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.name != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimPrefix(line, "data:")
+		default:
+			t.Fatalf("unexpected stream line %q", line)
+		}
+	}
+	return events
+}
+
+func newChatRouter(t *testing.T, client llm.Client, conversations conversation.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	profile := llm.NewProfile(client, llm.Settings{Model: "test-model"})
+	history := conversation.NewHistory(conversations, conversation.HistoryOptions{}, nil)
+	chatbotHandler := NewChatbotHandler(newTestPOS(t), model.NewBusiness("IDR", nil), profile, conversations, history, 3)
+	router.POST("/chat/stream", chatbotHandler.ChatStream)
+	return router
+}
+
+func TestChatStreamEvents(t *testing.T) {
+	lookup := llm.ToolCall{ID: "call_1", Name: "get_item", Arguments: json.RawMessage(`{"item_id": "ITEM-1"}`)}
+	tests := []struct {
+		name       string
+		responses  []llm.Response
+		wantEvents []string
+		wantReply  string // saved reply, empty if nothing is saved
+	}{
+		{
+			name:       "answer",
+			responses:  []llm.Response{{Content: "Sales are up."}},
+			wantEvents: []string{"delta", "delta", "delta", "done"},
+			wantReply:  "Sales are up.",
+		},
+		{
+			name: "text before a lookup is reset",
+			responses: []llm.Response{
+				{Content: "Let me check.", ToolCalls: []llm.ToolCall{lookup}},
+				{Content: "Eight left."},
+			},
+			wantEvents: []string{"delta", "delta", "delta", "reset", "tool", "delta", "delta", "done"},
+			wantReply:  "Eight left.",
+		},
+		{
+			name:       "model error",
+			responses:  nil,
+			wantEvents: []string{"error"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversations := conversation.NewMemoryStore()
+			router := newChatRouter(t, &scriptedClient{responses: tt.responses}, conversations)
+
+			recorder := serve(router, http.MethodPost, "/chat/stream", `{"message": "How is ITEM-1 doing?"}`)
+			if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/event-stream") {
+				t.Fatalf("response = %d %s, want a 200 event stream", recorder.Code, recorder.Header().Get("Content-Type"))
+			}
+
+			events := readEvents(t, recorder.Body.String())
+			var names []string
+			var shown strings.Builder
+			var done ChatStreamDone
+			for _, event := range events {
+				names = append(names, event.name)
+				switch event.name {
+				case "delta":
+					var delta ChatStreamDelta
+					if err := json.Unmarshal([]byte(event.data), &delta); err != nil {
+						t.Fatalf("decoding delta %s: %v", event.data, err)
+					}
+					shown.WriteString(delta.Content)
+				case "reset":
+					shown.Reset()
+				case "tool":
+					var run llm.ToolRun
+					if err := json.Unmarshal([]byte(event.data), &run); err != nil || run.Error != "" {
+						t.Errorf("tool event %s: %v, want a successful lookup", event.data, err)
+					}
+				case "done":
+					if err := json.Unmarshal([]byte(event.data), &done); err != nil {
+						t.Fatalf("decoding done %s: %v", event.data, err)
+					}
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantEvents, ",") {
+				t.Fatalf("events = %v, want %v", names, tt.wantEvents)
+			}
+			if tt.wantReply == "" {
+				if list, _ := conversations.List(0); len(list) != 0 {
+					t.Errorf("saved %d conversations after an error, want none", len(list))
+				}
+				return
+			}
+
+			// What the client shows is what was saved
+			if shown.String() != tt.wantReply {
+				t.Errorf("shown reply = %q, want %q", shown.String(), tt.wantReply)
+			}
+			messages, err := conversations.Messages(done.ConversationID)
+			if err != nil {
+				t.Fatalf("Messages(%s): %v", done.ConversationID, err)
+			}
+			if len(messages) != 2 || messages[1].Content != tt.wantReply {
+				t.Errorf("saved messages = %+v, want the question and %q", messages, tt.wantReply)
+			}
+			if done.Model != "test-model" {
+				t.Errorf("done model = %q, want test-model", done.Model)
+			}
+			if len(done.ToolRuns) != strings.Count(strings.Join(names, ","), "tool") {
+				t.Errorf("done lists %d tool runs, want one per tool event", len(done.ToolRuns))
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/YudaClairee/garudahacks/conversation"
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationHandler struct {
+	store conversation.Store
+}
+
+type ConversationResponse struct {
+	*conversation.Conversation
+	Messages []conversation.Message `json:"messages"`
+}
+
+type RenameConversationRequest struct {
+	Title string `json:"title" binding:"required"`
+}
+
+func NewConversationHandler(store conversation.Store) *ConversationHandler {
+	return &ConversationHandler{store: store}
+}
+
+// ListConversations - Returns the most recently active conversations first
+func (h *ConversationHandler) ListConversations(c *gin.Context) {
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	conversations, err := h.store.List(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list conversations: " + err.Error()})
+		return
+	}
+	if conversations == nil {
+		conversations = []conversation.Conversation{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations, "count": len(conversations)})
+}
+
+// GetConversation - Returns a conversation with all its messages
+func (h *ConversationHandler) GetConversation(c *gin.Context) {
+	current, err := h.store.Get(c.Param("id"))
+	if err != nil {
+		h.respondError(c, "load", err)
+		return
+	}
+
+	messages, err := h.store.Messages(current.ID)
+	if err != nil {
+		h.respondError(c, "load", err)
+		return
+	}
+	if messages == nil {
+		messages = []conversation.Message{}
+	}
+
+	c.JSON(http.StatusOK, ConversationResponse{Conversation: current, Messages: messages})
+}
+
+func (h *ConversationHandler) RenameConversation(c *gin.Context) {
+	var req RenameConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format: " + err.Error()})
+		return
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Title must not be blank"})
+		return
+	}
+
+	id := c.Param("id")
+	if err := h.store.Rename(id, title); err != nil {
+		h.respondError(c, "rename", err)
+		return
+	}
+
+	renamed, err := h.store.Get(id)
+	if err != nil {
+		h.respondError(c, "load", err)
+		return
+	}
+	c.JSON(http.StatusOK, renamed)
+}
+
+func (h *ConversationHandler) DeleteConversation(c *gin.Context) {
+	if err := h.store.Delete(c.Param("id")); err != nil {
+		h.respondError(c, "delete", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted successfully"})
+}
+
+// respondError - 404 for an unknown conversation, 500 otherwise
+func (h *ConversationHandler) respondError(c *gin.Context, action string, err error) {
+	if errors.Is(err, conversation.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action + " conversation: " + err.Error()})
+}
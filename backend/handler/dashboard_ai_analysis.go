@@ -1,47 +1,22 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/YudaClairee/garudahacks/llm"
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/gin-gonic/gin"
 )
 
 type DashboardAIHandler struct {
-	posAdapter model.POSAdapter
-}
-
-type GroqRequest struct {
-	Messages            []GroqMessage `json:"messages"`
-	Model               string        `json:"model"`
-	Temperature         float64       `json:"temperature"`
-	MaxCompletionTokens int           `json:"max_completion_tokens"`
-	TopP                float64       `json:"top_p"`
-	Stream              bool          `json:"stream"`
-	ReasoningEffort     string        `json:"reasoning_effort"`
-	ResponseFormat      struct {
-		Type string `json:"type"`
-	} `json:"response_format"`
-	Stop interface{} `json:"stop"`
-}
-
-type GroqMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type GroqResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+	posAdapter model.POSReader
+	business   model.Business
+	llm        *llm.Profile
+	retries    int
 }
 
 type AIAnalysisResponse struct {
@@ -54,99 +29,99 @@ type AIAnalysisResponse struct {
 	} `json:"crowd_analysis"`
 }
 
+// Validate - The rules of the analysis beyond its schema
+func (a *AIAnalysisResponse) Validate() []string {
+	var problems []string
+	for _, field := range []struct{ name, text string }{
+		{"top_sellers_recommendation", a.TopSellersRecommendation},
+		{"revenue_insights", a.RevenueInsights},
+		{"crowd_analysis.recommendation", a.CrowdAnalysis.Recommendation},
+	} {
+		if strings.TrimSpace(field.text) == "" {
+			problems = append(problems, field.name+": must not be empty")
+		}
+	}
+	if a.SalesForecastNextMonth < 0 {
+		problems = append(problems, "sales_forecast_next_month: must not be negative")
+	}
+	if a.CrowdAnalysis.EstimatedCrowds < 0 {
+		problems = append(problems, "crowd_analysis.estimated_crowds: must not be negative")
+	}
+	return problems
+}
+
 type CashflowAnalysis struct {
-	CleanProfit    float64 `json:"clean_profit"`
-	ProfitMargin   float64 `json:"profit_margin"`
-	CashflowStatus string  `json:"cashflow_status"`
-	StatusMessage  string  `json:"status_message"`
+	CleanProfit          model.Money `json:"clean_profit"`
+	CleanProfitFormatted string      `json:"clean_profit_formatted"`
+	ProfitMargin         float64     `json:"profit_margin"`
+	CashflowStatus       string      `json:"cashflow_status"`
+	StatusMessage        string      `json:"status_message"`
 }
 
-func NewDashboardAIHandler(posAdapter model.POSAdapter) *DashboardAIHandler {
-	return &DashboardAIHandler{posAdapter: posAdapter}
+// NewDashboardAIHandler - retries is how many times an invalid analysis is
+// sent back to the model to be corrected
+func NewDashboardAIHandler(posAdapter model.POSReader, business model.Business, profile *llm.Profile, retries int) *DashboardAIHandler {
+	return &DashboardAIHandler{posAdapter: posAdapter, business: business, llm: profile, retries: retries}
 }
 
 func (h *DashboardAIHandler) GetDashboardAIAnalysis(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get business location from query parameter
 	location := c.DefaultQuery("location", "Unknown Location")
 
 	// Get current year
-	currentYear := time.Now().Year()
-	startOfYear := time.Date(currentYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	currentYear := business.Now().Year()
+	startOfYear := business.StartOfDay(currentYear, 1, 1)
 
-	// Get top 5 selling items for the year
-	orders, err := h.posAdapter.GetCompletedOrders(startOfYear)
+	// Aggregate monthly totals for the year
+	monthlyRows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: startOfYear, GroupBy: model.GroupByMonth})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	inventory, err := h.posAdapter.GetInventory()
+	// Aggregate per-item sales for the year
+	itemRows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: startOfYear, GroupBy: model.GroupByItem})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	// Create item lookup map
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
-	}
-
 	// Calculate sales data and profit
-	salesMap := make(map[string]*ItemSales)
 	totalSalesYTD := 0
-	totalRevenueYTD := 0.0
-	totalProductionCost := 0.0
 	monthlySales := make(map[string]int) // month -> total items sold
 
-	for _, order := range orders {
-		totalRevenueYTD += order.Total
-
-		// Get month key for monthly breakdown
-		monthKey := order.CompletedAt.Format("2006-01")
-
-		for _, orderItem := range order.Items {
-			totalSalesYTD += orderItem.Quantity
-			monthlySales[monthKey] += orderItem.Quantity
-
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				// Calculate production cost
-				itemProductionCost := float64(orderItem.Quantity) * item.ProductionPrice
-				totalProductionCost += itemProductionCost
-
-				if sales, exists := salesMap[orderItem.ItemID]; exists {
-					sales.TotalSold += orderItem.Quantity
-					sales.TotalRevenue += float64(orderItem.Quantity) * item.Price
-				} else {
-					salesMap[orderItem.ItemID] = &ItemSales{
-						ItemID:       orderItem.ItemID,
-						ItemName:     item.Name,
-						Price:        item.Price,
-						TotalSold:    orderItem.Quantity,
-						TotalRevenue: float64(orderItem.Quantity) * item.Price,
-					}
-				}
-			}
-		}
+	for _, row := range monthlyRows {
+		totalSalesYTD += row.Units
+		monthlySales[row.Key] = row.Units
+	}
+	totalRevenueYTD, totalProductionCost, err := sumSales(business, monthlyRows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
+		return
 	}
 
 	// Convert monthly sales to ordered array
 	monthlySalesArray := h.generateMonthlySalesArray(monthlySales, currentYear)
 
 	// Calculate clean profit and cashflow status
-	cleanProfit := totalRevenueYTD - totalProductionCost
-	profitMargin := 0.0
-	if totalRevenueYTD > 0 {
-		profitMargin = (cleanProfit / totalRevenueYTD) * 100
+	cleanProfit, err := totalRevenueYTD.Sub(totalProductionCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
+		return
 	}
+	profitMargin := cleanProfit.Ratio(totalRevenueYTD) * 100
 
 	cashflowAnalysis := h.calculateCashflowStatus(cleanProfit, profitMargin)
 
-	// Convert to slice and get top 5
-	var itemSales []ItemSales
-	for _, sales := range salesMap {
-		itemSales = append(itemSales, *sales)
-	}
+	// Convert to item sales and get top 5
+	itemSales := itemSalesFromRows(itemRows)
 
 	// Sort by total sold (desc)
 	h.sortItemSales(itemSales, "total_sold", "desc")
@@ -160,23 +135,23 @@ func (h *DashboardAIHandler) GetDashboardAIAnalysis(c *gin.Context) {
 	// Prepare content for AI (include monthly sales)
 	content := h.prepareAIContent(topItems, totalSalesYTD, totalRevenueYTD, monthlySalesArray, cleanProfit, profitMargin, location)
 
-	// Get AI analysis
-	analysis, err := h.getAIAnalysis(content)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI analysis: " + err.Error()})
-		return
-	}
+	// Get AI analysis; the dashboard figures are returned without it, or
+	// with only its valid parts, when the model fails
+	analysis, warnings := h.getAIAnalysis(c.Request.Context(), content)
 
 	// Return the response
 	response := gin.H{
-		"top_selling_items": topItems,
-		"total_sales_ytd":   totalSalesYTD,
-		"monthly_sales":     monthlySalesArray,
-		"total_revenue_ytd": totalRevenueYTD,
-		"business_location": location,
-		"year":              currentYear,
-		"ai_analysis":       analysis,
-		"cashflow_analysis": cashflowAnalysis,
+		"top_selling_items":           topItems,
+		"total_sales_ytd":             totalSalesYTD,
+		"monthly_sales":               monthlySalesArray,
+		"currency":                    business.Currency,
+		"total_revenue_ytd":           totalRevenueYTD,
+		"total_revenue_ytd_formatted": totalRevenueYTD.Format(),
+		"business_location":           location,
+		"year":                        currentYear,
+		"ai_analysis":                 analysis,
+		"ai_warnings":                 warnings,
+		"cashflow_analysis":           cashflowAnalysis,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -201,7 +176,7 @@ func (h *DashboardAIHandler) generateMonthlySalesArray(monthlySales map[string]i
 	return salesArray
 }
 
-func (h *DashboardAIHandler) prepareAIContent(topItems []ItemSales, totalSalesYTD int, totalRevenueYTD float64, monthlySales []map[string]interface{}, cleanProfit, profitMargin float64, location string) string {
+func (h *DashboardAIHandler) prepareAIContent(topItems []ItemSales, totalSalesYTD int, totalRevenueYTD model.Money, monthlySales []map[string]interface{}, cleanProfit model.Money, profitMargin float64, location string) string {
 	content := fmt.Sprintf("Business Location: %s\n\n", location)
 	content += fmt.Sprintf("Total Sales Year-to-Date (YTD): %d items sold\n\n", totalSalesYTD)
 	content += "Monthly Sales Breakdown (items sold):\n"
@@ -210,24 +185,22 @@ func (h *DashboardAIHandler) prepareAIContent(topItems []ItemSales, totalSalesYT
 			monthData["month_name"], monthData["year"], monthData["sales"])
 	}
 	content += "\n"
-	content += fmt.Sprintf("Total Revenue This Year: $%.2f\n\n", totalRevenueYTD)
-	content += fmt.Sprintf("Clean Profit This Year: $%.2f (%.2f%% margin)\n\n", cleanProfit, profitMargin)
+	content += fmt.Sprintf("Currency: %s\n\n", h.business.Currency)
+	content += fmt.Sprintf("Total Revenue This Year: %s\n\n", totalRevenueYTD.Format())
+	content += fmt.Sprintf("Clean Profit This Year: %s (%.2f%% margin)\n\n", cleanProfit.Format(), profitMargin)
 
 	content += "Top 5 Best-Selling Items:\n"
 	for i, item := range topItems {
-		content += fmt.Sprintf("%d. %s - %d units sold (Revenue: $%.2f)\n",
-			i+1, item.ItemName, item.TotalSold, item.TotalRevenue)
+		content += fmt.Sprintf("%d. %s - %d units sold (Revenue: %s)\n",
+			i+1, item.ItemName, item.TotalSold, item.TotalRevenue.Format())
 	}
 
 	return content
 }
 
-func (h *DashboardAIHandler) getAIAnalysis(content string) (*AIAnalysisResponse, error) {
-	groqAPIKey := os.Getenv("GROQ_API_KEY")
-	if groqAPIKey == "" {
-		return nil, fmt.Errorf("GROQ_API_KEY environment variable is not set")
-	}
-
+// getAIAnalysis - Returns the analysis and what was wrong with it: fields
+// the model got wrong are left empty, and everything is when it failed
+func (h *DashboardAIHandler) getAIAnalysis(ctx context.Context, content string) (AIAnalysisResponse, []string) {
 	systemPrompt := `You are a business analytics assistant. I will provide you:
 
     The 5 best-selling items.
@@ -262,79 +235,21 @@ Respond only with a JSON output in the following format:
 
 Do not add any explanation or text outside the JSON.`
 
-	reqBody := GroqRequest{
-		Messages: []GroqMessage{
-			{
-				Role:    "system",
-				Content: systemPrompt,
-			},
-			{
-				Role:    "user",
-				Content: content,
-			},
-		},
-		Model:               "qwen/qwen3-32b",
-		Temperature:         0.6,
-		MaxCompletionTokens: 4096,
-		TopP:                0.95,
-		Stream:              false,
-		ReasoningEffort:     "default",
-		ResponseFormat: struct {
-			Type string `json:"type"`
-		}{
-			Type: "json_object",
-		},
-		Stop: nil,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(jsonData))
+	analysis, warnings, err := llm.CompleteStructured[AIAnalysisResponse](ctx, h.llm, h.retries,
+		llm.Message{Role: llm.RoleSystem, Content: systemPrompt},
+		llm.Message{Role: llm.RoleUser, Content: content},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+groqAPIKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+		warnings = []string{"AI analysis unavailable: " + err.Error()}
 	}
-
-	var groqResp GroqResponse
-	if err := json.NewDecoder(resp.Body).Decode(&groqResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	if len(groqResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	// Parse the AI response JSON
-	var analysis AIAnalysisResponse
-	if err := json.Unmarshal([]byte(groqResp.Choices[0].Message.Content), &analysis); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v", err)
-	}
-
-	return &analysis, nil
+	return analysis, aiWarnings("dashboard", warnings)
 }
 
-func (h *DashboardAIHandler) calculateCashflowStatus(cleanProfit, profitMargin float64) CashflowAnalysis {
+func (h *DashboardAIHandler) calculateCashflowStatus(cleanProfit model.Money, profitMargin float64) CashflowAnalysis {
 	var status, message string
 
 	switch {
-	case cleanProfit < 0:
+	case cleanProfit.IsNegative():
 		status = "NEGATIVE"
 		message = "Business is operating at a loss. Immediate action required."
 	case profitMargin < 5:
@@ -355,10 +270,11 @@ func (h *DashboardAIHandler) calculateCashflowStatus(cleanProfit, profitMargin f
 	}
 
 	return CashflowAnalysis{
-		CleanProfit:    cleanProfit,
-		ProfitMargin:   profitMargin,
-		CashflowStatus: status,
-		StatusMessage:  message,
+		CleanProfit:          cleanProfit,
+		CleanProfitFormatted: cleanProfit.Format(),
+		ProfitMargin:         profitMargin,
+		CashflowStatus:       status,
+		StatusMessage:        message,
 	}
 }
 
@@ -377,9 +293,9 @@ func (h *DashboardAIHandler) sortItemSales(items []ItemSales, sortBy, order stri
 				}
 			case "total_revenue":
 				if order == "desc" {
-					shouldSwap = items[j].TotalRevenue < items[j+1].TotalRevenue
+					shouldSwap = items[j].TotalRevenue.Amount < items[j+1].TotalRevenue.Amount
 				} else {
-					shouldSwap = items[j].TotalRevenue > items[j+1].TotalRevenue
+					shouldSwap = items[j].TotalRevenue.Amount > items[j+1].TotalRevenue.Amount
 				}
 			case "item_name":
 				if order == "desc" {
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// adapterErrorStatus maps the typed errors returned by POS adapters to HTTP
+// status codes. Records an adapter rejects as invalid, such as an order in
+// another currency than its items, are the client's fault.
+func adapterErrorStatus(err error) int {
+	var inUseErr *model.ItemInUseError
+	var stockErr *model.InsufficientStockError
+
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &inUseErr), errors.As(err, &stockErr):
+		return http.StatusConflict
+	case errors.Is(err, model.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+// patchRequest is a PATCH body split into its top-level fields plus the field
+// mask saying which of them to apply.
+//
+// The mask comes from the update_mask query parameter (comma separated, e.g.
+// ?update_mask=name,price). Without it, every field present in the body is
+// applied. A masked field that is missing from the body is reset to its zero
+// value, so ?update_mask=oversell_policy with an empty body clears the policy.
+type patchRequest struct {
+	fields map[string]json.RawMessage
+	mask   []string
+}
+
+// parsePatchRequest reads the PATCH body and field mask and rejects fields
+// that can't be patched
+func parsePatchRequest(c *gin.Context, patchable map[string]bool) (*patchRequest, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := c.ShouldBindJSON(&fields); err != nil {
+		return nil, fmt.Errorf("Invalid JSON format: %s", err.Error())
+	}
+
+	var mask []string
+	if updateMask := c.Query("update_mask"); updateMask != "" {
+		for _, field := range strings.Split(updateMask, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				mask = append(mask, field)
+			}
+		}
+	} else {
+		for field := range fields {
+			mask = append(mask, field)
+		}
+		sort.Strings(mask)
+	}
+
+	if len(mask) == 0 {
+		return nil, fmt.Errorf("nothing to update: send the fields to change or an update_mask")
+	}
+
+	for _, field := range mask {
+		if !patchable[field] {
+			return nil, fmt.Errorf("field %q cannot be updated", field)
+		}
+	}
+
+	return &patchRequest{fields: fields, mask: mask}, nil
+}
+
+// has reports whether a field is part of the mask
+func (p *patchRequest) has(field string) bool {
+	for _, masked := range p.mask {
+		if masked == field {
+			return true
+		}
+	}
+	return false
+}
+
+// decode writes the new value of a masked field into target. target must
+// already hold the field's zero value; it is left untouched when the field is
+// missing from the body.
+func (p *patchRequest) decode(field string, target interface{}) error {
+	raw, exists := p.fields[field]
+	if !exists {
+		return nil
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("invalid value for %s: %s", field, err.Error())
+	}
+	return nil
+}
+
+// decodeOrderItems - Like decode for order lines, whose amounts without a
+// currency are read in currency
+func (p *patchRequest) decodeOrderItems(field string, currency string) ([]model.OrderItem, error) {
+	items, err := model.DecodeOrderItems(p.fields[field], currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %s", field, err.Error())
+	}
+	return items, nil
+}
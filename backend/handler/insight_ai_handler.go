@@ -1,52 +1,28 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/YudaClairee/garudahacks/llm"
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/gin-gonic/gin"
 )
 
 type InsightAIHandler struct {
-	posAdapter model.POSAdapter
-}
-
-type GroqInsightRequest struct {
-	Messages            []GroqInsightMessage `json:"messages"`
-	Model               string               `json:"model"`
-	Temperature         float64              `json:"temperature"`
-	MaxCompletionTokens int                  `json:"max_completion_tokens"`
-	TopP                float64              `json:"top_p"`
-	Stream              bool                 `json:"stream"`
-	ReasoningEffort     string               `json:"reasoning_effort"`
-	ResponseFormat      struct {
-		Type string `json:"type"`
-	} `json:"response_format"`
-	Stop interface{} `json:"stop"`
-}
-
-type GroqInsightMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type GroqInsightResponse struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+	posAdapter model.POSReader
+	business   model.Business
+	llm        *llm.Profile
+	retries    int
 }
 
 type MonthlyRevenue struct {
-	Month   string  `json:"month"`
-	Revenue float64 `json:"revenue"`
+	Month            string      `json:"month"`
+	Revenue          model.Money `json:"revenue"`
+	RevenueFormatted string      `json:"revenue_formatted"`
 }
 
 type MonthProjection struct {
@@ -55,6 +31,14 @@ type MonthProjection struct {
 	BadPredict float64 `json:"bad_predict"`
 }
 
+// validate - The scenarios must be ordered: bad <= stagnant <= high
+func (p MonthProjection) validate(field string) []string {
+	if p.BadPredict <= p.Stagnancy && p.Stagnancy <= p.HiPredict {
+		return nil
+	}
+	return []string{fmt.Sprintf("revenue_forecast.%s: expected bad_predict <= stagnancy <= hi_predict, got %v, %v and %v", field, p.BadPredict, p.Stagnancy, p.HiPredict)}
+}
+
 type RevenueForecast struct {
 	LastMonthProjection MonthProjection `json:"last_month_projection"`
 	Month1              MonthProjection `json:"month_1"`
@@ -67,97 +51,111 @@ type InsightAIResponse struct {
 	TrendAnalytics  string          `json:"trend_analytics"`
 }
 
+// Validate - The rules of the insights beyond their schema
+func (i *InsightAIResponse) Validate() []string {
+	var problems []string
+	problems = append(problems, i.RevenueForecast.LastMonthProjection.validate("last_month_projection")...)
+	problems = append(problems, i.RevenueForecast.Month1.validate("month_1")...)
+	problems = append(problems, i.RevenueForecast.Month2.validate("month_2")...)
+
+	if len(i.FinancialTips) < 3 {
+		problems = append(problems, fmt.Sprintf("financial_tips: expected at least 3 tips, got %d", len(i.FinancialTips)))
+	}
+	if strings.TrimSpace(i.TrendAnalytics) == "" {
+		problems = append(problems, "trend_analytics: must not be empty")
+	}
+	return problems
+}
+
 type BusinessInsightResponse struct {
+	Currency        string            `json:"currency"`
 	MonthlyRevenues []MonthlyRevenue  `json:"monthly_revenues"`
-	TotalRevenue    float64           `json:"total_revenue"`
-	TotalProfit     float64           `json:"total_profit"`
-	TotalExpenses   float64           `json:"total_expenses"`
+	TotalRevenue    model.Money       `json:"total_revenue"`
+	TotalProfit     model.Money       `json:"total_profit"`
+	TotalExpenses   model.Money       `json:"total_expenses"`
 	AIInsights      InsightAIResponse `json:"ai_insights"`
+	AIWarnings      []string          `json:"ai_warnings"`
 	Year            int               `json:"year"`
 	Message         string            `json:"message"`
 }
 
-func NewInsightAIHandler(posAdapter model.POSAdapter) *InsightAIHandler {
-	return &InsightAIHandler{posAdapter: posAdapter}
+// NewInsightAIHandler - retries is how many times invalid insights are sent
+// back to the model to be corrected
+func NewInsightAIHandler(posAdapter model.POSReader, business model.Business, profile *llm.Profile, retries int) *InsightAIHandler {
+	return &InsightAIHandler{posAdapter: posAdapter, business: business, llm: profile, retries: retries}
 }
 
 func (h *InsightAIHandler) GetBusinessInsights(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get current year and month
-	now := time.Now()
+	now := business.Now()
 	currentYear := now.Year()
 	currentMonth := int(now.Month())
-	startOfYear := time.Date(currentYear, 1, 1, 0, 0, 0, 0, time.UTC)
+	startOfYear := business.StartOfDay(currentYear, 1, 1)
 
-	// Get all orders for the year
-	orders, err := h.posAdapter.GetCompletedOrders(startOfYear)
+	// Aggregate monthly revenue and production cost for the year
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: startOfYear, GroupBy: model.GroupByMonth})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	// Get inventory for production costs
-	inventory, err := h.posAdapter.GetInventory()
+	// Calculate monthly revenues and financial data
+	monthlyRevenues := make(map[string]model.Money)
+	for _, row := range rows {
+		monthlyRevenues[row.Key] = row.Revenue
+	}
+	totalRevenue, totalProductionCost, err := sumSales(business, rows)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
 		return
 	}
 
-	// Create item lookup map for production costs
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
-	}
-
-	// Calculate monthly revenues and financial data
-	monthlyRevenues := make(map[string]float64)
-	totalRevenue := 0.0
-	totalProductionCost := 0.0
-
-	for _, order := range orders {
-		monthKey := order.CompletedAt.Format("2006-01")
-		monthlyRevenues[monthKey] += order.Total
-		totalRevenue += order.Total
-
-		// Calculate production costs
-		for _, orderItem := range order.Items {
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				totalProductionCost += float64(orderItem.Quantity) * item.ProductionPrice
-			}
-		}
-	}
-
 	// Convert to ordered array of monthly revenues (only up to current month)
 	var monthlyRevenueArray []MonthlyRevenue
 	for month := 1; month <= currentMonth; month++ {
 		monthKey := fmt.Sprintf("%d-%02d", currentYear, month)
-		revenue := monthlyRevenues[monthKey]
+		revenue, exists := monthlyRevenues[monthKey]
+		if !exists {
+			revenue = business.Zero()
+		}
 		monthlyRevenueArray = append(monthlyRevenueArray, MonthlyRevenue{
-			Month:   fmt.Sprintf("%s %d", time.Month(month).String(), currentYear),
-			Revenue: revenue,
+			Month:            fmt.Sprintf("%s %d", time.Month(month).String(), currentYear),
+			Revenue:          revenue,
+			RevenueFormatted: revenue.Format(),
 		})
 	}
 
 	// Calculate financial metrics
-	totalProfit := totalRevenue - totalProductionCost
+	totalProfit, err := totalRevenue.Sub(totalProductionCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
+		return
+	}
 	totalExpenses := totalProductionCost
 
 	// Prepare AI content
-	content := h.prepareInsightContent(monthlyRevenueArray, totalRevenue, totalProfit, totalExpenses, currentMonth)
+	content := h.prepareInsightContent(monthlyRevenueArray, totalRevenue, totalProfit, totalExpenses, currentYear, currentMonth)
 
-	// Get AI insights
-	aiInsights, err := h.getAIInsights(content)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get AI insights: " + err.Error()})
-		return
-	}
+	// Get AI insights; the figures are returned without them, or with only
+	// their valid parts, when the model fails
+	aiInsights, warnings := h.getAIInsights(c.Request.Context(), content)
 
 	// Prepare response
 	response := BusinessInsightResponse{
+		Currency:        business.Currency,
 		MonthlyRevenues: monthlyRevenueArray,
 		TotalRevenue:    totalRevenue,
 		TotalProfit:     totalProfit,
 		TotalExpenses:   totalExpenses,
-		AIInsights:      *aiInsights,
+		AIInsights:      aiInsights,
+		AIWarnings:      warnings,
 		Year:            currentYear,
 		Message:         "Business insights generated successfully",
 	}
@@ -165,26 +163,24 @@ func (h *InsightAIHandler) GetBusinessInsights(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *InsightAIHandler) prepareInsightContent(monthlyRevenues []MonthlyRevenue, totalRevenue, totalProfit, totalExpenses float64, currentMonth int) string {
-	content := fmt.Sprintf("Monthly Revenue Data (January to %s %d):\n", time.Month(currentMonth).String(), time.Now().Year())
+func (h *InsightAIHandler) prepareInsightContent(monthlyRevenues []MonthlyRevenue, totalRevenue, totalProfit, totalExpenses model.Money, currentYear, currentMonth int) string {
+	content := fmt.Sprintf("All amounts are in %s.\n\n", h.business.Currency)
+	content += fmt.Sprintf("Monthly Revenue Data (January to %s %d):\n", time.Month(currentMonth).String(), currentYear)
 	for _, monthData := range monthlyRevenues {
-		content += fmt.Sprintf("%s: $%.2f\n", monthData.Month, monthData.Revenue)
+		content += fmt.Sprintf("%s: %s\n", monthData.Month, monthData.RevenueFormatted)
 	}
 
 	content += fmt.Sprintf("\nCurrent Financial Numbers (Year-to-Date through %s):\n", time.Month(currentMonth).String())
-	content += fmt.Sprintf("Total Revenue: $%.2f\n", totalRevenue)
-	content += fmt.Sprintf("Total Profit: $%.2f\n", totalProfit)
-	content += fmt.Sprintf("Total Expenses: $%.2f\n", totalExpenses)
+	content += fmt.Sprintf("Total Revenue: %s\n", totalRevenue.Format())
+	content += fmt.Sprintf("Total Profit: %s\n", totalProfit.Format())
+	content += fmt.Sprintf("Total Expenses: %s\n", totalExpenses.Format())
 
 	return content
 }
 
-func (h *InsightAIHandler) getAIInsights(content string) (*InsightAIResponse, error) {
-	groqAPIKey := os.Getenv("GROQ_API_KEY")
-	if groqAPIKey == "" {
-		return nil, fmt.Errorf("GROQ_API_KEY environment variable is not set")
-	}
-
+// getAIInsights - Returns the insights and what was wrong with them: fields
+// the model got wrong are left empty, and everything is when it failed
+func (h *InsightAIHandler) getAIInsights(ctx context.Context, content string) (InsightAIResponse, []string) {
 	systemPrompt := `You are a financial analytics assistant. I will provide:
 
         An array of monthly revenues (e.g., January to July).
@@ -238,70 +234,15 @@ func (h *InsightAIHandler) getAIInsights(content string) (*InsightAIResponse, er
 
     Do not return any text outside the JSON.`
 
-	reqBody := GroqInsightRequest{
-		Messages: []GroqInsightMessage{
-			{
-				Role:    "system",
-				Content: systemPrompt,
-			},
-			{
-				Role:    "user",
-				Content: content,
-			},
-		},
-		Model:               "qwen/qwen3-32b",
-		Temperature:         0.6,
-		MaxCompletionTokens: 4096,
-		TopP:                0.95,
-		Stream:              false,
-		ReasoningEffort:     "default",
-		ResponseFormat: struct {
-			Type string `json:"type"`
-		}{
-			Type: "json_object",
-		},
-		Stop: nil,
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", "https://api.groq.com/openai/v1/chat/completions", bytes.NewBuffer(jsonData))
+	insights, warnings, err := llm.CompleteStructured[InsightAIResponse](ctx, h.llm, h.retries,
+		llm.Message{Role: llm.RoleSystem, Content: systemPrompt},
+		llm.Message{Role: llm.RoleUser, Content: content},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		warnings = []string{"AI insights unavailable: " + err.Error()}
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+groqAPIKey)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %v", err)
+	if insights.FinancialTips == nil {
+		insights.FinancialTips = []string{}
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
-	}
-
-	var groqResp GroqInsightResponse
-	if err := json.NewDecoder(resp.Body).Decode(&groqResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %v", err)
-	}
-
-	if len(groqResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
-	}
-
-	// Parse the AI response JSON
-	var insights InsightAIResponse
-	if err := json.Unmarshal([]byte(groqResp.Choices[0].Message.Content), &insights); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v", err)
-	}
-
-	return &insights, nil
+	return insights, aiWarnings("insights", warnings)
 }
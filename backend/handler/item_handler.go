@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+type ItemHandler struct {
+	posAdapter model.POSReader
+}
+
+// itemPatchFields are the item fields PATCH /items/:id can change
+var itemPatchFields = map[string]bool{
+	"name":             true,
+	"stock":            true,
+	"price":            true,
+	"production_price": true,
+	"oversell_policy":  true,
+}
+
+func NewItemHandler(posAdapter model.POSReader) *ItemHandler {
+	return &ItemHandler{posAdapter: posAdapter}
+}
+
+func (h *ItemHandler) GetItem(c *gin.Context) {
+	item, err := h.posAdapter.GetItemByID(c.Param("id"))
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// ReplaceItem - PUT /items/:id replaces every field of an existing item
+func (h *ItemHandler) ReplaceItem(c *gin.Context) {
+	items, ok := inventoryWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+	itemID := c.Param("id")
+
+	existing, err := h.posAdapter.GetItemByID(itemID)
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Amounts are read in the item's currency
+	item := model.Item{
+		Price:           model.Money{Currency: existing.Price.Currency},
+		ProductionPrice: model.Money{Currency: existing.ProductionPrice.Currency},
+	}
+	if err := c.ShouldBindJSON(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format: " + err.Error()})
+		return
+	}
+
+	if item.ID != "" && item.ID != itemID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Item ID in the body does not match the URL"})
+		return
+	}
+	item.ID = itemID
+
+	h.saveItem(c, items, item)
+}
+
+// PatchItem - PATCH /items/:id updates the fields named in the field mask
+func (h *ItemHandler) PatchItem(c *gin.Context) {
+	items, ok := inventoryWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	item, err := h.posAdapter.GetItemByID(c.Param("id"))
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, err := parsePatchRequest(c, itemPatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, field := range patch.mask {
+		switch field {
+		case "name":
+			item.Name = ""
+			err = patch.decode(field, &item.Name)
+		case "stock":
+			item.Stock = 0
+			err = patch.decode(field, &item.Stock)
+		case "price":
+			item.Price = model.Money{Currency: item.Price.Currency}
+			err = patch.decode(field, &item.Price)
+		case "production_price":
+			item.ProductionPrice = model.Money{Currency: item.ProductionPrice.Currency}
+			err = patch.decode(field, &item.ProductionPrice)
+		case "oversell_policy":
+			item.OversellPolicy = ""
+			err = patch.decode(field, &item.OversellPolicy)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	h.saveItem(c, items, *item)
+}
+
+// saveItem - Validates and writes an updated item
+func (h *ItemHandler) saveItem(c *gin.Context, items model.InventoryWriter, item model.Item) {
+	if err := validateItem(&item); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error: " + err.Error()})
+		return
+	}
+
+	if err := items.UpdateItem(item); err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": "Failed to update item: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item updated successfully",
+		"item":    item,
+	})
+}
+
+func (h *ItemHandler) DeleteItem(c *gin.Context) {
+	items, ok := inventoryWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	if err := items.DeleteItem(c.Param("id")); err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item deleted successfully"})
+}
@@ -0,0 +1,252 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+var testTime = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+var errStorage = errors.New("storage unavailable")
+
+// newTestRouter - The item and order routes of main.go, served by posAdapter
+func newTestRouter(posAdapter model.POSReader) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	business := model.NewBusiness("IDR", nil)
+
+	addItemHandler := NewAddItemHandler(posAdapter, business)
+	addOrderHandler := NewAddOrderHandler(posAdapter, business)
+	itemHandler := NewItemHandler(posAdapter)
+	orderHandler := NewOrderHandler(posAdapter)
+
+	router.POST("/items/add-single-item", addItemHandler.AddSingleItem)
+	router.POST("/orders/add-single-item", addOrderHandler.AddSingleOrder)
+	router.GET("/items/:id", itemHandler.GetItem)
+	router.PUT("/items/:id", itemHandler.ReplaceItem)
+	router.PATCH("/items/:id", itemHandler.PatchItem)
+	router.DELETE("/items/:id", itemHandler.DeleteItem)
+	router.GET("/orders/:id", orderHandler.GetOrder)
+	router.PUT("/orders/:id", orderHandler.ReplaceOrder)
+	router.PATCH("/orders/:id", orderHandler.PatchOrder)
+	router.DELETE("/orders/:id", orderHandler.DeleteOrder)
+	return router
+}
+
+// newTestPOS - A memory POS with ITEM-1 (Rp 1.500), ITEM-2 (Rp 4.000, 5 in
+// stock) and ORD-1 selling two of the ten ITEM-1 stocked
+func newTestPOS(t *testing.T) *adapter.MemoryPosAdapter {
+	t.Helper()
+	pos := adapter.NewMemoryPosAdapter("IDR")
+	for _, item := range []model.Item{
+		{ID: "ITEM-1", Name: "Kopi Susu", Stock: 10, Price: model.MustParseMoney("1500", "IDR"), ProductionPrice: model.MustParseMoney("900", "IDR")},
+		{ID: "ITEM-2", Name: "Roti Bakar", Stock: 5, Price: model.MustParseMoney("4000", "IDR"), ProductionPrice: model.MustParseMoney("2500", "IDR")},
+	} {
+		if err := pos.AddItem(item); err != nil {
+			t.Fatalf("AddItem(%s): %v", item.ID, err)
+		}
+	}
+	order := model.Order{ID: "ORD-1", CompletedAt: testTime, Items: []model.OrderItem{{ItemID: "ITEM-1", Quantity: 2}}}
+	if err := pos.AddOrder(order); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	return pos
+}
+
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func getTestItem(t *testing.T, pos model.POSReader, itemID string) model.Item {
+	t.Helper()
+	item, err := pos.GetItemByID(itemID)
+	if err != nil {
+		t.Fatalf("GetItemByID(%s): %v", itemID, err)
+	}
+	return *item
+}
+
+func TestItemRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		want       *model.Item // ITEM-1 afterwards
+	}{
+		{
+			name:       "replace",
+			method:     http.MethodPut,
+			path:       "/items/ITEM-1",
+			body:       `{"name": "Kopi Gula Aren", "stock": 12, "price": 1800, "production_price": "1000"}`,
+			wantStatus: http.StatusOK,
+			want:       &model.Item{ID: "ITEM-1", Name: "Kopi Gula Aren", Stock: 12, Price: model.MustParseMoney("1800", "IDR"), ProductionPrice: model.MustParseMoney("1000", "IDR")},
+		},
+		{
+			name:       "replace with another ID in the body",
+			method:     http.MethodPut,
+			path:       "/items/ITEM-1",
+			body:       `{"id": "ITEM-2", "name": "Kopi Susu", "stock": 12, "price": 1800, "production_price": 1000}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "replace failing validation",
+			method:     http.MethodPut,
+			path:       "/items/ITEM-1",
+			body:       `{"name": "Kopi Susu", "stock": -1, "price": 1800, "production_price": 1000}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "replace a missing item",
+			method:     http.MethodPut,
+			path:       "/items/ITEM-9",
+			body:       `{"name": "Teh", "stock": 1, "price": 1000, "production_price": 500}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "patch the fields in the body",
+			method:     http.MethodPatch,
+			path:       "/items/ITEM-1",
+			body:       `{"price": 1750, "stock": 7}`,
+			wantStatus: http.StatusOK,
+			want:       &model.Item{ID: "ITEM-1", Name: "Kopi Susu", Stock: 7, Price: model.MustParseMoney("1750", "IDR"), ProductionPrice: model.MustParseMoney("900", "IDR")},
+		},
+		{
+			name:       "patch only the masked fields",
+			method:     http.MethodPatch,
+			path:       "/items/ITEM-1?update_mask=name",
+			body:       `{"name": "Kopi Hitam", "price": 1}`,
+			wantStatus: http.StatusOK,
+			want:       &model.Item{ID: "ITEM-1", Name: "Kopi Hitam", Stock: 8, Price: model.MustParseMoney("1500", "IDR"), ProductionPrice: model.MustParseMoney("900", "IDR")},
+		},
+		{
+			name:       "patch a field that can't change",
+			method:     http.MethodPatch,
+			path:       "/items/ITEM-1",
+			body:       `{"id": "ITEM-3"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch with nothing to change",
+			method:     http.MethodPatch,
+			path:       "/items/ITEM-1",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "patch a missing item",
+			method:     http.MethodPatch,
+			path:       "/items/ITEM-9",
+			body:       `{"stock": 1}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "delete an item with orders",
+			method:     http.MethodDelete,
+			path:       "/items/ITEM-1",
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "delete",
+			method:     http.MethodDelete,
+			path:       "/items/ITEM-2",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "delete a missing item",
+			method:     http.MethodDelete,
+			path:       "/items/ITEM-9",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		pos := newTestPOS(t)
+		recorder := serve(newTestRouter(pos), tt.method, tt.path, tt.body)
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, recorder.Code, tt.wantStatus, recorder.Body)
+			continue
+		}
+		if tt.want != nil {
+			if got := getTestItem(t, pos, "ITEM-1"); got != *tt.want {
+				t.Errorf("%s: ITEM-1 = %+v, want %+v", tt.name, got, *tt.want)
+			}
+		}
+	}
+}
+
+func TestPatchItemResetsMaskedFieldsMissingFromBody(t *testing.T) {
+	pos := newTestPOS(t)
+	item := getTestItem(t, pos, "ITEM-1")
+	item.OversellPolicy = model.OversellAllow
+	if err := pos.UpdateItem(item); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+
+	recorder := serve(newTestRouter(pos), http.MethodPatch, "/items/ITEM-1?update_mask=oversell_policy", `{}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200 (%s)", recorder.Code, recorder.Body)
+	}
+	if got := getTestItem(t, pos, "ITEM-1").OversellPolicy; got != "" {
+		t.Errorf("oversell policy = %q, want it cleared", got)
+	}
+}
+
+func TestItemWritesNeedAWriter(t *testing.T) {
+	router := newTestRouter(adapter.ReadOnly(newTestPOS(t)))
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		recorder := serve(router, method, "/items/ITEM-1", `{"stock": 1}`)
+		if recorder.Code != http.StatusNotImplemented {
+			t.Errorf("%s: status %d, want 501", method, recorder.Code)
+		}
+	}
+}
+
+// rejectingPOS is a memory POS whose writes fail with err
+type rejectingPOS struct {
+	*adapter.MemoryPosAdapter
+	err error
+}
+
+func (p rejectingPOS) AddItem(model.Item) error   { return p.err }
+func (p rejectingPOS) AddOrder(model.Order) error { return p.err }
+
+func TestAddSingleMapsAdapterErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "invalid", err: &model.ValidationError{Message: "item ITEM-3 has a price in IDR but a production price in USD"}, wantStatus: http.StatusUnprocessableEntity},
+		{name: "insufficient stock", err: &model.InsufficientStockError{ItemID: "ITEM-1", Requested: 20, Available: 10}, wantStatus: http.StatusConflict},
+		{name: "other", err: errStorage, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		router := newTestRouter(rejectingPOS{MemoryPosAdapter: newTestPOS(t), err: tt.err})
+
+		recorder := serve(router, http.MethodPost, "/items/add-single-item", `{"id": "ITEM-3", "name": "Teh", "stock": 1, "price": 1000, "production_price": 500}`)
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: item status %d, want %d", tt.name, recorder.Code, tt.wantStatus)
+		}
+
+		recorder = serve(router, http.MethodPost, "/orders/add-single-item", `{"id": "ORD-2", "completed_at": "2024-03-01T10:00:00Z", "items": [{"item_id": "ITEM-1", "quantity": 1}]}`)
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: order status %d, want %d", tt.name, recorder.Code, tt.wantStatus)
+		}
+	}
+}
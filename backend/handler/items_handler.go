@@ -11,15 +11,18 @@ import (
 )
 
 type ItemSalesHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter model.POSReader
+	business   model.Business
 }
 
 type ItemSales struct {
-	ItemID       string  `json:"item_id"`
-	ItemName     string  `json:"item_name"`
-	Price        float64 `json:"price"`
-	TotalSold    int     `json:"total_sold"`
-	TotalRevenue float64 `json:"total_revenue"`
+	ItemID       string      `json:"item_id"`
+	ItemName     string      `json:"item_name"`
+	Price        model.Money `json:"price"`
+	TotalSold    int         `json:"total_sold"`
+	TotalRevenue model.Money `json:"total_revenue"`
+
+	TotalRevenueFormatted string `json:"total_revenue_formatted"`
 }
 
 type ItemSalesResponse struct {
@@ -35,11 +38,18 @@ type AllItemsResponse struct {
 	Message    string       `json:"message"`
 }
 
-func NewItemSalesHandler(posAdapter model.POSAdapter) *ItemSalesHandler {
-	return &ItemSalesHandler{posAdapter: posAdapter}
+func NewItemSalesHandler(posAdapter model.POSReader, business model.Business) *ItemSalesHandler {
+	return &ItemSalesHandler{posAdapter: posAdapter, business: business}
 }
 
 func (h *ItemSalesHandler) GetAllItems(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get query parameters for filtering and sorting
 	sortBy := c.DefaultQuery("sort_by", "name") // "name", "price", "stock", "production_price"
 	order := c.DefaultQuery("order", "asc")     // "asc" or "desc"
@@ -47,8 +57,8 @@ func (h *ItemSalesHandler) GetAllItems(c *gin.Context) {
 	minStock := c.Query("min_stock")            // Optional minimum stock filter
 	maxPrice := c.Query("max_price")            // Optional maximum price filter
 
-	// Get all items from inventory
-	items, err := h.posAdapter.GetInventory()
+	// Get the items matching the search term, or the whole inventory
+	items, err := searchItems(h.posAdapter, search)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch items"})
 		return
@@ -58,15 +68,6 @@ func (h *ItemSalesHandler) GetAllItems(c *gin.Context) {
 	var filteredItems []model.Item
 
 	for _, item := range items {
-		// Apply search filter
-		if search != "" {
-			searchTerm := strings.ToLower(search)
-			if !strings.Contains(strings.ToLower(item.Name), searchTerm) &&
-				!strings.Contains(strings.ToLower(item.ID), searchTerm) {
-				continue
-			}
-		}
-
 		// Apply minimum stock filter
 		if minStock != "" {
 			minStockInt, err := strconv.Atoi(minStock)
@@ -75,11 +76,14 @@ func (h *ItemSalesHandler) GetAllItems(c *gin.Context) {
 			}
 		}
 
-		// Apply maximum price filter
+		// Apply maximum price filter (given in the business currency)
 		if maxPrice != "" {
-			maxPriceFloat, err := strconv.ParseFloat(maxPrice, 64)
-			if err == nil && item.Price > maxPriceFloat {
-				continue
+			maxPriceMoney, err := model.ParseMoney(maxPrice, business.Currency)
+			price, convertErr := business.Convert(item.Price)
+			if err == nil && convertErr == nil {
+				if cmp, err := price.Cmp(maxPriceMoney); err == nil && cmp > 0 {
+					continue
+				}
 			}
 		}
 
@@ -99,6 +103,23 @@ func (h *ItemSalesHandler) GetAllItems(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// searchItems - Returns the items whose name or ID contains term, searched by
+// the adapter when it can, or the whole inventory for an empty term
+func searchItems(posAdapter model.POSReader, term string) ([]model.Item, error) {
+	if strings.TrimSpace(term) == "" {
+		return posAdapter.GetInventory()
+	}
+	if searcher, ok := model.As[model.ItemSearcher](posAdapter); ok {
+		return searcher.SearchItems(term, 0)
+	}
+
+	inventory, err := posAdapter.GetInventory()
+	if err != nil {
+		return nil, err
+	}
+	return model.SearchItems(inventory, term, 0), nil
+}
+
 func (h *ItemSalesHandler) sortItems(items []model.Item, sortBy, order string) {
 	n := len(items)
 	for i := 0; i < n-1; i++ {
@@ -114,9 +135,9 @@ func (h *ItemSalesHandler) sortItems(items []model.Item, sortBy, order string) {
 				}
 			case "price":
 				if order == "desc" {
-					shouldSwap = items[j].Price < items[j+1].Price
+					shouldSwap = items[j].Price.Amount < items[j+1].Price.Amount
 				} else {
-					shouldSwap = items[j].Price > items[j+1].Price
+					shouldSwap = items[j].Price.Amount > items[j+1].Price.Amount
 				}
 			case "stock":
 				if order == "desc" {
@@ -126,9 +147,9 @@ func (h *ItemSalesHandler) sortItems(items []model.Item, sortBy, order string) {
 				}
 			case "production_price":
 				if order == "desc" {
-					shouldSwap = items[j].ProductionPrice < items[j+1].ProductionPrice
+					shouldSwap = items[j].ProductionPrice.Amount < items[j+1].ProductionPrice.Amount
 				} else {
-					shouldSwap = items[j].ProductionPrice > items[j+1].ProductionPrice
+					shouldSwap = items[j].ProductionPrice.Amount > items[j+1].ProductionPrice.Amount
 				}
 			case "id":
 				if order == "desc" {
@@ -149,6 +170,13 @@ func (h *ItemSalesHandler) sortItems(items []model.Item, sortBy, order string) {
 }
 
 func (h *ItemSalesHandler) GetItemSales(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get time period parameters
 	month := c.Query("month")                         // Format: "2025-07" or "07"
 	year := c.Query("year")                           // Format: "2025"
@@ -158,7 +186,7 @@ func (h *ItemSalesHandler) GetItemSales(c *gin.Context) {
 	var startTime, endTime time.Time
 	var period string
 
-	now := time.Now()
+	now := business.Now()
 
 	if year != "" && month != "" {
 		// Specific month and year
@@ -183,8 +211,8 @@ func (h *ItemSalesHandler) GetItemSales(c *gin.Context) {
 			return
 		}
 
-		startTime = time.Date(yearInt, time.Month(monthInt), 1, 0, 0, 0, 0, time.UTC)
-		endTime = startTime.AddDate(0, 1, 0).Add(-time.Second)
+		startTime = business.StartOfDay(yearInt, time.Month(monthInt), 1)
+		endTime = startTime.AddDate(0, 1, 0)
 		period = startTime.Format("2006-01")
 
 	} else if year != "" {
@@ -195,8 +223,8 @@ func (h *ItemSalesHandler) GetItemSales(c *gin.Context) {
 			return
 		}
 
-		startTime = time.Date(yearInt, 1, 1, 0, 0, 0, 0, time.UTC)
-		endTime = startTime.AddDate(1, 0, 0).Add(-time.Second)
+		startTime = business.StartOfDay(yearInt, 1, 1)
+		endTime = startTime.AddDate(1, 0, 0)
 		period = year
 
 	} else if month != "" {
@@ -216,70 +244,32 @@ func (h *ItemSalesHandler) GetItemSales(c *gin.Context) {
 			return
 		}
 
-		startTime = time.Date(now.Year(), time.Month(monthInt), 1, 0, 0, 0, 0, time.UTC)
-		endTime = startTime.AddDate(0, 1, 0).Add(-time.Second)
+		startTime = business.StartOfDay(now.Year(), time.Month(monthInt), 1)
+		endTime = startTime.AddDate(0, 1, 0)
 		period = startTime.Format("2006-01")
 
 	} else {
 		// Default: current month
-		startTime = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
-		endTime = startTime.AddDate(0, 1, 0).Add(-time.Second)
+		startTime = business.StartOfDay(now.Year(), now.Month(), 1)
+		endTime = startTime.AddDate(0, 1, 0)
 		period = startTime.Format("2006-01")
 	}
 
-	// Get orders for the specified period
-	orders, err := h.posAdapter.GetCompletedOrders(startTime)
+	// Aggregate item sales for the specified period
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{
+		Start:   startTime,
+		End:     endTime,
+		GroupBy: model.GroupByItem,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	// Filter orders within the end time and get inventory
-	inventory, err := h.posAdapter.GetInventory()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
-		return
-	}
-
-	// Create item lookup map
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
-	}
-
-	// Calculate sales data
-	salesMap := make(map[string]*ItemSales)
+	itemSales := itemSalesFromRows(rows)
 	totalSoldOverall := 0
-
-	for _, order := range orders {
-		// Skip orders outside our time range
-		if order.CompletedAt.After(endTime) {
-			continue
-		}
-
-		for _, orderItem := range order.Items {
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				if sales, exists := salesMap[orderItem.ItemID]; exists {
-					sales.TotalSold += orderItem.Quantity
-					sales.TotalRevenue += float64(orderItem.Quantity) * item.Price
-				} else {
-					salesMap[orderItem.ItemID] = &ItemSales{
-						ItemID:       orderItem.ItemID,
-						ItemName:     item.Name,
-						Price:        item.Price,
-						TotalSold:    orderItem.Quantity,
-						TotalRevenue: float64(orderItem.Quantity) * item.Price,
-					}
-				}
-				totalSoldOverall += orderItem.Quantity
-			}
-		}
-	}
-
-	// Convert map to slice
-	var itemSales []ItemSales
-	for _, sales := range salesMap {
-		itemSales = append(itemSales, *sales)
+	for _, sales := range itemSales {
+		totalSoldOverall += sales.TotalSold
 	}
 
 	// Sort the results
@@ -312,9 +302,9 @@ func (h *ItemSalesHandler) sortItemSales(items []ItemSales, sortBy, order string
 				}
 			case "total_revenue":
 				if order == "desc" {
-					shouldSwap = items[j].TotalRevenue < items[j+1].TotalRevenue
+					shouldSwap = items[j].TotalRevenue.Amount < items[j+1].TotalRevenue.Amount
 				} else {
-					shouldSwap = items[j].TotalRevenue > items[j+1].TotalRevenue
+					shouldSwap = items[j].TotalRevenue.Amount > items[j+1].TotalRevenue.Amount
 				}
 			case "item_name":
 				if order == "desc" {
@@ -335,6 +325,13 @@ func (h *ItemSalesHandler) sortItemSales(items []ItemSales, sortBy, order string
 }
 
 func (h *ItemSalesHandler) GetTopSellingItems(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	limitStr := c.DefaultQuery("limit", "10")
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
@@ -349,54 +346,17 @@ func (h *ItemSalesHandler) GetTopSellingItems(c *gin.Context) {
 		return
 	}
 
-	since := time.Now().AddDate(0, -monthsBack, 0)
+	since := monthsAgo(business, monthsBack)
 
-	// Get orders and inventory
-	orders, err := h.posAdapter.GetCompletedOrders(since)
+	// Aggregate item sales
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: since, GroupBy: model.GroupByItem})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	inventory, err := h.posAdapter.GetInventory()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
-		return
-	}
-
-	// Create item lookup map
-	itemMap := make(map[string]model.Item)
-	for _, item := range inventory {
-		itemMap[item.ID] = item
-	}
-
-	// Calculate sales data
-	salesMap := make(map[string]*ItemSales)
-	for _, order := range orders {
-		for _, orderItem := range order.Items {
-			if item, exists := itemMap[orderItem.ItemID]; exists {
-				if sales, exists := salesMap[orderItem.ItemID]; exists {
-					sales.TotalSold += orderItem.Quantity
-					sales.TotalRevenue += float64(orderItem.Quantity) * item.Price
-				} else {
-					salesMap[orderItem.ItemID] = &ItemSales{
-						ItemID:       orderItem.ItemID,
-						ItemName:     item.Name,
-						Price:        item.Price,
-						TotalSold:    orderItem.Quantity,
-						TotalRevenue: float64(orderItem.Quantity) * item.Price,
-					}
-				}
-			}
-		}
-	}
-
-	// Convert to slice and sort by total sold (desc)
-	var itemSales []ItemSales
-	for _, sales := range salesMap {
-		itemSales = append(itemSales, *sales)
-	}
-
+	// Sort by total sold (desc)
+	itemSales := itemSalesFromRows(rows)
 	h.sortItemSales(itemSales, "total_sold", "desc")
 
 	// Limit results
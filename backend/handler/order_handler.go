@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+type OrderHandler struct {
+	posAdapter model.POSReader
+}
+
+// orderPatchFields are the order fields PATCH /orders/:id can change
+var orderPatchFields = map[string]bool{
+	"items":        true,
+	"total":        true,
+	"completed_at": true,
+}
+
+func NewOrderHandler(posAdapter model.POSReader) *OrderHandler {
+	return &OrderHandler{posAdapter: posAdapter}
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, err := h.posAdapter.GetOrderByID(c.Param("id"))
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"order": order})
+}
+
+// ReplaceOrder - PUT /orders/:id replaces the lines, total and completion
+// time of an existing order. Stock is moved by the difference.
+func (h *OrderHandler) ReplaceOrder(c *gin.Context) {
+	orders, ok := orderWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+	orderID := c.Param("id")
+
+	existing, err := h.posAdapter.GetOrderByID(orderID)
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	order := model.Order{Total: model.Money{Currency: existing.Total.Currency}}
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format: " + err.Error()})
+		return
+	}
+
+	if order.ID != "" && order.ID != orderID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order ID in the body does not match the URL"})
+		return
+	}
+	order.ID = orderID
+
+	// Keep the original completion time if none was sent
+	if order.CompletedAt.IsZero() {
+		order.CompletedAt = existing.CompletedAt
+	}
+
+	h.saveOrder(c, orders, order)
+}
+
+// PatchOrder - PATCH /orders/:id updates the fields named in the field mask.
+// When the lines change but the total isn't part of the mask, the total is
+// recalculated from the lines.
+func (h *OrderHandler) PatchOrder(c *gin.Context) {
+	orders, ok := orderWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	order, err := h.posAdapter.GetOrderByID(c.Param("id"))
+	if err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	patch, err := parsePatchRequest(c, orderPatchFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, field := range patch.mask {
+		switch field {
+		case "items":
+			order.Items, err = patch.decodeOrderItems(field, order.Total.Currency)
+		case "total":
+			order.Total = model.Money{Currency: order.Total.Currency}
+			err = patch.decode(field, &order.Total)
+		case "completed_at":
+			order.CompletedAt = time.Time{}
+			err = patch.decode(field, &order.CompletedAt)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if patch.has("items") && !patch.has("total") {
+		order.Total = model.Money{}
+	}
+
+	h.saveOrder(c, orders, *order)
+}
+
+// saveOrder - Prices, validates and writes an updated order
+func (h *OrderHandler) saveOrder(c *gin.Context, orders model.OrderWriter, order model.Order) {
+	inventory, err := h.posAdapter.GetInventory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+		return
+	}
+
+	if err := fillOrderPrices(&order, inventory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := validateOrder(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Validation error: " + err.Error()})
+		return
+	}
+
+	if err := orders.AddOrder(order); err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": "Failed to update order: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Order updated successfully",
+		"order":   order,
+	})
+}
+
+// DeleteOrder - Deletes an order and puts its lines back into stock
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
+	orders, ok := orderWriter(c, h.posAdapter)
+	if !ok {
+		return
+	}
+
+	if err := orders.DeleteOrder(c.Param("id")); err != nil {
+		c.JSON(adapterErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+func getTestOrder(t *testing.T, pos model.POSReader, orderID string) model.Order {
+	t.Helper()
+	order, err := pos.GetOrderByID(orderID)
+	if err != nil {
+		t.Fatalf("GetOrderByID(%s): %v", orderID, err)
+	}
+	return *order
+}
+
+// orderLines - The item IDs and quantities of an order's lines
+func orderLines(order model.Order) map[string]int {
+	lines := make(map[string]int)
+	for _, line := range order.Items {
+		lines[line.ItemID] += line.Quantity
+	}
+	return lines
+}
+
+func TestOrderRoutes(t *testing.T) {
+	type stock struct{ item1, item2 int }
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		wantStatus    int
+		wantLines     map[string]int // of ORD-1 afterwards, nil if unchanged
+		wantTotal     string
+		wantCompleted time.Time
+		wantStock     stock
+	}{
+		{
+			name:          "replace",
+			method:        http.MethodPut,
+			path:          "/orders/ORD-1",
+			body:          `{"items": [{"item_id": "ITEM-1", "quantity": 3}, {"item_id": "ITEM-2", "quantity": 1}]}`,
+			wantStatus:    http.StatusOK,
+			wantLines:     map[string]int{"ITEM-1": 3, "ITEM-2": 1},
+			wantTotal:     "8500",
+			wantCompleted: testTime,
+			wantStock:     stock{7, 4},
+		},
+		{
+			name:       "replace with another ID in the body",
+			method:     http.MethodPut,
+			path:       "/orders/ORD-1",
+			body:       `{"id": "ORD-2", "items": [{"item_id": "ITEM-1", "quantity": 3}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantStock:  stock{8, 5},
+		},
+		{
+			name:       "replace with more than in stock",
+			method:     http.MethodPut,
+			path:       "/orders/ORD-1",
+			body:       `{"items": [{"item_id": "ITEM-2", "quantity": 6}]}`,
+			wantStatus: http.StatusConflict,
+			wantStock:  stock{8, 5},
+		},
+		{
+			name:       "replace a missing order",
+			method:     http.MethodPut,
+			path:       "/orders/ORD-9",
+			body:       `{"items": [{"item_id": "ITEM-1", "quantity": 1}]}`,
+			wantStatus: http.StatusNotFound,
+			wantStock:  stock{8, 5},
+		},
+		{
+			name:          "patch the lines recalculates the total",
+			method:        http.MethodPatch,
+			path:          "/orders/ORD-1",
+			body:          `{"items": [{"item_id": "ITEM-2", "quantity": 2}]}`,
+			wantStatus:    http.StatusOK,
+			wantLines:     map[string]int{"ITEM-2": 2},
+			wantTotal:     "8000",
+			wantCompleted: testTime,
+			wantStock:     stock{10, 3},
+		},
+		{
+			name:          "patch the lines and total",
+			method:        http.MethodPatch,
+			path:          "/orders/ORD-1",
+			body:          `{"items": [{"item_id": "ITEM-1", "quantity": 1}], "total": 1000}`,
+			wantStatus:    http.StatusOK,
+			wantLines:     map[string]int{"ITEM-1": 1},
+			wantTotal:     "1000",
+			wantCompleted: testTime,
+			wantStock:     stock{9, 5},
+		},
+		{
+			name:          "patch the completion time keeps the lines",
+			method:        http.MethodPatch,
+			path:          "/orders/ORD-1?update_mask=completed_at",
+			body:          `{"completed_at": "2024-03-02T09:00:00Z", "total": 1}`,
+			wantStatus:    http.StatusOK,
+			wantLines:     map[string]int{"ITEM-1": 2},
+			wantTotal:     "3000",
+			wantCompleted: testTime.AddDate(0, 0, 1),
+			wantStock:     stock{8, 5},
+		},
+		{
+			name:       "patch a field that can't change",
+			method:     http.MethodPatch,
+			path:       "/orders/ORD-1?update_mask=id",
+			body:       `{"id": "ORD-2"}`,
+			wantStatus: http.StatusBadRequest,
+			wantStock:  stock{8, 5},
+		},
+		{
+			name:       "delete puts the stock back",
+			method:     http.MethodDelete,
+			path:       "/orders/ORD-1",
+			wantStatus: http.StatusOK,
+			wantStock:  stock{10, 5},
+		},
+		{
+			name:       "delete a missing order",
+			method:     http.MethodDelete,
+			path:       "/orders/ORD-9",
+			wantStatus: http.StatusNotFound,
+			wantStock:  stock{8, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		pos := newTestPOS(t)
+		recorder := serve(newTestRouter(pos), tt.method, tt.path, tt.body)
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, recorder.Code, tt.wantStatus, recorder.Body)
+			continue
+		}
+
+		got := stock{getTestItem(t, pos, "ITEM-1").Stock, getTestItem(t, pos, "ITEM-2").Stock}
+		if got != tt.wantStock {
+			t.Errorf("%s: stock = %+v, want %+v", tt.name, got, tt.wantStock)
+		}
+		if tt.wantLines == nil {
+			continue
+		}
+		order := getTestOrder(t, pos, "ORD-1")
+		if lines := orderLines(order); !reflect.DeepEqual(lines, tt.wantLines) {
+			t.Errorf("%s: lines = %v, want %v", tt.name, lines, tt.wantLines)
+		}
+		if want := model.MustParseMoney(tt.wantTotal, "IDR"); order.Total != want {
+			t.Errorf("%s: total = %v, want %v", tt.name, order.Total, want)
+		}
+		if !order.CompletedAt.Equal(tt.wantCompleted) {
+			t.Errorf("%s: completed at %v, want %v", tt.name, order.CompletedAt, tt.wantCompleted)
+		}
+	}
+}
+
+func TestOrderWritesNeedAWriter(t *testing.T) {
+	router := newTestRouter(adapter.ReadOnly(newTestPOS(t)))
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		recorder := serve(router, method, "/orders/ORD-1", `{"items": [{"item_id": "ITEM-1", "quantity": 1}]}`)
+		if recorder.Code != http.StatusNotImplemented {
+			t.Errorf("%s: status %d, want 501", method, recorder.Code)
+		}
+	}
+}
@@ -1,17 +1,17 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/gin-gonic/gin"
 )
 
 type OrdersHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter model.POSReader
+	business   model.Business
 }
 
 type OrdersResponse struct {
@@ -23,140 +23,127 @@ type OrdersResponse struct {
 type AllOrdersResponse struct {
 	Orders      []model.Order `json:"orders"`
 	TotalOrders int           `json:"total_orders"`
+	NextCursor  string        `json:"next_cursor,omitempty"`
 	Message     string        `json:"message"`
 }
 
-func NewOrdersHandler(posAdapter model.POSAdapter) *OrdersHandler {
-	return &OrdersHandler{posAdapter: posAdapter}
+func NewOrdersHandler(posAdapter model.POSReader, business model.Business) *OrdersHandler {
+	return &OrdersHandler{posAdapter: posAdapter, business: business}
 }
 
 func (h *OrdersHandler) GetAllOrders(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get query parameters for filtering and sorting
 	sortBy := c.DefaultQuery("sort_by", "completed_at") // "completed_at", "total", "id"
 	order := c.DefaultQuery("order", "desc")            // "asc" or "desc"
-	limit := c.Query("limit")                           // Optional limit
+	limit := c.Query("limit")                           // Optional page size
+	cursor := c.Query("cursor")                         // Optional cursor from a previous page
 	startDate := c.Query("start_date")                  // Optional start date filter (YYYY-MM-DD)
 	endDate := c.Query("end_date")                      // Optional end date filter (YYYY-MM-DD)
 	minTotal := c.Query("min_total")                    // Optional minimum total filter
 	maxTotal := c.Query("max_total")                    // Optional maximum total filter
+	itemID := c.Query("item_id")                        // Optional filter on orders containing an item
+
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order parameter (asc or desc)"})
+		return
+	}
+
+	query := model.OrderQuery{
+		SortBy:     model.OrderSortField(sortBy),
+		Descending: order == "desc",
+		ItemID:     itemID,
+		Cursor:     cursor,
+	}
+	if _, err := query.Normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set default time range (last 12 months if no dates provided)
-	var since time.Time
 	if startDate != "" {
-		var err error
-		since, err = time.Parse("2006-01-02", startDate)
+		since, err := business.ParseDate(startDate)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format (YYYY-MM-DD)"})
 			return
 		}
+		query.Start = since
 	} else {
-		since = time.Now().AddDate(-1, 0, 0) // Default to 1 year ago
-	}
-
-	// Get completed orders
-	orders, err := h.posAdapter.GetCompletedOrders(since)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
-		return
+		query.Start = monthsAgo(business, 12) // Default to 1 year ago
 	}
 
-	// Apply filters
-	var filteredOrders []model.Order
-	var endTime time.Time
-
 	if endDate != "" {
-		endTime, err = time.Parse("2006-01-02", endDate)
+		endTime, err := business.ParseDate(endDate)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format (YYYY-MM-DD)"})
 			return
 		}
-		endTime = endTime.Add(24*time.Hour - time.Second) // End of day
+		query.End = endTime.AddDate(0, 0, 1) // Exclusive end of day
 	}
 
-	for _, order := range orders {
-		// Apply end date filter
-		if endDate != "" && order.CompletedAt.After(endTime) {
-			continue
-		}
-
-		// Apply minimum total filter
-		if minTotal != "" {
-			minTotalFloat, err := strconv.ParseFloat(minTotal, 64)
-			if err == nil && order.Total < minTotalFloat {
-				continue
-			}
+	if minTotal != "" {
+		minTotalMoney, err := model.ParseMoney(minTotal, business.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_total parameter"})
+			return
 		}
+		query.MinTotal = &minTotalMoney
+	}
 
-		// Apply maximum total filter
-		if maxTotal != "" {
-			maxTotalFloat, err := strconv.ParseFloat(maxTotal, 64)
-			if err == nil && order.Total > maxTotalFloat {
-				continue
-			}
+	if maxTotal != "" {
+		maxTotalMoney, err := model.ParseMoney(maxTotal, business.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_total parameter"})
+			return
 		}
-
-		filteredOrders = append(filteredOrders, order)
+		query.MaxTotal = &maxTotalMoney
 	}
 
-	// Sort the orders
-	h.sortOrders(filteredOrders, sortBy, order)
-
-	// Apply limit if specified
 	if limit != "" {
 		limitInt, err := strconv.Atoi(limit)
-		if err == nil && limitInt > 0 && limitInt < len(filteredOrders) {
-			filteredOrders = filteredOrders[:limitInt]
+		if err != nil || limitInt < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+			return
+		}
+		query.Limit = limitInt
+	}
+
+	// Get the requested page of orders
+	page, err := h.posAdapter.QueryOrders(query)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor parameter"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+		return
 	}
 
 	// Prepare response
 	response := AllOrdersResponse{
-		Orders:      filteredOrders,
-		TotalOrders: len(filteredOrders),
+		Orders:      page.Orders,
+		TotalOrders: len(page.Orders),
+		NextCursor:  page.NextCursor,
 		Message:     "Orders retrieved successfully",
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-func (h *OrdersHandler) sortOrders(orders []model.Order, sortBy, order string) {
-	n := len(orders)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			var shouldSwap bool
-
-			switch sortBy {
-			case "completed_at":
-				if order == "desc" {
-					shouldSwap = orders[j].CompletedAt.Before(orders[j+1].CompletedAt)
-				} else {
-					shouldSwap = orders[j].CompletedAt.After(orders[j+1].CompletedAt)
-				}
-			case "total":
-				if order == "desc" {
-					shouldSwap = orders[j].Total < orders[j+1].Total
-				} else {
-					shouldSwap = orders[j].Total > orders[j+1].Total
-				}
-			case "id":
-				if order == "desc" {
-					shouldSwap = strings.Compare(orders[j].ID, orders[j+1].ID) < 0
-				} else {
-					shouldSwap = strings.Compare(orders[j].ID, orders[j+1].ID) > 0
-				}
-			default:
-				// Default to completed_at descending (newest first)
-				shouldSwap = orders[j].CompletedAt.Before(orders[j+1].CompletedAt)
-			}
-
-			if shouldSwap {
-				orders[j], orders[j+1] = orders[j+1], orders[j]
-			}
-		}
+func (h *OrdersHandler) GetTotalOrders(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-}
 
-func (h *OrdersHandler) GetTotalOrders(c *gin.Context) {
 	// Get query parameter for months back (default 12 months)
 	monthsBackStr := c.DefaultQuery("months", "12")
 	monthsBack, err := strconv.Atoi(monthsBackStr)
@@ -166,23 +153,22 @@ func (h *OrdersHandler) GetTotalOrders(c *gin.Context) {
 	}
 
 	// Calculate since date
-	since := time.Now().AddDate(0, -monthsBack, 0)
+	since := monthsAgo(business, monthsBack)
 
-	// Get completed orders
-	orders, err := h.posAdapter.GetCompletedOrders(since)
+	// Aggregate order counts by month
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: since, GroupBy: model.GroupByMonth})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
 	// Calculate total orders and monthly breakdown
-	totalOrders := len(orders)
+	totalOrders := 0
 	monthlyOrders := make(map[string]int)
 
-	for _, order := range orders {
-		// Group by month-year
-		monthKey := order.CompletedAt.Format("2006-01")
-		monthlyOrders[monthKey]++
+	for _, row := range rows {
+		totalOrders += row.Orders
+		monthlyOrders[row.Key] = row.Orders
 	}
 
 	// Check if client wants detailed orders
@@ -194,6 +180,11 @@ func (h *OrdersHandler) GetTotalOrders(c *gin.Context) {
 	}
 
 	if includeOrders {
+		orders, err := h.posAdapter.GetCompletedOrders(since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			return
+		}
 		response.Orders = orders
 	}
 
@@ -201,6 +192,13 @@ func (h *OrdersHandler) GetTotalOrders(c *gin.Context) {
 }
 
 func (h *OrdersHandler) GetOrdersByDateRange(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 
@@ -209,37 +207,37 @@ func (h *OrdersHandler) GetOrdersByDateRange(c *gin.Context) {
 		return
 	}
 
-	start, err := time.Parse("2006-01-02", startDate)
+	start, err := business.ParseDate(startDate)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format (YYYY-MM-DD)"})
 		return
 	}
 
-	end, err := time.Parse("2006-01-02", endDate)
+	end, err := business.ParseDate(endDate)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format (YYYY-MM-DD)"})
 		return
 	}
 
 	// Get orders in date range
-	orders, err := h.posAdapter.GetCompletedOrders(start)
+	page, err := h.posAdapter.QueryOrders(model.OrderQuery{
+		Start:      start,
+		End:        end.AddDate(0, 0, 1),
+		SortBy:     model.SortByCompletedAt,
+		Descending: true,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	// Filter orders within the end date
-	var filteredOrders []model.Order
+	// Group by date
+	filteredOrders := page.Orders
 	dailyOrders := make(map[string]int)
 
-	for _, order := range orders {
-		if order.CompletedAt.Before(end.Add(24 * time.Hour)) {
-			filteredOrders = append(filteredOrders, order)
-
-			// Group by date
-			dateKey := order.CompletedAt.Format("2006-01-02")
-			dailyOrders[dateKey]++
-		}
+	for _, order := range filteredOrders {
+		dateKey := order.CompletedAt.In(business.Zone()).Format("2006-01-02")
+		dailyOrders[dateKey]++
 	}
 
 	response := map[string]interface{}{
@@ -252,6 +250,13 @@ func (h *OrdersHandler) GetOrdersByDateRange(c *gin.Context) {
 }
 
 func (h *OrdersHandler) GetOrdersStats(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	monthsBackStr := c.DefaultQuery("months", "1")
 	monthsBack, err := strconv.Atoi(monthsBackStr)
 	if err != nil {
@@ -259,16 +264,30 @@ func (h *OrdersHandler) GetOrdersStats(c *gin.Context) {
 		return
 	}
 
-	since := time.Now().AddDate(0, -monthsBack, 0)
+	since := monthsAgo(business, monthsBack)
 
-	// Get completed orders
-	orders, err := h.posAdapter.GetCompletedOrders(since)
+	// Aggregate order statistics
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: since, GroupBy: model.GroupByMonth})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
-	if len(orders) == 0 {
+	// Calculate statistics
+	totalOrders := 0
+	totalItemsSold := 0
+
+	for _, row := range rows {
+		totalOrders += row.Orders
+		totalItemsSold += row.Units
+	}
+	totalRevenue, _, err := sumSales(business, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
+		return
+	}
+
+	if totalOrders == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"total_orders":        0,
 			"average_order_value": 0,
@@ -277,28 +296,19 @@ func (h *OrdersHandler) GetOrdersStats(c *gin.Context) {
 		return
 	}
 
-	// Calculate statistics
-	totalOrders := len(orders)
-	totalRevenue := 0.0
-	totalItemsSold := 0
-
-	for _, order := range orders {
-		totalRevenue += order.Total
-		for _, item := range order.Items {
-			totalItemsSold += item.Quantity
-		}
-	}
-
-	averageOrderValue := totalRevenue / float64(totalOrders)
+	averageOrderValue := totalRevenue.Div(int64(totalOrders))
 
 	response := map[string]interface{}{
-		"total_orders":        totalOrders,
-		"total_revenue":       totalRevenue,
-		"average_order_value": averageOrderValue,
-		"total_items_sold":    totalItemsSold,
-		"period_start":        since,
-		"period_end":          time.Now(),
+		"total_orders":                  totalOrders,
+		"currency":                      business.Currency,
+		"total_revenue":                 totalRevenue,
+		"total_revenue_formatted":       totalRevenue.Format(),
+		"average_order_value":           averageOrderValue,
+		"average_order_value_formatted": averageOrderValue.Format(),
+		"total_items_sold":              totalItemsSold,
+		"period_start":                  since,
+		"period_end":                    business.Now(),
 	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
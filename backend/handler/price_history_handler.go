@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+type PriceHistoryHandler struct {
+	posAdapter model.POSReader
+	business   model.Business
+}
+
+type PriceHistoryResponse struct {
+	ItemID string            `json:"item_id"`
+	Prices []model.ItemPrice `json:"prices"`
+	AsOf   *model.ItemPrice  `json:"as_of,omitempty"`
+}
+
+func NewPriceHistoryHandler(posAdapter model.POSReader, business model.Business) *PriceHistoryHandler {
+	return &PriceHistoryHandler{posAdapter: posAdapter, business: business}
+}
+
+func (h *PriceHistoryHandler) GetPriceHistory(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	itemID := c.Param("id")
+	asOf := c.Query("as_of") // Optional date (YYYY-MM-DD) or timestamp (RFC 3339)
+
+	history, ok := model.As[model.PriceHistory](h.posAdapter)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Price history is not supported by this POS adapter"})
+		return
+	}
+
+	prices, err := history.GetItemPriceHistory(itemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+
+	if len(prices) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No price history found for item"})
+		return
+	}
+
+	response := PriceHistoryResponse{
+		ItemID: itemID,
+		Prices: prices,
+	}
+
+	if asOf != "" {
+		at, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			date, dateErr := business.ParseDate(asOf)
+			if dateErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid as_of format (YYYY-MM-DD or RFC 3339)"})
+				return
+			}
+			at = date.AddDate(0, 0, 1).Add(-time.Nanosecond) // End of day
+		}
+
+		response.AsOf, err = history.GetItemPriceAsOf(itemID, at)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
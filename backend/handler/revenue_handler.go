@@ -3,27 +3,36 @@ package handler
 import (
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/YudaClairee/garudahacks/model"
 	"github.com/gin-gonic/gin"
 )
 
 type RevenueHandler struct {
-	posAdapter model.POSAdapter
+	posAdapter model.POSReader
+	business   model.Business
 }
 
 type RevenueResponse struct {
-	TotalRevenue    float64            `json:"total_revenue"`
-	MonthlyRevenues map[string]float64 `json:"monthly_revenues"`
-	Orders          []model.Order      `json:"orders,omitempty"`
+	Currency              string                 `json:"currency"`
+	TotalRevenue          model.Money            `json:"total_revenue"`
+	TotalRevenueFormatted string                 `json:"total_revenue_formatted"`
+	MonthlyRevenues       map[string]model.Money `json:"monthly_revenues"`
+	Orders                []model.Order          `json:"orders,omitempty"`
 }
 
-func NewRevenueHandler(posAdapter model.POSAdapter) *RevenueHandler {
-	return &RevenueHandler{posAdapter: posAdapter}
+func NewRevenueHandler(posAdapter model.POSReader, business model.Business) *RevenueHandler {
+	return &RevenueHandler{posAdapter: posAdapter, business: business}
 }
 
 func (h *RevenueHandler) GetTotalRevenue(c *gin.Context) {
+	// Business settings for this request (?tz= overrides the timezone)
+	business, err := requestBusiness(c, h.business)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get query parameter for months back (default 12 months)
 	monthsBackStr := c.DefaultQuery("months", "12")
 	monthsBack, err := strconv.Atoi(monthsBackStr)
@@ -33,36 +42,42 @@ func (h *RevenueHandler) GetTotalRevenue(c *gin.Context) {
 	}
 
 	// Calculate since date
-	since := time.Now().AddDate(0, -monthsBack, 0)
+	since := monthsAgo(business, monthsBack)
 
-	// Get completed orders
-	orders, err := h.posAdapter.GetCompletedOrders(since)
+	// Aggregate revenue by month
+	rows, err := aggregateSales(h.posAdapter, business, model.AggregateQuery{Start: since, GroupBy: model.GroupByMonth})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
 	}
 
 	// Calculate total revenue and monthly breakdown
-	totalRevenue := 0.0
-	monthlyRevenues := make(map[string]float64)
-
-	for _, order := range orders {
-		totalRevenue += order.Total
-
-		// Group by month-year
-		monthKey := order.CompletedAt.Format("2006-01")
-		monthlyRevenues[monthKey] += order.Total
+	monthlyRevenues := make(map[string]model.Money)
+	for _, row := range rows {
+		monthlyRevenues[row.Key] = row.Revenue
+	}
+	totalRevenue, _, err := sumSales(business, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to total sales: " + err.Error()})
+		return
 	}
 
 	// Check if client wants detailed orders
 	includeOrders := c.DefaultQuery("include_orders", "false") == "true"
 
 	response := RevenueResponse{
-		TotalRevenue:    totalRevenue,
-		MonthlyRevenues: monthlyRevenues,
+		Currency:              business.Currency,
+		TotalRevenue:          totalRevenue,
+		TotalRevenueFormatted: totalRevenue.Format(),
+		MonthlyRevenues:       monthlyRevenues,
 	}
 
 	if includeOrders {
+		orders, err := h.posAdapter.GetCompletedOrders(since)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			return
+		}
 		response.Orders = orders
 	}
 
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/gin-gonic/gin"
+)
+
+type StockHandler struct {
+	posAdapter model.POSReader
+}
+
+type StockMovementsResponse struct {
+	ItemID       string                `json:"item_id"`
+	CurrentStock int                   `json:"current_stock"`
+	LedgerStock  int                   `json:"ledger_stock"`
+	Movements    []model.StockMovement `json:"movements"`
+}
+
+func NewStockHandler(posAdapter model.POSReader) *StockHandler {
+	return &StockHandler{posAdapter: posAdapter}
+}
+
+func (h *StockHandler) GetStockMovements(c *gin.Context) {
+	itemID := c.Param("id")
+
+	ledger, ok := model.As[model.StockLedger](h.posAdapter)
+	if !ok {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Stock movements are not supported by this POS adapter"})
+		return
+	}
+
+	// Find the item's current stock
+	inventory, err := h.posAdapter.GetInventory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory"})
+		return
+	}
+
+	var item *model.Item
+	for i := range inventory {
+		if inventory[i].ID == itemID {
+			item = &inventory[i]
+			break
+		}
+	}
+
+	if item == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	movements, err := ledger.GetStockMovements(itemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+
+	ledgerStock, err := ledger.GetLedgerStock(itemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock movements"})
+		return
+	}
+
+	if movements == nil {
+		movements = []model.StockMovement{}
+	}
+
+	c.JSON(http.StatusOK, StockMovementsResponse{
+		ItemID:       itemID,
+		CurrentStock: item.Stock,
+		LedgerStock:  ledgerStock,
+		Movements:    movements,
+	})
+}
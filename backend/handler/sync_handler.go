@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/YudaClairee/garudahacks/syncer"
+	"github.com/gin-gonic/gin"
+)
+
+type SyncHandler struct {
+	engines []*syncer.Engine
+}
+
+type SyncStatusResponse struct {
+	Enabled bool            `json:"enabled"`
+	Sources []syncer.Status `json:"sources"`
+}
+
+// NewSyncHandler - Reports on the given engines; none means sync is disabled
+func NewSyncHandler(engines ...*syncer.Engine) *SyncHandler {
+	return &SyncHandler{engines: engines}
+}
+
+// GetSyncStatus - Returns the last run, lag, checkpoint and recent conflicts
+// of every sync source
+func (h *SyncHandler) GetSyncStatus(c *gin.Context) {
+	response := SyncStatusResponse{
+		Enabled: len(h.engines) > 0,
+		Sources: make([]syncer.Status, 0, len(h.engines)),
+	}
+
+	for _, engine := range h.engines {
+		status, err := engine.Status()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sync status for " + engine.Source()})
+			return
+		}
+		response.Sources = append(response.Sources, status)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/YudaClairee/garudahacks/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody is the largest webhook payload accepted
+const maxWebhookBody = 1 << 20
+
+type WebhookHandler struct {
+	posAdapter model.POSReader
+	store      webhook.EventStore
+	processor  *webhook.Processor
+}
+
+// errReadOnly is returned for an event the POS adapter can't write
+var errReadOnly = errors.New("not supported by this POS adapter")
+
+// invalidEventError marks an event whose order or item failed validation.
+// It is stored as failed like any other error but reported as 422.
+type invalidEventError struct {
+	err error
+}
+
+func (e *invalidEventError) Error() string {
+	return "Validation error: " + e.err.Error()
+}
+
+func (e *invalidEventError) Unwrap() error {
+	return e.err
+}
+
+// NewWebhookHandler - Applies signed POS events to posAdapter, recording them
+// in store so replays are ignored and failures can be retried
+func NewWebhookHandler(posAdapter model.POSReader, store webhook.EventStore, config webhook.Config) *WebhookHandler {
+	h := &WebhookHandler{posAdapter: posAdapter, store: store}
+	h.processor = webhook.NewProcessor(store, config, h.applyEvent)
+	return h
+}
+
+// StartRetries - Retries failed events in the background every interval
+func (h *WebhookHandler) StartRetries(ctx context.Context, interval time.Duration) {
+	h.processor.Start(ctx, interval)
+}
+
+// ReceivePOSWebhook - Verifies, dedupes and applies one event pushed by a
+// POS provider
+func (h *WebhookHandler) ReceivePOSWebhook(c *gin.Context) {
+	provider := c.Param("provider")
+
+	capabilities := model.CapabilitiesOf(h.posAdapter)
+	if !capabilities.InventoryWriter && !capabilities.OrderWriter {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Webhooks need a POS adapter that can be written to"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Payload exceeds %d bytes", maxWebhookBody)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body: " + err.Error()})
+		return
+	}
+
+	outcome, event, err := h.processor.Handle(provider, c.Request.Header, body)
+	h.respond(c, provider, outcome, event, err)
+}
+
+// RetryEvent - Applies a stored event again, e.g. after fixing the item it
+// referenced
+func (h *WebhookHandler) RetryEvent(c *gin.Context) {
+	provider := c.Param("provider")
+	outcome, event, err := h.processor.Retry(provider, c.Param("event_id"))
+	h.respond(c, provider, outcome, event, err)
+}
+
+// ListEvents - Returns the stored events of a provider, optionally filtered
+// by status
+func (h *WebhookHandler) ListEvents(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", webhook.StatusProcessing, webhook.StatusProcessed, webhook.StatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Use processing, processed or failed"})
+		return
+	}
+
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.store.List(c.Param("provider"), status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook events"})
+		return
+	}
+	if events == nil {
+		events = []webhook.Record{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events, "count": len(events)})
+}
+
+// respond - Writes the result of handling or retrying an event
+func (h *WebhookHandler) respond(c *gin.Context, provider, outcome string, event *webhook.Event, err error) {
+	if err != nil {
+		var decodeErr *webhook.DecodeError
+		var invalidErr *invalidEventError
+		var stockErr *model.InsufficientStockError
+
+		switch {
+		case errors.Is(err, webhook.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown webhook provider: " + provider})
+		case errors.Is(err, webhook.ErrInvalidSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		case errors.Is(err, webhook.ErrEventNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook event not found"})
+		case errors.As(err, &decodeErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "event_id": decodeErr.EventID})
+		case errors.Is(err, errReadOnly):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error(), "event_id": event.ID})
+		case errors.As(err, &invalidErr):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "event_id": event.ID})
+		case errors.As(err, &stockErr):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "Insufficient stock: " + stockErr.Error(),
+				"event_id":  event.ID,
+				"item_id":   stockErr.ItemID,
+				"requested": stockErr.Requested,
+				"available": stockErr.Available,
+			})
+		case event != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply webhook event: " + err.Error(), "event_id": event.ID})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record webhook event"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   outcome,
+		"event_id": event.ID,
+		"type":     event.Type,
+	})
+}
+
+// applyEvent - Validates the order or item of an event the way the add
+// endpoints do and writes it. Orders and items are upserted, so applying an
+// event twice leaves the same state.
+func (h *WebhookHandler) applyEvent(event webhook.Event) error {
+	switch {
+	case event.Order != nil:
+		orders, ok := model.As[model.OrderWriter](h.posAdapter)
+		if !ok {
+			return fmt.Errorf("writing orders is %w", errReadOnly)
+		}
+		order := *event.Order
+
+		inventory, err := h.posAdapter.GetInventory()
+		if err != nil {
+			return fmt.Errorf("failed to fetch inventory: %w", err)
+		}
+		if err := fillOrderPrices(&order, inventory); err != nil {
+			return &invalidEventError{err: err}
+		}
+		if err := validateOrder(&order); err != nil {
+			return &invalidEventError{err: err}
+		}
+		return orders.AddOrder(order)
+
+	case event.Item != nil:
+		items, ok := model.As[model.InventoryWriter](h.posAdapter)
+		if !ok {
+			return fmt.Errorf("writing items is %w", errReadOnly)
+		}
+		item := *event.Item
+		if err := validateItem(&item); err != nil {
+			return &invalidEventError{err: err}
+		}
+		return items.AddItem(item)
+
+	default:
+		return &invalidEventError{err: fmt.Errorf("event %s carries neither an order nor an item", event.ID)}
+	}
+}
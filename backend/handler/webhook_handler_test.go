@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/YudaClairee/garudahacks/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// newWebhookRouter - The webhook routes of main.go for provider "pos",
+// applying events to posAdapter
+func newWebhookRouter(posAdapter model.POSReader) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	webhookHandler := NewWebhookHandler(posAdapter, webhook.NewMemoryEventStore(), webhook.Config{
+		Secrets:  map[string]string{"pos": testWebhookSecret},
+		Currency: "IDR",
+	})
+	router.POST("/webhooks/pos/:provider", webhookHandler.ReceivePOSWebhook)
+	router.POST("/webhooks/pos/:provider/events/:event_id/retry", webhookHandler.RetryEvent)
+	return router
+}
+
+// deliver - Posts body to provider's webhook, signed with secret
+func deliver(router *gin.Engine, provider, secret, body string) *httptest.ResponseRecorder {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	request := httptest.NewRequest(http.MethodPost, "/webhooks/pos/"+provider, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func orderEvent(eventID, data string) string {
+	return fmt.Sprintf(`{"id": %q, "type": "order.completed", "created_at": "2024-03-01T10:00:00Z", "data": %s}`, eventID, data)
+}
+
+func TestReceivePOSWebhookStatuses(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		secret     string
+		body       string
+		wantStatus int
+		wantError  string // substring of the error, empty on success
+		wantStock  int    // of ITEM-2 afterwards
+	}{
+		{
+			name:       "order",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 2}]}`),
+			wantStatus: http.StatusOK,
+			wantStock:  3,
+		},
+		{
+			name:       "item",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       `{"id": "evt_1", "type": "item.changed", "data": {"id": "ITEM-2", "name": "Roti Bakar", "stock": 9, "price": 4000, "production_price": 2500}}`,
+			wantStatus: http.StatusOK,
+			wantStock:  9,
+		},
+		{
+			name:       "unknown provider",
+			provider:   "other",
+			secret:     testWebhookSecret,
+			body:       orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 2}]}`),
+			wantStatus: http.StatusNotFound,
+			wantError:  "Unknown webhook provider",
+			wantStock:  5,
+		},
+		{
+			name:       "wrong signature",
+			provider:   "pos",
+			secret:     "whsec_other",
+			body:       orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 2}]}`),
+			wantStatus: http.StatusUnauthorized,
+			wantError:  "Invalid signature",
+			wantStock:  5,
+		},
+		{
+			name:       "undecodable payload",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       `{"id": "evt_1", "type": "order.refunded", "data": {}}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "decode",
+			wantStock:  5,
+		},
+		{
+			name:       "invalid item",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       `{"id": "evt_1", "type": "item.changed", "data": {"id": "ITEM-2", "name": "", "stock": 9, "price": 4000}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantError:  "name cannot be empty",
+			wantStock:  5,
+		},
+		{
+			name:       "more than in stock",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 6}]}`),
+			wantStatus: http.StatusConflict,
+			wantError:  "Insufficient stock",
+			wantStock:  5,
+		},
+		{
+			name:       "too large",
+			provider:   "pos",
+			secret:     testWebhookSecret,
+			body:       orderEvent("evt_1", `{"id": "ORD-2", "note": "`+strings.Repeat("x", maxWebhookBody)+`"}`),
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantError:  "exceeds",
+			wantStock:  5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos := newTestPOS(t)
+			recorder := deliver(newWebhookRouter(pos), tt.provider, tt.secret, tt.body)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+
+			var response struct {
+				Status string `json:"status"`
+				Error  string `json:"error"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decoding %s: %v", recorder.Body, err)
+			}
+			if tt.wantError == "" && response.Status != webhook.OutcomeProcessed {
+				t.Errorf("status = %q, want %q", response.Status, webhook.OutcomeProcessed)
+			}
+			if !strings.Contains(response.Error, tt.wantError) {
+				t.Errorf("error = %q, want it to mention %q", response.Error, tt.wantError)
+			}
+			if item := getTestItem(t, pos, "ITEM-2"); item.Stock != tt.wantStock {
+				t.Errorf("ITEM-2 stock = %d, want %d", item.Stock, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestReceivePOSWebhookReplay(t *testing.T) {
+	pos := newTestPOS(t)
+	router := newWebhookRouter(pos)
+	body := orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 2}]}`)
+
+	for i, want := range []string{webhook.OutcomeProcessed, webhook.OutcomeDuplicate} {
+		recorder := deliver(router, "pos", testWebhookSecret, body)
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"status":"`+want+`"`) {
+			t.Errorf("delivery %d = %d %s, want 200 %s", i+1, recorder.Code, recorder.Body, want)
+		}
+	}
+	if item := getTestItem(t, pos, "ITEM-2"); item.Stock != 3 {
+		t.Errorf("ITEM-2 stock = %d, want the order applied once", item.Stock)
+	}
+}
+
+func TestRetryEventAfterFixingItem(t *testing.T) {
+	pos := newTestPOS(t)
+	router := newWebhookRouter(pos)
+
+	// ITEM-3 isn't in the inventory yet, so the order has no price
+	body := orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-3", "quantity": 1}]}`)
+	if recorder := deliver(router, "pos", testWebhookSecret, body); recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("delivery = %d %s, want 422", recorder.Code, recorder.Body)
+	}
+
+	if recorder := serve(router, http.MethodPost, "/webhooks/pos/pos/events/evt_9/retry", ""); recorder.Code != http.StatusNotFound {
+		t.Errorf("retrying an unknown event = %d %s, want 404", recorder.Code, recorder.Body)
+	}
+
+	item := model.Item{ID: "ITEM-3", Name: "Es Teh", Stock: 4, Price: model.MustParseMoney("3000", "IDR"), ProductionPrice: model.MustParseMoney("1000", "IDR")}
+	if err := pos.AddItem(item); err != nil {
+		t.Fatalf("AddItem: %v", err)
+	}
+	if recorder := serve(router, http.MethodPost, "/webhooks/pos/pos/events/evt_1/retry", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("retry = %d %s, want 200", recorder.Code, recorder.Body)
+	}
+	if order := getTestOrder(t, pos, "ORD-2"); order.Total != model.MustParseMoney("3000", "IDR") {
+		t.Errorf("ORD-2 total = %s, want Rp 3000", order.Total.Format())
+	}
+}
+
+func TestReceivePOSWebhookNeedsAWriter(t *testing.T) {
+	pos := newTestPOS(t)
+	body := orderEvent("evt_1", `{"id": "ORD-2", "items": [{"item_id": "ITEM-2", "quantity": 2}]}`)
+
+	recorder := deliver(newWebhookRouter(adapter.ReadOnly(pos)), "pos", testWebhookSecret, body)
+	if recorder.Code != http.StatusNotImplemented {
+		t.Errorf("status = %d %s, want 501", recorder.Code, recorder.Body)
+	}
+}
@@ -0,0 +1,141 @@
+// Package llm talks to the language models behind the chatbot and the AI
+// analysis endpoints. A Client sends chat completions to one backend (any
+// OpenAI-compatible API, an Ollama server, or a deterministic fake) and a
+// Profile binds it to the model and sampling settings of one use case.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one turn of a conversation. An assistant message may ask for
+// tool calls instead of answering; each result comes back in a tool message
+// naming the call it answers.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	ToolName   string     `json:"tool_name,omitempty"`
+}
+
+// Request is a single chat completion
+type Request struct {
+	Model       string
+	Messages    []Message
+	Temperature float64
+	TopP        float64 // 0 leaves the backend's default
+	MaxTokens   int     // 0 leaves the backend's default
+	JSON        bool    // ask for a JSON object as the reply
+	Tools       []Tool  // tools the model may call
+	NoToolCalls bool    // keep the tools declared but make the model answer
+}
+
+// Usage counts the tokens of a completion
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Response is the reply to a Request: an answer, or tool calls for the
+// caller to run
+type Response struct {
+	Content   string
+	ToolCalls []ToolCall
+	Model     string
+	Usage     Usage
+}
+
+// Client sends chat completions to a backend. Complete must honour the
+// context's deadline and cancellation.
+type Client interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+}
+
+// Providers accepted by NewClient
+const (
+	ProviderOpenAI = "openai" // any OpenAI-compatible /chat/completions API, such as Groq
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+)
+
+// NewClient - Returns the client of a provider. Timeouts come from the
+// context of each call, so the HTTP client has none of its own.
+func NewClient(provider, baseURL, apiKey string) (Client, error) {
+	httpClient := &http.Client{}
+
+	switch provider {
+	case ProviderOpenAI:
+		return NewOpenAIClient(baseURL, apiKey, httpClient), nil
+	case ProviderOllama:
+		return NewOllamaClient(baseURL, httpClient), nil
+	case ProviderFake:
+		return NewFakeClient(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (use openai, ollama or fake)", provider)
+	}
+}
+
+// Settings are the model and sampling parameters of one use case
+type Settings struct {
+	Model       string
+	Temperature float64
+	TopP        float64
+	MaxTokens   int
+	Timeout     time.Duration // 0 means no timeout beyond the caller's context
+}
+
+// Profile is a Client configured for one use case, such as the chatbot or
+// the dashboard analysis
+type Profile struct {
+	client   Client
+	settings Settings
+}
+
+func NewProfile(client Client, settings Settings) *Profile {
+	return &Profile{client: client, settings: settings}
+}
+
+// Settings - Returns the settings of the use case
+func (p *Profile) Settings() Settings {
+	return p.settings
+}
+
+// Complete - Sends messages with the use case's settings and returns the
+// reply as text
+func (p *Profile) Complete(ctx context.Context, messages ...Message) (Response, error) {
+	return p.complete(ctx, messages, false)
+}
+
+// CompleteJSON - Like Complete, but asks the backend for a JSON object
+func (p *Profile) CompleteJSON(ctx context.Context, messages ...Message) (Response, error) {
+	return p.complete(ctx, messages, true)
+}
+
+func (p *Profile) complete(ctx context.Context, messages []Message, json bool) (Response, error) {
+	return p.send(ctx, p.request(messages, json), nil)
+}
+
+// request - Builds the Request for messages from the use case's settings
+func (p *Profile) request(messages []Message, json bool) Request {
+	return Request{
+		Model:       p.settings.Model,
+		Messages:    messages,
+		Temperature: p.settings.Temperature,
+		TopP:        p.settings.TopP,
+		MaxTokens:   p.settings.MaxTokens,
+		JSON:        json,
+	}
+}
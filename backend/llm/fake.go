@@ -0,0 +1,77 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// FakeClient is a deterministic Client for tests and offline development. It
+// replies with the queued replies in turn, then with a fixed reply: "{}" to
+// JSON requests and an echo of the last user message otherwise. It never
+// calls tools. Every request is recorded.
+type FakeClient struct {
+	mu       sync.Mutex
+	replies  []string
+	requests []Request
+}
+
+func NewFakeClient(replies ...string) *FakeClient {
+	return &FakeClient{replies: replies}
+}
+
+func (f *FakeClient) Complete(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, req)
+
+	var content string
+	switch {
+	case len(f.replies) > 0:
+		content = f.replies[0]
+		f.replies = f.replies[1:]
+	case req.JSON:
+		content = "{}"
+	default:
+		content = "Fake reply to: " + lastUserMessage(req.Messages)
+	}
+
+	prompt := 0
+	for _, message := range req.Messages {
+		prompt += countTokens(message.Content)
+	}
+	completion := countTokens(content)
+
+	return Response{
+		Content: content,
+		Model:   req.Model,
+		Usage:   Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}, nil
+}
+
+// Requests - Returns the requests received so far
+func (f *FakeClient) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Request(nil), f.requests...)
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// countTokens - A stable stand-in for a tokenizer: one token per word
+func countTokens(text string) int {
+	return len(strings.Fields(text))
+}
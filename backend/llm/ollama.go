@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaClient talks to the native chat API of an Ollama server
+type OllamaClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOllamaClient - baseURL is the server root, e.g. http://localhost:11434
+func NewOllamaClient(baseURL string, httpClient *http.Client) *OllamaClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &OllamaClient{baseURL: strings.TrimRight(baseURL, "/"), httpClient: httpClient}
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   string          `json:"format,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+	// Tools are declared as in the OpenAI API
+	Tools []openAITool `json:"tools,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	TopP        float64 `json:"top_p,omitempty"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaResponse struct {
+	Model   string        `json:"model"`
+	Message ollamaMessage `json:"message"`
+
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (o *OllamaClient) Complete(ctx context.Context, req Request) (Response, error) {
+	var decoded ollamaResponse
+	if err := postJSON(ctx, o.httpClient, o.baseURL+"/api/chat", "", newOllamaRequest(req), &decoded); err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Content:   decoded.Message.Content,
+		ToolCalls: decoded.Message.toolCalls(0),
+		Model:     decoded.Model,
+		Usage: Usage{
+			PromptTokens:     decoded.PromptEvalCount,
+			CompletionTokens: decoded.EvalCount,
+			TotalTokens:      decoded.PromptEvalCount + decoded.EvalCount,
+		},
+	}, nil
+}
+
+// newOllamaRequest - Translates req into the API's request body. Ollama can't
+// be told not to call tools, so they are left out when NoToolCalls is set.
+func newOllamaRequest(req Request) ollamaRequest {
+	body := ollamaRequest{
+		Model: req.Model,
+		Options: ollamaOptions{
+			Temperature: req.Temperature,
+			TopP:        req.TopP,
+			NumPredict:  req.MaxTokens,
+		},
+	}
+	if req.JSON {
+		body.Format = "json"
+	}
+
+	for _, message := range req.Messages {
+		wire := ollamaMessage{Role: message.Role, Content: message.Content, ToolName: message.ToolName}
+		for _, call := range message.ToolCalls {
+			var wireCall ollamaToolCall
+			wireCall.Function.Name = call.Name
+			wireCall.Function.Arguments = call.Arguments
+			if !json.Valid(call.Arguments) {
+				// Ollama wants an object back; the tool already reported
+				// the bad arguments
+				wireCall.Function.Arguments = json.RawMessage("{}")
+			}
+			wire.ToolCalls = append(wire.ToolCalls, wireCall)
+		}
+		body.Messages = append(body.Messages, wire)
+	}
+
+	if !req.NoToolCalls {
+		body.Tools = newOpenAIRequest(Request{Tools: req.Tools}).Tools
+	}
+	return body
+}
+
+// toolCalls - Returns the tool calls of the message. Ollama doesn't number
+// calls, so IDs are made up from their position, starting after first.
+func (m ollamaMessage) toolCalls(first int) []ToolCall {
+	var calls []ToolCall
+	for i, call := range m.ToolCalls {
+		calls = append(calls, ToolCall{
+			ID:        fmt.Sprintf("call_%d", first+i+1),
+			Name:      call.Function.Name,
+			Arguments: toolArguments(string(call.Function.Arguments)),
+		})
+	}
+	return calls
+}
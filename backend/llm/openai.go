@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIClient talks to an OpenAI-compatible chat completions API, such as
+// OpenAI itself, Groq, vLLM or llama.cpp's server
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewOpenAIClient - baseURL is the API root that /chat/completions is
+// appended to, e.g. https://api.groq.com/openai/v1
+func NewOpenAIClient(baseURL, apiKey string, httpClient *http.Client) *OpenAIClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &OpenAIClient{baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, httpClient: httpClient}
+}
+
+type openAIRequest struct {
+	Model               string          `json:"model"`
+	Messages            []openAIMessage `json:"messages"`
+	Temperature         float64         `json:"temperature"`
+	TopP                float64         `json:"top_p,omitempty"`
+	MaxCompletionTokens int             `json:"max_completion_tokens,omitempty"`
+	Stream              bool            `json:"stream"`
+	ResponseFormat      *struct {
+		Type string `json:"type"`
+	} `json:"response_format,omitempty"`
+	Tools      []openAITool `json:"tools,omitempty"`
+	ToolChoice string       `json:"tool_choice,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+		// Arguments is a JSON object encoded as a string
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string  `json:"name"`
+		Description string  `json:"description,omitempty"`
+		Parameters  *Schema `json:"parameters,omitempty"`
+	} `json:"function"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+func (o *OpenAIClient) Complete(ctx context.Context, req Request) (Response, error) {
+	if o.apiKey == "" {
+		return Response{}, errors.New("LLM API key is not configured (set GROQ_API_KEY)")
+	}
+
+	var decoded openAIResponse
+	if err := postJSON(ctx, o.httpClient, o.baseURL+"/chat/completions", o.apiKey, newOpenAIRequest(req), &decoded); err != nil {
+		return Response{}, err
+	}
+
+	if len(decoded.Choices) == 0 {
+		return Response{}, errors.New("no response choices received")
+	}
+
+	message := decoded.Choices[0].Message
+	resp := Response{Content: message.Content, Model: decoded.Model, Usage: decoded.Usage}
+	for _, call := range message.ToolCalls {
+		resp.ToolCalls = append(resp.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: toolArguments(call.Function.Arguments),
+		})
+	}
+	return resp, nil
+}
+
+// newOpenAIRequest - Translates req into the API's request body
+func newOpenAIRequest(req Request) openAIRequest {
+	body := openAIRequest{
+		Model:               req.Model,
+		Temperature:         req.Temperature,
+		TopP:                req.TopP,
+		MaxCompletionTokens: req.MaxTokens,
+	}
+	if req.JSON {
+		body.ResponseFormat = &struct {
+			Type string `json:"type"`
+		}{Type: "json_object"}
+	}
+
+	for _, message := range req.Messages {
+		wire := openAIMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			wireCall := openAIToolCall{ID: call.ID, Type: "function"}
+			wireCall.Function.Name = call.Name
+			wireCall.Function.Arguments = string(call.Arguments)
+			wire.ToolCalls = append(wire.ToolCalls, wireCall)
+		}
+		body.Messages = append(body.Messages, wire)
+	}
+
+	for _, tool := range req.Tools {
+		wire := openAITool{Type: "function"}
+		wire.Function.Name = tool.Name
+		wire.Function.Description = tool.Description
+		wire.Function.Parameters = tool.Parameters
+		body.Tools = append(body.Tools, wire)
+	}
+	if len(body.Tools) > 0 && req.NoToolCalls {
+		body.ToolChoice = "none"
+	}
+	return body
+}
+
+// toolArguments - The arguments of a tool call as received, or an empty
+// object when there are none
+func toolArguments(arguments string) json.RawMessage {
+	if strings.TrimSpace(arguments) == "" {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// postJSON - Posts body as JSON and decodes a 200 reply into out
+func postJSON(ctx context.Context, httpClient *http.Client, url, apiKey string, body, out interface{}) error {
+	stream, err := openStream(ctx, httpClient, url, apiKey, body)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if err := json.NewDecoder(stream).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
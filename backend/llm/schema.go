@@ -0,0 +1,294 @@
+package llm
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema used to describe tool arguments and
+// structured replies to the model
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+}
+
+// SchemaOf - Derives the schema of a Go value from its type. Struct fields
+// are named by their json tags and required unless tagged omitempty or a
+// pointer; a desc tag describes the field and an enum tag lists its allowed
+// values, comma-separated.
+func SchemaOf(v interface{}) *Schema {
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func schemaOfType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(jsonMarshalerType) {
+		// Such as model.Money, which encodes as a number
+		return schemaOfEncoding(t)
+	}
+	if t.Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: schemaOfType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		return schemaOfStruct(t)
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// schemaOfEncoding - The schema of a type with its own JSON encoding, judged
+// by how its zero value encodes
+func schemaOfEncoding(t reflect.Type) *Schema {
+	encoded, err := reflect.Zero(t).Interface().(json.Marshaler).MarshalJSON()
+	if err != nil || len(encoded) == 0 {
+		return &Schema{Type: "string"}
+	}
+	switch encoded[0] {
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return &Schema{Type: "number"}
+	case 't', 'f':
+		return &Schema{Type: "boolean"}
+	case '{':
+		return &Schema{Type: "object"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+func schemaOfStruct(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := schemaOfType(field.Type)
+		property.Description = field.Tag.Get("desc")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			property.Enum = strings.Split(enum, ",")
+		}
+		schema.Properties[name] = property
+
+		optional := field.Type.Kind() == reflect.Pointer
+		for _, option := range strings.Split(options, ",") {
+			optional = optional || option == "omitempty"
+		}
+		if !optional {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// Coerce - Checks a decoded JSON value (decoded with UseNumber) against the
+// schema, converting numbers and booleans sent as strings. Returns the value
+// without the parts that don't match, and a problem for each of those and
+// for each missing required field. The value is nil when the root itself
+// doesn't match.
+func (s *Schema) Coerce(value interface{}) (interface{}, []string) {
+	var problems []string
+	result, _ := s.coerce(value, "", &problems)
+	return result, problems
+}
+
+func (s *Schema) coerce(value interface{}, path string, problems *[]string) (interface{}, bool) {
+	fail := func(format string, args ...interface{}) (interface{}, bool) {
+		name := path
+		if name == "" {
+			name = "reply"
+		}
+		*problems = append(*problems, name+": "+fmt.Sprintf(format, args...))
+		return nil, false
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("expected an object, got %s", describeJSON(value))
+		}
+		if s.Properties == nil {
+			return object, true
+		}
+
+		required := make(map[string]bool)
+		for _, name := range s.Required {
+			required[name] = true
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		result := make(map[string]interface{})
+		for _, name := range names {
+			propertyPath := name
+			if path != "" {
+				propertyPath = path + "." + name
+			}
+
+			property, present := object[name]
+			if !present || property == nil {
+				if required[name] {
+					*problems = append(*problems, propertyPath+": is required")
+				}
+				continue
+			}
+			if coerced, ok := s.Properties[name].coerce(property, propertyPath, problems); ok {
+				result[name] = coerced
+			}
+		}
+		return result, true
+
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return fail("expected an array, got %s", describeJSON(value))
+		}
+		result := make([]interface{}, 0, len(list))
+		for i, item := range list {
+			if coerced, ok := s.Items.coerce(item, fmt.Sprintf("%s[%d]", path, i), problems); ok {
+				result = append(result, coerced)
+			}
+		}
+		return result, true
+
+	case "number", "integer":
+		number, ok := jsonNumber(value)
+		if !ok {
+			if text, isString := value.(string); isString && strings.Contains(text, ",") {
+				// 1,250 is 1250 in English and 1.25 in Indonesian
+				return fail("expected a number without thousands separators and with a decimal point, got %s", describeJSON(value))
+			}
+			return fail("expected a number, got %s", describeJSON(value))
+		}
+		if s.Type == "integer" && number != math.Trunc(number) {
+			return fail("expected a whole number, got %v", number)
+		}
+		return json.Number(strconv.FormatFloat(number, 'f', -1, 64)), true
+
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, true
+		case string:
+			if parsed, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return parsed, true
+			}
+		}
+		return fail("expected true or false, got %s", describeJSON(value))
+
+	default:
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = v
+		case json.Number:
+			text = v.String()
+		default:
+			return fail("expected a string, got %s", describeJSON(value))
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, text) {
+			return fail("expected one of %s, got %q", strings.Join(s.Enum, ", "), text)
+		}
+		return text, true
+	}
+}
+
+// jsonNumber - The value as a number, also when it was sent as a string such
+// as "1250.5". Strings with commas are refused rather than guessed at: which
+// of "," and "." groups thousands depends on the locale.
+func jsonNumber(value interface{}) (float64, bool) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case float64:
+		return v, true
+	case string:
+		if strings.Contains(v, ",") {
+			return 0, false
+		}
+		text = strings.NewReplacer("_", "", " ", "").Replace(strings.TrimSpace(v))
+	default:
+		return 0, false
+	}
+
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, false
+	}
+	return number, true
+}
+
+// describeJSON - Names the type of a decoded JSON value for a problem
+func describeJSON(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if len(v) > 40 {
+			v = v[:40] + "..."
+		}
+		return fmt.Sprintf("the string %q", v)
+	case json.Number, float64:
+		return fmt.Sprintf("the number %v", v)
+	case bool:
+		return fmt.Sprintf("%v", v)
+	case []interface{}:
+		return "an array"
+	case map[string]interface{}:
+		return "an object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DeltaFunc receives each piece of a streamed reply as it arrives. Returning
+// an error stops the stream.
+type DeltaFunc func(delta string) error
+
+// Streamer is implemented by clients that can stream a reply. Stream calls
+// onDelta for each piece and returns the whole reply with its usage once the
+// backend is done.
+type Streamer interface {
+	Stream(ctx context.Context, req Request, onDelta DeltaFunc) (Response, error)
+}
+
+// Stream - Sends messages with the use case's settings and streams the reply
+// to onDelta. Clients that can't stream deliver the whole reply as one delta.
+func (p *Profile) Stream(ctx context.Context, onDelta DeltaFunc, messages ...Message) (Response, error) {
+	return p.send(ctx, p.request(messages, false), onDelta)
+}
+
+// send - Sends req within the use case's timeout, streaming the reply to
+// onDelta when it is set
+func (p *Profile) send(ctx context.Context, req Request, onDelta DeltaFunc) (Response, error) {
+	if p.settings.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.settings.Timeout)
+		defer cancel()
+	}
+
+	if onDelta == nil {
+		return p.client.Complete(ctx, req)
+	}
+	if streamer, ok := p.client.(Streamer); ok {
+		return streamer.Stream(ctx, req, onDelta)
+	}
+
+	resp, err := p.client.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+	if resp.Content != "" {
+		if err := onDelta(resp.Content); err != nil {
+			return Response{}, err
+		}
+	}
+	return resp, nil
+}
+
+type openAIStreamRequest struct {
+	openAIRequest
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type openAIChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index int `json:"index"`
+				openAIToolCall
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+	// Groq reports usage here instead of in usage
+	XGroq *struct {
+		Usage *Usage `json:"usage"`
+	} `json:"x_groq"`
+}
+
+// Stream - Requests a streamed completion and reads its server-sent events.
+// Tool calls arrive in pieces and are returned once complete.
+func (o *OpenAIClient) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (Response, error) {
+	if o.apiKey == "" {
+		return Response{}, fmt.Errorf("LLM API key is not configured (set GROQ_API_KEY)")
+	}
+
+	body := openAIStreamRequest{openAIRequest: newOpenAIRequest(req)}
+	body.Stream = true
+	body.StreamOptions.IncludeUsage = true
+
+	stream, err := openStream(ctx, o.httpClient, o.baseURL+"/chat/completions", o.apiKey, body)
+	if err != nil {
+		return Response{}, err
+	}
+	defer stream.Close()
+
+	var result Response
+	var content bytes.Buffer
+	var calls []*openAIToolCall
+	scanner := newLineScanner(stream)
+	for scanner.Scan() {
+		data, found := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !found {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return Response{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		} else if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+			result.Usage = *chunk.XGroq.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			for _, piece := range choice.Delta.ToolCalls {
+				for len(calls) <= piece.Index {
+					calls = append(calls, &openAIToolCall{})
+				}
+				call := calls[piece.Index]
+				if piece.ID != "" {
+					call.ID = piece.ID
+				}
+				if piece.Function.Name != "" {
+					call.Function.Name = piece.Function.Name
+				}
+				call.Function.Arguments += piece.Function.Arguments
+			}
+
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return Response{}, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	result.Content = content.String()
+	for _, call := range calls {
+		result.ToolCalls = append(result.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: toolArguments(call.Function.Arguments),
+		})
+	}
+	return result, nil
+}
+
+// Stream - Requests a streamed chat, which Ollama sends as one JSON object
+// per line
+func (o *OllamaClient) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (Response, error) {
+	body := newOllamaRequest(req)
+	body.Stream = true
+
+	stream, err := openStream(ctx, o.httpClient, o.baseURL+"/api/chat", "", body)
+	if err != nil {
+		return Response{}, err
+	}
+	defer stream.Close()
+
+	var result Response
+	var content bytes.Buffer
+	scanner := newLineScanner(stream)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk struct {
+			ollamaResponse
+			Done bool `json:"done"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return Response{}, fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		result.Model = chunk.Model
+		result.ToolCalls = append(result.ToolCalls, chunk.Message.toolCalls(len(result.ToolCalls))...)
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return Response{}, err
+			}
+		}
+		if chunk.Done {
+			result.Usage = Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{}, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// Stream - Replies like Complete, one word at a time
+func (f *FakeClient) Stream(ctx context.Context, req Request, onDelta DeltaFunc) (Response, error) {
+	resp, err := f.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	for _, word := range bytes.SplitAfter([]byte(resp.Content), []byte(" ")) {
+		if err := ctx.Err(); err != nil {
+			return Response{}, err
+		}
+		if len(word) == 0 {
+			continue
+		}
+		if err := onDelta(string(word)); err != nil {
+			return Response{}, err
+		}
+	}
+	return resp, nil
+}
+
+// openStream - Posts body as JSON and returns the body of a 200 reply for the
+// caller to read and close. apiKey is sent as a bearer token when set.
+func openStream(ctx context.Context, httpClient *http.Client, url, apiKey string, body interface{}) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return resp.Body, nil
+}
+
+// newLineScanner - Returns a scanner for streamed lines, which may be longer
+// than bufio's default limit
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return scanner
+}
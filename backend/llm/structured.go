@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validator is implemented by structured replies with rules their schema
+// can't express. Validate returns one message per broken rule.
+type Validator interface {
+	Validate() []string
+}
+
+// reasoningBlock matches the <think> blocks reasoning models such as qwen3
+// put before their answer
+var reasoningBlock = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// CompleteStructured - Asks the model of p for a JSON reply shaped like T.
+// The schema of T (see SchemaOf) is added to messages; the reply is cleaned
+// of reasoning blocks and code fences, coerced and checked against the schema
+// and, when T is a Validator, against its rules. An invalid reply is sent
+// back with its problems up to retries times.
+//
+// When no reply is fully valid, the best one is returned with its problems
+// as warnings: fields that didn't match the schema are left at their zero
+// values. An error is only returned when no reply could be used at all.
+func CompleteStructured[T any](ctx context.Context, p *Profile, retries int, messages ...Message) (T, []string, error) {
+	var best T
+	var bestProblems []string
+	decoded := false
+
+	schema := SchemaOf(best)
+	encodedSchema, err := json.Marshal(schema)
+	if err != nil {
+		return best, nil, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	messages = append(append([]Message(nil), messages...), Message{
+		Role:    RoleSystem,
+		Content: "Reply with one JSON object matching this JSON Schema:\n" + string(encodedSchema),
+	})
+
+	var lastProblems []string
+	for attempt := 0; attempt <= retries; attempt++ {
+		resp, err := p.CompleteJSON(ctx, messages...)
+		if err != nil {
+			if !decoded {
+				return best, nil, err
+			}
+			// A failed request isn't worth retrying: keep the best reply
+			return best, append(bestProblems, "the model request failed: "+err.Error()), nil
+		}
+
+		value, problems := decodeStructured[T](schema, resp.Content)
+		if problems == nil {
+			return *value, nil, nil
+		}
+		if value != nil && (!decoded || len(problems) < len(bestProblems)) {
+			best, bestProblems, decoded = *value, problems, true
+		}
+		lastProblems = problems
+
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: resp.Content},
+			Message{Role: RoleUser, Content: "Your reply doesn't match the required format:\n- " + strings.Join(problems, "\n- ") + "\nReply again with the corrected JSON object only."},
+		)
+	}
+
+	if !decoded {
+		return best, nil, fmt.Errorf("no valid reply after %d attempts: %s", retries+1, strings.Join(lastProblems, "; "))
+	}
+	return best, bestProblems, nil
+}
+
+// decodeStructured - Decodes a reply into T. Returns nil problems for a valid
+// reply and a nil value for one that couldn't be decoded at all.
+func decodeStructured[T any](schema *Schema, content string) (*T, []string) {
+	var raw interface{}
+	decoder := json.NewDecoder(strings.NewReader(extractJSON(content)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, []string{"reply: is not valid JSON: " + err.Error()}
+	}
+
+	coerced, problems := schema.Coerce(raw)
+	if coerced == nil {
+		return nil, problems
+	}
+
+	encoded, err := json.Marshal(coerced)
+	if err != nil {
+		return nil, append(problems, "reply: "+err.Error())
+	}
+	var value T
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, append(problems, "reply: "+err.Error())
+	}
+
+	if validator, ok := any(&value).(Validator); ok {
+		// Fields the schema already rejected needn't be reported again
+		rejected := problems
+		for _, problem := range validator.Validate() {
+			if !overlapsProblem(rejected, problem) {
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return &value, problems
+}
+
+// overlapsProblem - Reports whether problem is about a field, or a part or
+// parent of a field, that one of problems is already about
+func overlapsProblem(problems []string, problem string) bool {
+	field, _, _ := strings.Cut(problem, ":")
+	for _, other := range problems {
+		otherField, _, _ := strings.Cut(other, ":")
+		if field == otherField || isSubfield(otherField, field) || isSubfield(field, otherField) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractJSON - The JSON object in a reply, without reasoning blocks, code
+// fences or text around it
+func extractJSON(content string) string {
+	content = reasoningBlock.ReplaceAllString(content, "")
+	if _, after, found := strings.Cut(content, "</think>"); found {
+		// The opening tag was cut off
+		content = after
+	}
+
+	start := strings.IndexByte(content, '{')
+	end := strings.LastIndexByte(content, '}')
+	if start < 0 || end < start {
+		return strings.TrimSpace(content)
+	}
+	return content[start : end+1]
+}
+
+// isSubfield - Reports whether path is inside the field at parent
+func isSubfield(path, parent string) bool {
+	return strings.HasPrefix(path, parent+".") || strings.HasPrefix(path, parent+"[")
+}
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testInsight is a structured reply with a rule its schema can't express
+type testInsight struct {
+	Summary string   `json:"summary"`
+	Trend   string   `json:"trend" enum:"up,down,flat"`
+	Revenue float64  `json:"revenue"`
+	Notes   []string `json:"notes,omitempty"`
+}
+
+func (i testInsight) Validate() []string {
+	if i.Revenue < 0 {
+		return []string{"revenue: must not be negative"}
+	}
+	return nil
+}
+
+func TestCompleteStructured(t *testing.T) {
+	tests := []struct {
+		name         string
+		replies      []string
+		retries      int
+		want         testInsight
+		wantProblems []string
+		wantErr      bool
+		wantRequests int
+	}{
+		{
+			name:         "valid reply",
+			replies:      []string{`{"summary": "Sales grew", "trend": "up", "revenue": 1250.5}`},
+			want:         testInsight{Summary: "Sales grew", Trend: "up", Revenue: 1250.5},
+			wantRequests: 1,
+		},
+		{
+			name:         "reasoning block, code fence and a number as a string",
+			replies:      []string{"<think>Revenue is up.</think>\n```json\n{\"summary\": \"Sales grew\", \"trend\": \"up\", \"revenue\": \"1250.5\", \"notes\": [\"weekend peak\"]}\n```"},
+			want:         testInsight{Summary: "Sales grew", Trend: "up", Revenue: 1250.5, Notes: []string{"weekend peak"}},
+			wantRequests: 1,
+		},
+		{
+			name: "repaired after a schema problem",
+			replies: []string{
+				`{"summary": "Sales grew", "trend": "sideways", "revenue": 1250.5}`,
+				`{"summary": "Sales grew", "trend": "flat", "revenue": 1250.5}`,
+			},
+			retries:      2,
+			want:         testInsight{Summary: "Sales grew", Trend: "flat", Revenue: 1250.5},
+			wantRequests: 2,
+		},
+		{
+			name: "repaired after a validation problem",
+			replies: []string{
+				`{"summary": "Sales fell", "trend": "down", "revenue": -5}`,
+				`{"summary": "Sales fell", "trend": "down", "revenue": 5}`,
+			},
+			retries:      1,
+			want:         testInsight{Summary: "Sales fell", Trend: "down", Revenue: 5},
+			wantRequests: 2,
+		},
+		{
+			name: "best invalid reply with warnings",
+			replies: []string{
+				`{"trend": "sideways", "revenue": "1,250"}`,
+				`{"summary": "Sales grew", "trend": "up", "revenue": "1,250"}`,
+			},
+			retries:      1,
+			want:         testInsight{Summary: "Sales grew", Trend: "up"},
+			wantProblems: []string{"revenue: expected a number without thousands separators and with a decimal point, got the string \"1,250\""},
+			wantRequests: 2,
+		},
+		{
+			name:         "schema and rule problems are not reported twice",
+			replies:      []string{`{"summary": "Sales fell", "trend": "down", "revenue": -5}`},
+			want:         testInsight{Summary: "Sales fell", Trend: "down", Revenue: -5},
+			wantProblems: []string{"revenue: must not be negative"},
+			wantRequests: 1,
+		},
+		{
+			name:         "no usable reply",
+			replies:      []string{"Sales grew this month.", `["up"]`},
+			retries:      1,
+			wantErr:      true,
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		client := NewFakeClient(tt.replies...)
+		profile := NewProfile(client, Settings{Model: "test"})
+
+		got, problems, err := CompleteStructured[testInsight](context.Background(), profile, tt.retries, Message{Role: RoleUser, Content: "Summarise this month"})
+		requests := client.Requests()
+		if len(requests) != tt.wantRequests {
+			t.Errorf("%s: sent %d requests, want %d", tt.name, len(requests), tt.wantRequests)
+		}
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: CompleteStructured = %+v, %v, want an error", tt.name, got, problems)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: CompleteStructured returned error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CompleteStructured = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(problems, tt.wantProblems) {
+			t.Errorf("%s: problems = %q, want %q", tt.name, problems, tt.wantProblems)
+		}
+	}
+}
+
+func TestCompleteStructuredRepairPrompt(t *testing.T) {
+	client := NewFakeClient(
+		`{"summary": "Sales grew", "trend": "sideways", "revenue": 1250.5}`,
+		`{"summary": "Sales grew", "trend": "up", "revenue": 1250.5}`,
+	)
+	profile := NewProfile(client, Settings{Model: "test"})
+
+	if _, _, err := CompleteStructured[testInsight](context.Background(), profile, 1, Message{Role: RoleUser, Content: "Summarise this month"}); err != nil {
+		t.Fatalf("CompleteStructured returned error: %v", err)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	first := requests[0]
+	if !first.JSON || len(first.Messages) != 2 || first.Messages[1].Role != RoleSystem || !strings.Contains(first.Messages[1].Content, `"enum":["up","down","flat"]`) {
+		t.Errorf("first request = %+v, want a JSON request with the schema appended", first)
+	}
+
+	// The retry carries the rejected reply and what was wrong with it
+	retry := requests[1].Messages
+	if len(retry) != 4 {
+		t.Fatalf("retry has %d messages, want 4", len(retry))
+	}
+	if retry[2].Role != RoleAssistant || !strings.Contains(retry[2].Content, "sideways") {
+		t.Errorf("retry repeats %+v, want the rejected reply", retry[2])
+	}
+	if retry[3].Role != RoleUser || !strings.Contains(retry[3].Content, `trend: expected one of up, down, flat, got "sideways"`) {
+		t.Errorf("retry asks %q, want the trend problem", retry[3].Content)
+	}
+}
@@ -0,0 +1,195 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tool is a function the model may ask to call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  *Schema // an object schema of the arguments
+}
+
+// ToolCall is the model's request to call a tool. Arguments is a JSON object.
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolFunc runs a tool. The result is sent to the model as JSON; an error is
+// sent as {"error": "..."} so that the model can correct the call.
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (interface{}, error)
+
+// Toolbox is a registry of tools and the functions that run them
+type Toolbox struct {
+	tools []Tool
+	funcs map[string]ToolFunc
+}
+
+func NewToolbox() *Toolbox {
+	return &Toolbox{funcs: make(map[string]ToolFunc)}
+}
+
+// Add - Registers a tool, replacing one with the same name
+func (t *Toolbox) Add(tool Tool, run ToolFunc) {
+	if _, exists := t.funcs[tool.Name]; !exists {
+		t.tools = append(t.tools, tool)
+	} else {
+		for i := range t.tools {
+			if t.tools[i].Name == tool.Name {
+				t.tools[i] = tool
+			}
+		}
+	}
+	t.funcs[tool.Name] = run
+}
+
+// AddTool - Registers a tool whose arguments decode into A; the parameters
+// schema is derived from A (see SchemaOf)
+func AddTool[A any](t *Toolbox, name, description string, run func(ctx context.Context, args A) (interface{}, error)) {
+	var zero A
+	t.Add(Tool{Name: name, Description: description, Parameters: SchemaOf(zero)}, func(ctx context.Context, arguments json.RawMessage) (interface{}, error) {
+		var args A
+		if len(bytes.TrimSpace(arguments)) > 0 {
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments: %w", err)
+			}
+		}
+		return run(ctx, args)
+	})
+}
+
+// Tools - Returns the registered tools in registration order
+func (t *Toolbox) Tools() []Tool {
+	return append([]Tool(nil), t.tools...)
+}
+
+// Run - Runs a tool call and returns its result as JSON
+func (t *Toolbox) Run(ctx context.Context, call ToolCall) (json.RawMessage, error) {
+	run, exists := t.funcs[call.Name]
+	if !exists {
+		return nil, fmt.Errorf("unknown tool %q", call.Name)
+	}
+
+	result, err := run(ctx, call.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result of %s: %w", call.Name, err)
+	}
+	return encoded, nil
+}
+
+// ToolRun records one tool call made while answering, for auditing
+type ToolRun struct {
+	Round      int             `json:"round"`
+	Name       string          `json:"name"`
+	Arguments  json.RawMessage `json:"arguments"`
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+}
+
+// ToolLoop sets up Profile.RunTools
+type ToolLoop struct {
+	Toolbox *Toolbox
+	// MaxRounds bounds the rounds of tool calls; the model must answer after
+	// the last one
+	MaxRounds int
+	// OnDelta, when set, receives the reply as it is streamed. Text streamed
+	// in a round that ends in tool calls isn't part of the answer: OnDiscard
+	// is called after such a round.
+	OnDelta   DeltaFunc
+	OnDiscard func()
+	// OnToolRun, when set, is called after each tool call
+	OnToolRun func(run ToolRun)
+}
+
+// ErrNoAnswer is returned when the model still asks for tools after the last
+// tool round
+var ErrNoAnswer = errors.New("the model did not answer after its tool calls")
+
+// RunTools - Sends messages with the use case's settings and the tools of
+// loop, runs the tool calls the model asks for and sends their results back,
+// until the model answers or MaxRounds rounds have run. Returns the answer
+// with the usage of every round, and the tool calls that ran.
+func (p *Profile) RunTools(ctx context.Context, loop ToolLoop, messages ...Message) (Response, []ToolRun, error) {
+	messages = append([]Message(nil), messages...)
+	tools := loop.Toolbox.Tools()
+
+	var usage Usage
+	var runs []ToolRun
+	for round := 1; ; round++ {
+		req := p.request(messages, false)
+		req.Tools = tools
+		req.NoToolCalls = round > loop.MaxRounds
+
+		streamed := false
+		var onDelta DeltaFunc
+		if loop.OnDelta != nil {
+			onDelta = func(delta string) error {
+				streamed = true
+				return loop.OnDelta(delta)
+			}
+		}
+		resp, err := p.send(ctx, req, onDelta)
+		if err != nil {
+			return Response{}, runs, err
+		}
+		usage = usage.Add(resp.Usage)
+
+		if len(resp.ToolCalls) == 0 || req.NoToolCalls {
+			if resp.Content == "" && len(resp.ToolCalls) > 0 {
+				return Response{}, runs, ErrNoAnswer
+			}
+			resp.ToolCalls = nil
+			resp.Usage = usage
+			return resp, runs, nil
+		}
+
+		if streamed && loop.OnDiscard != nil {
+			loop.OnDiscard()
+		}
+		messages = append(messages, Message{Role: RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls})
+		for _, call := range resp.ToolCalls {
+			started := time.Now()
+			result, err := loop.Toolbox.Run(ctx, call)
+			if err := ctx.Err(); err != nil {
+				return Response{}, runs, err
+			}
+
+			run := ToolRun{Round: round, Name: call.Name, Arguments: call.Arguments, DurationMS: time.Since(started).Milliseconds()}
+			if !json.Valid(call.Arguments) {
+				// Keep malformed arguments readable in the audit
+				run.Arguments, _ = json.Marshal(string(call.Arguments))
+			}
+			if err != nil {
+				run.Error = err.Error()
+				result, _ = json.Marshal(map[string]string{"error": err.Error()})
+			}
+			runs = append(runs, run)
+			if loop.OnToolRun != nil {
+				loop.OnToolRun(run)
+			}
+
+			messages = append(messages, Message{Role: RoleTool, Content: string(result), ToolCallID: call.ID, ToolName: call.Name})
+		}
+	}
+}
+
+// Add - Returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
@@ -1,70 +1,214 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Business timezones must load even without system tzdata
 
 	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/YudaClairee/garudahacks/config"
+	"github.com/YudaClairee/garudahacks/conversation"
 	"github.com/YudaClairee/garudahacks/handler"
+	"github.com/YudaClairee/garudahacks/llm"
+	"github.com/YudaClairee/garudahacks/migrations"
+	"github.com/YudaClairee/garudahacks/model"
+	"github.com/YudaClairee/garudahacks/syncer"
+	"github.com/YudaClairee/garudahacks/webhook"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
 
 func main() {
-
-	err := godotenv.Load()
+	// Configuration from .env, CONFIG_FILE and the environment
+	cfg, err := config.Load()
 	if err != nil {
-		log.Println("No .env file found, using environment variables")
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
+	log.Printf("Effective configuration:\n%s", cfg.Redacted())
 
-	// Database configuration
-	connectionString := os.Getenv("POSTGRES_DSN")
-
-	// Connect to database using sqlx
-	db, err := sqlx.Connect("postgres", connectionString)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	// Only the database-backed providers need a connection
+	var db *sqlx.DB
+	if cfg.NeedsDatabase() {
+		db, err = openDatabase(cfg)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer db.Close()
 	}
-	defer db.Close()
-
-	// Configure connection pool for Neon
-	db.SetMaxOpenConns(10)
-	db.SetMaxIdleConns(5)
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Failed to ping database: %v", err)
+	// `main migrate up|down|status` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if cfg.POS.Provider != "db" {
+			log.Printf("Migrations only apply to Postgres; the %s provider manages its own schema", cfg.POS.Provider)
+			return
+		}
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
-	log.Println("Database connection established")
+	// Apply pending migrations at startup unless disabled
+	if cfg.POS.Provider == "db" && cfg.Database.MigrateOnStart {
+		if err := runMigrate(db, []string{"up"}); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
 
 	// Create Gin router
 	r := gin.Default()
 
 	// Configure CORS
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:8080", "https://nabungai.uk"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
-	config.AllowCredentials = true
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.Server.CORSOrigins
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	corsConfig.AllowCredentials = true
 
 	// Use CORS middleware
-	r.Use(cors.New(config))
-
-	// Initialize adapters and handlers with sqlx DB
-	posAdapter := adapter.NewDBPosAdapter(db)
-	revenueHandler := handler.NewRevenueHandler(posAdapter)
-	ordersHandler := handler.NewOrdersHandler(posAdapter)
-	itemSalesHandler := handler.NewItemSalesHandler(posAdapter)
-	dashboardAIAnalytics := handler.NewDashboardAIHandler(posAdapter)
-	addItemHandler := handler.NewAddItemHandler(posAdapter)
-	chatbotHandler := handler.NewChatbotHandler(posAdapter)
-	insightAIHandler := handler.NewInsightAIHandler(posAdapter)
-	addOrderHandler := handler.NewAddOrderHandler(posAdapter)
+	r.Use(cors.New(corsConfig))
+
+	// Business currency and timezone, and the exchange rates used to report
+	// amounts recorded in other currencies
+	business := model.NewBusiness(cfg.Business.Currency, loadExchangeRates(db, cfg.Business.ExchangeRatesCSV))
+	business = business.InLocation(cfg.Location())
+	log.Printf("Reporting in %s, timezone %s", business.Currency, business.Zone())
+
+	// Build the configured POS adapter; amounts recorded without a currency
+	// are in the business currency
+	posAdapter, err := adapter.GetPOSAdapter(cfg.POS.Provider, cfg.POS.APIKey, db, cfg.RESTAdapterConfig(), cfg.DemoGeneratorConfig(), business)
+	if err != nil {
+		log.Fatalf("Failed to create POS adapter: %v", err)
+	}
+
+	// Cache reads of the providers that make a round trip per call; the
+	// in-memory ones gain nothing from it
+	var cache *adapter.CachingAdapter
+	if cfg.Cache.Enabled && cfg.POS.Provider != "memory" && cfg.POS.Provider != "demo" {
+		cache = adapter.NewCachingAdapter(posAdapter, adapter.CacheOptions{
+			TTL:        cfg.Cache.TTL.Duration,
+			MaxRecords: cfg.Cache.MaxRecords,
+		})
+		posAdapter = cache.Adapter()
+		log.Printf("Caching POS reads for %s (up to %d records)", cfg.Cache.TTL.Duration, cfg.Cache.MaxRecords)
+	}
+
+	capabilities := model.CapabilitiesOf(posAdapter)
+	log.Printf("POS provider %s supports: %s", cfg.POS.Provider, strings.Join(capabilities.Supported(), ", "))
+
+	// Configure what happens when an order takes more than the available stock
+	oversellPolicy, err := model.ParseOversellPolicy(cfg.POS.OversellPolicy)
+	if err != nil {
+		log.Fatalf("Invalid oversell policy: %v", err)
+	}
+	if setter, ok := model.As[model.OversellPolicySetter](posAdapter); ok {
+		setter.SetOversellPolicy(oversellPolicy)
+	}
+
+	// Mirror a remote POS into the configured adapter in the background
+	var syncEngines []*syncer.Engine
+	if cfg.Sync.Source != "" {
+		remote, err := adapter.GetPOSAdapter(cfg.Sync.Source, cfg.POS.APIKey, nil, cfg.RESTAdapterConfig(), cfg.DemoGeneratorConfig(), business)
+		if err != nil {
+			log.Fatalf("Failed to create sync source: %v", err)
+		}
+
+		// Checkpoints are kept across restarts in the provider's database
+		var store syncer.CheckpointStore = syncer.NewMemoryCheckpointStore()
+		switch cfg.POS.Provider {
+		case "db":
+			store = syncer.NewDBCheckpointStore(db)
+		case "sqlite":
+			sqliteStore, err := syncer.NewSQLiteCheckpointStore(db)
+			if err != nil {
+				log.Fatalf("Failed to open sync checkpoint store: %v", err)
+			}
+			store = sqliteStore
+		default:
+			log.Printf("Sync checkpoints are kept in memory: every restart syncs %s from scratch", cfg.Sync.Source)
+		}
+
+		local, ok := model.As[model.POSAdapter](posAdapter)
+		if !ok {
+			log.Fatalf("Cannot sync into POS provider %s: it is read-only", cfg.POS.Provider)
+		}
+
+		engine := syncer.NewEngine(cfg.Sync.Source, remote, local, store, syncer.Options{
+			Interval:  cfg.Sync.Interval.Duration,
+			Overlap:   cfg.Sync.Overlap.Duration,
+			BatchSize: cfg.Sync.BatchSize,
+		})
+		engine.Start(context.Background())
+		syncEngines = append(syncEngines, engine)
+	}
+
+	// Signed webhooks from POS providers; events are only remembered across
+	// restarts in Postgres
+	var webhookStore webhook.EventStore = webhook.NewMemoryEventStore()
+	if cfg.POS.Provider == "db" {
+		webhookStore = webhook.NewDBEventStore(db)
+	}
+	webhookHandler := handler.NewWebhookHandler(posAdapter, webhookStore, webhook.Config{
+		Secrets:         cfg.WebhookSecrets(),
+		SignatureHeader: cfg.Webhooks.SignatureHeader,
+		MaxAttempts:     cfg.Webhooks.MaxAttempts,
+		Currency:        business.Currency,
+	})
+	webhookHandler.StartRetries(context.Background(), cfg.Webhooks.RetryInterval.Duration)
+	if len(cfg.Webhooks.Secrets) > 0 && (!capabilities.InventoryWriter || !capabilities.OrderWriter) {
+		log.Printf("POS provider %s can't write every webhook event: unsupported events are rejected with 501", cfg.POS.Provider)
+	}
+
+	// One LLM client shared by the chatbot and the AI analysis endpoints,
+	// each with the model settings of its use case
+	llmClient, err := llm.NewClient(cfg.LLM.Provider, cfg.LLM.BaseURL, cfg.LLM.APIKey)
+	if err != nil {
+		log.Fatalf("Failed to create LLM client: %v", err)
+	}
+	chatLLM := llm.NewProfile(llmClient, cfg.LLM.Chat.Settings())
+	dashboardLLM := llm.NewProfile(llmClient, cfg.LLM.Dashboard.Settings())
+	insightLLM := llm.NewProfile(llmClient, cfg.LLM.Insight.Settings())
+
+	// Chat conversations are kept across restarts in the provider's database
+	var conversations conversation.Store = conversation.NewMemoryStore()
+	switch cfg.POS.Provider {
+	case "db":
+		conversations = conversation.NewDBStore(db)
+	case "sqlite":
+		sqliteConversations, err := conversation.NewSQLiteStore(db)
+		if err != nil {
+			log.Fatalf("Failed to open conversation store: %v", err)
+		}
+		conversations = sqliteConversations
+	default:
+		log.Printf("Chat conversations are kept in memory: they are lost on restart")
+	}
+	history := conversation.NewHistory(conversations, cfg.HistoryOptions(), conversation.LLMSummarizer(chatLLM))
+
+	revenueHandler := handler.NewRevenueHandler(posAdapter, business)
+	ordersHandler := handler.NewOrdersHandler(posAdapter, business)
+	itemSalesHandler := handler.NewItemSalesHandler(posAdapter, business)
+	dashboardAIAnalytics := handler.NewDashboardAIHandler(posAdapter, business, dashboardLLM, cfg.LLM.StructuredRetries)
+	addItemHandler := handler.NewAddItemHandler(posAdapter, business)
+	chatbotHandler := handler.NewChatbotHandler(posAdapter, business, chatLLM, conversations, history, cfg.LLM.MaxToolRounds)
+	conversationHandler := handler.NewConversationHandler(conversations)
+	insightAIHandler := handler.NewInsightAIHandler(posAdapter, business, insightLLM, cfg.LLM.StructuredRetries)
+	addOrderHandler := handler.NewAddOrderHandler(posAdapter, business)
+	stockHandler := handler.NewStockHandler(posAdapter)
+	priceHistoryHandler := handler.NewPriceHistoryHandler(posAdapter, business)
+	itemHandler := handler.NewItemHandler(posAdapter)
+	orderHandler := handler.NewOrderHandler(posAdapter)
+	syncHandler := handler.NewSyncHandler(syncEngines...)
+	capabilitiesHandler := handler.NewCapabilitiesHandler(cfg.POS.Provider, posAdapter)
+	cacheHandler := handler.NewCacheHandler(cache)
 
 	// Routes
 	r.GET("/", func(c *gin.Context) {
@@ -82,6 +226,8 @@ func main() {
 		api.GET("/items/sales", itemSalesHandler.GetItemSales)
 		api.GET("/items/top-selling", itemSalesHandler.GetTopSellingItems)
 		api.GET("/items/get-all", itemSalesHandler.GetAllItems)
+		api.GET("/items/:id/stock-movements", stockHandler.GetStockMovements)
+		api.GET("/items/:id/price-history", priceHistoryHandler.GetPriceHistory)
 		api.GET("/dashboard/ai-analysis", dashboardAIAnalytics.GetDashboardAIAnalysis)
 		api.GET("/insights/ai-analysis", insightAIHandler.GetBusinessInsights) // New route
 
@@ -95,11 +241,152 @@ func main() {
 		api.GET("/orders/csv-template", addOrderHandler.GetCSVTemplate)
 
 		api.POST("/chat", chatbotHandler.Chat)
+		api.POST("/chat/stream", chatbotHandler.ChatStream)
+		api.GET("/conversations", conversationHandler.ListConversations)
+		api.GET("/conversations/:id", conversationHandler.GetConversation)
+		api.PATCH("/conversations/:id", conversationHandler.RenameConversation)
+		api.DELETE("/conversations/:id", conversationHandler.DeleteConversation)
+
+		// Item and order resources
+		api.GET("/items/:id", itemHandler.GetItem)
+		api.PUT("/items/:id", itemHandler.ReplaceItem)
+		api.PATCH("/items/:id", itemHandler.PatchItem)
+		api.DELETE("/items/:id", itemHandler.DeleteItem)
+
+		api.GET("/orders/:id", orderHandler.GetOrder)
+		api.PUT("/orders/:id", orderHandler.ReplaceOrder)
+		api.PATCH("/orders/:id", orderHandler.PatchOrder)
+		api.DELETE("/orders/:id", orderHandler.DeleteOrder)
+
+		api.GET("/sync/status", syncHandler.GetSyncStatus)
+		api.GET("/capabilities", capabilitiesHandler.GetCapabilities)
+		api.GET("/cache/stats", cacheHandler.GetCacheStats)
+		api.DELETE("/cache", cacheHandler.ClearCache)
+
+		api.POST("/webhooks/pos/:provider", webhookHandler.ReceivePOSWebhook)
+		api.GET("/webhooks/pos/:provider/events", webhookHandler.ListEvents)
+		api.POST("/webhooks/pos/:provider/events/:event_id/retry", webhookHandler.RetryEvent)
 	}
 
-	// Start server on port 8080
-	log.Println("Server starting on :8080")
-	if err := r.Run(":8080"); err != nil {
+	// Start server
+	log.Printf("Server starting on %s", cfg.Server.Addr)
+	if err := r.Run(cfg.Server.Addr); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// openDatabase opens the database of a database-backed provider: Postgres at
+// the configured DSN or the SQLite file
+func openDatabase(cfg *config.Config) (*sqlx.DB, error) {
+	if cfg.POS.Provider == "sqlite" {
+		db, err := adapter.OpenSQLite(cfg.POS.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Using SQLite database %s", cfg.POS.SQLitePath)
+		return db, nil
+	}
+
+	// Connect to database using sqlx
+	db, err := sqlx.Connect("postgres", cfg.Database.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	// Configure connection pool for Neon
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("Database connection established")
+	return db, nil
+}
+
+// runMigrate runs the migrate subcommand: up applies every pending migration,
+// down [n] rolls back the last n (default 1) and status lists them all
+func runMigrate(db *sqlx.DB, args []string) error {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Schema up to date (%d migrations applied)", len(applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled back %d migrations", len(reverted))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q (use up, down or status)", command)
+	}
+	return nil
+}
+
+// loadExchangeRates reads rates from the CSV file at path when set and from
+// the exchange_rates table otherwise (when there is a database). Without rates
+// only amounts already in the business currency can be reported.
+func loadExchangeRates(db *sqlx.DB, path string) *model.RateTable {
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open exchange rates: %v", err)
+		}
+		defer file.Close()
+
+		rates, err := model.LoadRateTableCSV(file)
+		if err != nil {
+			log.Fatalf("Failed to load exchange rates: %v", err)
+		}
+		log.Printf("Loaded %d exchange rates from %s", rates.Len(), path)
+		return rates
+	}
+
+	if db == nil {
+		return model.NewRateTable()
+	}
+
+	rates, err := adapter.LoadExchangeRates(db)
+	if err != nil {
+		log.Printf("No exchange rates loaded: %v", err)
+		return model.NewRateTable()
+	}
+	log.Printf("Loaded %d exchange rates from the database", rates.Len())
+	return rates
+}
@@ -0,0 +1,29 @@
+-- Core POS tables used by DBPosAdapter, as they were before migrations
+-- existed. Amounts were floating point until 0006_money_numeric.
+CREATE TABLE IF NOT EXISTS items (
+    id                TEXT PRIMARY KEY,
+    name              TEXT NOT NULL,
+    stock             INTEGER NOT NULL DEFAULT 0,
+    price             DOUBLE PRECISION NOT NULL DEFAULT 0,
+    production_price  DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id            TEXT PRIMARY KEY,
+    total         DOUBLE PRECISION NOT NULL DEFAULT 0,
+    completed_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_completed_at ON orders (completed_at, id);
+
+-- Lines may reference items that were never imported, so item_id has no
+-- foreign key; DeleteItem checks for references itself.
+CREATE TABLE IF NOT EXISTS order_items (
+    id        BIGSERIAL PRIMARY KEY,
+    order_id  TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    item_id   TEXT NOT NULL,
+    quantity  INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_item ON order_items (item_id);
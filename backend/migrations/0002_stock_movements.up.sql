@@ -0,0 +1,23 @@
+-- Stock movement ledger. The POS adapter records a signed movement here in
+-- the same transaction as every stock change it makes (orders, item updates
+-- and adjustments), so for stock written through it SUM(delta) per item equals
+-- items.stock. Nothing in the database enforces this: stock written by other
+-- means bypasses the ledger, and GetLedgerStock reports the ledger's view.
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id          BIGSERIAL PRIMARY KEY,
+    item_id     TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    order_id    TEXT,
+    delta       INTEGER NOT NULL,
+    reason      TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_item ON stock_movements (item_id, id);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_order ON stock_movements (order_id);
+
+-- Seed the ledger with the stock items already hold
+INSERT INTO stock_movements (item_id, delta, reason)
+SELECT i.id, i.stock, 'opening_balance'
+FROM items i
+WHERE i.stock <> 0
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.item_id = i.id);
@@ -0,0 +1,2 @@
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS oversold;
+ALTER TABLE items DROP COLUMN IF EXISTS oversell_policy;
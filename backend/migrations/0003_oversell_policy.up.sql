@@ -0,0 +1,7 @@
+-- Per-item oversell policy override (reject, allow or flag). NULL means the
+-- deployment default from OVERSELL_POLICY applies.
+ALTER TABLE items ADD COLUMN IF NOT EXISTS oversell_policy TEXT
+    CHECK (oversell_policy IN ('reject', 'allow', 'flag'));
+
+-- Sales recorded under the "flag" policy that took stock below zero
+ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS oversold BOOLEAN NOT NULL DEFAULT FALSE;
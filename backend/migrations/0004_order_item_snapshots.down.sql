@@ -0,0 +1,3 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS discount;
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE order_items DROP COLUMN IF EXISTS unit_price;
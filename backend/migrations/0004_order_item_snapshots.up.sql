@@ -0,0 +1,22 @@
+-- Price and cost captured on every order line at sale time, so later price
+-- changes don't rewrite historical revenue and margin.
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_price DOUBLE PRECISION;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_cost DOUBLE PRECISION;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Back-fill existing lines with the best information we have: the item's
+-- current price and production price
+UPDATE order_items oi
+SET unit_price = i.price,
+    unit_cost = i.production_price
+FROM items i
+WHERE i.id = oi.item_id
+  AND oi.unit_price IS NULL;
+
+UPDATE order_items SET unit_price = 0 WHERE unit_price IS NULL;
+UPDATE order_items SET unit_cost = 0 WHERE unit_cost IS NULL;
+
+ALTER TABLE order_items ALTER COLUMN unit_price SET DEFAULT 0;
+ALTER TABLE order_items ALTER COLUMN unit_price SET NOT NULL;
+ALTER TABLE order_items ALTER COLUMN unit_cost SET DEFAULT 0;
+ALTER TABLE order_items ALTER COLUMN unit_cost SET NOT NULL;
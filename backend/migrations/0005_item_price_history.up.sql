@@ -0,0 +1,17 @@
+-- Timeline of every item price and production price change
+CREATE TABLE IF NOT EXISTS item_price_history (
+    id                BIGSERIAL PRIMARY KEY,
+    item_id           TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    price             DOUBLE PRECISION NOT NULL,
+    production_price  DOUBLE PRECISION NOT NULL,
+    effective_from    TIMESTAMPTZ NOT NULL,
+    recorded_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_price_history_item ON item_price_history (item_id, effective_from);
+
+-- Current prices are assumed to have always applied
+INSERT INTO item_price_history (item_id, price, production_price, effective_from)
+SELECT i.id, i.price, i.production_price, TIMESTAMPTZ '1970-01-01 00:00:00+00'
+FROM items i
+WHERE NOT EXISTS (SELECT 1 FROM item_price_history h WHERE h.item_id = i.id);
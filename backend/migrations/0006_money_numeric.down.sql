@@ -0,0 +1,17 @@
+-- Back to floating point. Amounts that aren't exact binary fractions, such as
+-- 0.10, are rounded to the nearest double again.
+ALTER TABLE item_price_history
+    ALTER COLUMN price TYPE DOUBLE PRECISION USING price::double precision,
+    ALTER COLUMN production_price TYPE DOUBLE PRECISION USING production_price::double precision;
+
+ALTER TABLE order_items
+    ALTER COLUMN unit_price TYPE DOUBLE PRECISION USING unit_price::double precision,
+    ALTER COLUMN unit_cost TYPE DOUBLE PRECISION USING unit_cost::double precision,
+    ALTER COLUMN discount TYPE DOUBLE PRECISION USING discount::double precision;
+
+ALTER TABLE orders
+    ALTER COLUMN total TYPE DOUBLE PRECISION USING total::double precision;
+
+ALTER TABLE items
+    ALTER COLUMN price TYPE DOUBLE PRECISION USING price::double precision,
+    ALTER COLUMN production_price TYPE DOUBLE PRECISION USING production_price::double precision;
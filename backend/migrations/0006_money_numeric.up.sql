@@ -0,0 +1,16 @@
+-- Store money as exact decimals instead of floating point
+ALTER TABLE items
+    ALTER COLUMN price TYPE NUMERIC(14, 2) USING round(price::numeric, 2),
+    ALTER COLUMN production_price TYPE NUMERIC(14, 2) USING round(production_price::numeric, 2);
+
+ALTER TABLE orders
+    ALTER COLUMN total TYPE NUMERIC(14, 2) USING round(total::numeric, 2);
+
+ALTER TABLE order_items
+    ALTER COLUMN unit_price TYPE NUMERIC(14, 2) USING round(unit_price::numeric, 2),
+    ALTER COLUMN unit_cost TYPE NUMERIC(14, 2) USING round(unit_cost::numeric, 2),
+    ALTER COLUMN discount TYPE NUMERIC(14, 2) USING round(discount::numeric, 2);
+
+ALTER TABLE item_price_history
+    ALTER COLUMN price TYPE NUMERIC(14, 2) USING round(price::numeric, 2),
+    ALTER COLUMN production_price TYPE NUMERIC(14, 2) USING round(production_price::numeric, 2);
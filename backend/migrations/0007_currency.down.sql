@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS exchange_rates;
+ALTER TABLE item_price_history DROP COLUMN IF EXISTS currency;
+ALTER TABLE orders DROP COLUMN IF EXISTS currency;
+ALTER TABLE items DROP COLUMN IF EXISTS currency;
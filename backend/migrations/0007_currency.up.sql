@@ -0,0 +1,15 @@
+-- Amounts are stored together with the currency they were recorded in
+ALTER TABLE items ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE item_price_history ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'IDR';
+
+-- Rates used to convert amounts into the business currency for reporting.
+-- One unit of from_currency is worth rate units of to_currency; the inverse
+-- direction is derived.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    from_currency  CHAR(3) NOT NULL,
+    to_currency    CHAR(3) NOT NULL,
+    rate           NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (from_currency, to_currency)
+);
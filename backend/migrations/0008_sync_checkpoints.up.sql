@@ -0,0 +1,11 @@
+-- Progress of the background sync from each remote POS source
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+    source            TEXT PRIMARY KEY,
+    high_water_mark   TIMESTAMPTZ,
+    last_run_at       TIMESTAMPTZ,
+    last_success_at   TIMESTAMPTZ,
+    last_error        TEXT NOT NULL DEFAULT '',
+    items_synced      BIGINT NOT NULL DEFAULT 0,
+    orders_synced     BIGINT NOT NULL DEFAULT 0,
+    conflicts         BIGINT NOT NULL DEFAULT 0
+);
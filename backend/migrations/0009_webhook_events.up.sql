@@ -0,0 +1,16 @@
+-- Inbound POS webhook deliveries, kept to dedupe replays and retry failures
+CREATE TABLE IF NOT EXISTS webhook_events (
+    provider      TEXT NOT NULL,
+    event_id      TEXT NOT NULL,
+    event_type    TEXT NOT NULL,
+    status        TEXT NOT NULL CHECK (status IN ('processing', 'processed', 'failed')),
+    payload       TEXT NOT NULL,
+    attempts      INTEGER NOT NULL DEFAULT 0,
+    last_error    TEXT NOT NULL DEFAULT '',
+    received_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at  TIMESTAMPTZ,
+    PRIMARY KEY (provider, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status ON webhook_events (status, updated_at);
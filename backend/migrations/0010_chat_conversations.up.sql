@@ -0,0 +1,21 @@
+-- Chatbot conversations and their message history
+CREATE TABLE IF NOT EXISTS chat_conversations (
+    id                  TEXT PRIMARY KEY,
+    title               TEXT NOT NULL,
+    summary             TEXT NOT NULL DEFAULT '',
+    summarized_through  BIGINT NOT NULL DEFAULT 0,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_conversations_updated_at ON chat_conversations (updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS chat_messages (
+    id               BIGSERIAL PRIMARY KEY,
+    conversation_id  TEXT NOT NULL REFERENCES chat_conversations (id) ON DELETE CASCADE,
+    role             TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content          TEXT NOT NULL,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_messages_conversation ON chat_messages (conversation_id, id);
@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Load found no migrations")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d is %04d_%s, want versions without gaps", i+1, migration.Version, migration.Name)
+		}
+		// A down script has to undo something
+		for _, line := range strings.Split(migration.Down, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "--") {
+				if strings.EqualFold(strings.TrimSuffix(line, ";"), "SELECT 1") {
+					t.Errorf("%04d_%s has a down script that does nothing", migration.Version, migration.Name)
+				}
+				break
+			}
+		}
+	}
+}
+
+// TestUpDown - Applies every migration, rolls them all back and applies them
+// again on the Postgres database in TEST_DATABASE_DSN, in a schema of its own
+func TestUpDown(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connecting to Postgres: %v", err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("creating schema %s: %v", schema, err)
+	}
+	defer admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+
+	if parsed, err := url.Parse(dsn); err == nil && strings.Contains(dsn, "://") {
+		query := parsed.Query()
+		query.Set("search_path", schema)
+		parsed.RawQuery = query.Encode()
+		dsn = parsed.String()
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("connecting to schema %s: %v", schema, err)
+	}
+	defer db.Close()
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	total := len(migrator.migrations)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != total {
+		t.Fatalf("Up applied %d of %d migrations: %v", len(applied), total, err)
+	}
+
+	// Amounts written as floats before 0006 come out as exact decimals
+	if _, err := migrator.Down(ctx, total-5); err != nil {
+		t.Fatalf("Down to 0005: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO items (id, name, stock, price, production_price) VALUES ('ITEM-1', 'Kopi', 1, 0.1 + 0.2, 1500.125)`); err != nil {
+		t.Fatalf("inserting a float-priced item: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up from 0005: %v", err)
+	}
+	var prices struct {
+		Price           string `db:"price"`
+		ProductionPrice string `db:"production_price"`
+	}
+	if err := db.Get(&prices, `SELECT price::text AS price, production_price::text AS production_price FROM items WHERE id = 'ITEM-1'`); err != nil {
+		t.Fatalf("reading prices: %v", err)
+	}
+	if prices.Price != "0.30" || prices.ProductionPrice != "1500.13" {
+		t.Errorf("prices after 0006 = %s and %s, want 0.30 and 1500.13", prices.Price, prices.ProductionPrice)
+	}
+
+	reverted, err := migrator.Down(ctx, total)
+	if err != nil || len(reverted) != total {
+		t.Fatalf("Down reverted %d of %d migrations: %v", len(reverted), total, err)
+	}
+	var tables int
+	if err := db.Get(&tables, `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = $1 AND table_name <> 'schema_version'`, schema); err != nil {
+		t.Fatalf("counting tables: %v", err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after rolling everything back", tables)
+	}
+
+	if applied, err := migrator.Up(ctx); err != nil || len(applied) != total {
+		t.Fatalf("Up again applied %d of %d migrations: %v", len(applied), total, err)
+	}
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Granularity controls how aggregated sales are grouped.
+type Granularity string
+
+const (
+	GroupByDay   Granularity = "day"
+	GroupByWeek  Granularity = "week"
+	GroupByMonth Granularity = "month"
+	GroupByItem  Granularity = "item"
+)
+
+// AggregateQuery selects orders completed in [Start, End) and groups them.
+// A zero End means "no upper bound". Days, weeks and months are counted in
+// Location (UTC when nil).
+type AggregateQuery struct {
+	Start    time.Time
+	End      time.Time
+	GroupBy  Granularity
+	Location *time.Location
+}
+
+// Zone returns the timezone periods are bucketed in.
+func (q AggregateQuery) Zone() *time.Location {
+	if q.Location == nil {
+		return time.UTC
+	}
+	return q.Location
+}
+
+// AggregateRow is one bucket of an aggregation.
+//
+// For day/week/month grouping Key is the period ("2006-01-02", "2006-W01",
+// "2006-01") and Revenue is the sum of order totals. For item grouping Key is
+// the item ID and Revenue is the sum of line revenues. Costs always come from
+// the price snapshot on each order line; Price is the item's current price.
+//
+// Rows are per currency: a bucket with amounts in two currencies produces two
+// rows with the same Key. Business.ConvertAggregateRows merges them.
+type AggregateRow struct {
+	Key      string `json:"key" db:"key"`
+	Currency string `json:"currency" db:"currency"`
+	ItemName string `json:"item_name,omitempty" db:"item_name"`
+	Price    Money  `json:"price" db:"price"`
+	Orders   int    `json:"orders" db:"orders"`
+	Units    int    `json:"units" db:"units"`
+	Revenue  Money  `json:"revenue" db:"revenue"`
+	Cost     Money  `json:"cost" db:"cost"`
+}
+
+// ApplyCurrency tags the row's amounts with its Currency, for rows scanned
+// from columns that don't carry one.
+func (r *AggregateRow) ApplyCurrency() {
+	r.Revenue = r.Revenue.WithCurrency(r.Currency)
+	r.Cost = r.Cost.WithCurrency(r.Currency)
+}
+
+// SalesAggregator is implemented by adapters that can compute aggregates
+// without loading every order into memory.
+type SalesAggregator interface {
+	AggregateSales(query AggregateQuery) ([]AggregateRow, error)
+}
+
+// PeriodKey returns the bucket key for t at the given granularity, using the
+// wall clock of t's own location.
+func PeriodKey(t time.Time, granularity Granularity) string {
+	switch granularity {
+	case GroupByDay:
+		return t.Format("2006-01-02")
+	case GroupByWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// AggregateOrders is the in-memory fallback for adapters that don't implement
+// SalesAggregator. It follows the same rules as the SQL implementation. Each
+// amount is summed in the row of its own currency, so orders whose lines are
+// in another currency than their total are split rather than mixed.
+func AggregateOrders(orders []Order, inventory []Item, query AggregateQuery) ([]AggregateRow, error) {
+	itemMap := make(map[string]Item)
+	for _, item := range inventory {
+		itemMap[item.ID] = item
+	}
+
+	rowMap := make(map[string]*AggregateRow)
+	var keys []string
+
+	getRow := func(key string, currency string) *AggregateRow {
+		mapKey := key + "\x00" + currency
+		row, exists := rowMap[mapKey]
+		if !exists {
+			row = &AggregateRow{Key: key, Currency: currency, Revenue: Money{Currency: currency}, Cost: Money{Currency: currency}}
+			rowMap[mapKey] = row
+			keys = append(keys, mapKey)
+		}
+		return row
+	}
+	getItemRow := func(itemID string, currency string) *AggregateRow {
+		row := getRow(itemID, currency)
+		row.ItemName = itemID
+		if item, exists := itemMap[itemID]; exists {
+			row.ItemName = item.Name
+			row.Price = item.Price
+		}
+		return row
+	}
+
+	for _, order := range orders {
+		if order.CompletedAt.Before(query.Start) {
+			continue
+		}
+		if !query.End.IsZero() && !order.CompletedAt.Before(query.End) {
+			continue
+		}
+
+		if query.GroupBy == GroupByItem {
+			counted := make(map[string]bool)
+			for _, orderItem := range order.Items {
+				revenue, err := orderItem.Revenue()
+				if err != nil {
+					return nil, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+				row := getItemRow(orderItem.ItemID, revenue.currency())
+				if !counted[row.Key+"\x00"+row.Currency] {
+					row.Orders++
+					counted[row.Key+"\x00"+row.Currency] = true
+				}
+				row.Units += orderItem.Quantity
+				if row.Revenue, err = row.Revenue.Add(revenue); err != nil {
+					return nil, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+				cost := getItemRow(orderItem.ItemID, orderItem.Cost().currency())
+				if cost.Cost, err = cost.Cost.Add(orderItem.Cost()); err != nil {
+					return nil, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+			}
+			continue
+		}
+
+		key := PeriodKey(order.CompletedAt.In(query.Zone()), query.GroupBy)
+		row := getRow(key, order.Total.currency())
+		row.Orders++
+		var err error
+		if row.Revenue, err = row.Revenue.Add(order.Total); err != nil {
+			return nil, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+		for _, orderItem := range order.Items {
+			row.Units += orderItem.Quantity
+			cost := getRow(key, orderItem.Cost().currency())
+			if cost.Cost, err = cost.Cost.Add(orderItem.Cost()); err != nil {
+				return nil, fmt.Errorf("order %s: %w", order.ID, err)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	rows := make([]AggregateRow, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, *rowMap[key])
+	}
+	return rows, nil
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBatchModeUnsupported is returned by adapters that can't honour the
+// requested batch mode, such as an atomic batch against a remote POS.
+var ErrBatchModeUnsupported = errors.New("batch mode not supported by this POS")
+
+// BatchMode chooses what happens to the rest of a batch when a row fails.
+type BatchMode string
+
+const (
+	// BatchPartial writes every row that succeeds. A failed row is rolled
+	// back on its own and leaves nothing behind.
+	BatchPartial BatchMode = "partial"
+	// BatchAtomic writes the whole batch or nothing: a single failed row
+	// rolls back every other row.
+	BatchAtomic BatchMode = "atomic"
+)
+
+// ParseBatchMode parses a batch mode, defaulting to BatchPartial when value
+// is empty.
+func ParseBatchMode(value string) (BatchMode, error) {
+	switch BatchMode(value) {
+	case "", BatchPartial:
+		return BatchPartial, nil
+	case BatchAtomic:
+		return BatchAtomic, nil
+	default:
+		return "", fmt.Errorf("invalid batch mode %q (use partial or atomic)", value)
+	}
+}
+
+// BatchFailureReason classifies why a row of a batch wasn't written.
+type BatchFailureReason string
+
+const (
+	FailureInvalid           BatchFailureReason = "invalid"            // the adapter refused the row, e.g. mixed currencies
+	FailureInsufficientStock BatchFailureReason = "insufficient_stock" // rejected by the oversell policy
+	FailureNotFound          BatchFailureReason = "not_found"          // the row refers to a record that doesn't exist
+	FailureRolledBack        BatchFailureReason = "rolled_back"        // the row was fine but an atomic batch failed elsewhere
+	FailureError             BatchFailureReason = "error"              // any other error, e.g. a database constraint
+)
+
+// FailureReason classifies an error returned for a single row.
+func FailureReason(err error) BatchFailureReason {
+	var stockErr *InsufficientStockError
+	switch {
+	case errors.As(err, &stockErr):
+		return FailureInsufficientStock
+	case errors.Is(err, ErrNotFound):
+		return FailureNotFound
+	case errors.Is(err, ErrInvalid):
+		return FailureInvalid
+	default:
+		return FailureError
+	}
+}
+
+// BatchFailure is a row of a batch that wasn't written. Index is the row's
+// position in the slice passed to the adapter.
+type BatchFailure struct {
+	Index  int                `json:"index"`
+	ID     string             `json:"id"`
+	Reason BatchFailureReason `json:"reason"`
+	Error  string             `json:"error"`
+}
+
+// BatchResult reports what happened to each row of AddItems or AddOrders.
+// Succeeded holds the IDs that were written, in batch order.
+type BatchResult struct {
+	Mode      BatchMode      `json:"mode"`
+	Succeeded []string       `json:"succeeded"`
+	Failed    []BatchFailure `json:"failed,omitempty"`
+}
+
+// OK reports whether every row was written.
+func (r BatchResult) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// Fail records a failed row, classifying its error.
+func (r *BatchResult) Fail(index int, id string, err error) {
+	r.Failed = append(r.Failed, BatchFailure{Index: index, ID: id, Reason: FailureReason(err), Error: err.Error()})
+}
+
+// RollBack marks every succeeded row as rolled back, for an atomic batch that
+// was abandoned. ids are the IDs of the whole batch, so the failures stay in
+// batch order.
+func (r *BatchResult) RollBack(ids []string) {
+	failed := make(map[int]BatchFailure, len(r.Failed))
+	for _, failure := range r.Failed {
+		failed[failure.Index] = failure
+	}
+
+	r.Failed = r.Failed[:0]
+	for i, id := range ids {
+		if failure, exists := failed[i]; exists {
+			r.Failed = append(r.Failed, failure)
+			continue
+		}
+		r.Failed = append(r.Failed, BatchFailure{Index: i, ID: id, Reason: FailureRolledBack, Error: "rolled back because another row in the batch failed"})
+	}
+	r.Succeeded = nil
+}
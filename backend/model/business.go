@@ -0,0 +1,116 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Business holds the tenant-wide reporting settings: the currency reports are
+// expressed in, the rates used to convert amounts recorded in others and the
+// timezone that days and months are counted in.
+type Business struct {
+	Currency string
+	Rates    ExchangeRates
+	Location *time.Location
+}
+
+// NewBusiness returns the settings for a business reporting in currency, in UTC.
+func NewBusiness(currency string, rates ExchangeRates) Business {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Business{Currency: strings.ToUpper(currency), Rates: rates, Location: time.UTC}
+}
+
+// InLocation returns a copy of the settings using another timezone, e.g. for a
+// single request.
+func (b Business) InLocation(location *time.Location) Business {
+	b.Location = location
+	return b
+}
+
+// Zone returns the business timezone, UTC if none is set.
+func (b Business) Zone() *time.Location {
+	if b.Location == nil {
+		return time.UTC
+	}
+	return b.Location
+}
+
+// Now returns the current time in the business timezone.
+func (b Business) Now() time.Time {
+	return time.Now().In(b.Zone())
+}
+
+// StartOfDay returns midnight of the given date in the business timezone.
+func (b Business) StartOfDay(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, b.Zone())
+}
+
+// ParseDate parses a "2006-01-02" date as midnight in the business timezone.
+func (b Business) ParseDate(value string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, b.Zone())
+}
+
+// Zero returns a zero amount in the business currency.
+func (b Business) Zero() Money {
+	return Money{Currency: b.Currency}
+}
+
+// Convert converts an amount into the business currency.
+func (b Business) Convert(amount Money) (Money, error) {
+	return ConvertMoney(amount, b.Currency, b.Rates)
+}
+
+// Format converts an amount into the business currency when possible and
+// formats it for display. Amounts that can't be converted keep their own
+// currency.
+func (b Business) Format(amount Money) string {
+	if converted, err := b.Convert(amount); err == nil {
+		amount = converted
+	}
+	return amount.Format()
+}
+
+// ConvertAggregateRows converts every amount of rows into the business
+// currency and merges rows that only differed by currency.
+func (b Business) ConvertAggregateRows(rows []AggregateRow) ([]AggregateRow, error) {
+	merged := make([]AggregateRow, 0, len(rows))
+	index := make(map[string]int)
+
+	for _, row := range rows {
+		price, err := b.Convert(row.Price)
+		if err != nil {
+			return nil, err
+		}
+		revenue, err := b.Convert(row.Revenue)
+		if err != nil {
+			return nil, err
+		}
+		cost, err := b.Convert(row.Cost)
+		if err != nil {
+			return nil, err
+		}
+
+		if i, exists := index[row.Key]; exists {
+			merged[i].Orders += row.Orders
+			merged[i].Units += row.Units
+			if merged[i].Revenue, err = merged[i].Revenue.Add(revenue); err != nil {
+				return nil, err
+			}
+			if merged[i].Cost, err = merged[i].Cost.Add(cost); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		row.Currency = b.Currency
+		row.Price = price
+		row.Revenue = revenue
+		row.Cost = cost
+		index[row.Key] = len(merged)
+		merged = append(merged, row)
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,117 @@
+package model
+
+import "strings"
+
+// ItemSearcher finds items whose name or ID contains a term, ignoring case,
+// ordered by name. A limit of 0 or less returns every match.
+type ItemSearcher interface {
+	SearchItems(term string, limit int) ([]Item, error)
+}
+
+// SearchItems is the in-memory fallback for adapters that don't implement
+// ItemSearcher. items must already be ordered by name.
+func SearchItems(items []Item, term string, limit int) []Item {
+	term = strings.ToLower(strings.TrimSpace(term))
+
+	matches := make([]Item, 0)
+	for _, item := range items {
+		if limit > 0 && len(matches) == limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(item.Name), term) || strings.Contains(strings.ToLower(item.ID), term) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+// Capabilities lists what a POS adapter supports
+type Capabilities struct {
+	InventoryReader bool `json:"inventory_reader"`
+	OrderReader     bool `json:"order_reader"`
+	InventoryWriter bool `json:"inventory_writer"`
+	OrderWriter     bool `json:"order_writer"`
+	SalesAggregator bool `json:"sales_aggregator"`
+	ItemSearcher    bool `json:"item_searcher"`
+	StockLedger     bool `json:"stock_ledger"`
+	PriceHistory    bool `json:"price_history"`
+	OversellPolicy  bool `json:"oversell_policy"`
+}
+
+// Unwrapper is implemented by adapters that decorate another one, such as a
+// cache. Capabilities the decorator doesn't implement itself are looked up on
+// the adapter it wraps.
+type Unwrapper interface {
+	Unwrap() POSReader
+}
+
+// As finds the first adapter in a chain of decorators that implements T. A
+// decorator must implement every write capability of the adapter it wraps, or
+// writes would bypass it.
+func As[T any](adapter POSReader) (T, bool) {
+	for adapter != nil {
+		if capability, ok := adapter.(T); ok {
+			return capability, true
+		}
+		unwrapper, ok := adapter.(Unwrapper)
+		if !ok {
+			break
+		}
+		adapter = unwrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// CapabilitiesOf reports which capability interfaces adapter implements,
+// itself or through the adapters it decorates
+func CapabilitiesOf(adapter POSReader) Capabilities {
+	_, inventoryReader := As[InventoryReader](adapter)
+	_, orderReader := As[OrderReader](adapter)
+	_, inventoryWriter := As[InventoryWriter](adapter)
+	_, orderWriter := As[OrderWriter](adapter)
+	_, aggregator := As[SalesAggregator](adapter)
+	_, searcher := As[ItemSearcher](adapter)
+	_, ledger := As[StockLedger](adapter)
+	_, history := As[PriceHistory](adapter)
+	_, oversell := As[OversellPolicySetter](adapter)
+
+	return Capabilities{
+		InventoryReader: inventoryReader,
+		OrderReader:     orderReader,
+		InventoryWriter: inventoryWriter,
+		OrderWriter:     orderWriter,
+		SalesAggregator: aggregator,
+		ItemSearcher:    searcher,
+		StockLedger:     ledger,
+		PriceHistory:    history,
+		OversellPolicy:  oversell,
+	}
+}
+
+// Supported returns the JSON names of the supported capabilities
+func (c Capabilities) Supported() []string {
+	flags := []struct {
+		name      string
+		supported bool
+	}{
+		{"inventory_reader", c.InventoryReader},
+		{"order_reader", c.OrderReader},
+		{"inventory_writer", c.InventoryWriter},
+		{"order_writer", c.OrderWriter},
+		{"sales_aggregator", c.SalesAggregator},
+		{"item_searcher", c.ItemSearcher},
+		{"stock_ledger", c.StockLedger},
+		{"price_history", c.PriceHistory},
+		{"oversell_policy", c.OversellPolicy},
+	}
+
+	var names []string
+	for _, flag := range flags {
+		if flag.supported {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// ErrNoExchangeRate is returned when an amount can't be converted because no
+// rate between its currency and the target currency is known.
+var ErrNoExchangeRate = errors.New("no exchange rate")
+
+// ExchangeRates provides the rate to convert one unit of a currency into
+// another. Implementations can be backed by a CSV file, a database table or
+// an external service.
+type ExchangeRates interface {
+	Rate(from, to string) (*big.Rat, error)
+}
+
+// RateTable is an in-memory ExchangeRates. A rate stored for A->B is also used
+// (inverted) for B->A.
+type RateTable struct {
+	rates map[string]*big.Rat
+}
+
+// NewRateTable returns an empty rate table.
+func NewRateTable() *RateTable {
+	return &RateTable{rates: make(map[string]*big.Rat)}
+}
+
+// Set stores the rate of one unit of from expressed in to, e.g.
+// Set("USD", "IDR", "16250") means 1 USD = 16,250 IDR.
+func (t *RateTable) Set(from, to, rate string) error {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || value.Sign() <= 0 {
+		return fmt.Errorf("invalid exchange rate %q for %s->%s", rate, from, to)
+	}
+	t.rates[ratePair(from, to)] = value
+	return nil
+}
+
+// Len returns the number of stored rates.
+func (t *RateTable) Len() int {
+	return len(t.rates)
+}
+
+// Rate implements ExchangeRates.
+func (t *RateTable) Rate(from, to string) (*big.Rat, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	if rate, exists := t.rates[ratePair(from, to)]; exists {
+		return rate, nil
+	}
+	if rate, exists := t.rates[ratePair(to, from)]; exists {
+		return new(big.Rat).Inv(rate), nil
+	}
+	return nil, fmt.Errorf("%w from %s to %s", ErrNoExchangeRate, from, to)
+}
+
+func ratePair(from, to string) string {
+	return strings.ToUpper(strings.TrimSpace(from)) + "/" + strings.ToUpper(strings.TrimSpace(to))
+}
+
+// LoadRateTableCSV reads rates from a CSV with the columns from,to,rate. A
+// header row is optional.
+func LoadRateTableCSV(reader io.Reader) (*RateTable, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+
+	table := NewRateTable()
+	for i, record := range records {
+		if len(record) < 3 {
+			return nil, fmt.Errorf("exchange rates row %d: expected from,to,rate", i+1)
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "from") {
+			continue
+		}
+		if err := table.Set(record[0], record[1], record[2]); err != nil {
+			return nil, fmt.Errorf("exchange rates row %d: %w", i+1, err)
+		}
+	}
+
+	return table, nil
+}
+
+// ConvertMoney converts an amount into another currency, rounding half away
+// from zero to the target currency's minor unit.
+func ConvertMoney(amount Money, to string, rates ExchangeRates) (Money, error) {
+	from := amount.currency()
+	if from == to {
+		return Money{Amount: amount.Amount, Currency: to}, nil
+	}
+	if rates == nil {
+		return Money{}, fmt.Errorf("%w from %s to %s", ErrNoExchangeRate, from, to)
+	}
+
+	rate, err := rates.Rate(from, to)
+	if err != nil {
+		return Money{}, err
+	}
+
+	// minor(to) = minor(from) / 10^exp(from) * rate * 10^exp(to)
+	value := new(big.Rat).SetInt64(amount.Amount)
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetFrac(pow10(CurrencyExponent(to)), pow10(CurrencyExponent(from))))
+
+	return Money{Amount: roundRat(value), Currency: to}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundRat rounds a rational to the nearest integer, half away from zero.
+func roundRat(value *big.Rat) int64 {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(value.Denom()) >= 0 {
+		if value.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	return quotient.Int64()
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound matches every NotFoundError with errors.Is.
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError is returned by adapters when an item or order doesn't exist.
+type NotFoundError struct {
+	Resource string // "item" or "order"
+	ID       string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s with ID %s not found", e.Resource, e.ID)
+}
+
+// Is makes errors.Is(err, ErrNotFound) true for any NotFoundError.
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// ItemInUseError is returned by DeleteItem when orders still reference the item.
+type ItemInUseError struct {
+	ItemID     string
+	OrderCount int
+}
+
+func (e *ItemInUseError) Error() string {
+	return fmt.Sprintf("cannot delete item %s: it is referenced in %d order(s)", e.ItemID, e.OrderCount)
+}
+
+// ErrInvalid matches every ValidationError with errors.Is.
+var ErrInvalid = errors.New("invalid")
+
+// ValidationError is returned by adapters for a record they refuse to store,
+// such as an order whose lines are in a different currency from its total.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Is makes errors.Is(err, ErrInvalid) true for any ValidationError.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalid
+}
@@ -0,0 +1,450 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of an amount without one, such as the zero
+// Money. It is not the business currency: code reading bare numbers from CSV
+// files, JSON bodies or a POS tags them with the business currency itself,
+// e.g. by presetting Money.Currency before decoding.
+const DefaultCurrency = "IDR"
+
+// currencyFormat describes how amounts of a currency are displayed.
+type currencyFormat struct {
+	Symbol    string
+	Space     bool   // put a space between the symbol and the number
+	Thousands string // digit group separator
+	Decimal   string // decimal separator
+	Digits    int    // decimal places shown
+}
+
+// currencyFormats follows the usual local conventions, e.g. "Rp 12.500" for
+// Rupiah, which is written without its (unused) cents.
+var currencyFormats = map[string]currencyFormat{
+	"IDR": {Symbol: "Rp", Space: true, Thousands: ".", Decimal: ",", Digits: 0},
+	"USD": {Symbol: "$", Thousands: ",", Decimal: ".", Digits: 2},
+	"EUR": {Symbol: "€", Thousands: ".", Decimal: ",", Digits: 2},
+	"SGD": {Symbol: "S$", Thousands: ",", Decimal: ".", Digits: 2},
+	"MYR": {Symbol: "RM", Thousands: ",", Decimal: ".", Digits: 2},
+	"JPY": {Symbol: "¥", Thousands: ",", Decimal: ".", Digits: 0},
+	"KRW": {Symbol: "₩", Thousands: ",", Decimal: ".", Digits: 0},
+}
+
+// currencyExponents lists the number of minor-unit digits (ISO 4217) of the
+// currencies we know about. Unknown currencies use two digits.
+var currencyExponents = map[string]int{
+	"IDR": 2,
+	"USD": 2,
+	"EUR": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// CurrencyExponent returns the number of minor-unit digits of a currency.
+func CurrencyExponent(currency string) int {
+	if exponent, exists := currencyExponents[currency]; exists {
+		return exponent
+	}
+	return 2
+}
+
+// Money is an exact amount stored as an integer number of minor units (cents,
+// sen, ...) of its currency.
+//
+// Rounding rule: whenever an operation would produce a fraction of a minor
+// unit (parsing a decimal with more places than the currency has, converting
+// from a float or dividing) the result is rounded half away from zero, so
+// 0.125 USD becomes 0.13 and -0.125 USD becomes -0.13.
+//
+// Money encodes to JSON as a number in major units (12.50) and to the
+// database as a decimal string. When decoding JSON it accepts a number or a
+// decimal string in major units ("12.50"); numbers are read from their
+// literal, never through a float, so they stay exact. Integers are not read
+// as minor units: prices were float major units before Money existed, and
+// API clients and the dashboard still send and expect them.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns an amount given in minor units.
+func NewMoney(minorUnits int64, currency string) Money {
+	return Money{Amount: minorUnits, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "12.5" or "-3.999" in the given
+// currency, rounding extra decimal places half away from zero.
+func ParseMoney(value string, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Money{}, errors.New("empty amount")
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if (whole == "" && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+
+	exponent := CurrencyExponent(currency)
+	roundUp := false
+	if len(fraction) > exponent {
+		roundUp = fraction[exponent] >= '5'
+		fraction = fraction[:exponent]
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", value, err)
+	}
+	if roundUp {
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// MustParseMoney is ParseMoney for constants; it panics on invalid input.
+func MustParseMoney(value string, currency string) Money {
+	m, err := ParseMoney(value, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// MoneyFromFloat converts a float in major units, rounding half away from zero.
+// Only use it for values that are already approximate, such as forecasts.
+func MoneyFromFloat(value float64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	scale := math.Pow10(CurrencyExponent(currency))
+	return Money{Amount: int64(math.Round(value * scale)), Currency: currency}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrCurrencyMismatch is returned when amounts in two currencies are combined
+// without converting one of them first (see ConvertMoney).
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// currencyFor resolves the currency of a binary operation. A zero amount, or
+// one with no currency, takes the currency of the other operand.
+func (m Money) currencyFor(other Money) (string, error) {
+	switch {
+	case m.Currency == "" || (m.Amount == 0 && other.Currency != ""):
+		return other.Currency, nil
+	case other.Currency == "" || other.Amount == 0 || other.Currency == m.Currency:
+		return m.Currency, nil
+	default:
+		return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+}
+
+// Add returns m + other. Amounts in different currencies return
+// ErrCurrencyMismatch.
+func (m Money) Add(other Money) (Money, error) {
+	currency, err := m.currencyFor(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: currency}, nil
+}
+
+// Sub returns m - other. Amounts in different currencies return
+// ErrCurrencyMismatch.
+func (m Money) Sub(other Money) (Money, error) {
+	currency, err := m.currencyFor(other)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: currency}, nil
+}
+
+// Mul returns m multiplied by a whole quantity.
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+// Div returns m divided by n, rounded half away from zero. Dividing by zero
+// returns zero.
+func (m Money) Div(n int64) Money {
+	if n == 0 {
+		return Money{Currency: m.Currency}
+	}
+	quotient, remainder := m.Amount/n, m.Amount%n
+	if remainder < 0 {
+		remainder = -remainder
+	}
+	if absInt64(n) <= 2*remainder {
+		if (m.Amount < 0) != (n < 0) {
+			quotient--
+		} else {
+			quotient++
+		}
+	}
+	return Money{Amount: quotient, Currency: m.Currency}
+}
+
+func absInt64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Cmp compares two amounts and returns -1, 0 or +1. Amounts in different
+// currencies return ErrCurrencyMismatch.
+func (m Money) Cmp(other Money) (int, error) {
+	if _, err := m.currencyFor(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Float64 returns the amount in major units. Use it for ratios and charts,
+// never to do arithmetic that gets stored.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / math.Pow10(CurrencyExponent(m.currency()))
+}
+
+// Ratio returns m / other as a float, or 0 when other is zero.
+func (m Money) Ratio(other Money) float64 {
+	if other.Amount == 0 {
+		return 0
+	}
+	return float64(m.Amount) / float64(other.Amount)
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// String returns the plain decimal form, e.g. "-12.50".
+func (m Money) String() string {
+	exponent := CurrencyExponent(m.currency())
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// Format returns the amount formatted for display in its currency's locale,
+// e.g. "Rp 12.500", "$1,250.00" or "-€3,50". Currencies without a known format
+// are shown as "CHF 12.50".
+func (m Money) Format() string {
+	currency := m.currency()
+	format, exists := currencyFormats[currency]
+	if !exists {
+		return currency + " " + m.String()
+	}
+
+	// Round to the displayed number of decimals
+	exponent := CurrencyExponent(currency)
+	amount := m
+	if format.Digits < exponent {
+		amount = m.Div(int64(math.Pow10(exponent - format.Digits)))
+		exponent = format.Digits
+	}
+
+	sign := ""
+	units := amount.Amount
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	digits := strconv.FormatInt(units, 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-exponent], digits[len(digits)-exponent:]
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	number := grouped.String()
+	if fraction != "" {
+		number += format.Decimal + fraction
+	}
+
+	symbol := format.Symbol
+	if format.Space {
+		symbol += " "
+	}
+	return sign + symbol + number
+}
+
+// CurrencyCode returns the currency of the amount, falling back to DefaultCurrency.
+func (m Money) CurrencyCode() string {
+	return m.currency()
+}
+
+// WithCurrency returns the same amount tagged with currency. It doesn't
+// convert; use ConvertMoney for that.
+func (m Money) WithCurrency(currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: m.Amount, Currency: currency}
+}
+
+// MarshalJSON encodes the amount as a number in major units, written exactly
+// as String does.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a number (12.5) or a decimal string ("12.50") in major
+// units.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(value, currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	if string(data) == "null" {
+		return nil
+	}
+
+	literal := string(data)
+	if strings.ContainsAny(literal, "eE") {
+		// Large or tiny numbers may be written with an exponent
+		parsed, err := strconv.ParseFloat(literal, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %s", data)
+		}
+		*m = MoneyFromFloat(parsed, currency)
+		return nil
+	}
+	parsed, err := ParseMoney(literal, currency)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s: use a number or a decimal string in major units", data)
+	}
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount in a NUMERIC column.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads a NUMERIC (or integer/float) column. The currency is not part of
+// the column, so the default currency is assumed unless already set.
+func (m *Money) Scan(src interface{}) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	switch value := src.(type) {
+	case nil:
+		*m = Money{Currency: currency}
+	case []byte:
+		return m.scanString(string(value), currency)
+	case string:
+		return m.scanString(value, currency)
+	case int64:
+		*m = Money{Amount: value * int64(math.Pow10(CurrencyExponent(currency))), Currency: currency}
+	case float64:
+		*m = MoneyFromFloat(value, currency)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanString(value string, currency string) error {
+	parsed, err := ParseMoney(value, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
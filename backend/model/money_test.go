@@ -0,0 +1,205 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{value: "12.5", currency: "USD", want: NewMoney(1250, "USD")},
+		{value: "12", currency: "USD", want: NewMoney(1200, "USD")},
+		{value: ".5", currency: "USD", want: NewMoney(50, "USD")},
+		{value: "+3.10", currency: "USD", want: NewMoney(310, "USD")},
+		{value: " 7.00 ", currency: "USD", want: NewMoney(700, "USD")},
+		{value: "15000", currency: "", want: NewMoney(1500000, DefaultCurrency)},
+
+		// Extra places round half away from zero
+		{value: "0.125", currency: "USD", want: NewMoney(13, "USD")},
+		{value: "-0.125", currency: "USD", want: NewMoney(-13, "USD")},
+		{value: "0.124", currency: "USD", want: NewMoney(12, "USD")},
+		{value: "-3.999", currency: "USD", want: NewMoney(-400, "USD")},
+		{value: "1500.5", currency: "JPY", want: NewMoney(1501, "JPY")},
+		{value: "1500.4", currency: "JPY", want: NewMoney(1500, "JPY")},
+
+		{value: "", currency: "USD", wantErr: true},
+		{value: "-", currency: "USD", wantErr: true},
+		{value: ".", currency: "USD", wantErr: true},
+		{value: "1,5", currency: "USD", wantErr: true},
+		{value: "1.2.3", currency: "USD", wantErr: true},
+		{value: "1e3", currency: "USD", wantErr: true},
+		{value: "abc", currency: "USD", wantErr: true},
+		{value: "99999999999999999999", currency: "USD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %q) = %v, want an error", tt.value, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %q) returned error: %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %#v, want %#v", tt.value, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyFromFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		currency string
+		want     Money
+	}{
+		{value: 12.5, currency: "USD", want: NewMoney(1250, "USD")},
+		{value: 0.125, currency: "USD", want: NewMoney(13, "USD")},
+		{value: -0.125, currency: "USD", want: NewMoney(-13, "USD")},
+		{value: 2.5, currency: "JPY", want: NewMoney(3, "JPY")},
+	}
+
+	for _, tt := range tests {
+		if got := MoneyFromFloat(tt.value, tt.currency); got != tt.want {
+			t.Errorf("MoneyFromFloat(%v, %q) = %#v, want %#v", tt.value, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyDiv(t *testing.T) {
+	tests := []struct {
+		amount int64
+		n      int64
+		want   int64
+	}{
+		{amount: 100, n: 4, want: 25},
+		{amount: 10, n: 4, want: 3},   // 2.5 rounds up
+		{amount: -10, n: 4, want: -3}, // -2.5 rounds down
+		{amount: 10, n: -4, want: -3},
+		{amount: 9, n: 4, want: 2},  // 2.25
+		{amount: 11, n: 4, want: 3}, // 2.75
+		{amount: 10, n: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		got := NewMoney(tt.amount, "USD").Div(tt.n)
+		if got != NewMoney(tt.want, "USD") {
+			t.Errorf("%d.Div(%d) = %d, want %d", tt.amount, tt.n, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMoneyArithmeticCurrencies(t *testing.T) {
+	usd := NewMoney(500, "USD")
+	tests := []struct {
+		name    string
+		a, b    Money
+		want    Money
+		wantErr bool
+	}{
+		{name: "same currency", a: usd, b: NewMoney(250, "USD"), want: NewMoney(750, "USD")},
+		{name: "zero takes the other currency", a: NewMoney(0, "IDR"), b: usd, want: usd},
+		{name: "untagged takes the other currency", a: Money{Amount: 100}, b: usd, want: NewMoney(600, "USD")},
+		{name: "adding zero of another currency", a: usd, b: NewMoney(0, "IDR"), want: usd},
+		{name: "different currencies", a: usd, b: NewMoney(100, "IDR"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.a.Add(tt.b)
+		if tt.wantErr {
+			if !errors.Is(err, ErrCurrencyMismatch) {
+				t.Errorf("%s: Add returned %v, %v, want ErrCurrencyMismatch", tt.name, got, err)
+			}
+			if _, err := tt.a.Cmp(tt.b); !errors.Is(err, ErrCurrencyMismatch) {
+				t.Errorf("%s: Cmp returned %v, want ErrCurrencyMismatch", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Add returned error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: Add = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: NewMoney(1250, "USD"), want: "12.50"},
+		{money: NewMoney(-5, "USD"), want: "-0.05"},
+		{money: NewMoney(1500, "JPY"), want: "1500"},
+		{money: NewMoney(1500000, "IDR"), want: "15000.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		json     string
+		currency string // preset before decoding
+		want     Money
+		wantErr  bool
+	}{
+		{json: `12.5`, currency: "USD", want: NewMoney(1250, "USD")},
+		{json: `"12.50"`, currency: "USD", want: NewMoney(1250, "USD")},
+		{json: `15000`, want: NewMoney(1500000, DefaultCurrency)},
+		{json: `1500`, currency: "JPY", want: NewMoney(1500, "JPY")},
+		{json: `1.5e3`, currency: "USD", want: NewMoney(150000, "USD")},
+		{json: `0.125`, currency: "USD", want: NewMoney(13, "USD")},
+		{json: `null`, currency: "USD", want: Money{Currency: "USD"}},
+		{json: `"1,5"`, currency: "USD", wantErr: true},
+		{json: `true`, currency: "USD", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got := Money{Currency: tt.currency}
+		err := json.Unmarshal([]byte(tt.json), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("decoding %s = %#v, want an error", tt.json, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("decoding %s returned error: %v", tt.json, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("decoding %s = %#v, want %#v", tt.json, got, tt.want)
+		}
+	}
+
+	// Amounts encode as numbers in major units and decode back unchanged
+	for _, money := range []Money{NewMoney(1250, "USD"), NewMoney(-5, "USD"), NewMoney(1500, "JPY")} {
+		data, err := json.Marshal(money)
+		if err != nil {
+			t.Fatalf("encoding %#v: %v", money, err)
+		}
+		decoded := Money{Currency: money.Currency}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("decoding %s: %v", data, err)
+		}
+		if decoded != money {
+			t.Errorf("%#v encoded as %s and decoded as %#v", money, data, decoded)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OrderSortField is a column orders can be sorted (and paginated) by.
+type OrderSortField string
+
+const (
+	SortByCompletedAt OrderSortField = "completed_at"
+	SortByTotal       OrderSortField = "total"
+	SortByID          OrderSortField = "id"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor can't be decoded or
+// doesn't belong to the query it was passed to.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderQuery selects completed orders in [Start, End). Zero times and nil
+// totals mean "unbounded"; a Limit <= 0 returns every matching order.
+//
+// Every adapter sorts orders the same way: by the sort column, then by ID.
+// Totals are sorted by currency code first and then by amount, since amounts
+// in different currencies can't be compared. Strings compare byte by byte.
+type OrderQuery struct {
+	Start      time.Time
+	End        time.Time
+	MinTotal   *Money
+	MaxTotal   *Money
+	ItemID     string
+	SortBy     OrderSortField
+	Descending bool
+	Limit      int
+	Cursor     string
+}
+
+// OrderPage is one page of an OrderQuery. NextCursor is empty on the last page.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// OrderCursor is the keyset position after the last order of a page: the
+// value of the sort column plus the order ID as a tie-breaker. Filter is a
+// fingerprint of the query's filters, so the cursor can't be reused with
+// different ones.
+type OrderCursor struct {
+	SortBy      OrderSortField
+	Descending  bool
+	Filter      string
+	CompletedAt time.Time
+	Total       Money
+	ID          string
+}
+
+// orderCursorJSON is the encoded form of an OrderCursor. The total is kept in
+// minor units with its currency so that it decodes back exactly.
+type orderCursorJSON struct {
+	SortBy      OrderSortField `json:"s"`
+	Descending  bool           `json:"d"`
+	Filter      string         `json:"f"`
+	CompletedAt time.Time      `json:"c,omitempty"`
+	Total       int64          `json:"t"`
+	Currency    string         `json:"u,omitempty"`
+	ID          string         `json:"i"`
+}
+
+// NewOrderCursor builds the cursor pointing just after order.
+func NewOrderCursor(query OrderQuery, order Order) OrderCursor {
+	return OrderCursor{
+		SortBy:      query.SortBy,
+		Descending:  query.Descending,
+		Filter:      query.filterFingerprint(),
+		CompletedAt: order.CompletedAt,
+		Total:       order.Total,
+		ID:          order.ID,
+	}
+}
+
+// Encode returns the opaque string form handed to API clients.
+func (c OrderCursor) Encode() string {
+	data, _ := json.Marshal(orderCursorJSON{
+		SortBy:      c.SortBy,
+		Descending:  c.Descending,
+		Filter:      c.Filter,
+		CompletedAt: c.CompletedAt,
+		Total:       c.Total.Amount,
+		Currency:    c.Total.Currency,
+		ID:          c.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeOrderCursor parses a cursor and checks it matches the query's sort
+// order and filters.
+func DecodeOrderCursor(query OrderQuery) (*OrderCursor, error) {
+	if query.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	var encoded orderCursorJSON
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	cursor := OrderCursor{
+		SortBy:      encoded.SortBy,
+		Descending:  encoded.Descending,
+		Filter:      encoded.Filter,
+		CompletedAt: encoded.CompletedAt,
+		Total:       NewMoney(encoded.Total, encoded.Currency),
+		ID:          encoded.ID,
+	}
+
+	if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+		return nil, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+	}
+	if cursor.Filter != query.filterFingerprint() {
+		return nil, fmt.Errorf("%w: cursor was issued for different filters", ErrInvalidCursor)
+	}
+
+	return &cursor, nil
+}
+
+// filterFingerprint - A short hash of the filters of the query. The limit is
+// left out so that clients may change the page size between pages.
+func (q OrderQuery) filterFingerprint() string {
+	bound := func(total *Money) string {
+		if total == nil {
+			return ""
+		}
+		return fmt.Sprintf("%d %s", total.Amount, total.Currency)
+	}
+	instant := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%q",
+		instant(q.Start), instant(q.End), bound(q.MinTotal), bound(q.MaxTotal), q.ItemID)))
+	return base64.RawURLEncoding.EncodeToString(sum[:9])
+}
+
+// Normalize fills in the default sort order, newest first, when none is set.
+// An unknown sort column is a ValidationError.
+func (q OrderQuery) Normalize() (OrderQuery, error) {
+	switch q.SortBy {
+	case SortByCompletedAt, SortByTotal, SortByID:
+	case "":
+		q.SortBy = SortByCompletedAt
+		q.Descending = true
+	default:
+		return q, &ValidationError{Message: fmt.Sprintf("unknown sort field %q (use completed_at, total or id)", q.SortBy)}
+	}
+	return q, nil
+}
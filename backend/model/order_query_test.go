@@ -0,0 +1,114 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOrderCursorRoundTrip(t *testing.T) {
+	completedAt := time.Date(2024, 3, 1, 10, 30, 0, 123456789, time.UTC)
+	tests := []struct {
+		name  string
+		query OrderQuery
+		order Order
+	}{
+		{
+			name:  "newest first",
+			query: OrderQuery{SortBy: SortByCompletedAt, Descending: true},
+			order: Order{ID: "ORD-1", Total: NewMoney(1250, "USD"), CompletedAt: completedAt},
+		},
+		{
+			name:  "by total in a currency without cents",
+			query: OrderQuery{SortBy: SortByTotal},
+			order: Order{ID: "ORD-2", Total: NewMoney(1500, "JPY"), CompletedAt: completedAt},
+		},
+		{
+			name:  "by id",
+			query: OrderQuery{SortBy: SortByID, Descending: true},
+			order: Order{ID: "ORD-3", Total: NewMoney(-5, "IDR")},
+		},
+	}
+
+	for _, tt := range tests {
+		cursor := NewOrderCursor(tt.query, tt.order)
+		tt.query.Cursor = cursor.Encode()
+
+		decoded, err := DecodeOrderCursor(tt.query)
+		if err != nil {
+			t.Errorf("%s: DecodeOrderCursor returned error: %v", tt.name, err)
+			continue
+		}
+		if decoded.SortBy != cursor.SortBy || decoded.Descending != cursor.Descending || decoded.ID != cursor.ID ||
+			decoded.Total != cursor.Total || !decoded.CompletedAt.Equal(cursor.CompletedAt) {
+			t.Errorf("%s: decoded %+v, want %+v", tt.name, *decoded, cursor)
+		}
+	}
+}
+
+func TestDecodeOrderCursorRejects(t *testing.T) {
+	issued := OrderQuery{SortBy: SortByTotal, Descending: true}
+	cursor := NewOrderCursor(issued, Order{ID: "ORD-1", Total: NewMoney(100, "USD")}).Encode()
+	minTotal := NewMoney(50, "USD")
+
+	tests := []struct {
+		name  string
+		query OrderQuery
+	}{
+		{name: "other column", query: OrderQuery{SortBy: SortByCompletedAt, Descending: true, Cursor: cursor}},
+		{name: "other direction", query: OrderQuery{SortBy: SortByTotal, Cursor: cursor}},
+		{name: "not base64", query: OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: "%%%"}},
+		{name: "not JSON", query: OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: base64.RawURLEncoding.EncodeToString([]byte("nope"))}},
+		{name: "other start", query: OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: cursor, Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{name: "other item", query: OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: cursor, ItemID: "A"}},
+		{name: "other minimum", query: OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: cursor, MinTotal: &minTotal}},
+	}
+
+	for _, tt := range tests {
+		if _, err := DecodeOrderCursor(tt.query); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: DecodeOrderCursor returned %v, want ErrInvalidCursor", tt.name, err)
+		}
+	}
+
+	if decoded, err := DecodeOrderCursor(OrderQuery{}); decoded != nil || err != nil {
+		t.Errorf("DecodeOrderCursor without a cursor = %v, %v, want nil, nil", decoded, err)
+	}
+
+	// The page size may change between pages
+	if _, err := DecodeOrderCursor(OrderQuery{SortBy: SortByTotal, Descending: true, Cursor: cursor, Limit: 50}); err != nil {
+		t.Errorf("DecodeOrderCursor with another limit returned error: %v", err)
+	}
+}
+
+func TestOrderQueryNormalize(t *testing.T) {
+	tests := []struct {
+		query          OrderQuery
+		wantSortBy     OrderSortField
+		wantDescending bool
+		wantErr        bool
+	}{
+		{query: OrderQuery{}, wantSortBy: SortByCompletedAt, wantDescending: true},
+		{query: OrderQuery{SortBy: SortByTotal}, wantSortBy: SortByTotal, wantDescending: false},
+		{query: OrderQuery{SortBy: SortByID, Descending: true}, wantSortBy: SortByID, wantDescending: true},
+		{query: OrderQuery{SortBy: "price"}, wantErr: true},
+		{query: OrderQuery{SortBy: "Total"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.query.Normalize()
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalid) {
+				t.Errorf("Normalize(%q) returned %v, want ErrInvalid", tt.query.SortBy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Normalize(%q) returned error: %v", tt.query.SortBy, err)
+			continue
+		}
+		if got.SortBy != tt.wantSortBy || got.Descending != tt.wantDescending {
+			t.Errorf("Normalize(%q, %v) = %q, %v, want %q, %v", tt.query.SortBy, tt.query.Descending, got.SortBy, got.Descending, tt.wantSortBy, tt.wantDescending)
+		}
+	}
+}
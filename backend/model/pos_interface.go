@@ -1,34 +1,152 @@
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
 
 type Item struct {
-	ID              string  `json:"id"`
-	Name            string  `json:"name"`
-	Stock           int     `json:"stock"`
-	Price           float64 `json:"price"`
-	ProductionPrice float64 `json:"production_price"`
+	ID              string         `json:"id"`
+	Name            string         `json:"name"`
+	Stock           int            `json:"stock"`
+	Price           Money          `json:"price"`
+	ProductionPrice Money          `json:"production_price"`
+	OversellPolicy  OversellPolicy `json:"oversell_policy,omitempty"`
 }
 
 type Order struct {
 	ID          string      `json:"id"`
 	Items       []OrderItem `json:"items"`
-	Total       float64     `json:"total"`
+	Total       Money       `json:"total"`
 	CompletedAt time.Time   `json:"completed_at"`
 }
 
+// OrderItem is one order line. UnitPrice, UnitCost and Discount are a
+// snapshot taken when the order was recorded; analytics must use them rather
+// than the item's current price.
 type OrderItem struct {
-	ItemID   string `json:"item_id"`
-	Quantity int    `json:"quantity"`
+	ItemID    string `json:"item_id"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unit_price"`
+	UnitCost  Money  `json:"unit_cost"`
+	Discount  Money  `json:"discount"`
 }
 
-type POSAdapter interface {
+// Revenue is the amount charged for the line after its discount. A discount
+// in another currency than the price returns ErrCurrencyMismatch.
+func (l OrderItem) Revenue() (Money, error) {
+	return l.UnitPrice.Mul(int64(l.Quantity)).Sub(l.Discount)
+}
+
+// Cost is the production cost of the line.
+func (l OrderItem) Cost() Money {
+	return l.UnitCost.Mul(int64(l.Quantity))
+}
+
+// HasSnapshot reports whether the line already carries a price snapshot.
+func (l OrderItem) HasSnapshot() bool {
+	return !l.UnitPrice.IsZero() || !l.UnitCost.IsZero()
+}
+
+// UnmarshalJSON reads the lines' amounts in the currency of the total, which
+// can be preset before decoding like that of any Money.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plainOrder Order
+	var decoded struct {
+		plainOrder
+		Items json.RawMessage `json:"items"`
+	}
+	decoded.Total = Money{Currency: o.Total.Currency}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	items, err := DecodeOrderItems(decoded.Items, decoded.Total.Currency)
+	if err != nil {
+		return err
+	}
+
+	*o = Order(decoded.plainOrder)
+	o.Items = items
+	return nil
+}
+
+// DecodeOrderItems decodes a JSON array of order lines, reading amounts
+// without a currency in currency.
+func DecodeOrderItems(data []byte, currency string) ([]OrderItem, error) {
+	var lines []json.RawMessage
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &lines); err != nil {
+			return nil, errors.New("items must be an array of order lines")
+		}
+	}
+	if lines == nil {
+		return nil, nil
+	}
+
+	items := make([]OrderItem, len(lines))
+	for i, line := range lines {
+		items[i] = OrderItem{
+			UnitPrice: Money{Currency: currency},
+			UnitCost:  Money{Currency: currency},
+			Discount:  Money{Currency: currency},
+		}
+		if err := json.Unmarshal(line, &items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// InventoryReader reads the item catalogue. Looking up a missing item
+// returns a *NotFoundError.
+type InventoryReader interface {
 	GetInventory() ([]Item, error)
+	GetItemByID(itemID string) (*Item, error)
+	CheckItemExists(itemID string) (bool, error)
+}
+
+// OrderReader reads completed orders. Looking up a missing order returns a
+// *NotFoundError.
+type OrderReader interface {
 	GetCompletedOrders(since time.Time) ([]Order, error)
+	QueryOrders(query OrderQuery) (OrderPage, error)
+	GetOrderByID(orderID string) (*Order, error)
+	CheckOrderExists(orderID string) (bool, error)
+}
+
+// InventoryWriter creates, updates and deletes items. Deleting a missing item
+// returns a *NotFoundError and deleting one still referenced by orders an
+// *ItemInUseError. AddItems reports each row in its BatchResult and only
+// returns an error when the batch as a whole couldn't be written.
+type InventoryWriter interface {
 	AddItem(item Item) error
-	AddItems(items []Item) error
+	AddItems(items []Item, mode BatchMode) (BatchResult, error)
 	UpdateItem(item Item) error
 	DeleteItem(itemID string) error
+}
+
+// OrderWriter records and deletes orders. Recording an order that takes more
+// than the available stock may return an *InsufficientStockError. AddOrders
+// reports each row in its BatchResult like AddItems.
+type OrderWriter interface {
 	AddOrder(order Order) error
-	AddOrders(orders []Order) error
+	AddOrders(orders []Order, mode BatchMode) (BatchResult, error)
+	DeleteOrder(orderID string) error
+}
+
+// POSReader is what every point-of-sale backend implements. Writes, search,
+// aggregation, the stock ledger and price history are optional capabilities
+// detected with As; see CapabilitiesOf.
+type POSReader interface {
+	InventoryReader
+	OrderReader
+}
+
+// POSAdapter is a backend that can also be written to
+type POSAdapter interface {
+	POSReader
+	InventoryWriter
+	OrderWriter
 }
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// ItemPrice is one entry of an item's price timeline. The price is valid from
+// EffectiveFrom until the EffectiveFrom of the next entry.
+type ItemPrice struct {
+	ID              int64     `json:"id" db:"id"`
+	ItemID          string    `json:"item_id" db:"item_id"`
+	Price           Money     `json:"price" db:"price"`
+	ProductionPrice Money     `json:"production_price" db:"production_price"`
+	EffectiveFrom   time.Time `json:"effective_from" db:"effective_from"`
+	RecordedAt      time.Time `json:"recorded_at" db:"recorded_at"`
+}
+
+// PriceHistory is implemented by adapters that record every price change.
+type PriceHistory interface {
+	// GetItemPriceHistory returns the timeline of an item, oldest first.
+	GetItemPriceHistory(itemID string) ([]ItemPrice, error)
+	// GetItemPriceAsOf returns the price valid at the given time, or nil if
+	// the item has no price recorded before it.
+	GetItemPriceAsOf(itemID string, at time.Time) (*ItemPrice, error)
+}
+
+// PriceAsOf picks the entry valid at the given time from a timeline sorted
+// oldest first, or nil if the timeline starts after it.
+func PriceAsOf(history []ItemPrice, at time.Time) *ItemPrice {
+	var current *ItemPrice
+	for i := range history {
+		if history[i].EffectiveFrom.After(at) {
+			break
+		}
+		current = &history[i]
+	}
+	return current
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reasons recorded on stock movements.
+const (
+	StockReasonOpeningBalance = "opening_balance"
+	StockReasonAdjustment     = "adjustment"
+	StockReasonSale           = "sale"
+	StockReasonOrderReversal  = "order_reversal"
+)
+
+// StockMovement is one signed entry of the stock ledger. Adapters record a
+// movement with every stock change they make, so summing Delta over every
+// movement of an item gives its current stock unless the stock was changed
+// outside the adapter.
+type StockMovement struct {
+	ID        int64     `json:"id" db:"id"`
+	ItemID    string    `json:"item_id" db:"item_id"`
+	OrderID   *string   `json:"order_id,omitempty" db:"order_id"`
+	Delta     int       `json:"delta" db:"delta"`
+	Reason    string    `json:"reason" db:"reason"`
+	Oversold  bool      `json:"oversold,omitempty" db:"oversold"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// StockLedger is implemented by adapters that keep a stock movement history.
+type StockLedger interface {
+	GetStockMovements(itemID string) ([]StockMovement, error)
+	GetLedgerStock(itemID string) (int, error)
+}
+
+// OversellPolicy decides what happens when an order takes more units of an
+// item than it has in stock.
+type OversellPolicy string
+
+const (
+	// OversellReject refuses the whole order.
+	OversellReject OversellPolicy = "reject"
+	// OversellAllow records the order and lets stock go negative.
+	OversellAllow OversellPolicy = "allow"
+	// OversellFlag records the order like OversellAllow but marks the
+	// offending stock movements as oversold.
+	OversellFlag OversellPolicy = "flag"
+)
+
+// ParseOversellPolicy validates a policy name. The empty string is accepted
+// and means "use the deployment default".
+func ParseOversellPolicy(value string) (OversellPolicy, error) {
+	switch policy := OversellPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "", OversellReject, OversellAllow, OversellFlag:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid oversell policy %q (use reject, allow or flag)", value)
+	}
+}
+
+// InsufficientStockError is returned when an order is rejected by the
+// oversell policy.
+type InsufficientStockError struct {
+	OrderID   string
+	ItemID    string
+	Requested int
+	Available int
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for item %s in order %s: requested %d, available %d",
+		e.ItemID, e.OrderID, e.Requested, e.Available)
+}
+
+// OversellPolicySetter is implemented by adapters that enforce an oversell
+// policy when recording orders.
+type OversellPolicySetter interface {
+	SetOversellPolicy(policy OversellPolicy)
+}
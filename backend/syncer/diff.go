@@ -0,0 +1,112 @@
+package syncer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// itemChanges - Lists the fields where the local copy of an item differs from
+// the source. An empty list means the item is already in sync.
+func itemChanges(local, remote model.Item) []string {
+	var changes []string
+	if local.Name != remote.Name {
+		changes = append(changes, fmt.Sprintf("name %q -> %q", local.Name, remote.Name))
+	}
+	if local.Stock != remote.Stock {
+		changes = append(changes, fmt.Sprintf("stock %d -> %d", local.Stock, remote.Stock))
+	}
+	if !sameMoney(local.Price, remote.Price) {
+		changes = append(changes, fmt.Sprintf("price %s -> %s", local.Price.Format(), remote.Price.Format()))
+	}
+	if !sameMoney(local.ProductionPrice, remote.ProductionPrice) {
+		changes = append(changes, fmt.Sprintf("production price %s -> %s", local.ProductionPrice.Format(), remote.ProductionPrice.Format()))
+	}
+	return changes
+}
+
+// orderChanges - Lists the fields where the local copy of an order differs
+// from the source. Price snapshots the source doesn't provide are filled in
+// locally when the order is written, so they aren't compared.
+func orderChanges(local, remote model.Order) []string {
+	var changes []string
+	// Postgres keeps microseconds
+	if !local.CompletedAt.Truncate(time.Microsecond).Equal(remote.CompletedAt.Truncate(time.Microsecond)) {
+		changes = append(changes, fmt.Sprintf("completed_at %s -> %s", local.CompletedAt.Format(time.RFC3339), remote.CompletedAt.Format(time.RFC3339)))
+	}
+	if !sameMoney(local.Total, remote.Total) {
+		changes = append(changes, fmt.Sprintf("total %s -> %s", local.Total.Format(), remote.Total.Format()))
+	}
+
+	localLines := linesByItem(local.Items)
+	remoteLines := linesByItem(remote.Items)
+	itemIDs := make([]string, 0, len(localLines)+len(remoteLines))
+	for itemID := range localLines {
+		itemIDs = append(itemIDs, itemID)
+	}
+	for itemID := range remoteLines {
+		if _, exists := localLines[itemID]; !exists {
+			itemIDs = append(itemIDs, itemID)
+		}
+	}
+	sort.Strings(itemIDs)
+
+	for _, itemID := range itemIDs {
+		localLine, inLocal := localLines[itemID]
+		remoteLine, inRemote := remoteLines[itemID]
+		switch {
+		case !inLocal:
+			changes = append(changes, fmt.Sprintf("line %s added", itemID))
+		case !inRemote:
+			changes = append(changes, fmt.Sprintf("line %s removed", itemID))
+		default:
+			if localLine.Quantity != remoteLine.Quantity {
+				changes = append(changes, fmt.Sprintf("line %s quantity %d -> %d", itemID, localLine.Quantity, remoteLine.Quantity))
+			}
+			if !remoteLine.UnitPrice.IsZero() && !sameMoney(localLine.UnitPrice, remoteLine.UnitPrice) {
+				changes = append(changes, fmt.Sprintf("line %s unit price %s -> %s", itemID, localLine.UnitPrice.Format(), remoteLine.UnitPrice.Format()))
+			}
+			if !remoteLine.UnitCost.IsZero() && !sameMoney(localLine.UnitCost, remoteLine.UnitCost) {
+				changes = append(changes, fmt.Sprintf("line %s unit cost %s -> %s", itemID, localLine.UnitCost.Format(), remoteLine.UnitCost.Format()))
+			}
+			if !sameMoney(localLine.Discount, remoteLine.Discount) {
+				changes = append(changes, fmt.Sprintf("line %s discount %s -> %s", itemID, localLine.Discount.Format(), remoteLine.Discount.Format()))
+			}
+		}
+	}
+
+	return changes
+}
+
+// linesByItem - Indexes order lines by item, merging repeated items
+func linesByItem(lines []model.OrderItem) map[string]model.OrderItem {
+	byItem := make(map[string]model.OrderItem, len(lines))
+	for _, line := range lines {
+		key := line.ItemID
+		if existing, exists := byItem[key]; exists {
+			discount, err := existing.Discount.Add(line.Discount)
+			if err != nil {
+				// A repeat with a discount in another currency stays apart and
+				// shows up as a change
+				byItem[key+" ("+line.Discount.CurrencyCode()+")"] = line
+				continue
+			}
+			existing.Quantity += line.Quantity
+			existing.Discount = discount
+			line = existing
+		}
+		byItem[key] = line
+	}
+	return byItem
+}
+
+// sameMoney - Compares amounts including their currency; zero amounts are
+// equal whatever their currency
+func sameMoney(a, b model.Money) bool {
+	if a.IsZero() && b.IsZero() {
+		return true
+	}
+	return a.Amount == b.Amount && a.CurrencyCode() == b.CurrencyCode()
+}
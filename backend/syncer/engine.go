@@ -0,0 +1,449 @@
+// Package syncer mirrors a remote POS into the local database in the
+// background, so the analytics endpoints read local data instead of calling
+// the remote POS on every request.
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// maxRecentConflicts is how many conflicts Status keeps for inspection
+const maxRecentConflicts = 50
+
+// Options tune an Engine. Zero fields get the defaults listed below.
+type Options struct {
+	Interval  time.Duration // between runs (default 5m)
+	Overlap   time.Duration // how far before the high-water mark each run re-reads, to catch late or edited orders
+	BatchSize int           // orders written per AddOrders call (default 500)
+}
+
+// withDefaults fills in the zero fields of the options
+func (o Options) withDefaults() Options {
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Minute
+	}
+	if o.Overlap < 0 {
+		o.Overlap = 0
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 500
+	}
+	return o
+}
+
+// Conflict is a record whose local copy differed from the source. The source
+// always wins; the conflict records what was overwritten.
+type Conflict struct {
+	Resource string    `json:"resource"` // "item" or "order"
+	ID       string    `json:"id"`
+	Changes  []string  `json:"changes"`
+	At       time.Time `json:"at"`
+}
+
+// RunStats summarizes one sync run
+type RunStats struct {
+	StartedAt     time.Time `json:"started_at"`
+	Duration      string    `json:"duration"`
+	OrdersFetched int       `json:"orders_fetched"`
+	OrdersAdded   int       `json:"orders_added"`
+	OrdersUpdated int       `json:"orders_updated"`
+	OrdersFailed  int       `json:"orders_failed"`
+	ItemsFetched  int       `json:"items_fetched"`
+	ItemsAdded    int       `json:"items_added"`
+	ItemsUpdated  int       `json:"items_updated"`
+	ItemsFailed   int       `json:"items_failed"`
+	Conflicts     int       `json:"conflicts"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Status is the state of an engine as reported by /sync/status. LagSeconds is
+// the time since the last successful run (nil before the first one).
+type Status struct {
+	Source              string     `json:"source"`
+	Running             bool       `json:"running"`
+	Interval            string     `json:"interval"`
+	NextRunAt           *time.Time `json:"next_run_at,omitempty"`
+	LagSeconds          *float64   `json:"lag_seconds"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Checkpoint          Checkpoint `json:"checkpoint"`
+	LastRun             *RunStats  `json:"last_run,omitempty"`
+	RecentConflicts     []Conflict `json:"recent_conflicts"`
+}
+
+// Engine copies inventory and completed orders from a source adapter into a
+// local adapter. Each run re-reads orders from shortly before the source's
+// high-water mark, writes the new and changed ones in completion order with
+// AddOrders, then upserts changed items with AddItems. Orders are written
+// before items so that sales become stock movements and the item upsert only
+// records a genuine stock difference as an adjustment. A record whose local
+// copy differs from the source is a conflict: it is logged and overwritten.
+// The high-water mark never moves past an order that couldn't be written, so
+// failed orders are retried on the next run.
+type Engine struct {
+	source  string
+	remote  model.POSReader
+	local   model.POSAdapter
+	store   CheckpointStore
+	options Options
+	now     func() time.Time
+
+	// runMu serializes runs; mu guards the fields below it
+	runMu     sync.Mutex
+	mu        sync.Mutex
+	running   bool
+	nextRunAt *time.Time
+	lastRun   *RunStats
+	failures  int
+	conflicts []Conflict
+}
+
+// NewEngine - Creates an engine that mirrors remote into local under the
+// checkpoint name source
+func NewEngine(source string, remote model.POSReader, local model.POSAdapter, store CheckpointStore, options Options) *Engine {
+	return &Engine{
+		source:  source,
+		remote:  remote,
+		local:   local,
+		store:   store,
+		options: options.withDefaults(),
+		now:     time.Now,
+	}
+}
+
+// Start - Runs the engine now and then every Interval until ctx is cancelled
+func (e *Engine) Start(ctx context.Context) {
+	go func() {
+		for {
+			// Errors are recorded in the checkpoint and the status
+			_, _ = e.RunOnce(ctx)
+
+			next := e.now().Add(e.options.Interval)
+			e.mu.Lock()
+			e.nextRunAt = &next
+			e.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.options.Interval):
+			}
+		}
+	}()
+	log.Printf("Sync from %s started, every %s", e.source, e.options.Interval)
+}
+
+// RunOnce - Performs one sync run and saves the checkpoint. A run already in
+// progress is waited for.
+func (e *Engine) RunOnce(ctx context.Context) (RunStats, error) {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	e.mu.Lock()
+	e.running = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running = false
+		e.mu.Unlock()
+	}()
+
+	started := e.now()
+	stats := RunStats{StartedAt: started}
+
+	checkpoint, err := e.store.Load(e.source)
+	if err == nil {
+		var mark *time.Time
+		mark, err = e.syncOrders(ctx, checkpoint.HighWaterMark, &stats)
+		if mark != nil {
+			checkpoint.HighWaterMark = mark
+		}
+		if ctx.Err() == nil {
+			err = errors.Join(err, e.syncItems(&stats))
+		} else {
+			err = errors.Join(err, ctx.Err())
+		}
+
+		checkpoint.LastRunAt = &started
+		checkpoint.ItemsSynced += int64(stats.ItemsAdded + stats.ItemsUpdated)
+		checkpoint.OrdersSynced += int64(stats.OrdersAdded + stats.OrdersUpdated)
+		checkpoint.Conflicts += int64(stats.Conflicts)
+		if err != nil {
+			checkpoint.LastError = err.Error()
+		} else {
+			checkpoint.LastSuccessAt = &started
+			checkpoint.LastError = ""
+		}
+		if saveErr := e.store.Save(checkpoint); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}
+
+	stats.Duration = e.now().Sub(started).Round(time.Millisecond).String()
+	if err != nil {
+		stats.Error = err.Error()
+	}
+
+	e.mu.Lock()
+	e.lastRun = &stats
+	if err != nil {
+		e.failures++
+	} else {
+		e.failures = 0
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Sync from %s failed after %s: %v", e.source, stats.Duration, err)
+	} else {
+		log.Printf("Sync from %s done in %s: %d orders added, %d updated; %d items added, %d updated; %d conflicts",
+			e.source, stats.Duration, stats.OrdersAdded, stats.OrdersUpdated, stats.ItemsAdded, stats.ItemsUpdated, stats.Conflicts)
+	}
+
+	return stats, err
+}
+
+// syncOrders - Mirrors the orders completed since shortly before the
+// high-water mark and returns the new mark (nil to keep the current one)
+func (e *Engine) syncOrders(ctx context.Context, highWaterMark *time.Time, stats *RunStats) (*time.Time, error) {
+	var since time.Time
+	if highWaterMark != nil {
+		since = highWaterMark.Add(-e.options.Overlap)
+	}
+
+	remote, err := e.remote.GetCompletedOrders(since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch orders from %s: %w", e.source, err)
+	}
+	stats.OrdersFetched = len(remote)
+	if len(remote) == 0 {
+		return nil, nil
+	}
+
+	local, err := e.localOrders(since)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []model.Order
+	isUpdate := make(map[string]bool)
+	for _, order := range remote {
+		existing, exists := local[order.ID]
+		if exists {
+			changes := orderChanges(existing, order)
+			if len(changes) == 0 {
+				continue
+			}
+			e.recordConflict(stats, "order", order.ID, changes)
+			isUpdate[order.ID] = true
+		}
+		pending = append(pending, order)
+	}
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].CompletedAt.Before(pending[j].CompletedAt) })
+
+	var unwritten []model.Order
+	for start := 0; start < len(pending); start += e.options.BatchSize {
+		if ctx.Err() != nil {
+			unwritten = pending[start:]
+			pending = pending[:start]
+			break
+		}
+		batch := pending[start:min(start+e.options.BatchSize, len(pending))]
+		result, err := e.local.AddOrders(batch, model.BatchPartial)
+		if err != nil {
+			log.Printf("Sync from %s: batch of %d orders failed (%v), writing them one by one", e.source, len(batch), err)
+			for _, order := range batch {
+				if err := e.local.AddOrder(order); err != nil {
+					log.Printf("Sync from %s: failed to write order %s: %v", e.source, order.ID, err)
+				}
+			}
+			continue
+		}
+		for _, failure := range result.Failed {
+			log.Printf("Sync from %s: failed to write order %s (%s): %s", e.source, failure.ID, failure.Reason, failure.Error)
+		}
+	}
+
+	// Check what actually landed rather than trusting the batch results: a
+	// batch that failed as a whole leaves them unknown
+	written, err := e.localOrders(since)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []model.Order
+	for _, order := range pending {
+		stored, exists := written[order.ID]
+		if !exists || len(orderChanges(stored, order)) > 0 {
+			failed = append(failed, order)
+			continue
+		}
+		if isUpdate[order.ID] {
+			stats.OrdersUpdated++
+		} else {
+			stats.OrdersAdded++
+		}
+	}
+	stats.OrdersFailed = len(failed)
+
+	// The mark is the latest order seen, held back just before the earliest
+	// order that failed or wasn't written because the run was cancelled
+	mark := remote[0].CompletedAt
+	for _, order := range remote {
+		if order.CompletedAt.After(mark) {
+			mark = order.CompletedAt
+		}
+	}
+	for _, order := range append(failed, unwritten...) {
+		if held := order.CompletedAt.Add(-time.Nanosecond); held.Before(mark) {
+			mark = held
+		}
+	}
+
+	if len(failed) > 0 {
+		return &mark, fmt.Errorf("%d of %d orders from %s could not be written, first %s", len(failed), len(pending), e.source, failed[0].ID)
+	}
+	return &mark, nil
+}
+
+// localOrders - Indexes the local orders completed since the given time
+func (e *Engine) localOrders(since time.Time) (map[string]model.Order, error) {
+	page, err := e.local.QueryOrders(model.OrderQuery{Start: since})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local orders: %w", err)
+	}
+
+	orders := make(map[string]model.Order, len(page.Orders))
+	for _, order := range page.Orders {
+		orders[order.ID] = order
+	}
+	return orders, nil
+}
+
+// syncItems - Upserts the source items that are new or differ locally. Items
+// that only exist locally are left alone, and the local oversell policy of an
+// item is kept.
+func (e *Engine) syncItems(stats *RunStats) error {
+	remote, err := e.remote.GetInventory()
+	if err != nil {
+		return fmt.Errorf("failed to fetch inventory from %s: %w", e.source, err)
+	}
+	stats.ItemsFetched = len(remote)
+
+	inventory, err := e.local.GetInventory()
+	if err != nil {
+		return fmt.Errorf("failed to read local inventory: %w", err)
+	}
+	local := make(map[string]model.Item, len(inventory))
+	for _, item := range inventory {
+		local[item.ID] = item
+	}
+
+	var pending []model.Item
+	for _, item := range remote {
+		existing, exists := local[item.ID]
+		if exists {
+			changes := itemChanges(existing, item)
+			if len(changes) == 0 {
+				continue
+			}
+			e.recordConflict(stats, "item", item.ID, changes)
+			if item.OversellPolicy == "" {
+				item.OversellPolicy = existing.OversellPolicy
+			}
+			stats.ItemsUpdated++
+		} else {
+			stats.ItemsAdded++
+		}
+		pending = append(pending, item)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var failed []string
+	result, err := e.local.AddItems(pending, model.BatchPartial)
+	if err == nil {
+		for _, failure := range result.Failed {
+			log.Printf("Sync from %s: failed to write item %s (%s): %s", e.source, failure.ID, failure.Reason, failure.Error)
+			failed = append(failed, failure.ID)
+		}
+	} else {
+		// Retry one by one so a batch that failed as a whole doesn't block
+		// every item
+		log.Printf("Sync from %s: batch of %d items failed (%v), writing them one by one", e.source, len(pending), err)
+		for _, item := range pending {
+			if err := e.local.AddItem(item); err != nil {
+				log.Printf("Sync from %s: failed to write item %s: %v", e.source, item.ID, err)
+				failed = append(failed, item.ID)
+			}
+		}
+	}
+
+	for _, id := range failed {
+		if _, exists := local[id]; exists {
+			stats.ItemsUpdated--
+		} else {
+			stats.ItemsAdded--
+		}
+	}
+	stats.ItemsFailed = len(failed)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d items from %s could not be written, first %s", len(failed), len(pending), e.source, failed[0])
+	}
+	return nil
+}
+
+// recordConflict - Logs a conflict and keeps it for the status endpoint
+func (e *Engine) recordConflict(stats *RunStats, resource, id string, changes []string) {
+	stats.Conflicts++
+	log.Printf("Sync conflict from %s: local %s %s differs (%v); keeping the source version", e.source, resource, id, changes)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.conflicts = append(e.conflicts, Conflict{Resource: resource, ID: id, Changes: changes, At: e.now()})
+	if len(e.conflicts) > maxRecentConflicts {
+		e.conflicts = e.conflicts[len(e.conflicts)-maxRecentConflicts:]
+	}
+}
+
+// Source - Returns the checkpoint name of the engine's source
+func (e *Engine) Source() string {
+	return e.source
+}
+
+// Status - Reports the checkpoint, the last run and the recent conflicts
+func (e *Engine) Status() (Status, error) {
+	checkpoint, err := e.store.Load(e.source)
+	if err != nil {
+		return Status{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status := Status{
+		Source:              e.source,
+		Running:             e.running,
+		Interval:            e.options.Interval.String(),
+		NextRunAt:           e.nextRunAt,
+		ConsecutiveFailures: e.failures,
+		Checkpoint:          checkpoint,
+		LastRun:             e.lastRun,
+		RecentConflicts:     append([]Conflict{}, e.conflicts...),
+	}
+	if checkpoint.LastSuccessAt != nil {
+		lag := e.now().Sub(*checkpoint.LastSuccessAt).Seconds()
+		status.LagSeconds = &lag
+	}
+
+	return status, nil
+}
@@ -0,0 +1,221 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+var testTime = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// newTestSource - A memory POS with two items and three orders, one minute
+// apart from testTime
+func newTestSource(t *testing.T) *adapter.MemoryPosAdapter {
+	t.Helper()
+	source := adapter.NewMemoryPosAdapter("IDR")
+	for _, item := range []model.Item{
+		{ID: "ITEM-1", Name: "Kopi Susu", Stock: 100, Price: model.MustParseMoney("1500", "IDR"), ProductionPrice: model.MustParseMoney("900", "IDR")},
+		{ID: "ITEM-2", Name: "Roti Bakar", Stock: 50, Price: model.MustParseMoney("4000", "IDR"), ProductionPrice: model.MustParseMoney("2500", "IDR")},
+	} {
+		if err := source.AddItem(item); err != nil {
+			t.Fatalf("AddItem(%s): %v", item.ID, err)
+		}
+	}
+	for i, line := range []model.OrderItem{{ItemID: "ITEM-1", Quantity: 2}, {ItemID: "ITEM-2", Quantity: 1}, {ItemID: "ITEM-1", Quantity: 1}} {
+		order := model.Order{ID: fmt.Sprintf("ORD-%d", i+1), CompletedAt: orderTime(i + 1), Items: []model.OrderItem{line}}
+		if err := source.AddOrder(order); err != nil {
+			t.Fatalf("AddOrder(%s): %v", order.ID, err)
+		}
+	}
+	return source
+}
+
+func orderTime(n int) time.Time {
+	return testTime.Add(time.Duration(n) * time.Minute)
+}
+
+// testSink is a memory POS whose writes can be made to fail: the orders in
+// failOrders one by one, or every batch as a whole
+type testSink struct {
+	*adapter.MemoryPosAdapter
+	failOrders  map[string]bool
+	failBatches bool
+}
+
+func newTestSink() *testSink {
+	return &testSink{MemoryPosAdapter: adapter.NewMemoryPosAdapter("IDR"), failOrders: make(map[string]bool)}
+}
+
+func (s *testSink) AddOrder(order model.Order) error {
+	if s.failOrders[order.ID] {
+		return errors.New("disk full")
+	}
+	return s.MemoryPosAdapter.AddOrder(order)
+}
+
+func (s *testSink) AddOrders(orders []model.Order, mode model.BatchMode) (model.BatchResult, error) {
+	result := model.BatchResult{Mode: mode}
+	if s.failBatches {
+		return result, errors.New("connection reset")
+	}
+	for i, order := range orders {
+		if err := s.AddOrder(order); err != nil {
+			result.Failed = append(result.Failed, model.BatchFailure{Index: i, ID: order.ID, Reason: model.FailureReason(err), Error: err.Error()})
+			continue
+		}
+		result.Succeeded = append(result.Succeeded, order.ID)
+	}
+	return result, nil
+}
+
+func (s *testSink) AddItems(items []model.Item, mode model.BatchMode) (model.BatchResult, error) {
+	if s.failBatches {
+		return model.BatchResult{Mode: mode}, errors.New("connection reset")
+	}
+	return s.MemoryPosAdapter.AddItems(items, mode)
+}
+
+// checkInSync - Fails unless every source item and order has an identical
+// local copy
+func checkInSync(t *testing.T, source, sink model.POSReader) {
+	t.Helper()
+	items, err := source.GetInventory()
+	if err != nil {
+		t.Fatalf("GetInventory: %v", err)
+	}
+	for _, item := range items {
+		local, err := sink.GetItemByID(item.ID)
+		if err != nil {
+			t.Errorf("local item %s: %v", item.ID, err)
+			continue
+		}
+		if changes := itemChanges(*local, item); len(changes) > 0 {
+			t.Errorf("local item %s differs: %v", item.ID, changes)
+		}
+	}
+
+	orders, err := source.GetCompletedOrders(time.Time{})
+	if err != nil {
+		t.Fatalf("GetCompletedOrders: %v", err)
+	}
+	for _, order := range orders {
+		local, err := sink.GetOrderByID(order.ID)
+		if err != nil {
+			t.Errorf("local order %s: %v", order.ID, err)
+			continue
+		}
+		if changes := orderChanges(*local, order); len(changes) > 0 {
+			t.Errorf("local order %s differs: %v", order.ID, changes)
+		}
+	}
+}
+
+func TestRunOnceHoldsBackHighWaterMark(t *testing.T) {
+	source, sink, store := newTestSource(t), newTestSink(), NewMemoryCheckpointStore()
+	engine := NewEngine("demo", source, sink, store, Options{})
+
+	// ORD-2 can't be written: the mark stops just before it
+	sink.failOrders["ORD-2"] = true
+	stats, err := engine.RunOnce(context.Background())
+	if err == nil {
+		t.Fatal("RunOnce succeeded with an unwritable order")
+	}
+	if stats.OrdersFetched != 3 || stats.OrdersAdded != 2 || stats.OrdersFailed != 1 {
+		t.Errorf("stats = %+v, want 3 orders fetched, 2 added and 1 failed", stats)
+	}
+	checkpoint, _ := store.Load("demo")
+	if want := orderTime(2).Add(-time.Nanosecond); checkpoint.HighWaterMark == nil || !checkpoint.HighWaterMark.Equal(want) {
+		t.Errorf("high-water mark = %v, want %v", checkpoint.HighWaterMark, want)
+	}
+	if checkpoint.LastError == "" || checkpoint.LastSuccessAt != nil || checkpoint.OrdersSynced != 2 {
+		t.Errorf("checkpoint = %+v, want the error recorded and 2 orders synced", checkpoint)
+	}
+
+	// The next run starts from the failed order and catches up
+	delete(sink.failOrders, "ORD-2")
+	stats, err = engine.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if stats.OrdersFetched != 2 || stats.OrdersAdded != 1 {
+		t.Errorf("stats = %+v, want ORD-2 and ORD-3 fetched and ORD-2 added", stats)
+	}
+	checkpoint, _ = store.Load("demo")
+	if checkpoint.HighWaterMark == nil || !checkpoint.HighWaterMark.Equal(orderTime(3)) {
+		t.Errorf("high-water mark = %v, want %v", checkpoint.HighWaterMark, orderTime(3))
+	}
+	if checkpoint.LastError != "" || checkpoint.LastSuccessAt == nil || checkpoint.OrdersSynced != 3 {
+		t.Errorf("checkpoint = %+v, want a success and 3 orders synced", checkpoint)
+	}
+	checkInSync(t, source, sink)
+}
+
+func TestRunOnceRecordsConflicts(t *testing.T) {
+	source, sink := newTestSource(t), newTestSink()
+	engine := NewEngine("demo", source, sink, NewMemoryCheckpointStore(), Options{Overlap: time.Hour})
+	if _, err := engine.RunOnce(context.Background()); err != nil {
+		t.Fatalf("first RunOnce: %v", err)
+	}
+
+	// Local edits to a synced order and item
+	order, _ := sink.GetOrderByID("ORD-1")
+	order.Items[0].Quantity = 5
+	order.Total = model.Money{}
+	if err := sink.MemoryPosAdapter.AddOrder(*order); err != nil {
+		t.Fatalf("AddOrder: %v", err)
+	}
+	item, _ := sink.GetItemByID("ITEM-2")
+	item.Price = model.MustParseMoney("4500", "IDR")
+	item.OversellPolicy = model.OversellFlag
+	if err := sink.UpdateItem(*item); err != nil {
+		t.Fatalf("UpdateItem: %v", err)
+	}
+
+	stats, err := engine.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if stats.Conflicts != 2 || stats.OrdersUpdated != 1 || stats.ItemsUpdated != 1 {
+		t.Errorf("stats = %+v, want ORD-1 and ITEM-2 updated as conflicts", stats)
+	}
+
+	status, err := engine.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.Checkpoint.Conflicts != 2 || len(status.RecentConflicts) != 2 {
+		t.Fatalf("status = %+v, want 2 conflicts", status)
+	}
+	for i, want := range []string{"order ORD-1", "item ITEM-2"} {
+		conflict := status.RecentConflicts[i]
+		if got := conflict.Resource + " " + conflict.ID; got != want || len(conflict.Changes) == 0 {
+			t.Errorf("conflict %d = %+v, want %s with its changes", i, conflict, want)
+		}
+	}
+
+	// The source wins, except for the local oversell policy
+	checkInSync(t, source, sink)
+	if item, _ := sink.GetItemByID("ITEM-2"); item.OversellPolicy != model.OversellFlag {
+		t.Errorf("ITEM-2 oversell policy = %q, want the local flag kept", item.OversellPolicy)
+	}
+}
+
+func TestRunOnceWritesOneByOneWhenBatchFails(t *testing.T) {
+	source, sink := newTestSource(t), newTestSink()
+	sink.failBatches = true
+	engine := NewEngine("demo", source, sink, NewMemoryCheckpointStore(), Options{BatchSize: 2})
+
+	stats, err := engine.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if stats.OrdersAdded != 3 || stats.OrdersFailed != 0 || stats.ItemsAdded != 2 || stats.ItemsFailed != 0 {
+		t.Errorf("stats = %+v, want every order and item added", stats)
+	}
+	checkInSync(t, source, sink)
+}
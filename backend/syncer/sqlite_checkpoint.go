@@ -0,0 +1,123 @@
+package syncer
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed sqlite_schema.sql
+var sqliteSchema string
+
+// sqliteTimeFormat is how timestamps are stored, the same as the SQLite POS
+// adapter: always UTC and fixed width
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// SQLiteCheckpointStore keeps checkpoints in the embedded SQLite database of
+// the sqlite POS provider
+type SQLiteCheckpointStore struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteCheckpointStore - Creates the store and applies its schema to db,
+// which must come from adapter.OpenSQLite
+func NewSQLiteCheckpointStore(db *sqlx.DB) (*SQLiteCheckpointStore, error) {
+	if db == nil {
+		return nil, errors.New("sqlite checkpoint store needs a database")
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return nil, fmt.Errorf("failed to apply sync checkpoint schema: %w", err)
+	}
+	return &SQLiteCheckpointStore{db: db}, nil
+}
+
+// sqliteCheckpointRow is a checkpoint with its timestamps as stored
+type sqliteCheckpointRow struct {
+	Source        string         `db:"source"`
+	HighWaterMark sql.NullString `db:"high_water_mark"`
+	LastRunAt     sql.NullString `db:"last_run_at"`
+	LastSuccessAt sql.NullString `db:"last_success_at"`
+	LastError     string         `db:"last_error"`
+	ItemsSynced   int64          `db:"items_synced"`
+	OrdersSynced  int64          `db:"orders_synced"`
+	Conflicts     int64          `db:"conflicts"`
+}
+
+func (s *SQLiteCheckpointStore) Load(source string) (Checkpoint, error) {
+	query := `
+        SELECT source, high_water_mark, last_run_at, last_success_at, last_error,
+               items_synced, orders_synced, conflicts
+        FROM sync_checkpoints
+        WHERE source = ?`
+
+	var row sqliteCheckpointRow
+	err := s.db.Get(&row, query, source)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Checkpoint{Source: source}, nil
+	}
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to load sync checkpoint for %s: %w", source, err)
+	}
+
+	checkpoint := Checkpoint{
+		Source:       row.Source,
+		LastError:    row.LastError,
+		ItemsSynced:  row.ItemsSynced,
+		OrdersSynced: row.OrdersSynced,
+		Conflicts:    row.Conflicts,
+	}
+	for _, field := range []struct {
+		stored sql.NullString
+		target **time.Time
+	}{
+		{row.HighWaterMark, &checkpoint.HighWaterMark},
+		{row.LastRunAt, &checkpoint.LastRunAt},
+		{row.LastSuccessAt, &checkpoint.LastSuccessAt},
+	} {
+		if !field.stored.Valid {
+			continue
+		}
+		t, err := time.Parse(sqliteTimeFormat, field.stored.String)
+		if err != nil {
+			return Checkpoint{}, fmt.Errorf("sync checkpoint for %s has an invalid timestamp %q: %w", source, field.stored.String, err)
+		}
+		*field.target = &t
+	}
+
+	return checkpoint, nil
+}
+
+func (s *SQLiteCheckpointStore) Save(checkpoint Checkpoint) error {
+	query := `
+        INSERT INTO sync_checkpoints (source, high_water_mark, last_run_at, last_success_at, last_error,
+                                      items_synced, orders_synced, conflicts)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+        ON CONFLICT (source) DO UPDATE SET
+            high_water_mark = excluded.high_water_mark,
+            last_run_at = excluded.last_run_at,
+            last_success_at = excluded.last_success_at,
+            last_error = excluded.last_error,
+            items_synced = excluded.items_synced,
+            orders_synced = excluded.orders_synced,
+            conflicts = excluded.conflicts`
+
+	_, err := s.db.Exec(query, checkpoint.Source, sqliteTime(checkpoint.HighWaterMark), sqliteTime(checkpoint.LastRunAt),
+		sqliteTime(checkpoint.LastSuccessAt), checkpoint.LastError, checkpoint.ItemsSynced, checkpoint.OrdersSynced, checkpoint.Conflicts)
+	if err != nil {
+		return fmt.Errorf("failed to save sync checkpoint for %s: %w", checkpoint.Source, err)
+	}
+
+	return nil
+}
+
+// sqliteTime - A timestamp as stored, NULL for nil
+func sqliteTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(sqliteTimeFormat), Valid: true}
+}
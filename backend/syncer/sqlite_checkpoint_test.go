@@ -0,0 +1,53 @@
+package syncer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/adapter"
+)
+
+func TestSQLiteCheckpointStore(t *testing.T) {
+	db, err := adapter.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer db.Close()
+	store, err := NewSQLiteCheckpointStore(db)
+	if err != nil {
+		t.Fatalf("NewSQLiteCheckpointStore: %v", err)
+	}
+
+	empty, err := store.Load("rest")
+	if err != nil || !reflect.DeepEqual(empty, Checkpoint{Source: "rest"}) {
+		t.Errorf("Load of a new source = %+v, %v, want an empty checkpoint", empty, err)
+	}
+
+	mark := time.Date(2024, 3, 1, 9, 30, 0, 123456789, time.FixedZone("WIB", 7*60*60))
+	run := mark.Add(time.Hour).UTC()
+	checkpoints := []Checkpoint{
+		{Source: "rest", HighWaterMark: &mark, LastRunAt: &run, LastError: "timeout", ItemsSynced: 3, OrdersSynced: 40, Conflicts: 1},
+		// Saving again replaces the checkpoint
+		{Source: "rest", HighWaterMark: &mark, LastRunAt: &run, LastSuccessAt: &run, ItemsSynced: 5, OrdersSynced: 42, Conflicts: 1},
+	}
+	for _, checkpoint := range checkpoints {
+		if err := store.Save(checkpoint); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		got, err := store.Load("rest")
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if !got.HighWaterMark.Equal(mark) || !got.LastRunAt.Equal(run) {
+			t.Errorf("times = %v and %v, want %v and %v", got.HighWaterMark, got.LastRunAt, mark, run)
+		}
+		got.HighWaterMark, got.LastRunAt, got.LastSuccessAt = checkpoint.HighWaterMark, checkpoint.LastRunAt, checkpoint.LastSuccessAt
+		if !reflect.DeepEqual(got, checkpoint) {
+			t.Errorf("Load = %+v, want %+v", got, checkpoint)
+		}
+	}
+	if got, _ := store.Load("rest"); got.LastSuccessAt == nil || !got.LastSuccessAt.Equal(run) {
+		t.Errorf("last success = %v, want %v", got.LastSuccessAt, run)
+	}
+}
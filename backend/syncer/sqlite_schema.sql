@@ -0,0 +1,14 @@
+-- Sync checkpoints in the embedded SQLite database, applied every time the
+-- store is opened. Mirrors migrations/0008_sync_checkpoints for Postgres;
+-- timestamps are fixed-width UTC TEXT (see sqliteTimeFormat).
+
+CREATE TABLE IF NOT EXISTS sync_checkpoints (
+    source            TEXT PRIMARY KEY,
+    high_water_mark   TEXT,
+    last_run_at       TEXT,
+    last_success_at   TEXT,
+    last_error        TEXT NOT NULL DEFAULT '',
+    items_synced      INTEGER NOT NULL DEFAULT 0,
+    orders_synced     INTEGER NOT NULL DEFAULT 0,
+    conflicts         INTEGER NOT NULL DEFAULT 0
+);
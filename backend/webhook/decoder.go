@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+// Event types understood by the processor
+const (
+	OrderCompleted = "order.completed"
+	ItemChanged    = "item.changed"
+)
+
+// Event is a decoded webhook delivery. Order is set for OrderCompleted and
+// Item for ItemChanged.
+type Event struct {
+	ID    string
+	Type  string
+	Order *model.Order
+	Item  *model.Item
+}
+
+// Decoder translates one provider's payload into an Event. When a stored
+// event is retried the header only carries X-Event-Id.
+type Decoder interface {
+	Decode(header http.Header, body []byte) (Event, error)
+}
+
+// DecoderFunc adapts a function to a Decoder
+type DecoderFunc func(header http.Header, body []byte) (Event, error)
+
+func (f DecoderFunc) Decode(header http.Header, body []byte) (Event, error) {
+	return f(header, body)
+}
+
+// JSONDecoder decodes the default envelope, used for providers without a
+// decoder of their own:
+//
+//	{"id": "evt_1", "type": "order.completed", "created_at": "...", "data": {...}}
+//
+// data is an order or an item in this API's JSON format, with amounts without
+// a currency in Currency. The ID may come from the X-Event-Id header instead,
+// and an order without completed_at takes the event's created_at.
+type JSONDecoder struct {
+	Currency string
+}
+
+type jsonEnvelope struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (d JSONDecoder) Decode(header http.Header, body []byte) (Event, error) {
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return Event{}, fmt.Errorf("invalid event JSON: %w", err)
+	}
+
+	event := Event{ID: envelope.ID, Type: envelope.Type}
+	if event.ID == "" {
+		event.ID = header.Get("X-Event-Id")
+	}
+	if event.ID == "" {
+		return Event{}, errors.New("event has no id")
+	}
+	if len(envelope.Data) == 0 {
+		return Event{}, errors.New("event has no data")
+	}
+
+	switch event.Type {
+	case OrderCompleted:
+		order := model.Order{Total: model.Money{Currency: d.Currency}}
+		if err := json.Unmarshal(envelope.Data, &order); err != nil {
+			return Event{}, fmt.Errorf("invalid order in event %s: %w", event.ID, err)
+		}
+		if order.CompletedAt.IsZero() {
+			order.CompletedAt = envelope.CreatedAt
+		}
+		event.Order = &order
+	case ItemChanged:
+		item := model.Item{Price: model.Money{Currency: d.Currency}, ProductionPrice: model.Money{Currency: d.Currency}}
+		if err := json.Unmarshal(envelope.Data, &item); err != nil {
+			return Event{}, fmt.Errorf("invalid item in event %s: %w", event.ID, err)
+		}
+		event.Item = &item
+	default:
+		return Event{}, fmt.Errorf("unsupported event type %q (use %s or %s)", event.Type, OrderCompleted, ItemChanged)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,250 @@
+// Package webhook ingests order and item events pushed by POS terminals and
+// vendors: it verifies their signature, decodes them with a per-provider
+// decoder, dedupes replays by event ID and keeps failed events for retry.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider without a secret
+	ErrUnknownProvider = errors.New("unknown webhook provider")
+	// ErrInvalidSignature is returned when the signature is missing or wrong
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrEventNotFound is returned when retrying an event that isn't stored
+	ErrEventNotFound = errors.New("webhook event not found")
+)
+
+// DecodeError wraps a payload the provider's decoder rejected. The payload is
+// stored as a failed event under a hash of its body.
+type DecodeError struct {
+	EventID string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode webhook event: %v", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Outcome of handling a delivery
+const (
+	OutcomeProcessed  = "processed"  // applied now
+	OutcomeDuplicate  = "duplicate"  // already applied earlier
+	OutcomeInProgress = "processing" // another delivery of the event is being applied
+)
+
+// Applier writes a decoded event to the POS
+type Applier func(event Event) error
+
+// Config configures a Processor. Zero fields get the defaults listed below.
+type Config struct {
+	Secrets         map[string]string  // signing secret per provider; other providers are rejected
+	Decoders        map[string]Decoder // decoder per provider (default JSONDecoder)
+	Currency        string             // currency of amounts JSONDecoder reads without one (default model.DefaultCurrency)
+	SignatureHeader string             // header carrying the hex or base64 HMAC-SHA256 of the body, optionally prefixed "sha256=" (default X-Signature)
+	MaxAttempts     int                // attempts before a failed event is only retried by hand (default 10)
+}
+
+// Processor verifies, dedupes, decodes and applies webhook deliveries
+type Processor struct {
+	store  EventStore
+	config Config
+	apply  Applier
+}
+
+func NewProcessor(store EventStore, config Config, apply Applier) *Processor {
+	if config.SignatureHeader == "" {
+		config.SignatureHeader = "X-Signature"
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 10
+	}
+	return &Processor{store: store, config: config, apply: apply}
+}
+
+// Handle - Processes one delivery. A replay of a processed event is not
+// applied again. A failed event is stored and the apply error returned; it is
+// retried when the provider redelivers it, by RetryFailed, or by Retry.
+func (p *Processor) Handle(provider string, header http.Header, body []byte) (string, *Event, error) {
+	secret, exists := p.config.Secrets[provider]
+	if !exists || secret == "" {
+		return "", nil, ErrUnknownProvider
+	}
+	if !VerifySignature(secret, header.Get(p.config.SignatureHeader), body) {
+		return "", nil, ErrInvalidSignature
+	}
+
+	event, err := p.decoder(provider).Decode(header, body)
+	if err != nil {
+		// Keep the payload so it can be retried once the decoder is fixed
+		eventID := "sha256:" + hashBody(body)
+		decodeErr := &DecodeError{EventID: eventID, Err: err}
+		record := Record{Provider: provider, EventID: eventID, Type: "unknown", Payload: string(body)}
+		if claimed, _, claimErr := p.store.Claim(record); claimErr != nil {
+			return "", nil, claimErr
+		} else if claimed {
+			if finishErr := p.store.Finish(provider, eventID, decodeErr); finishErr != nil {
+				log.Printf("Failed to store undecodable %s webhook: %v", provider, finishErr)
+			}
+		}
+		return "", nil, decodeErr
+	}
+
+	outcome, err := p.process(provider, event, body)
+	return outcome, &event, err
+}
+
+// Retry - Applies a stored event again, whatever its attempt count
+func (p *Processor) Retry(provider, eventID string) (string, *Event, error) {
+	record, err := p.store.Get(provider, eventID)
+	if err != nil {
+		return "", nil, err
+	}
+	if record == nil {
+		return "", nil, ErrEventNotFound
+	}
+	return p.retry(*record)
+}
+
+// RetryFailed - Retries every failed event below the attempt limit and
+// returns how many succeeded
+func (p *Processor) RetryFailed() (int, error) {
+	records, err := p.store.List("", StatusFailed, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, record := range records {
+		if record.Attempts >= p.config.MaxAttempts {
+			continue
+		}
+		if outcome, _, err := p.retry(record); err == nil && outcome == OutcomeProcessed {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// Start - Calls RetryFailed every interval until ctx is cancelled
+func (p *Processor) Start(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				succeeded, err := p.RetryFailed()
+				if err != nil {
+					log.Printf("Failed to retry webhook events: %v", err)
+				} else if succeeded > 0 {
+					log.Printf("Retried %d failed webhook events", succeeded)
+				}
+			}
+		}
+	}()
+}
+
+// retry - Decodes a stored payload again (the signature was checked on
+// receipt) and applies it
+func (p *Processor) retry(record Record) (string, *Event, error) {
+	header := http.Header{}
+	header.Set("X-Event-Id", record.EventID)
+
+	event, err := p.decoder(record.Provider).Decode(header, []byte(record.Payload))
+	if err != nil {
+		decodeErr := &DecodeError{EventID: record.EventID, Err: err}
+		if claimed, _, claimErr := p.store.Claim(record); claimErr == nil && claimed {
+			if finishErr := p.store.Finish(record.Provider, record.EventID, decodeErr); finishErr != nil {
+				log.Printf("Failed to record retry of %s webhook %s: %v", record.Provider, record.EventID, finishErr)
+			}
+		}
+		return "", nil, decodeErr
+	}
+	// A payload stored under its hash keeps that ID
+	event.ID = record.EventID
+
+	outcome, err := p.process(record.Provider, event, []byte(record.Payload))
+	return outcome, &event, err
+}
+
+// process - Claims the event, applies it and records the result
+func (p *Processor) process(provider string, event Event, body []byte) (string, error) {
+	claimed, existing, err := p.store.Claim(Record{Provider: provider, EventID: event.ID, Type: event.Type, Payload: string(body)})
+	if err != nil {
+		return "", err
+	}
+	if !claimed {
+		if existing != nil && existing.Status == StatusProcessing {
+			return OutcomeInProgress, nil
+		}
+		return OutcomeDuplicate, nil
+	}
+
+	applyErr := p.apply(event)
+	if err := p.store.Finish(provider, event.ID, applyErr); err != nil {
+		if applyErr != nil {
+			return "", errors.Join(applyErr, err)
+		}
+		return "", err
+	}
+	if applyErr != nil {
+		log.Printf("Failed to apply %s webhook %s (%s): %v", provider, event.ID, event.Type, applyErr)
+		return "", applyErr
+	}
+
+	log.Printf("Applied %s webhook %s (%s)", provider, event.ID, event.Type)
+	return OutcomeProcessed, nil
+}
+
+// decoder - Returns the provider's decoder or the default JSON one
+func (p *Processor) decoder(provider string) Decoder {
+	if decoder, exists := p.config.Decoders[provider]; exists {
+		return decoder
+	}
+	return JSONDecoder{Currency: p.config.Currency}
+}
+
+// VerifySignature - Checks an HMAC-SHA256 of body given as hex or base64,
+// optionally prefixed with "sha256="
+func VerifySignature(secret, signature string, body []byte) bool {
+	signature = strings.TrimSpace(signature)
+	signature = strings.TrimPrefix(signature, "sha256=")
+	if signature == "" {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+		return true
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+		return true
+	}
+	return false
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
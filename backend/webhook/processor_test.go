@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YudaClairee/garudahacks/model"
+)
+
+const testSecret = "whsec_test"
+
+func sign(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// signedHeader - A header carrying a valid hex signature of body
+func signedHeader(body string) http.Header {
+	header := http.Header{}
+	header.Set("X-Signature", "sha256="+hex.EncodeToString(sign(testSecret, []byte(body))))
+	return header
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	mac := sign(testSecret, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{name: "hex", secret: testSecret, signature: hex.EncodeToString(mac), body: body, want: true},
+		{name: "upper-case hex", secret: testSecret, signature: strings.ToUpper(hex.EncodeToString(mac)), body: body, want: true},
+		{name: "prefixed hex", secret: testSecret, signature: "sha256=" + hex.EncodeToString(mac), body: body, want: true},
+		{name: "base64", secret: testSecret, signature: base64.StdEncoding.EncodeToString(mac), body: body, want: true},
+		{name: "prefixed base64 with spaces", secret: testSecret, signature: " sha256=" + base64.StdEncoding.EncodeToString(mac) + " ", body: body, want: true},
+		{name: "other secret", secret: "whsec_other", signature: hex.EncodeToString(mac), body: body, want: false},
+		{name: "tampered body", secret: testSecret, signature: hex.EncodeToString(mac), body: []byte(`{"id":"evt_2"}`), want: false},
+		{name: "truncated", secret: testSecret, signature: hex.EncodeToString(mac[:16]), body: body, want: false},
+		{name: "missing", secret: testSecret, signature: "", body: body, want: false},
+		{name: "prefix only", secret: testSecret, signature: "sha256=", body: body, want: false},
+		{name: "not encoded", secret: testSecret, signature: "not a signature", body: body, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := VerifySignature(tt.secret, tt.signature, tt.body); got != tt.want {
+			t.Errorf("%s: VerifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testApplier records applied event IDs and fails while failing is set
+type testApplier struct {
+	applied []string
+	failing bool
+}
+
+func (a *testApplier) apply(event Event) error {
+	if a.failing {
+		return errors.New("POS unavailable")
+	}
+	a.applied = append(a.applied, event.ID)
+	return nil
+}
+
+func newTestProcessor() (*Processor, *MemoryEventStore, *testApplier) {
+	store := NewMemoryEventStore()
+	applier := &testApplier{}
+	processor := NewProcessor(store, Config{Secrets: map[string]string{"pos": testSecret}, Currency: "IDR"}, applier.apply)
+	return processor, store, applier
+}
+
+const testOrderEvent = `{"id": "evt_1", "type": "order.completed", "created_at": "2024-03-01T09:00:00Z",
+	"data": {"id": "ORD-1", "total": 30000, "items": [{"item_id": "A", "quantity": 2, "unit_price": 15000}]}}`
+
+func TestProcessorRejectsUnsignedDeliveries(t *testing.T) {
+	processor, store, applier := newTestProcessor()
+
+	tests := []struct {
+		name     string
+		provider string
+		header   http.Header
+		want     error
+	}{
+		{name: "unknown provider", provider: "other", header: signedHeader(testOrderEvent), want: ErrUnknownProvider},
+		{name: "no signature", provider: "pos", header: http.Header{}, want: ErrInvalidSignature},
+		{name: "signed with another secret", provider: "pos", header: http.Header{"X-Signature": {hex.EncodeToString(sign("whsec_other", []byte(testOrderEvent)))}}, want: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		if _, _, err := processor.Handle(tt.provider, tt.header, []byte(testOrderEvent)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Handle returned %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if records, _ := store.List("", "", 0); len(records) != 0 || len(applier.applied) != 0 {
+		t.Errorf("rejected deliveries stored %d records and applied %v, want none", len(records), applier.applied)
+	}
+}
+
+func TestProcessorDedupesReplays(t *testing.T) {
+	processor, store, applier := newTestProcessor()
+
+	outcome, event, err := processor.Handle("pos", signedHeader(testOrderEvent), []byte(testOrderEvent))
+	if err != nil || outcome != OutcomeProcessed {
+		t.Fatalf("first delivery = %q, %v, want %q", outcome, err, OutcomeProcessed)
+	}
+	if event.Order == nil || event.Order.Total != model.NewMoney(3000000, "IDR") || !event.Order.CompletedAt.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("decoded order = %+v, want 30000 IDR completed at the event time", event.Order)
+	}
+
+	// A replay is acknowledged but only applied once
+	for i := 0; i < 2; i++ {
+		if outcome, _, err := processor.Handle("pos", signedHeader(testOrderEvent), []byte(testOrderEvent)); err != nil || outcome != OutcomeDuplicate {
+			t.Errorf("replay %d = %q, %v, want %q", i+1, outcome, err, OutcomeDuplicate)
+		}
+	}
+	if len(applier.applied) != 1 {
+		t.Errorf("applied %v, want evt_1 once", applier.applied)
+	}
+
+	// A delivery still being applied elsewhere is reported, not applied
+	store.Claim(Record{Provider: "pos", EventID: "evt_2", Type: OrderCompleted})
+	body := strings.Replace(testOrderEvent, "evt_1", "evt_2", 1)
+	if outcome, _, err := processor.Handle("pos", signedHeader(body), []byte(body)); err != nil || outcome != OutcomeInProgress {
+		t.Errorf("delivery in progress = %q, %v, want %q", outcome, err, OutcomeInProgress)
+	}
+
+	// ...until it has been processing for too long
+	store.now = func() time.Time { return time.Now().Add(staleAfter + time.Minute) }
+	if outcome, _, err := processor.Handle("pos", signedHeader(body), []byte(body)); err != nil || outcome != OutcomeProcessed {
+		t.Errorf("stale delivery = %q, %v, want %q", outcome, err, OutcomeProcessed)
+	}
+}
+
+func TestProcessorRetriesFailedEvents(t *testing.T) {
+	processor, store, applier := newTestProcessor()
+
+	applier.failing = true
+	if _, _, err := processor.Handle("pos", signedHeader(testOrderEvent), []byte(testOrderEvent)); err == nil {
+		t.Fatal("Handle succeeded while the POS was failing, want an error")
+	}
+	record, _ := store.Get("pos", "evt_1")
+	if record == nil || record.Status != StatusFailed || record.Attempts != 1 || record.LastError != "POS unavailable" {
+		t.Fatalf("failed event stored as %+v, want failed after 1 attempt", record)
+	}
+	if succeeded, err := processor.RetryFailed(); err != nil || succeeded != 0 {
+		t.Errorf("RetryFailed while failing = %d, %v, want 0, nil", succeeded, err)
+	}
+
+	applier.failing = false
+	if succeeded, err := processor.RetryFailed(); err != nil || succeeded != 1 {
+		t.Errorf("RetryFailed = %d, %v, want 1, nil", succeeded, err)
+	}
+	record, _ = store.Get("pos", "evt_1")
+	if record.Status != StatusProcessed || record.Attempts != 3 || record.ProcessedAt == nil {
+		t.Errorf("retried event stored as %+v, want processed after 3 attempts", record)
+	}
+
+	// Once processed, a redelivery or manual retry is a duplicate
+	if outcome, _, err := processor.Retry("pos", "evt_1"); err != nil || outcome != OutcomeDuplicate {
+		t.Errorf("Retry of a processed event = %q, %v, want %q", outcome, err, OutcomeDuplicate)
+	}
+	if len(applier.applied) != 1 {
+		t.Errorf("applied %v, want evt_1 once", applier.applied)
+	}
+
+	if _, _, err := processor.Retry("pos", "evt_missing"); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Retry of an unknown event returned %v, want ErrEventNotFound", err)
+	}
+}
+
+func TestProcessorStoresUndecodableEvents(t *testing.T) {
+	processor, store, applier := newTestProcessor()
+
+	body := `{"id": "evt_1", "type": "order.refunded", "data": {}}`
+	_, _, err := processor.Handle("pos", signedHeader(body), []byte(body))
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || !strings.HasPrefix(decodeErr.EventID, "sha256:") {
+		t.Fatalf("Handle returned %v, want a DecodeError with a hashed ID", err)
+	}
+
+	record, _ := store.Get("pos", decodeErr.EventID)
+	if record == nil || record.Status != StatusFailed || record.Payload != body {
+		t.Errorf("undecodable event stored as %+v, want the failed payload", record)
+	}
+
+	// The same payload is stored once
+	processor.Handle("pos", signedHeader(body), []byte(body))
+	if records, _ := store.List("pos", "", 0); len(records) != 1 || len(applier.applied) != 0 {
+		t.Errorf("stored %d records and applied %v, want 1 record and nothing applied", len(records), applier.applied)
+	}
+}
+
+func TestJSONDecoder(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		currency  string
+		header    http.Header
+		body      string
+		wantID    string
+		wantOrder *model.Order
+		wantItem  *model.Item
+		wantErr   bool
+	}{
+		{
+			name:     "order in a currency without cents",
+			currency: "JPY",
+			body: `{"id": "evt_1", "type": "order.completed", "created_at": "2024-03-01T09:00:00Z",
+				"data": {"id": "ORD-1", "total": 1500, "items": [{"item_id": "A", "quantity": 3, "unit_price": 500}]}}`,
+			wantID: "evt_1",
+			wantOrder: &model.Order{
+				ID: "ORD-1", Total: model.NewMoney(1500, "JPY"), CompletedAt: createdAt,
+				Items: []model.OrderItem{{ItemID: "A", Quantity: 3, UnitPrice: model.NewMoney(500, "JPY")}},
+			},
+		},
+		{
+			name:     "item with the ID in a header",
+			currency: "USD",
+			header:   http.Header{"X-Event-Id": {"evt_2"}},
+			body:     `{"type": "item.changed", "data": {"id": "A", "name": "Kopi", "stock": 3, "price": 2.5, "production_price": 1}}`,
+			wantID:   "evt_2",
+			wantItem: &model.Item{ID: "A", Name: "Kopi", Stock: 3, Price: model.NewMoney(250, "USD"), ProductionPrice: model.NewMoney(100, "USD")},
+		},
+		{name: "no ID", body: `{"type": "item.changed", "data": {"id": "A"}}`, wantErr: true},
+		{name: "no data", body: `{"id": "evt_3", "type": "item.changed"}`, wantErr: true},
+		{name: "unsupported type", body: `{"id": "evt_4", "type": "order.refunded", "data": {}}`, wantErr: true},
+		{name: "not JSON", body: `order completed`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		header := tt.header
+		if header == nil {
+			header = http.Header{}
+		}
+		event, err := JSONDecoder{Currency: tt.currency}.Decode(header, []byte(tt.body))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: Decode = %+v, want an error", tt.name, event)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Decode returned error: %v", tt.name, err)
+			continue
+		}
+		if event.ID != tt.wantID {
+			t.Errorf("%s: event ID = %q, want %q", tt.name, event.ID, tt.wantID)
+		}
+		if tt.wantOrder != nil && (event.Order == nil || event.Order.ID != tt.wantOrder.ID || event.Order.Total != tt.wantOrder.Total ||
+			!event.Order.CompletedAt.Equal(tt.wantOrder.CompletedAt) || len(event.Order.Items) != 1 ||
+			event.Order.Items[0].UnitPrice != tt.wantOrder.Items[0].UnitPrice) {
+			t.Errorf("%s: order = %+v, want %+v", tt.name, event.Order, tt.wantOrder)
+		}
+		if tt.wantItem != nil && (event.Item == nil || *event.Item != *tt.wantItem) {
+			t.Errorf("%s: item = %+v, want %+v", tt.name, event.Item, tt.wantItem)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Event statuses
+const (
+	StatusProcessing = "processing"
+	StatusProcessed  = "processed"
+	StatusFailed     = "failed"
+)
+
+// staleAfter is how long an event may stay "processing" before another
+// delivery or retry may take it over (the process handling it probably died)
+const staleAfter = 5 * time.Minute
+
+// Record is a stored delivery. Payload is the raw body as received.
+type Record struct {
+	Provider    string     `db:"provider" json:"provider"`
+	EventID     string     `db:"event_id" json:"event_id"`
+	Type        string     `db:"event_type" json:"type"`
+	Status      string     `db:"status" json:"status"`
+	Payload     string     `db:"payload" json:"payload"`
+	Attempts    int        `db:"attempts" json:"attempts"`
+	LastError   string     `db:"last_error" json:"last_error,omitempty"`
+	ReceivedAt  time.Time  `db:"received_at" json:"received_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	ProcessedAt *time.Time `db:"processed_at" json:"processed_at,omitempty"`
+}
+
+// EventStore records deliveries so replays are detected and failures can be
+// retried.
+//
+// Claim stores a new event as processing, or takes over a failed or stale one
+// (replacing its payload), and returns true. For an event that is processed
+// or being processed it returns false with the stored record. Finish records
+// the outcome of an attempt.
+type EventStore interface {
+	Claim(record Record) (bool, *Record, error)
+	Finish(provider, eventID string, processErr error) error
+	Get(provider, eventID string) (*Record, error)
+	List(provider, status string, limit int) ([]Record, error)
+}
+
+// DBEventStore keeps events in the Postgres webhook_events table
+type DBEventStore struct {
+	db *sqlx.DB
+}
+
+func NewDBEventStore(db *sqlx.DB) *DBEventStore {
+	return &DBEventStore{db: db}
+}
+
+func (s *DBEventStore) Claim(record Record) (bool, *Record, error) {
+	query := `
+        INSERT INTO webhook_events (provider, event_id, event_type, status, payload)
+        VALUES ($1, $2, $3, 'processing', $4)
+        ON CONFLICT (provider, event_id) DO UPDATE SET
+            event_type = EXCLUDED.event_type,
+            status = 'processing',
+            payload = EXCLUDED.payload,
+            updated_at = NOW()
+        WHERE webhook_events.status = 'failed'
+           OR (webhook_events.status = 'processing' AND webhook_events.updated_at < $5)
+        RETURNING provider`
+
+	var provider string
+	err := s.db.Get(&provider, query, record.Provider, record.EventID, record.Type, record.Payload, time.Now().Add(-staleAfter))
+	if err == nil {
+		return true, nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, nil, fmt.Errorf("failed to claim webhook event %s: %w", record.EventID, err)
+	}
+
+	existing, err := s.Get(record.Provider, record.EventID)
+	if err != nil {
+		return false, nil, err
+	}
+	return false, existing, nil
+}
+
+func (s *DBEventStore) Finish(provider, eventID string, processErr error) error {
+	status, lastError := StatusProcessed, ""
+	if processErr != nil {
+		status, lastError = StatusFailed, processErr.Error()
+	}
+
+	query := `
+        UPDATE webhook_events
+        SET status = $3,
+            last_error = $4,
+            attempts = attempts + 1,
+            updated_at = NOW(),
+            processed_at = CASE WHEN $3 = 'processed' THEN NOW() END
+        WHERE provider = $1 AND event_id = $2`
+
+	if _, err := s.db.Exec(query, provider, eventID, status, lastError); err != nil {
+		return fmt.Errorf("failed to record webhook event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+func (s *DBEventStore) Get(provider, eventID string) (*Record, error) {
+	query := `
+        SELECT provider, event_id, event_type, status, payload, attempts, last_error,
+               received_at, updated_at, processed_at
+        FROM webhook_events
+        WHERE provider = $1 AND event_id = $2`
+
+	var record Record
+	err := s.db.Get(&record, query, provider, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook event %s: %w", eventID, err)
+	}
+	return &record, nil
+}
+
+// List - Returns a provider's events, oldest first. An empty provider or
+// status matches all.
+func (s *DBEventStore) List(provider, status string, limit int) ([]Record, error) {
+	query := `
+        SELECT provider, event_id, event_type, status, payload, attempts, last_error,
+               received_at, updated_at, processed_at
+        FROM webhook_events
+        WHERE ($1 = '' OR provider = $1) AND ($2 = '' OR status = $2)
+        ORDER BY received_at, event_id
+        LIMIT $3`
+
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	var records []Record
+	if err := s.db.Select(&records, query, provider, status, limit); err != nil {
+		return nil, fmt.Errorf("failed to list webhook events: %w", err)
+	}
+	return records, nil
+}
+
+// MemoryEventStore keeps events for the life of the process, for deployments
+// without the webhook_events table. Replays are still harmless after a
+// restart because orders and items are upserted.
+type MemoryEventStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{records: make(map[string]Record), now: time.Now}
+}
+
+func eventKey(provider, eventID string) string {
+	return provider + "\x00" + eventID
+}
+
+func (s *MemoryEventStore) Claim(record Record) (bool, *Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	key := eventKey(record.Provider, record.EventID)
+	existing, exists := s.records[key]
+	if exists {
+		stale := existing.Status == StatusProcessing && existing.UpdatedAt.Before(now.Add(-staleAfter))
+		if existing.Status != StatusFailed && !stale {
+			return false, &existing, nil
+		}
+		existing.Type = record.Type
+		existing.Status = StatusProcessing
+		existing.Payload = record.Payload
+		existing.UpdatedAt = now
+		s.records[key] = existing
+		return true, nil, nil
+	}
+
+	record.Status = StatusProcessing
+	record.Attempts = 0
+	record.LastError = ""
+	record.ReceivedAt = now
+	record.UpdatedAt = now
+	record.ProcessedAt = nil
+	s.records[key] = record
+	return true, nil, nil
+}
+
+func (s *MemoryEventStore) Finish(provider, eventID string, processErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey(provider, eventID)
+	record, exists := s.records[key]
+	if !exists {
+		return fmt.Errorf("webhook event %s was never claimed", eventID)
+	}
+
+	now := s.now()
+	record.Attempts++
+	record.UpdatedAt = now
+	if processErr != nil {
+		record.Status = StatusFailed
+		record.LastError = processErr.Error()
+		record.ProcessedAt = nil
+	} else {
+		record.Status = StatusProcessed
+		record.LastError = ""
+		record.ProcessedAt = &now
+	}
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryEventStore) Get(provider, eventID string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.records[eventKey(provider, eventID)]
+	if !exists {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (s *MemoryEventStore) List(provider, status string, limit int) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var records []Record
+	for _, record := range s.records {
+		if (provider == "" || record.Provider == provider) && (status == "" || record.Status == status) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].ReceivedAt.Equal(records[j].ReceivedAt) {
+			return records[i].ReceivedAt.Before(records[j].ReceivedAt)
+		}
+		return records[i].EventID < records[j].EventID
+	})
+
+	if limit <= 0 {
+		limit = 1000
+	}
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}